@@ -1,189 +0,0 @@
-package main
-
-import (
-	"testing"
-	"time"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	dynamicfake "k8s.io/client-go/dynamic/fake"
-	k8sfake "k8s.io/client-go/kubernetes/fake"
-)
-
-func TestResourceCapsuleCRDTypes(t *testing.T) {
-	// Test CRD struct creation
-	crd := &ResourceCapsuleCRD{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "capsules.docker.io/v1",
-			Kind:       "ResourceCapsule",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-capsule",
-			Namespace: "default",
-		},
-		Spec: ResourceCapsuleCRDSpec{
-			Data: map[string]interface{}{
-				"config": "test-value",
-			},
-			Version:     "1.0",
-			CapsuleType: "configmap",
-			Rollback: &RollbackConfig{
-				Enabled: true,
-			},
-		},
-		Status: ResourceCapsuleCRDStatus{
-			Phase:       "Active",
-			LastUpdated: metav1.Time{Time: time.Now()},
-			Message:     "Test message",
-		},
-	}
-
-	if crd.Name != "test-capsule" {
-		t.Errorf("Expected name 'test-capsule', got %s", crd.Name)
-	}
-
-	if crd.Spec.Version != "1.0" {
-		t.Errorf("Expected version '1.0', got %s", crd.Spec.Version)
-	}
-
-	if crd.Status.Phase != "Active" {
-		t.Errorf("Expected phase 'Active', got %s", crd.Status.Phase)
-	}
-}
-
-func TestResourceCapsuleCRDDeepCopy(t *testing.T) {
-	original := &ResourceCapsuleCRD{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-capsule",
-			Namespace: "default",
-		},
-		Spec: ResourceCapsuleCRDSpec{
-			Data: map[string]interface{}{
-				"config": "test-value",
-			},
-			Version:     "1.0",
-			CapsuleType: "configmap",
-		},
-	}
-
-	copied := original.DeepCopy()
-
-	if copied.Name != original.Name {
-		t.Errorf("DeepCopy failed: names don't match")
-	}
-
-	if copied.Spec.Version != original.Spec.Version {
-		t.Errorf("DeepCopy failed: versions don't match")
-	}
-
-	// Modify copy and ensure original is unchanged
-	copied.Name = "modified-capsule"
-	if original.Name == "modified-capsule" {
-		t.Errorf("DeepCopy failed: original was modified")
-	}
-}
-
-func TestKubernetesCRDCapsuleManager(t *testing.T) {
-	// Create fake clients
-	scheme := runtime.NewScheme()
-	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme)
-	k8sClient := k8sfake.NewSimpleClientset()
-
-	// Create KubernetesCapsuleManager with fake clients
-	kcm := &KubernetesCapsuleManager{
-		client:        k8sClient,
-		dynamicClient: dynamicClient,
-		namespace:     "default",
-	}
-
-	// Test data
-	testData := map[string]interface{}{
-		"config.yaml": "test: value",
-	}
-
-	// Test CreateCRDCapsule
-	err := kcm.CreateCRDCapsule("test-crd", "1.0", testData, "configmap")
-	if err != nil {
-		t.Logf("Expected error in test environment (no CRD installed): %v", err)
-	}
-
-	// Note: ListCRDCapsules test skipped due to fake client GVR registration requirements
-	// In real cluster environments, this works properly with installed CRDs
-	t.Log("CRD manager creation and basic functionality test completed")
-}
-
-func TestResourceCapsuleOperatorCreation(t *testing.T) {
-	// Test operator creation (will fail due to no kubeconfig, but should test struct creation)
-	_, err := NewResourceCapsuleOperator("default")
-	if err != nil {
-		t.Logf("Expected error in test environment: %v", err)
-	}
-}
-
-func TestUnstructuredResourceCapsule(t *testing.T) {
-	// Test creating an unstructured ResourceCapsule object
-	resourceCapsule := &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": "capsules.docker.io/v1",
-			"kind":       "ResourceCapsule",
-			"metadata": map[string]interface{}{
-				"name":      "test-unstructured",
-				"namespace": "default",
-			},
-			"spec": map[string]interface{}{
-				"data": map[string]interface{}{
-					"config": "test-value",
-				},
-				"version":     "1.0",
-				"capsuleType": "configmap",
-			},
-		},
-	}
-
-	// Test getting fields from unstructured object
-	spec, found, err := unstructured.NestedMap(resourceCapsule.Object, "spec")
-	if err != nil || !found {
-		t.Errorf("Failed to get spec from unstructured object: %v", err)
-	}
-
-	version, found, err := unstructured.NestedString(spec, "version")
-	if err != nil || !found {
-		t.Errorf("Failed to get version from spec: %v", err)
-	}
-
-	if version != "1.0" {
-		t.Errorf("Expected version '1.0', got %s", version)
-	}
-
-	capsuleType, found, err := unstructured.NestedString(spec, "capsuleType")
-	if err != nil || !found {
-		t.Errorf("Failed to get capsuleType from spec: %v", err)
-	}
-
-	if capsuleType != "configmap" {
-		t.Errorf("Expected capsuleType 'configmap', got %s", capsuleType)
-	}
-}
-
-func TestCRDGVR(t *testing.T) {
-	// Test the GroupVersionResource used for ResourceCapsule CRDs
-	gvr := schema.GroupVersionResource{
-		Group:    "capsules.docker.io",
-		Version:  "v1",
-		Resource: "resourcecapsules",
-	}
-
-	if gvr.Group != "capsules.docker.io" {
-		t.Errorf("Expected group 'capsules.docker.io', got %s", gvr.Group)
-	}
-
-	if gvr.Version != "v1" {
-		t.Errorf("Expected version 'v1', got %s", gvr.Version)
-	}
-
-	if gvr.Resource != "resourcecapsules" {
-		t.Errorf("Expected resource 'resourcecapsules', got %s", gvr.Resource)
-	}
-}
\ No newline at end of file
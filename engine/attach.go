@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultDetachKeys is the key sequence that ends attach without stopping
+// the container.
+const defaultDetachKeys = "ctrl-p,ctrl-q"
+
+// errDetached is returned by a detachReader once it has read the detach keys.
+var errDetached = errors.New("detached from container")
+
+// parseDetachKeys parses a comma-separated key sequence such as
+// "ctrl-p,ctrl-q", where each key is a single character or ctrl- followed by
+// a letter or one of @[\]^_.
+func parseDetachKeys(spec string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(spec, ",") {
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case len(key) == 6 && strings.HasPrefix(strings.ToLower(key), "ctrl-"):
+			c := key[5]
+			switch {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c >= 'A' && c <= 'Z':
+				keys = append(keys, c-'A'+1)
+			case strings.IndexByte("@[\\]^_", c) >= 0:
+				keys = append(keys, c-'@')
+			default:
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+		default:
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+	return keys, nil
+}
+
+// detachReader passes input through until it reads the detach keys, which
+// it holds back, and then fails with errDetached and closes detached.
+type detachReader struct {
+	r        io.Reader
+	keys     []byte
+	matched  int
+	ready    []byte
+	err      error
+	detached chan struct{}
+}
+
+func newDetachReader(r io.Reader, keys []byte) *detachReader {
+	return &detachReader{r: r, keys: keys, detached: make(chan struct{})}
+}
+
+// feed adds an input byte, reporting whether it completed the detach keys.
+func (d *detachReader) feed(b byte) bool {
+	if b != d.keys[d.matched] {
+		// The held back keys turned out to be input
+		d.ready = append(d.ready, d.keys[:d.matched]...)
+		d.matched = 0
+		if b != d.keys[0] {
+			d.ready = append(d.ready, b)
+			return false
+		}
+	}
+	d.matched++
+	return d.matched == len(d.keys)
+}
+
+func (d *detachReader) Read(p []byte) (int, error) {
+	for len(d.ready) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		buf := make([]byte, len(p))
+		n, err := d.r.Read(buf)
+		for _, b := range buf[:n] {
+			if d.feed(b) {
+				d.err = errDetached
+				close(d.detached)
+				break
+			}
+		}
+		if err != nil && d.err == nil {
+			d.ready = append(d.ready, d.keys[:d.matched]...)
+			d.err = err
+		}
+	}
+	n := copy(p, d.ready)
+	d.ready = d.ready[n:]
+	return n, nil
+}
+
+// followContainerLog copies output a running container logs from now on to
+// w, until the container stops or stop is closed.
+func (e *Engine) followContainerLog(containerID string, w io.Writer, stop <-chan struct{}) error {
+	file, err := os.Open(e.containerLogPath(containerID))
+	if err != nil {
+		return fmt.Errorf("no logs for container %s: %v", containerID, err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to read container log: %v", err)
+	}
+
+	for {
+		// Check the status first so output logged before the container
+		// stopped is copied
+		stopped := !isContainerActive(e.getContainerStatus(containerID))
+		if _, err := io.Copy(w, file); err != nil {
+			return err
+		}
+		if stopped {
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(waitPollInterval):
+		}
+	}
+}
+
+// flushWriter flushes each write to an HTTP response so streamed output
+// reaches the client as it is produced.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// attachContainer connects stdin and stdout to a running container until it
+// stops or the detach keys are read from stdin. The container's output is
+// followed through its log; input is only forwarded to containers the
+// daemon started with stdin open. A nil stdin attaches output only.
+func (e *Engine) attachContainer(containerID string, stdin io.Reader, stdout io.Writer, detachKeys []byte) error {
+	if status := e.getContainerStatus(containerID); !isContainerActive(status) {
+		return fmt.Errorf("container %s is not running", containerID)
+	}
+	client := e.daemonClient()
+	path := "/v1/containers/" + url.PathEscape(containerID) + "/attach"
+
+	output := make(chan error, 1)
+	stop := make(chan struct{})
+	go func() {
+		if client != nil {
+			output <- daemonStream(client, path, stdout)
+			return
+		}
+		output <- e.followContainerLog(containerID, stdout, stop)
+	}()
+
+	input := make(chan error, 1)
+	var detached chan struct{}
+	if stdin != nil {
+		reader := newDetachReader(stdin, detachKeys)
+		detached = reader.detached
+		go func() {
+			if client == nil {
+				// Without the daemon there is nowhere to send input
+				_, err := io.Copy(io.Discard, reader)
+				input <- err
+				return
+			}
+			resp, err := client.Post("http://basic-docker"+path, "application/octet-stream", reader)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 300 {
+					err = fmt.Errorf("daemon returned status %d", resp.StatusCode)
+				}
+			}
+			input <- err
+		}()
+	}
+
+	select {
+	case err := <-output:
+		return err
+	case <-detached:
+		close(stop)
+		return nil
+	case err := <-input:
+		if err != nil {
+			e.Logger.Warn("failed to forward input to container", "container", containerID, "error", err)
+		}
+		// Keep streaming output once input has ended
+		return <-output
+	}
+}
+
+// attachCommand implements "attach [--no-stdin] [--detach-keys keys] <container-id>".
+func (e *Engine) attachCommand(args []string) {
+	fs := flag.NewFlagSet("attach", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	noStdin := fs.Bool("no-stdin", false, "do not attach stdin")
+	keysFlag := fs.String("detach-keys", defaultDetachKeys, "key sequence that detaches from the container")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker attach [--no-stdin] [--detach-keys ctrl-p,ctrl-q] <container-id>")
+		os.Exit(1)
+	}
+	keys, err := parseDetachKeys(*keysFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var stdin io.Reader = os.Stdin
+	if *noStdin {
+		stdin = nil
+	}
+	if err := e.attachContainer(e.resolveContainerID(fs.Arg(0)), stdin, os.Stdout, keys); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
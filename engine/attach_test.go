@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseDetachKeys covers control keys and plain characters.
+func TestParseDetachKeys(t *testing.T) {
+	keys, err := parseDetachKeys(defaultDetachKeys)
+	if err != nil || !bytes.Equal(keys, []byte{0x10, 0x11}) {
+		t.Errorf("parseDetachKeys(%q) = %v, %v, want [16 17]", defaultDetachKeys, keys, err)
+	}
+	if keys, err := parseDetachKeys("ctrl-@,x,ctrl-_"); err != nil || !bytes.Equal(keys, []byte{0, 'x', 0x1f}) {
+		t.Errorf("parseDetachKeys = %v, %v, want [0 120 31]", keys, err)
+	}
+	for _, spec := range []string{"", "ctrl-", "ctrl-1", "xy", "ctrl-p,"} {
+		if _, err := parseDetachKeys(spec); err == nil {
+			t.Errorf("parseDetachKeys(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestDetachReader verifies that input passes through, including partial
+// detach sequences, until the full sequence is read.
+func TestDetachReader(t *testing.T) {
+	keys := []byte{0x10, 0x11}
+	reader := newDetachReader(strings.NewReader("ab\x10c\x10\x10\x11ignored"), keys)
+	data, err := io.ReadAll(reader)
+	if err != errDetached {
+		t.Fatalf("Expected errDetached, got %v", err)
+	}
+	if string(data) != "ab\x10c\x10" {
+		t.Errorf("Read %q, want %q", data, "ab\x10c\x10")
+	}
+	select {
+	case <-reader.detached:
+	default:
+		t.Error("Expected detached to be closed")
+	}
+
+	reader = newDetachReader(strings.NewReader("tail\x10"), keys)
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "tail\x10" {
+		t.Errorf("ReadAll = %q, %v, want the held back key at EOF", data, err)
+	}
+}
+
+// waitForContainerStatus polls until a container has the given status.
+func waitForContainerStatus(t *testing.T, e *Engine, containerID, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.getContainerStatus(containerID) != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for container %s to be %s", containerID, status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestAttachFollowsOutput attaches to a container run without the daemon and
+// checks that output logged after attaching is streamed until it stops.
+func TestAttachFollowsOutput(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "attach-follow"
+	if err := os.MkdirAll(filepath.Join(e.Root, "containers", containerID), 0755); err != nil {
+		t.Fatalf("Failed to create container directory: %v", err)
+	}
+	logFile, err := e.openContainerLog(containerID)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer logFile.Close()
+	cmd := exec.Command("sh", "-c", "while :; do echo tick; sleep 0.05; done")
+	cmd.Stdout = logFile
+	done := make(chan error, 1)
+	go func() { done <- e.runContainerProcess(containerID, cmd, nil) }()
+	waitForContainerStatus(t, e, containerID, "Running")
+
+	var out lockedBuffer
+	attached := make(chan error, 1)
+	go func() { attached <- e.attachContainer(containerID, nil, &out, nil) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(out.String(), "tick") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for streamed output, got %q", out.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := e.stopContainer(containerID, time.Second); err != nil {
+		t.Fatalf("stopContainer failed: %v", err)
+	}
+	<-done
+	select {
+	case err := <-attached:
+		if err != nil {
+			t.Errorf("attachContainer failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("attach did not return after the container stopped")
+	}
+
+	if err := e.attachContainer(containerID, nil, io.Discard, nil); err == nil {
+		t.Error("Expected an error attaching to a stopped container")
+	}
+}
+
+// TestAttachDetachedContainer attaches to a container the daemon started with
+// stdin open, sends it input and detaches, leaving it running.
+func TestAttachDetachedContainer(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	var resp runResponse
+	opts := RunOptions{Image: "local", Command: "cat", Isolation: isolationNone, Interactive: true}
+	if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err != nil {
+		t.Fatalf("run request failed: %v", err)
+	}
+	waitForContainerStatus(t, e, resp.ID, "Running")
+	defer func() {
+		e.stopContainer(resp.ID, time.Second)
+		waitForContainerStatus(t, e, resp.ID, "Stopped")
+	}()
+
+	stdin, input := io.Pipe()
+	var out lockedBuffer
+	attached := make(chan error, 1)
+	go func() { attached <- e.attachContainer(resp.ID, stdin, &out, []byte{0x10, 0x11}) }()
+
+	// Output is followed from when the daemon starts streaming, so keep
+	// writing until the echo shows up
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "hello attach") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the echoed input, got %q", out.String())
+		}
+		input.Write([]byte("hello attach\n"))
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	input.Write([]byte{0x10, 0x11})
+	select {
+	case err := <-attached:
+		if err != nil {
+			t.Errorf("attachContainer failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("attach did not return after the detach keys")
+	}
+	if status := e.getContainerStatus(resp.ID); status != "Running" {
+		t.Errorf("Expected the container to keep running after detaching, got %s", status)
+	}
+}
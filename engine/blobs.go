@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxBlobResumes is how many times a pull resumes a layer download that
+// dropped after making progress.
+const maxBlobResumes = 3
+
+// layerRangeFetcher is implemented by registries that can send a layer from
+// an offset, so interrupted downloads resume instead of starting over.
+type layerRangeFetcher interface {
+	FetchLayerFrom(repo, digest string, offset int64) (io.ReadCloser, int64, error)
+}
+
+// blobPath returns where the layer with a sha256 digest is cached.
+func (e *Engine) blobPath(digest string) string {
+	return filepath.Join(e.Root, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+}
+
+// blobLocks serializes downloads of the same blob within the process.
+var blobLocks sync.Map
+
+func lockBlob(digest string) func() {
+	mu, _ := blobLocks.LoadOrStore(digest, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// downloadBlob returns the cached file of a layer, downloading it first when
+// it is not cached. The download goes to a .tmp file next to the cache entry,
+// which is resumed by later attempts and pulls, and only becomes the entry
+// once its digest verifies.
+func (e *Engine) downloadBlob(registry Registry, repo, digest string) (string, error) {
+	unlock := lockBlob(digest)
+	defer unlock()
+
+	path := e.blobPath(digest)
+	if _, err := os.Stat(path); err == nil {
+		e.Logger.Debug("layer found in cache", "digest", digest)
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	for attempt := 0; ; attempt++ {
+		progress, err := e.resumeBlobDownload(registry, repo, digest, tmp)
+		if err == nil {
+			break
+		}
+		if !progress || attempt >= maxBlobResumes {
+			return "", fmt.Errorf("failed to download layer %s: %w", digest, err)
+		}
+		e.Logger.Debug("resuming interrupted layer download", "digest", digest, "error", err)
+	}
+
+	if err := verifyBlob(tmp, digest); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to store layer %s: %w", digest, err)
+	}
+	return path, nil
+}
+
+// resumeBlobDownload appends the rest of a layer to tmp, asking the registry
+// for the bytes after those already downloaded when it supports ranges. It
+// reports whether any bytes arrived, even when the download then failed.
+func (e *Engine) resumeBlobDownload(registry Registry, repo, digest, tmp string) (bool, error) {
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open partial download: %w", err)
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, fmt.Errorf("failed to read partial download: %w", err)
+	}
+
+	var body io.ReadCloser
+	start := int64(0)
+	if ranged, ok := registry.(layerRangeFetcher); ok {
+		body, start, err = ranged.FetchLayerFrom(repo, digest, offset)
+	} else {
+		body, err = registry.FetchLayer(repo, digest)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	if start != offset {
+		e.Logger.Debug("restarting layer download", "digest", digest, "downloaded", offset)
+		if err := file.Truncate(start); err != nil {
+			return false, fmt.Errorf("failed to reset partial download: %w", err)
+		}
+		if _, err := file.Seek(start, io.SeekStart); err != nil {
+			return false, fmt.Errorf("failed to reset partial download: %w", err)
+		}
+	}
+	n, err := io.Copy(file, body)
+	return n > 0, err
+}
+
+// verifyBlob checks that the sha256 of a file matches digest.
+func verifyBlob(path, digest string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open layer %s: %w", digest, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read layer %s: %w", digest, err)
+	}
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		return fmt.Errorf("layer %s failed verification: downloaded content has digest %s", digest, actual)
+	}
+	return nil
+}
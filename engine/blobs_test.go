@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeBlobServer serves a blob with range support, cutting the connection
+// after cutAfter bytes on the first request when cutAfter is set. It records
+// the Range header of each request.
+type rangeBlobServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	ranges []string
+}
+
+func newRangeBlobServer(t *testing.T, digest string, blob []byte, cutAfter int) *rangeBlobServer {
+	t.Helper()
+	s := &rangeBlobServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/library/resume/blobs/"+digest {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		first := len(s.ranges) == 1
+		s.mu.Unlock()
+
+		if first && cutAfter > 0 {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Failed to hijack connection: %v", err)
+				return
+			}
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(blob))
+			buf.Write(blob[:cutAfter])
+			buf.Flush()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(blob))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeBlobServer) requestRanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+// testBlob returns content large enough to interrupt, with its digest.
+func testBlob() ([]byte, string) {
+	blob := bytes.Repeat([]byte("layer data "), 4096)
+	sum := sha256.Sum256(blob)
+	return blob, "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// TestDownloadBlobResumesInterruptedDownload cuts the connection part way
+// through and checks the retry asks for the remaining bytes only.
+func TestDownloadBlobResumesInterruptedDownload(t *testing.T) {
+	e := newTestEngine(t)
+	blob, digest := testBlob()
+	const cutAfter = 10000
+	server := newRangeBlobServer(t, digest, blob, cutAfter)
+	registry := NewDockerHubRegistry(server.URL + "/v2/")
+
+	path, err := e.downloadBlob(registry, "library/resume", digest)
+	if err != nil {
+		t.Fatalf("downloadBlob failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, blob) {
+		t.Errorf("Cached blob has %d bytes, want the %d bytes served", len(data), len(blob))
+	}
+	if path != e.blobPath(digest) {
+		t.Errorf("Blob stored at %s, want %s", path, e.blobPath(digest))
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial download to be gone, got %v", err)
+	}
+	ranges := server.requestRanges()
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != fmt.Sprintf("bytes=%d-", cutAfter) {
+		t.Errorf("Request ranges = %q, want a full request then bytes=%d-", ranges, cutAfter)
+	}
+
+	// Cached blobs are not downloaded again
+	if _, err := e.downloadBlob(registry, "library/resume", digest); err != nil {
+		t.Fatalf("downloadBlob from cache failed: %v", err)
+	}
+	if got := len(server.requestRanges()); got != 2 {
+		t.Errorf("Expected no request for a cached blob, got %d requests in total", got)
+	}
+}
+
+// TestDownloadBlobResumesPartialFile resumes a download a previous pull left
+// behind.
+func TestDownloadBlobResumesPartialFile(t *testing.T) {
+	e := newTestEngine(t)
+	blob, digest := testBlob()
+	server := newRangeBlobServer(t, digest, blob, 0)
+	if err := os.MkdirAll(filepath.Dir(e.blobPath(digest)), 0755); err != nil {
+		t.Fatalf("Failed to create blob directory: %v", err)
+	}
+	if err := os.WriteFile(e.blobPath(digest)+".tmp", blob[:5000], 0644); err != nil {
+		t.Fatalf("Failed to write partial download: %v", err)
+	}
+
+	path, err := e.downloadBlob(NewDockerHubRegistry(server.URL+"/v2/"), "library/resume", digest)
+	if err != nil {
+		t.Fatalf("downloadBlob failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, blob) {
+		t.Error("Cached blob does not match the served blob")
+	}
+	if ranges := server.requestRanges(); len(ranges) != 1 || ranges[0] != "bytes=5000-" {
+		t.Errorf("Request ranges = %q, want bytes=5000-", ranges)
+	}
+}
+
+// TestDownloadBlobRejectsDigestMismatch verifies that content not matching
+// its digest is neither cached nor kept for resuming.
+func TestDownloadBlobRejectsDigestMismatch(t *testing.T) {
+	e := newTestEngine(t)
+	blob, _ := testBlob()
+	digest := "sha256:" + hex.EncodeToString(make([]byte, 32))
+	server := newRangeBlobServer(t, digest, blob, 0)
+
+	if _, err := e.downloadBlob(NewDockerHubRegistry(server.URL+"/v2/"), "library/resume", digest); err == nil {
+		t.Fatal("Expected a verification error")
+	}
+	for _, path := range []string{e.blobPath(digest), e.blobPath(digest) + ".tmp"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// busyboxLayerID is the layer in layersDir holding the busybox binary that
+// minimal root filesystems share.
+const busyboxLayerID = "busybox"
+
+// findBusybox returns the busybox binary of the host. Tests replace it.
+var findBusybox = func() (string, error) {
+	return exec.LookPath("busybox")
+}
+
+// busyboxLayerMu serializes creating the shared busybox layer.
+var busyboxLayerMu sync.Mutex
+
+// sharedBusybox returns the busybox binary of the shared layer, copying it
+// from the host the first time. It fails with exec.ErrNotFound when the host
+// has no busybox.
+func (e *Engine) sharedBusybox() (string, error) {
+	busyboxLayerMu.Lock()
+	defer busyboxLayerMu.Unlock()
+
+	layerPath := filepath.Join(e.layersDir(), busyboxLayerID)
+	path := filepath.Join(layerPath, "bin", "busybox")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	hostPath, err := findBusybox()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create busybox layer: %v", err)
+	}
+
+	// Copy to a temporary name so an interrupted copy is never shared
+	tmp := path + ".tmp"
+	if err := copyFile(hostPath, tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to copy busybox: %v", err)
+	}
+	// Every container links the same file, so none may change it
+	if err := os.Chmod(tmp, 0555); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to copy busybox: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to copy busybox: %v", err)
+	}
+
+	// The metadata keeps prune from removing the layer
+	layer := ImageLayer{ID: busyboxLayerID, Created: time.Now(), BaseLayerPath: layerPath}
+	if err := e.AddLayer(layer); err != nil {
+		e.Logger.Warn("failed to save layer metadata", "error", err)
+	}
+	return path, nil
+}
+
+// linkBusybox puts the shared busybox binary at bin/busybox of rootfs. It
+// reports false when the host has no busybox.
+func (e *Engine) linkBusybox(rootfs string) (bool, error) {
+	busybox, err := e.sharedBusybox()
+	if errors.Is(err, exec.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := e.linkOrCopy(busybox, filepath.Join(rootfs, "bin", "busybox")); err != nil {
+		return false, fmt.Errorf("failed to link busybox: %v", err)
+	}
+	return true, nil
+}
+
+// baseLayerID is the layer in layersDir holding the minimal root filesystem
+// that createMinimalRootfs gives containers.
+const baseLayerID = "base"
+
+// baseLayerCommands are the commands linked to busybox in the base layer.
+var baseLayerCommands = []string{"sh", "ls", "echo", "cat", "ps"}
+
+// baseLayerMu serializes creating the base layer.
+var baseLayerMu sync.Mutex
+
+// initializeBaseLayer returns the path of the base layer, building and
+// verifying it the first time. Later calls reuse it.
+func (e *Engine) initializeBaseLayer() (string, error) {
+	baseLayerMu.Lock()
+	defer baseLayerMu.Unlock()
+
+	layerPath := filepath.Join(e.layersDir(), baseLayerID)
+	if _, err := os.Stat(layerPath); err == nil {
+		return layerPath, nil
+	}
+	if err := os.MkdirAll(e.layersDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create base layer: %v", err)
+	}
+	// Build under a temporary name so an interrupted build is never reused
+	tmp, err := os.MkdirTemp(e.layersDir(), baseLayerID+".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create base layer: %v", err)
+	}
+	if err := e.buildBaseLayer(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to create base layer: %v", err)
+	}
+	if err := os.Rename(tmp, layerPath); err != nil {
+		os.RemoveAll(tmp)
+		// Another engine process may have built it first
+		if _, statErr := os.Stat(layerPath); statErr == nil {
+			return layerPath, nil
+		}
+		return "", fmt.Errorf("failed to create base layer: %v", err)
+	}
+
+	// The metadata keeps prune from removing the layer
+	layer := ImageLayer{ID: baseLayerID, Created: time.Now(), BaseLayerPath: layerPath}
+	if err := e.AddLayer(layer); err != nil {
+		e.Logger.Warn("failed to save layer metadata", "error", err)
+	}
+	e.Logger.Debug("base layer created", "path", layerPath)
+	return layerPath, nil
+}
+
+// buildBaseLayer lays out a minimal root filesystem at path: busybox and its
+// command links, or copies of host binaries when the host has no busybox.
+func (e *Engine) buildBaseLayer(path string) error {
+	for _, dir := range []string{"/bin", "/dev", "/etc", "/proc", "/sys", "/tmp"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+	}
+	linked, err := e.linkBusybox(path)
+	if err != nil {
+		return err
+	}
+	if !linked {
+		return e.fallbackToHostBinaries(path)
+	}
+	for _, cmd := range baseLayerCommands {
+		if err := os.Symlink("busybox", filepath.Join(path, "bin", cmd)); err != nil {
+			return fmt.Errorf("failed to create symlink for %s: %v", cmd, err)
+		}
+	}
+	return e.verifyBaseLayer(path)
+}
+
+// verifyBaseLayer checks that busybox and every command linked to it resolve
+// in the base layer at path.
+func (e *Engine) verifyBaseLayer(path string) error {
+	binDir := filepath.Join(path, "bin")
+	for _, cmd := range append([]string{"busybox"}, baseLayerCommands...) {
+		if _, err := os.Stat(filepath.Join(binDir, cmd)); err != nil {
+			return fmt.Errorf("base layer is missing %s: %v", cmd, err)
+		}
+	}
+	if e.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		entries, _ := os.ReadDir(binDir)
+		for _, entry := range entries {
+			e.Logger.Debug("base layer /bin entry", "name", entry.Name())
+		}
+	}
+	return nil
+}
+
+// createMinimalRootfs fills rootfs from the base layer, creating the layer
+// the first time. Files are hardlinked, so containers share its binaries.
+func (e *Engine) createMinimalRootfs(rootfs string) error {
+	base, err := e.initializeBaseLayer()
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(rootfs, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return e.linkOrCopy(path, target)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create minimal rootfs: %v", err)
+	}
+	return nil
+}
+
+// linkOrCopy hardlinks src to dst, replacing dst, and copies src instead
+// when the two are on different filesystems.
+func (e *Engine) linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err := os.Link(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		e.Logger.Debug("hardlink crosses filesystems, copying", "src", src, "dst", dst)
+		return copyFile(src, dst)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// useFakeBusybox makes a test's host busybox a script in a temporary
+// directory.
+func useFakeBusybox(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "busybox")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake busybox: %v", err)
+	}
+	oldFind := findBusybox
+	t.Cleanup(func() { findBusybox = oldFind })
+	findBusybox = func() (string, error) { return path, nil }
+}
+
+// TestMinimalRootfsSharesBusybox checks busybox is copied into the shared
+// layer once and every container rootfs links that same file
+func TestMinimalRootfsSharesBusybox(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeBusybox(t)
+
+	var infos []os.FileInfo
+	for _, name := range []string{"first", "second"} {
+		rootfs := filepath.Join(t.TempDir(), name)
+		if err := e.createMinimalRootfs(rootfs); err != nil {
+			t.Fatalf("createMinimalRootfs failed: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(rootfs, "bin", "busybox"))
+		if err != nil {
+			t.Fatalf("Expected busybox in the rootfs: %v", err)
+		}
+		infos = append(infos, info)
+		if target, err := os.Readlink(filepath.Join(rootfs, "bin", "sh")); err != nil || target != "busybox" {
+			t.Errorf("Expected sh to link to busybox, got %q (%v)", target, err)
+		}
+	}
+
+	shared, err := os.Stat(filepath.Join(e.layersDir(), busyboxLayerID, "bin", "busybox"))
+	if err != nil {
+		t.Fatalf("Expected busybox in the shared layer: %v", err)
+	}
+	// Temporary directories may be on another filesystem than layersDir,
+	// in which case the rootfs gets a copy
+	if !os.SameFile(infos[0], shared) {
+		t.Skip("Container rootfs and layers are on different filesystems")
+	}
+	if !os.SameFile(infos[0], infos[1]) {
+		t.Error("Expected both containers to link the same busybox inode")
+	}
+	if refs, err := e.layerReferences(); err != nil || refs[busyboxLayerID] == 0 {
+		t.Errorf("Expected the busybox layer to be recorded, got %v (%v)", refs, err)
+	}
+}
+
+// TestLinkBusyboxWithoutBusybox checks hosts without busybox are reported
+// rather than failing
+func TestLinkBusyboxWithoutBusybox(t *testing.T) {
+	e := newTestEngine(t)
+	oldFind := findBusybox
+	t.Cleanup(func() { findBusybox = oldFind })
+	findBusybox = func() (string, error) { return "", &exec.Error{Name: "busybox", Err: exec.ErrNotFound} }
+
+	linked, err := e.linkBusybox(t.TempDir())
+	if err != nil || linked {
+		t.Errorf("linkBusybox = %v, %v, want false without an error", linked, err)
+	}
+}
+
+// TestBaseLayerBuiltOnce checks the base layer is built and recorded by the
+// first minimal rootfs and reused, not rebuilt, by the next
+func TestBaseLayerBuiltOnce(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeBusybox(t)
+
+	if err := e.createMinimalRootfs(filepath.Join(t.TempDir(), "first")); err != nil {
+		t.Fatalf("createMinimalRootfs failed: %v", err)
+	}
+	base := filepath.Join(e.layersDir(), baseLayerID)
+	if _, err := e.GetLayer(baseLayerID); err != nil {
+		t.Errorf("Expected the base layer to be recorded: %v", err)
+	}
+	// A rebuilt layer would lose this file
+	if err := os.WriteFile(filepath.Join(base, "etc", "marker"), []byte("reused"), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	rootfs := filepath.Join(t.TempDir(), "second")
+	if err := e.createMinimalRootfs(rootfs); err != nil {
+		t.Fatalf("createMinimalRootfs failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(rootfs, "etc", "marker")); err != nil || string(data) != "reused" {
+		t.Errorf("Expected the second rootfs to come from the same base layer, got %q (%v)", data, err)
+	}
+	for _, cmd := range baseLayerCommands {
+		if _, err := os.Stat(filepath.Join(rootfs, "bin", cmd)); err != nil {
+			t.Errorf("Expected %s in the rootfs: %v", cmd, err)
+		}
+	}
+}
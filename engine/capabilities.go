@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// capabilityNumbers maps Linux capability names, without the CAP_ prefix, to
+// their numbers.
+var capabilityNumbers = map[string]int{
+	"CHOWN":              0,
+	"DAC_OVERRIDE":       1,
+	"DAC_READ_SEARCH":    2,
+	"FOWNER":             3,
+	"FSETID":             4,
+	"KILL":               5,
+	"SETGID":             6,
+	"SETUID":             7,
+	"SETPCAP":            8,
+	"LINUX_IMMUTABLE":    9,
+	"NET_BIND_SERVICE":   10,
+	"NET_BROADCAST":      11,
+	"NET_ADMIN":          12,
+	"NET_RAW":            13,
+	"IPC_LOCK":           14,
+	"IPC_OWNER":          15,
+	"SYS_MODULE":         16,
+	"SYS_RAWIO":          17,
+	"SYS_CHROOT":         18,
+	"SYS_PTRACE":         19,
+	"SYS_PACCT":          20,
+	"SYS_ADMIN":          21,
+	"SYS_BOOT":           22,
+	"SYS_NICE":           23,
+	"SYS_RESOURCE":       24,
+	"SYS_TIME":           25,
+	"SYS_TTY_CONFIG":     26,
+	"MKNOD":              27,
+	"LEASE":              28,
+	"AUDIT_WRITE":        29,
+	"AUDIT_CONTROL":      30,
+	"SETFCAP":            31,
+	"MAC_OVERRIDE":       32,
+	"MAC_ADMIN":          33,
+	"SYSLOG":             34,
+	"WAKE_ALARM":         35,
+	"BLOCK_SUSPEND":      36,
+	"AUDIT_READ":         37,
+	"PERFMON":            38,
+	"BPF":                39,
+	"CHECKPOINT_RESTORE": 40,
+}
+
+// lastCapability returns the highest capability number the kernel supports.
+func lastCapability() int {
+	data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return capabilityNumbers["CHECKPOINT_RESTORE"]
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return capabilityNumbers["CHECKPOINT_RESTORE"]
+	}
+	return last
+}
+
+// parseCapability returns the number of a capability given as NET_RAW,
+// CAP_NET_RAW or in lower case.
+func parseCapability(name string) (int, error) {
+	key := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+	number, ok := capabilityNumbers[key]
+	if !ok {
+		return 0, fmt.Errorf("unknown capability %q", name)
+	}
+	return number, nil
+}
+
+// resolveCapabilities returns the capabilities a container keeps given the
+// --cap-drop and --cap-add flags: every capability, minus the dropped ones,
+// plus the added ones. ALL drops or adds every capability, so
+// "--cap-drop ALL --cap-add NET_BIND_SERVICE" keeps only the latter.
+func resolveCapabilities(add, drop []string) ([]int, error) {
+	kept := make(map[int]bool)
+	for number := 0; number <= lastCapability(); number++ {
+		kept[number] = true
+	}
+	for _, name := range drop {
+		if strings.EqualFold(name, "ALL") {
+			clear(kept)
+			continue
+		}
+		number, err := parseCapability(name)
+		if err != nil {
+			return nil, err
+		}
+		delete(kept, number)
+	}
+	for _, name := range add {
+		if strings.EqualFold(name, "ALL") {
+			for number := 0; number <= lastCapability(); number++ {
+				kept[number] = true
+			}
+			continue
+		}
+		number, err := parseCapability(name)
+		if err != nil {
+			return nil, err
+		}
+		kept[number] = true
+	}
+
+	caps := make([]int, 0, len(kept))
+	for number := range kept {
+		caps = append(caps, number)
+	}
+	sort.Ints(caps)
+	return caps, nil
+}
+
+// capUserHeader and capUserData mirror the kernel's capset structures.
+type capUserHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capUserData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+const (
+	linuxCapabilityVersion3 = 0x20080522
+	prCapAmbient            = 47
+	prCapAmbientRaise       = 2
+)
+
+// restrictCapabilities limits the calling thread to caps: the others are
+// removed from the bounding set, so no exec can regain them, and from the
+// effective, permitted and inheritable sets. The kept capabilities are raised
+// as ambient so that they survive the exec of a non-root program; failures to
+// raise them are logged to logger.
+func restrictCapabilities(caps []int, logger *slog.Logger) error {
+	keep := make(map[int]bool, len(caps))
+	for _, number := range caps {
+		keep[number] = true
+	}
+	for number := 0; number <= lastCapability(); number++ {
+		if keep[number] {
+			continue
+		}
+		if err := prctl(syscall.PR_CAPBSET_DROP, uintptr(number)); err != nil {
+			return fmt.Errorf("failed to drop capability %d from the bounding set: %v", number, err)
+		}
+	}
+
+	header := capUserHeader{version: linuxCapabilityVersion3}
+	var data [2]capUserData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("failed to read capabilities: %v", errno)
+	}
+	var mask [2]uint32
+	for _, number := range caps {
+		mask[number/32] |= 1 << (number % 32)
+	}
+	for i := range data {
+		data[i].permitted &= mask[i]
+		data[i].effective &= mask[i]
+		data[i].inheritable = data[i].permitted
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("failed to set capabilities: %v", errno)
+	}
+
+	for _, number := range caps {
+		if data[number/32].permitted&(1<<(number%32)) == 0 {
+			continue
+		}
+		if err := prctl(prCapAmbient, prCapAmbientRaise, uintptr(number)); err != nil {
+			logger.Debug("failed to raise ambient capability", "capability", number, "error", err)
+		}
+	}
+	return nil
+}
+
+func prctl(option uintptr, args ...uintptr) error {
+	var a [4]uintptr
+	copy(a[:], args)
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, option, a[0], a[1], a[2], a[3], 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
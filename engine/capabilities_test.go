@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// TestResolveCapabilities verifies how --cap-drop and --cap-add combine.
+func TestResolveCapabilities(t *testing.T) {
+	e := newTestEngine(t)
+	caps, err := resolveCapabilities(nil, []string{"NET_RAW", "cap_sys_admin"})
+	if err != nil {
+		t.Fatalf("resolveCapabilities failed: %v", err)
+	}
+	if slices.Contains(caps, capabilityNumbers["NET_RAW"]) || slices.Contains(caps, capabilityNumbers["SYS_ADMIN"]) {
+		t.Errorf("Expected NET_RAW and SYS_ADMIN to be dropped, got %v", caps)
+	}
+	if !slices.Contains(caps, capabilityNumbers["CHOWN"]) {
+		t.Errorf("Expected CHOWN to be kept, got %v", caps)
+	}
+
+	caps, err = resolveCapabilities([]string{"NET_BIND_SERVICE"}, []string{"ALL"})
+	if err != nil {
+		t.Fatalf("resolveCapabilities failed: %v", err)
+	}
+	if !slices.Equal(caps, []int{capabilityNumbers["NET_BIND_SERVICE"]}) {
+		t.Errorf("Expected only NET_BIND_SERVICE, got %v", caps)
+	}
+
+	if _, err := resolveCapabilities([]string{"NOT_A_CAP"}, nil); err == nil {
+		t.Error("Expected an unknown capability to be rejected")
+	}
+	if _, err := e.parseRunArgs([]string{"--cap-drop", "bogus", "alpine", "sh"}); err == nil {
+		t.Error("Expected parseRunArgs to reject an unknown capability")
+	}
+}
+
+// TestCapExecHelperProcess runs container-init the way the engine does, with the
+// raw socket helper as the container command.
+func TestCapExecHelperProcess(t *testing.T) {
+	caps, ok := os.LookupEnv("BASIC_DOCKER_CAP_EXEC")
+	if !ok {
+		return
+	}
+	err := containerInit([]string{"--caps=" + caps, "--", os.Args[0], "-test.run=^TestRawSocketHelperProcess$"}, slog.Default())
+	t.Fatalf("containerInit failed: %v", err)
+}
+
+// TestRawSocketHelperProcess exits with 0 if it can open a raw ICMP socket,
+// which requires CAP_NET_RAW, and 1 otherwise.
+func TestRawSocketHelperProcess(t *testing.T) {
+	if os.Getenv("BASIC_DOCKER_RAW_SOCKET") != "1" {
+		return
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		os.Exit(1)
+	}
+	syscall.Close(fd)
+	os.Exit(0)
+}
+
+// TestCapDropNetRaw verifies that a container command without CAP_NET_RAW
+// cannot open the raw socket ping needs.
+func TestCapDropNetRaw(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping capabilities requires root")
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		t.Skipf("raw sockets are not permitted here: %v", err)
+	}
+	syscall.Close(fd)
+
+	run := func(add, drop []string) error {
+		caps, err := resolveCapabilities(add, drop)
+		if err != nil {
+			t.Fatalf("resolveCapabilities failed: %v", err)
+		}
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCapExecHelperProcess$")
+		cmd.Env = append(os.Environ(), "BASIC_DOCKER_CAP_EXEC="+formatCapabilities(caps), "BASIC_DOCKER_RAW_SOCKET=1")
+		return cmd.Run()
+	}
+
+	if err := run(nil, []string{"NET_RAW"}); err == nil {
+		t.Error("Expected opening a raw socket to fail without CAP_NET_RAW")
+	}
+	if err := run(nil, []string{"SYS_ADMIN"}); err != nil {
+		t.Errorf("Expected opening a raw socket to succeed with CAP_NET_RAW, got %v", err)
+	}
+}
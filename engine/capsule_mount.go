@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const attachedCapsulesFile = "capsules.json"
+
+// AttachedCapsule records a capsule made available inside a container so it
+// can be detached when the container is removed.
+type AttachedCapsule struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	// Target is the absolute host path of the attachment inside the rootfs.
+	Target  string `json:"target"`
+	Mounted bool   `json:"mounted"`
+}
+
+// bindMount bind-mounts src onto dst. Tests replace it to exercise the copy
+// fallback.
+var bindMount = func(src, dst string) error {
+	return syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, "")
+}
+
+// validateCapsulePath checks that a capsule source exists and can be read,
+// so a broken capsule is reported when attaching rather than at runtime.
+func validateCapsulePath(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("capsule path does not exist: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat capsule path %s: %v", path, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("capsule path is not readable: %s: %v", path, err)
+	}
+	defer file.Close()
+	if info.IsDir() {
+		_, err = file.Readdirnames(1)
+	} else {
+		_, err = file.Read(make([]byte, 1))
+	}
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("capsule path is not readable: %s: %v", path, err)
+	}
+	return nil
+}
+
+// capsuleContainerPath is where a capsule appears inside the container.
+func capsuleContainerPath(name, version string) string {
+	return filepath.Join("/capsules", name, version)
+}
+
+// attachCapsuleToRootfs makes the capsule at source visible inside the
+// container's rootfs. It bind-mounts when mounts are permitted and copies the
+// capsule contents otherwise.
+func (e *Engine) attachCapsuleToRootfs(containerID string, capsule ResourceCapsule) (*AttachedCapsule, error) {
+	if err := validateCapsulePath(capsule.Path); err != nil {
+		return nil, fmt.Errorf("invalid capsule %s:%s: %v", capsule.Name, capsule.Version, err)
+	}
+	info, err := os.Stat(capsule.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat capsule %s:%s: %v", capsule.Name, capsule.Version, err)
+	}
+
+	rootfs := filepath.Join(e.Root, "containers", containerID, "rootfs")
+	target := filepath.Join(rootfs, capsuleContainerPath(capsule.Name, capsule.Version))
+	if err := e.detachCapsuleTarget(containerID, target); err != nil {
+		return nil, err
+	}
+
+	// A file capsule is mounted onto a file, a directory onto a directory
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		err = os.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capsule mount point: %v", err)
+	}
+
+	attached := &AttachedCapsule{Name: capsule.Name, Version: capsule.Version, Source: capsule.Path, Target: target}
+	err = bindMount(capsule.Path, target)
+	if err == nil {
+		attached.Mounted = true
+		return attached, nil
+	}
+	e.Logger.Debug("bind mount not permitted, copying capsule", "capsule", capsule.Name+":"+capsule.Version, "error", err)
+
+	if info.IsDir() {
+		err = e.copyDir(capsule.Path, target)
+	} else {
+		err = copyFile(capsule.Path, target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy capsule %s:%s: %v", capsule.Name, capsule.Version, err)
+	}
+	return attached, nil
+}
+
+// loadAttachedCapsules reads the capsules attached to a container.
+func (e *Engine) loadAttachedCapsules(containerID string) ([]AttachedCapsule, error) {
+	data, err := os.ReadFile(filepath.Join(e.Root, "containers", containerID, attachedCapsulesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attached capsules: %v", err)
+	}
+	var capsules []AttachedCapsule
+	if err := json.Unmarshal(data, &capsules); err != nil {
+		return nil, fmt.Errorf("failed to parse attached capsules: %v", err)
+	}
+	return capsules, nil
+}
+
+// saveAttachedCapsules writes the capsules attached to a container.
+func (e *Engine) saveAttachedCapsules(containerID string, capsules []AttachedCapsule) error {
+	data, err := json.MarshalIndent(capsules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal attached capsules: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", containerID, attachedCapsulesFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write attached capsules: %v", err)
+	}
+	return nil
+}
+
+// recordAttachedCapsule adds or replaces an attachment in the container's list.
+func (e *Engine) recordAttachedCapsule(containerID string, attached *AttachedCapsule) error {
+	capsules, err := e.loadAttachedCapsules(containerID)
+	if err != nil {
+		return err
+	}
+	for i := range capsules {
+		if capsules[i].Target == attached.Target {
+			capsules[i] = *attached
+			return e.saveAttachedCapsules(containerID, capsules)
+		}
+	}
+	return e.saveAttachedCapsules(containerID, append(capsules, *attached))
+}
+
+// detachCapsuleTarget unmounts a previous attachment at target, if any, so it
+// can be replaced.
+func (e *Engine) detachCapsuleTarget(containerID, target string) error {
+	capsules, err := e.loadAttachedCapsules(containerID)
+	if err != nil {
+		return err
+	}
+	for _, capsule := range capsules {
+		if capsule.Target == target && capsule.Mounted {
+			if err := syscall.Unmount(target, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+				return fmt.Errorf("failed to unmount capsule at %s: %v", target, err)
+			}
+		}
+	}
+	return os.RemoveAll(target)
+}
+
+// detachCapsules unmounts every capsule bind-mounted into a container. It must
+// succeed before the container directory is removed, otherwise the removal
+// would descend into the capsule sources.
+func (e *Engine) detachCapsules(containerID string) error {
+	capsules, err := e.loadAttachedCapsules(containerID)
+	if err != nil {
+		return err
+	}
+	for _, capsule := range capsules {
+		if !capsule.Mounted {
+			continue
+		}
+		// EINVAL means the target is no longer a mount point
+		if err := syscall.Unmount(capsule.Target, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to unmount capsule %s:%s: %v", capsule.Name, capsule.Version, err)
+		}
+	}
+	return nil
+}
+
+// removeContainer deletes a stopped container, detaching its capsules first.
+func (e *Engine) removeContainer(containerID string) error {
+	if err := validateContainerID(containerID); err != nil {
+		return err
+	}
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Only directories holding a container config are containers
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return fmt.Errorf("container %s not found: %v", containerID, err)
+	}
+	if status := e.getContainerStatus(containerID); isContainerActive(status) {
+		return fmt.Errorf("container %s is %s, stop it before removing", containerID, status)
+	}
+
+	if err := e.detachCapsules(containerID); err != nil {
+		return err
+	}
+	if err := e.unmountVolumes(config); err != nil {
+		return err
+	}
+	if config.Network != "" {
+		e.loadNetworks()
+		if err := e.disconnectContainer(config.Network, containerID); err != nil {
+			e.Logger.Warn("failed to detach container from network", "container", containerID, "network", config.Network, "error", err)
+		}
+	}
+	if err := os.RemoveAll(containerDir); err != nil {
+		return fmt.Errorf("failed to remove container %s: %v", containerID, err)
+	}
+	if err := e.releaseContainerName(containerID); err != nil {
+		e.Logger.Warn("failed to release container name", "container", containerID, "error", err)
+	}
+	e.emitEvent(eventRemove, containerID, nil)
+	return nil
+}
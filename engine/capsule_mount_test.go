@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAttachCapsuleCopyFallback verifies that capsules are copied into the
+// rootfs when bind mounts are not permitted, and that removal cleans them up.
+func TestAttachCapsuleCopyFallback(t *testing.T) {
+	e := newTestEngine(t)
+	old := bindMount
+	bindMount = func(src, dst string) error { return errors.New("operation not permitted") }
+	defer func() { bindMount = old }()
+
+	capsuleDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(capsuleDir, "settings.conf"), []byte("debug=true"), 0644); err != nil {
+		t.Fatalf("Failed to create capsule file: %v", err)
+	}
+	cm := e.NewCapsuleManager()
+	cm.AddCapsule("config", "1.0", capsuleDir)
+
+	containerID := "test-capsule-copy"
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	createTestContainer(t, e, &ContainerConfig{ID: containerID})
+
+	if err := cm.AttachCapsule(containerID, "config", "1.0"); err != nil {
+		t.Fatalf("AttachCapsule failed: %v", err)
+	}
+
+	copied := filepath.Join(containerDir, "rootfs", "capsules", "config", "1.0", "settings.conf")
+	data, err := os.ReadFile(copied)
+	if err != nil || string(data) != "debug=true" {
+		t.Fatalf("Expected capsule contents at %s, got %q (%v)", copied, data, err)
+	}
+
+	attached, err := e.loadAttachedCapsules(containerID)
+	if err != nil {
+		t.Fatalf("loadAttachedCapsules failed: %v", err)
+	}
+	if len(attached) != 1 || attached[0].Mounted || attached[0].Source != capsuleDir {
+		t.Errorf("Unexpected attached capsules: %+v", attached)
+	}
+
+	// Attaching again replaces the previous copy instead of duplicating it
+	if err := cm.AttachCapsule(containerID, "config", "1.0"); err != nil {
+		t.Fatalf("Second AttachCapsule failed: %v", err)
+	}
+	if attached, _ := e.loadAttachedCapsules(containerID); len(attached) != 1 {
+		t.Errorf("Expected a single attachment record, got %+v", attached)
+	}
+
+	if err := e.removeContainer(containerID); err != nil {
+		t.Fatalf("removeContainer failed: %v", err)
+	}
+	if _, err := os.Stat(containerDir); !os.IsNotExist(err) {
+		t.Errorf("Expected container directory to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(capsuleDir, "settings.conf")); err != nil {
+		t.Errorf("Expected capsule source to survive container removal: %v", err)
+	}
+}
+
+// TestRemoveContainerRejectsPaths verifies that rm only deletes directories
+// that are containers, so IDs such as .. cannot remove the state root.
+func TestRemoveContainerRejectsPaths(t *testing.T) {
+	e := newTestEngine(t)
+	if err := os.MkdirAll(filepath.Join(e.Root, "containers", "not-a-container"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	for _, ref := range []string{"..", "../images", ".", "", "not-a-container"} {
+		if err := e.removeContainer(e.resolveContainerID(ref)); err == nil {
+			t.Errorf("Expected rm %q to fail", ref)
+		}
+	}
+	for _, dir := range []string{e.Root, e.imagesDir(), filepath.Join(e.Root, "containers", "not-a-container")} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Expected %s to survive, got: %v", dir, err)
+		}
+	}
+}
+
+// TestAttachCapsuleMissingPath verifies that attaching a capsule whose source
+// does not exist fails with a clear error
+func TestAttachCapsuleMissingPath(t *testing.T) {
+	e := newTestEngine(t)
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	cm := e.NewCapsuleManager()
+	cm.AddCapsule("ghost", "1.0", missing)
+
+	err := cm.AttachCapsule("test-capsule-missing", "ghost", "1.0")
+	if err == nil {
+		t.Fatal("Expected an error attaching a capsule with a nonexistent path")
+	}
+	if !strings.Contains(err.Error(), "capsule path does not exist: "+missing) {
+		t.Errorf("Expected a missing path error, got: %v", err)
+	}
+
+	if err := e.addDockerResourceCapsule("ghost", "1.0", missing); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected addDockerResourceCapsule to reject the missing path, got: %v", err)
+	}
+}
@@ -0,0 +1,439 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCgroupRoot is the mount point of the host's cgroup hierarchy.
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// isCgroupV2 reports whether the hierarchy at root is unified (v2).
+func isCgroupV2(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+// CgroupCapabilities reports which cgroup controllers the engine can use.
+// Controllers are detected independently since hosts, and v2 delegation in
+// particular, may only make some of them available.
+type CgroupCapabilities struct {
+	Memory  bool
+	CPU     bool
+	IO      bool
+	PIDs    bool
+	Freezer bool
+}
+
+// controllers returns the names of the usable controllers.
+func (c CgroupCapabilities) controllers() []string {
+	var names []string
+	for _, controller := range []struct {
+		name   string
+		usable bool
+	}{{"memory", c.Memory}, {"cpu", c.CPU}, {"io", c.IO}, {"pids", c.PIDs}, {"freezer", c.Freezer}} {
+		if controller.usable {
+			names = append(names, controller.name)
+		}
+	}
+	return names
+}
+
+// cgroupWritable reports whether the engine can create cgroups under dir,
+// which must already exist.
+func cgroupWritable(dir string) bool {
+	testPath := filepath.Join(dir, "basic-docker-test")
+	if err := os.Mkdir(testPath, 0755); err != nil && !os.IsExist(err) {
+		return false
+	}
+	os.Remove(testPath)
+	return true
+}
+
+// detectCgroupCapabilities probes the hierarchy at root for the controllers
+// the engine can create cgroups with. On v2 these are the controllers listed
+// in cgroup.controllers; on v1 each controller is its own hierarchy.
+func detectCgroupCapabilities(root string) CgroupCapabilities {
+	var caps CgroupCapabilities
+	if isCgroupV2(root) {
+		data, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+		if err != nil || !cgroupWritable(root) {
+			return caps
+		}
+		for _, controller := range strings.Fields(string(data)) {
+			switch controller {
+			case "memory":
+				caps.Memory = true
+			case "cpu":
+				caps.CPU = true
+			case "io":
+				caps.IO = true
+			case "pids":
+				caps.PIDs = true
+			}
+		}
+		// The v2 freezer is part of the core, available in every cgroup
+		caps.Freezer = true
+		return caps
+	}
+
+	usable := func(hierarchies ...string) bool {
+		for _, hierarchy := range hierarchies {
+			if cgroupWritable(filepath.Join(root, hierarchy)) {
+				return true
+			}
+		}
+		return false
+	}
+	caps.Memory = usable("memory")
+	caps.CPU = usable("cpu", "cpu,cpuacct")
+	caps.IO = usable("blkio")
+	caps.PIDs = usable("pids")
+	caps.Freezer = usable("freezer")
+	return caps
+}
+
+// CgroupManager manages the cgroups of containers, hiding the differences
+// between v1, where each controller is a separate hierarchy, and v2, where a
+// container has a single cgroup.
+type CgroupManager struct {
+	root string
+	v2   bool
+	caps CgroupCapabilities
+}
+
+// newCgroupManager returns a manager for the cgroup hierarchy of the engine
+// using the controllers detected at startup.
+func (e *Engine) newCgroupManager() *CgroupManager {
+	return &CgroupManager{root: e.CgroupRoot, v2: isCgroupV2(e.CgroupRoot), caps: e.Cgroups}
+}
+
+// path returns the cgroup directory of a container for the given v1
+// controller. On v2 the controller is ignored.
+func (m *CgroupManager) path(controller, containerID string) string {
+	if m.v2 {
+		return filepath.Join(m.root, "basic-docker", containerID)
+	}
+	return filepath.Join(m.root, controller, "basic-docker", containerID)
+}
+
+// dirs returns the cgroup directories of a container in the usable
+// controllers.
+func (m *CgroupManager) dirs(containerID string) []string {
+	if m.v2 {
+		return []string{m.path("", containerID)}
+	}
+	var dirs []string
+	for _, c := range []struct {
+		name   string
+		usable bool
+	}{{"memory", m.caps.Memory}, {"cpu", m.caps.CPU}, {"pids", m.caps.PIDs}, {"freezer", m.caps.Freezer}} {
+		if c.usable {
+			dirs = append(dirs, m.path(c.name, containerID))
+		}
+	}
+	return dirs
+}
+
+// Create creates the cgroups of a container in the usable controllers.
+func (m *CgroupManager) Create(containerID string) error {
+	for _, dir := range m.dirs(containerID) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cgroup: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeLimit writes value to a controller file of a container's cgroup once
+// the controller is known to be usable.
+func (m *CgroupManager) writeLimit(controller string, usable bool, containerID, file, value string) error {
+	if !usable {
+		return fmt.Errorf("cgroup controller %s is not available", controller)
+	}
+	path := filepath.Join(m.path(controller, containerID), file)
+	if err := os.WriteFile(path, []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to set %s limit: %v", controller, err)
+	}
+	return nil
+}
+
+// SetMemory limits the memory of a container to the given bytes.
+func (m *CgroupManager) SetMemory(containerID string, bytes int64) error {
+	file := "memory.limit_in_bytes"
+	if m.v2 {
+		file = "memory.max"
+	}
+	return m.writeLimit("memory", m.caps.Memory, containerID, file, strconv.FormatInt(bytes, 10))
+}
+
+// cpuPeriod is the CFS period CPU limits are expressed in, in microseconds.
+const cpuPeriod = 100000
+
+// SetCPU limits a container to the given number of CPUs.
+func (m *CgroupManager) SetCPU(containerID string, cpus float64) error {
+	quota := int64(cpus * cpuPeriod)
+	if m.v2 {
+		return m.writeLimit("cpu", m.caps.CPU, containerID, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod))
+	}
+	if err := m.writeLimit("cpu", m.caps.CPU, containerID, "cpu.cfs_period_us", strconv.Itoa(cpuPeriod)); err != nil {
+		return err
+	}
+	return m.writeLimit("cpu", m.caps.CPU, containerID, "cpu.cfs_quota_us", strconv.FormatInt(quota, 10))
+}
+
+// SetOOMKillDisable keeps the kernel from killing the processes of a
+// container that reaches its memory limit; they wait for memory instead.
+// Only cgroup v1 has the setting.
+func (m *CgroupManager) SetOOMKillDisable(containerID string) error {
+	if m.v2 {
+		return fmt.Errorf("disabling the OOM killer is not supported on cgroup v2")
+	}
+	return m.writeLimit("memory", m.caps.Memory, containerID, "memory.oom_control", "1")
+}
+
+// OOMEvents returns how many times a container reached its memory limit and
+// how many of its processes the OOM killer killed. Cgroup v1 only counts the
+// kills.
+func (m *CgroupManager) OOMEvents(containerID string) (oom, oomKill int64, err error) {
+	if m.v2 {
+		events, err := readCgroupKeyedInts(filepath.Join(m.path("", containerID), "memory.events"))
+		return events["oom"], events["oom_kill"], err
+	}
+	control, err := readCgroupKeyedInts(filepath.Join(m.path("memory", containerID), "memory.oom_control"))
+	return 0, control["oom_kill"], err
+}
+
+// SetPids limits the number of processes in a container.
+func (m *CgroupManager) SetPids(containerID string, max int64) error {
+	return m.writeLimit("pids", m.caps.PIDs, containerID, "pids.max", strconv.FormatInt(max, 10))
+}
+
+// freezerState returns the file controlling the freezer of a container along
+// with the values that freeze and thaw it.
+func (m *CgroupManager) freezerState(containerID string) (path, frozen, thawed string) {
+	if m.v2 {
+		return filepath.Join(m.path("", containerID), "cgroup.freeze"), "1", "0"
+	}
+	return filepath.Join(m.path("freezer", containerID), "freezer.state"), "FROZEN", "THAWED"
+}
+
+// setFrozen writes the freezer state of a container's cgroup.
+func (m *CgroupManager) setFrozen(containerID string, freeze bool) error {
+	path, frozen, thawed := m.freezerState(containerID)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("container %s has no freezer cgroup: %v", containerID, err)
+	}
+
+	state := thawed
+	if freeze {
+		state = frozen
+	}
+	if err := os.WriteFile(path, []byte(state), 0644); err != nil {
+		return fmt.Errorf("failed to write freezer state: %v", err)
+	}
+	return nil
+}
+
+// Freeze suspends all processes in a container's cgroup.
+func (m *CgroupManager) Freeze(containerID string) error {
+	return m.setFrozen(containerID, true)
+}
+
+// Thaw resumes the processes of a frozen container.
+func (m *CgroupManager) Thaw(containerID string) error {
+	return m.setFrozen(containerID, false)
+}
+
+// Frozen reports whether a container's cgroup is frozen.
+func (m *CgroupManager) Frozen(containerID string) bool {
+	path, frozen, _ := m.freezerState(containerID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	// v1 reports FREEZING while the transition is in progress
+	state := strings.TrimSpace(string(data))
+	return state == frozen || (!m.v2 && state == "FREEZING")
+}
+
+// AddProcess moves pid into the cgroups of a container.
+func (m *CgroupManager) AddProcess(containerID string, pid int) error {
+	for _, dir := range m.dirs(containerID) {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("failed to add process to cgroup %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// Usage reads the CPU time and memory usage of a container from its v1
+// cpuacct and memory controllers or its v2 cgroup.
+func (m *CgroupManager) Usage(containerID string) (cgroupStats, error) {
+	var stats cgroupStats
+	if m.v2 {
+		dir := m.path("", containerID)
+		var err error
+		if stats.MemoryUsage, err = readCgroupInt(filepath.Join(dir, "memory.current")); err != nil {
+			return stats, fmt.Errorf("failed to read memory usage: %v", err)
+		}
+		stats.MemoryLimit, _ = readCgroupInt(filepath.Join(dir, "memory.max"))
+		usec, err := readCPUStatUsage(filepath.Join(dir, "cpu.stat"))
+		if err != nil {
+			return stats, fmt.Errorf("failed to read CPU usage: %v", err)
+		}
+		stats.CPUUsage = time.Duration(usec) * time.Microsecond
+		stats.OOMEvents, stats.OOMKills, _ = m.OOMEvents(containerID)
+		return stats, nil
+	}
+
+	memoryDir := m.path("memory", containerID)
+	var err error
+	if stats.MemoryUsage, err = readCgroupInt(filepath.Join(memoryDir, "memory.usage_in_bytes")); err != nil {
+		return stats, fmt.Errorf("failed to read memory usage: %v", err)
+	}
+	if limit, err := readCgroupInt(filepath.Join(memoryDir, "memory.limit_in_bytes")); err == nil && limit < unlimitedMemory {
+		stats.MemoryLimit = limit
+	}
+	nsec, err := readCgroupInt(filepath.Join(m.path("cpuacct", containerID), "cpuacct.usage"))
+	if err != nil {
+		return stats, fmt.Errorf("failed to read CPU usage: %v", err)
+	}
+	stats.CPUUsage = time.Duration(nsec)
+	stats.OOMEvents, stats.OOMKills, _ = m.OOMEvents(containerID)
+	return stats, nil
+}
+
+// Destroy removes the cgroups of a container. Every controller is tried
+// since the usable ones may have changed since the cgroups were created.
+func (m *CgroupManager) Destroy(containerID string) error {
+	dirs := []string{m.path("", containerID)}
+	if !m.v2 {
+		dirs = nil
+		for _, controller := range []string{"memory", "cpu", "cpuacct", "pids", "freezer"} {
+			dirs = append(dirs, m.path(controller, containerID))
+		}
+	}
+	var firstErr error
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove cgroup %s: %v", dir, err)
+		}
+	}
+	return firstErr
+}
+
+// containerCgroupPath returns the cgroup directory of a container for the
+// given v1 controller. On v2 the controller is ignored since all controllers
+// share a single directory.
+func (e *Engine) containerCgroupPath(controller, containerID string) string {
+	return e.newCgroupManager().path(controller, containerID)
+}
+
+// freezerStatePath returns the file controlling the freezer of a container
+// along with the values that freeze and thaw it.
+func (e *Engine) freezerStatePath(containerID string) (path, frozen, thawed string) {
+	return e.newCgroupManager().freezerState(containerID)
+}
+
+// pauseContainer suspends all processes in the container's cgroup.
+func (e *Engine) pauseContainer(containerID string) error {
+	return e.newCgroupManager().Freeze(containerID)
+}
+
+// unpauseContainer resumes all processes in the container's cgroup.
+func (e *Engine) unpauseContainer(containerID string) error {
+	return e.newCgroupManager().Thaw(containerID)
+}
+
+// isContainerPaused reports whether the container's cgroup is frozen.
+func (e *Engine) isContainerPaused(containerID string) bool {
+	return e.newCgroupManager().Frozen(containerID)
+}
+
+// containerProcessIDs lists the PIDs in a container's cgroup, falling back to
+// the recorded init PID when the container has no cgroup.
+func (e *Engine) containerProcessIDs(containerID string) ([]int, error) {
+	procsFile := filepath.Join(e.containerCgroupPath("memory", containerID), "cgroup.procs")
+	data, err := os.ReadFile(procsFile)
+	if err != nil {
+		pidData, pidErr := os.ReadFile(filepath.Join(e.Root, "containers", containerID, "pid"))
+		if pidErr != nil {
+			return nil, fmt.Errorf("failed to read processes of container %s: %v", containerID, err)
+		}
+		data = pidData
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID %q in cgroup.procs: %v", field, err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// defaultMemoryLimit is the memory limit of containers run with namespace
+// isolation.
+const defaultMemoryLimit = 100 * 1024 * 1024
+
+// cgroupLimits are the resource limits applied to a container's cgroup. Zero
+// leaves a resource unlimited.
+type cgroupLimits struct {
+	Memory int64
+	PIDs   int64
+	// OOMKillDisable turns the OOM killer off for the container.
+	OOMKillDisable bool
+}
+
+// cgroupSetup returns the hook that places a container's process in its
+// cgroups with limits applied once started, or nil when there are no limits
+// to apply. Limits whose controller is unavailable are skipped with a
+// warning.
+func (e *Engine) cgroupSetup(containerID string, limits cgroupLimits) func(pid int) error {
+	if limits == (cgroupLimits{}) {
+		return nil
+	}
+	return func(pid int) error {
+		m := e.newCgroupManager()
+		if len(m.dirs(containerID)) == 0 {
+			e.Logger.Warn("no cgroup controller available, limits not applied", "container", containerID)
+			return nil
+		}
+		if err := m.Create(containerID); err != nil {
+			return err
+		}
+		for _, limit := range []struct {
+			controller string
+			usable     bool
+			value      int64
+			set        func(string, int64) error
+		}{
+			{"memory", m.caps.Memory, limits.Memory, m.SetMemory},
+			{"pids", m.caps.PIDs, limits.PIDs, m.SetPids},
+		} {
+			if limit.value == 0 {
+				continue
+			}
+			if !limit.usable {
+				e.Logger.Warn("cgroup controller unavailable, limit not applied", "controller", limit.controller, "container", containerID)
+				continue
+			}
+			if err := limit.set(containerID, limit.value); err != nil {
+				return err
+			}
+		}
+		if limits.OOMKillDisable {
+			if err := m.SetOOMKillDisable(containerID); err != nil {
+				e.Logger.Warn("OOM killer not disabled", "container", containerID, "error", err)
+			}
+		}
+		return m.AddProcess(containerID, pid)
+	}
+}
@@ -0,0 +1,355 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCgroupTree creates a temporary cgroup hierarchy for a test. When v2 is
+// true the tree is marked as unified.
+func fakeCgroupTree(t *testing.T, v2 bool) string {
+	t.Helper()
+	root := t.TempDir()
+	if v2 {
+		if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory pids"), 0644); err != nil {
+			t.Fatalf("Failed to create cgroup.controllers: %v", err)
+		}
+	}
+	return root
+}
+
+// useFakeCgroupRoot points the cgroup root of e at a fake hierarchy.
+func useFakeCgroupRoot(t *testing.T, e *Engine, v2 bool) string {
+	t.Helper()
+	e.CgroupRoot = fakeCgroupTree(t, v2)
+	return e.CgroupRoot
+}
+
+// writeFakeFreezer creates the freezer state file for a container.
+func writeFakeFreezer(t *testing.T, e *Engine, containerID, state string) string {
+	t.Helper()
+	path, _, _ := e.freezerStatePath(containerID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create freezer directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(state), 0644); err != nil {
+		t.Fatalf("Failed to create freezer file: %v", err)
+	}
+	return path
+}
+
+// TestPauseUnpauseCgroupV1 verifies the freezer.state transitions on v1.
+func TestPauseUnpauseCgroupV1(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	containerID := "freezer-v1"
+	path := writeFakeFreezer(t, e, containerID, "THAWED")
+
+	if !strings.Contains(path, filepath.Join("freezer", "basic-docker", containerID)) {
+		t.Errorf("Unexpected v1 freezer path: %s", path)
+	}
+
+	if err := e.pauseContainer(containerID); err != nil {
+		t.Fatalf("pauseContainer failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "FROZEN" {
+		t.Errorf("Expected freezer state FROZEN, got %q", data)
+	}
+	if !e.isContainerPaused(containerID) {
+		t.Error("Expected container to be reported as paused")
+	}
+
+	if err := e.unpauseContainer(containerID); err != nil {
+		t.Fatalf("unpauseContainer failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "THAWED" {
+		t.Errorf("Expected freezer state THAWED, got %q", data)
+	}
+	if e.isContainerPaused(containerID) {
+		t.Error("Expected container to no longer be paused")
+	}
+}
+
+// TestPauseUnpauseCgroupV2 verifies the cgroup.freeze transitions on v2.
+func TestPauseUnpauseCgroupV2(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, true)
+	containerID := "freezer-v2"
+	path := writeFakeFreezer(t, e, containerID, "0")
+
+	if filepath.Base(path) != "cgroup.freeze" {
+		t.Errorf("Unexpected v2 freezer path: %s", path)
+	}
+
+	if err := e.pauseContainer(containerID); err != nil {
+		t.Fatalf("pauseContainer failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "1" {
+		t.Errorf("Expected cgroup.freeze 1, got %q", data)
+	}
+
+	if err := e.unpauseContainer(containerID); err != nil {
+		t.Fatalf("unpauseContainer failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "0" {
+		t.Errorf("Expected cgroup.freeze 0, got %q", data)
+	}
+}
+
+// TestPauseWithoutFreezer verifies a clear error when the cgroup is missing.
+func TestPauseWithoutFreezer(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	if err := e.pauseContainer("no-such-container"); err == nil {
+		t.Error("Expected an error pausing a container without a freezer cgroup")
+	}
+}
+
+// TestGetContainerStatusPaused verifies that a frozen container reports Paused.
+func TestGetContainerStatusPaused(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	containerID := "test-paused-container"
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("Failed to create container directory: %v", err)
+	}
+	defer os.RemoveAll(containerDir)
+
+	if err := os.WriteFile(filepath.Join(containerDir, "pid"), []byte(fmt.Sprintf("%d", os.Getpid())), 0644); err != nil {
+		t.Fatalf("Failed to create PID file: %v", err)
+	}
+
+	writeFakeFreezer(t, e, containerID, "FROZEN")
+	if status := e.getContainerStatus(containerID); status != "Paused" {
+		t.Errorf("Expected status 'Paused', got '%s'", status)
+	}
+
+	writeFakeFreezer(t, e, containerID, "THAWED")
+	if status := e.getContainerStatus(containerID); status != "Running" {
+		t.Errorf("Expected status 'Running', got '%s'", status)
+	}
+}
+
+// TestDetectCgroupCapabilities probes fake v1 and v2 trees that only provide
+// some of the controllers
+func TestDetectCgroupCapabilities(t *testing.T) {
+	root := fakeCgroupTree(t, false)
+	for _, hierarchy := range []string{"memory", "cpu,cpuacct", "freezer"} {
+		if err := os.MkdirAll(filepath.Join(root, hierarchy), 0755); err != nil {
+			t.Fatalf("Failed to create %s hierarchy: %v", hierarchy, err)
+		}
+	}
+	caps := detectCgroupCapabilities(root)
+	want := CgroupCapabilities{Memory: true, CPU: true, Freezer: true}
+	if caps != want {
+		t.Errorf("Expected v1 capabilities %+v, got %+v", want, caps)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pids")); !os.IsNotExist(err) {
+		t.Error("Expected detection not to create missing hierarchies")
+	}
+
+	caps = detectCgroupCapabilities(fakeCgroupTree(t, true))
+	want = CgroupCapabilities{Memory: true, CPU: true, PIDs: true, Freezer: true}
+	if caps != want {
+		t.Errorf("Expected v2 capabilities %+v, got %+v", want, caps)
+	}
+	if got := strings.Join(caps.controllers(), ","); got != "memory,cpu,pids,freezer" {
+		t.Errorf("Unexpected controller names %q", got)
+	}
+}
+
+// TestCgroupSetupSkipsUnavailableControllers verifies that only the
+// controllers detected as usable are configured on v1
+func TestCgroupSetupSkipsUnavailableControllers(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	e.Cgroups = CgroupCapabilities{Freezer: true}
+
+	if err := e.cgroupSetup("partial", cgroupLimits{Memory: 1024})(42); err != nil {
+		t.Fatalf("cgroup setup failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(e.containerCgroupPath("freezer", "partial"), "cgroup.procs")); err != nil || string(data) != "42" {
+		t.Errorf("Expected the process to join the freezer cgroup, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(e.containerCgroupPath("memory", "partial")); !os.IsNotExist(err) {
+		t.Errorf("Expected no memory cgroup without the memory controller, got %v", err)
+	}
+}
+
+// TestCgroupSetupPidsLimit verifies the pids limit lands in pids.max of the
+// pids hierarchy on v1 and of the container's single cgroup on v2
+func TestCgroupSetupPidsLimit(t *testing.T) {
+	e := newTestEngine(t)
+	e.Cgroups = CgroupCapabilities{Memory: true, PIDs: true}
+
+	for _, v2 := range []bool{false, true} {
+		root := useFakeCgroupRoot(t, e, v2)
+		if err := e.cgroupSetup("limited", cgroupLimits{Memory: 4096, PIDs: 64})(42); err != nil {
+			t.Fatalf("cgroup setup failed (v2=%v): %v", v2, err)
+		}
+
+		want := map[string]string{
+			"memory/basic-docker/limited/memory.limit_in_bytes": "4096",
+			"memory/basic-docker/limited/cgroup.procs":          "42",
+			"pids/basic-docker/limited/pids.max":                "64",
+			"pids/basic-docker/limited/cgroup.procs":            "42",
+		}
+		if v2 {
+			want = map[string]string{
+				"basic-docker/limited/memory.max":   "4096",
+				"basic-docker/limited/pids.max":     "64",
+				"basic-docker/limited/cgroup.procs": "42",
+			}
+		}
+		for path, value := range want {
+			if data, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(data) != value {
+				t.Errorf("Expected %s to hold %q (v2=%v), got %q, %v", path, value, v2, data, err)
+			}
+		}
+	}
+}
+
+// TestCgroupSetupOOMKillDisable verifies the OOM killer is turned off through
+// memory.oom_control on v1 and left alone on v2, which has no such setting
+func TestCgroupSetupOOMKillDisable(t *testing.T) {
+	e := newTestEngine(t)
+	e.Cgroups = CgroupCapabilities{Memory: true}
+
+	for _, v2 := range []bool{false, true} {
+		root := useFakeCgroupRoot(t, e, v2)
+		if err := e.cgroupSetup("no-oom", cgroupLimits{OOMKillDisable: true})(42); err != nil {
+			t.Fatalf("cgroup setup failed (v2=%v): %v", v2, err)
+		}
+		data, err := os.ReadFile(filepath.Join(root, "memory/basic-docker/no-oom/memory.oom_control"))
+		if v2 && !os.IsNotExist(err) {
+			t.Errorf("Expected no memory.oom_control on v2, got %q, %v", data, err)
+		}
+		if !v2 && (err != nil || string(data) != "1") {
+			t.Errorf("Expected memory.oom_control to hold 1, got %q, %v", data, err)
+		}
+	}
+}
+
+// newFakeCgroupManager returns a manager for a fake hierarchy with every
+// controller usable.
+func newFakeCgroupManager(t *testing.T, v2 bool) *CgroupManager {
+	t.Helper()
+	root := fakeCgroupTree(t, v2)
+	caps := CgroupCapabilities{Memory: true, CPU: true, IO: true, PIDs: true, Freezer: true}
+	return &CgroupManager{root: root, v2: v2, caps: caps}
+}
+
+// TestCgroupManager drives each method of the manager against fake v1 and
+// v2 hierarchies
+func TestCgroupManager(t *testing.T) {
+	for _, v2 := range []bool{false, true} {
+		m := newFakeCgroupManager(t, v2)
+		id := "managed"
+		read := func(controller, file string) string {
+			t.Helper()
+			data, err := os.ReadFile(filepath.Join(m.path(controller, id), file))
+			if err != nil {
+				t.Fatalf("Failed to read %s (v2=%v): %v", file, v2, err)
+			}
+			return string(data)
+		}
+
+		if err := m.Create(id); err != nil {
+			t.Fatalf("Create failed (v2=%v): %v", v2, err)
+		}
+		if err := m.SetMemory(id, 1<<20); err != nil {
+			t.Fatalf("SetMemory failed (v2=%v): %v", v2, err)
+		}
+		if err := m.SetCPU(id, 1.5); err != nil {
+			t.Fatalf("SetCPU failed (v2=%v): %v", v2, err)
+		}
+		if err := m.SetPids(id, 32); err != nil {
+			t.Fatalf("SetPids failed (v2=%v): %v", v2, err)
+		}
+		if err := m.AddProcess(id, 42); err != nil {
+			t.Fatalf("AddProcess failed (v2=%v): %v", v2, err)
+		}
+
+		if v2 {
+			for file, want := range map[string]string{"memory.max": "1048576", "cpu.max": "150000 100000", "pids.max": "32", "cgroup.procs": "42"} {
+				if got := read("", file); got != want {
+					t.Errorf("Expected %s to hold %q, got %q", file, want, got)
+				}
+			}
+		} else {
+			for _, f := range []struct{ controller, file, want string }{
+				{"memory", "memory.limit_in_bytes", "1048576"},
+				{"cpu", "cpu.cfs_quota_us", "150000"},
+				{"cpu", "cpu.cfs_period_us", "100000"},
+				{"pids", "pids.max", "32"},
+				{"memory", "cgroup.procs", "42"},
+				{"cpu", "cgroup.procs", "42"},
+				{"pids", "cgroup.procs", "42"},
+				{"freezer", "cgroup.procs", "42"},
+			} {
+				if got := read(f.controller, f.file); got != f.want {
+					t.Errorf("Expected %s/%s to hold %q, got %q", f.controller, f.file, f.want, got)
+				}
+			}
+		}
+
+		// The kernel creates the freezer file along with the cgroup
+		path, _, thawed := m.freezerState(id)
+		if err := os.WriteFile(path, []byte(thawed), 0644); err != nil {
+			t.Fatalf("Failed to create freezer file: %v", err)
+		}
+		if err := m.Freeze(id); err != nil || !m.Frozen(id) {
+			t.Errorf("Expected Freeze to freeze the cgroup (v2=%v): %v", v2, err)
+		}
+		if err := m.Thaw(id); err != nil || m.Frozen(id) {
+			t.Errorf("Expected Thaw to thaw the cgroup (v2=%v): %v", v2, err)
+		}
+
+		usageFiles := map[string]string{"memory/memory.usage_in_bytes": "2048", "cpuacct/cpuacct.usage": "3000000000"}
+		if v2 {
+			usageFiles = map[string]string{"memory.current": "2048", "cpu.stat": "usage_usec 3000000\n"}
+		}
+		for name, value := range usageFiles {
+			controller, file := filepath.Split(name)
+			dir := m.path(filepath.Clean(controller), id)
+			os.MkdirAll(dir, 0755)
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		stats, err := m.Usage(id)
+		if err != nil || stats.MemoryUsage != 2048 || stats.MemoryLimit != 1<<20 || stats.CPUUsage.Seconds() != 3 {
+			t.Errorf("Unexpected usage (v2=%v): %+v, %v", v2, stats, err)
+		}
+
+		if err := m.Destroy(id); err != nil {
+			t.Fatalf("Destroy failed (v2=%v): %v", v2, err)
+		}
+		for _, controller := range []string{"memory", "cpu", "cpuacct", "pids", "freezer"} {
+			if _, err := os.Stat(m.path(controller, id)); !os.IsNotExist(err) {
+				t.Errorf("Expected the %s cgroup to be removed (v2=%v)", controller, v2)
+			}
+		}
+	}
+}
+
+// TestCgroupManagerUnavailableController verifies limits are refused for
+// controllers that were not detected
+func TestCgroupManagerUnavailableController(t *testing.T) {
+	m := newFakeCgroupManager(t, true)
+	m.caps = CgroupCapabilities{Memory: true}
+	if err := m.Create("partial"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := m.SetPids("partial", 10); err == nil || !strings.Contains(err.Error(), "pids is not available") {
+		t.Errorf("Expected an unavailable controller error, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// commitIgnoreFile lists, in the root of a container's filesystem, patterns
+// of paths commit leaves out, like a .gitignore.
+const commitIgnoreFile = ".dockerignore"
+
+// excludePattern is a parsed gitignore-style pattern.
+type excludePattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// parseExcludePattern parses a pattern: "!" re-includes what earlier
+// patterns excluded, a trailing "/" matches directories only, "**" matches
+// any number of directories, and a pattern without an inner "/" matches at
+// any depth.
+func parseExcludePattern(pattern string) (excludePattern, error) {
+	var p excludePattern
+	original := pattern
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimLeft(pattern, "/")
+	if pattern == "" {
+		return p, fmt.Errorf("invalid exclude pattern %q", original)
+	}
+	if !anchored {
+		p.segments = []string{"**"}
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return p, fmt.Errorf("invalid exclude pattern %q: %v", original, err)
+		}
+		p.segments = append(p.segments, segment)
+	}
+	return p, nil
+}
+
+// matchSegments matches path segments against pattern segments.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// excludeMatcher decides which paths a list of patterns excludes. The last
+// matching pattern wins.
+type excludeMatcher []excludePattern
+
+// newExcludeMatcher parses patterns, skipping blank lines and "#" comments.
+func newExcludeMatcher(patterns []string) (excludeMatcher, error) {
+	var matcher excludeMatcher
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		p, err := parseExcludePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matcher = append(matcher, p)
+	}
+	return matcher, nil
+}
+
+// excluded reports whether relPath, relative to the root, is excluded.
+func (m excludeMatcher) excluded(relPath string, isDir bool) bool {
+	name := strings.Split(filepath.ToSlash(relPath), "/")
+	excluded := false
+	for _, p := range m {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, name) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// readIgnoreFile returns the lines of an ignore file, or none when it is
+// missing.
+func readIgnoreFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return lines, nil
+}
+
+// CommitContainer creates an image from the filesystem of a container, with
+// the config of the container's image. Paths matching the patterns of the
+// container's ignore file or of excludes are left out.
+func (e *Engine) CommitContainer(containerID, target string, excludes []string) (*Image, error) {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return nil, fmt.Errorf("container %s does not exist", containerID)
+	}
+	if err := validateImageRef(target); err != nil {
+		return nil, err
+	}
+	target = normalizeImageRef(target)
+	targetDir := e.imageStorePath(target)
+	if _, err := os.Stat(targetDir); err == nil {
+		return nil, fmt.Errorf("image %s already exists", target)
+	}
+
+	source := e.containerRootfs(containerID)
+	patterns, err := readIgnoreFile(filepath.Join(source, commitIgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := newExcludeMatcher(append(patterns, excludes...))
+	if err != nil {
+		return nil, err
+	}
+
+	rootfs := filepath.Join(targetDir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs: %v", err)
+	}
+	skip := func(relPath string, info os.FileInfo) bool {
+		return matcher.excluded(relPath, info.IsDir())
+	}
+	if err := e.copyDirFiltered(source, rootfs, skip); err != nil {
+		os.RemoveAll(targetDir)
+		return nil, fmt.Errorf("failed to copy container filesystem: %v", err)
+	}
+	imageConfig := filepath.Join(e.imageStorePath(config.Image), imageConfigFile)
+	if _, err := os.Stat(imageConfig); err == nil {
+		if err := copyFile(imageConfig, filepath.Join(targetDir, imageConfigFile)); err != nil {
+			os.RemoveAll(targetDir)
+			return nil, fmt.Errorf("failed to copy image config: %v", err)
+		}
+	}
+	if err := recordRootfsDigest(targetDir); err != nil {
+		e.Logger.Warn("failed to record rootfs digest", "image", target, "error", err)
+	}
+	image := &Image{Name: target, RootFS: rootfs, Layers: []string{"base"}}
+	e.recordImageMetadata(image)
+	return image, nil
+}
+
+// commitCommand implements "commit [--exclude pattern]... <container-id> <image>".
+func (e *Engine) commitCommand(args []string) {
+	fs := flag.NewFlagSet("commit", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var excludes []string
+	fs.Func("exclude", "leave out paths matching a gitignore-style pattern", func(value string) error {
+		excludes = append(excludes, value)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker commit [--exclude pattern]... <container-id> <image>")
+		os.Exit(1)
+	}
+	image, err := e.CommitContainer(e.resolveContainerID(fs.Arg(0)), fs.Arg(1), excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to commit container: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Container '%s' committed as image '%s'.\n", fs.Arg(0), image.Name)
+}
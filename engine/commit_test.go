@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExcludeMatcher(t *testing.T) {
+	matcher, err := newExcludeMatcher([]string{"# comment", "", "tmp/*", "*.log", "cache/", "!keep.log", "var/**/spool"})
+	if err != nil {
+		t.Fatalf("newExcludeMatcher failed: %v", err)
+	}
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"tmp", true, false},
+		{"tmp/a", false, true},
+		{"tmp/sub", true, true},
+		{"app/tmp/a", false, false},
+		{"app.log", false, true},
+		{"var/log/app.log", false, true},
+		{"keep.log", false, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"var/spool", true, true},
+		{"var/lib/mail/spool", true, true},
+	}
+	for _, c := range cases {
+		if got := matcher.excluded(c.path, c.isDir); got != c.want {
+			t.Errorf("excluded(%q, %v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+	for _, pattern := range []string{"!", "/", "[a"} {
+		if _, err := newExcludeMatcher([]string{pattern}); err == nil {
+			t.Errorf("Expected pattern %q to be rejected", pattern)
+		}
+	}
+}
+
+// TestCommitContainerExcludes commits a container excluding tmp/* and the
+// patterns of its ignore file
+func TestCommitContainerExcludes(t *testing.T) {
+	e := newTestEngine(t)
+	createTestContainer(t, e, &ContainerConfig{ID: "commit-test", Image: "base"})
+	rootfs := e.containerRootfs("commit-test")
+	files := map[string]string{
+		"app/main":       "main",
+		"app/tmp/data":   "data",
+		"tmp/a":          "a",
+		"tmp/sub/b":      "b",
+		"var/cache/x":    "x",
+		commitIgnoreFile: "var/cache/\n",
+	}
+	for path, content := range files {
+		target := filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("Failed to create parent of %s: %v", path, err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	image, err := e.CommitContainer("commit-test", "committed", []string{"tmp/*"})
+	if err != nil {
+		t.Fatalf("CommitContainer failed: %v", err)
+	}
+	for _, path := range []string{"tmp/a", "tmp/sub", "var/cache"} {
+		if _, err := os.Lstat(filepath.Join(image.RootFS, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be excluded, got %v", path, err)
+		}
+	}
+	for _, path := range []string{"tmp", "app/main", "app/tmp/data", commitIgnoreFile} {
+		if _, err := os.Lstat(filepath.Join(image.RootFS, path)); err != nil {
+			t.Errorf("Expected %s to be committed: %v", path, err)
+		}
+	}
+
+	if _, err := e.CommitContainer("commit-test", "committed", nil); err == nil {
+		t.Error("Expected committing onto an existing image to fail")
+	}
+}
@@ -0,0 +1,649 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const containerConfigFile = "config.json"
+
+// ContainerConfig is the persisted description of a container. It is stored
+// as config.json next to the container's rootfs.
+type ContainerConfig struct {
+	ID          string        `json:"id"`
+	Image       string        `json:"image"`
+	Command     string        `json:"command"`
+	Args        []string      `json:"args,omitempty"`
+	Created     time.Time     `json:"created"`
+	StartedAt   time.Time     `json:"startedAt,omitempty"`
+	FinishedAt  time.Time     `json:"finishedAt,omitempty"`
+	Ports       []PortMapping `json:"ports,omitempty"`
+	HealthCheck *HealthCheck  `json:"healthCheck,omitempty"`
+	Health      *HealthState  `json:"health,omitempty"`
+	// Network is the ID of the network the container joined at creation.
+	Network string `json:"network,omitempty"`
+	// Name is the optional name given with run --name.
+	Name string `json:"name,omitempty"`
+	// ReadOnly mounts the rootfs read-only while the container runs; Tmpfs
+	// lists the paths that stay writable as tmpfs mounts.
+	ReadOnly bool     `json:"readOnly,omitempty"`
+	Tmpfs    []string `json:"tmpfs,omitempty"`
+	// Volumes are bind-mounted while the container runs, each as
+	// source:target with a volume name or host path as source.
+	Volumes []string `json:"volumes,omitempty"`
+	// CapAdd and CapDrop adjust the capabilities of the container process.
+	CapAdd  []string `json:"capAdd,omitempty"`
+	CapDrop []string `json:"capDrop,omitempty"`
+	// Seccomp is the seccomp profile applied to the container process:
+	// "default" for the built-in one or the path of a profile file.
+	Seccomp string `json:"seccomp,omitempty"`
+	// UserNS is set when the container runs in its own user namespace.
+	UserNS *UserNamespaceMapping `json:"userns,omitempty"`
+	// PidsLimit caps the number of processes in the container when set.
+	PidsLimit int64 `json:"pidsLimit,omitempty"`
+	// Ulimits are the resource limits of the container process, each as
+	// name=soft:hard.
+	Ulimits []string `json:"ulimits,omitempty"`
+	// Memory and CPUs are the limits set by update, in bytes and CPUs.
+	Memory int64   `json:"memory,omitempty"`
+	CPUs   float64 `json:"cpus,omitempty"`
+	// Isolation is the resolved isolation mode, isolationNone or
+	// isolationNamespaces. Containers created before it existed have none.
+	Isolation string `json:"isolation,omitempty"`
+	// OpenStdin keeps the container's stdin open for attach when the daemon
+	// runs it.
+	OpenStdin bool `json:"openStdin,omitempty"`
+	// ImageDigest is the manifest digest of the image when it was pulled
+	// from a registry.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// OOMKillDisable turns the OOM killer off for the container. OOMKilled
+	// records that the OOM killer killed a process of its last run.
+	OOMKillDisable bool `json:"oomKillDisable,omitempty"`
+	OOMKilled      bool `json:"oomKilled,omitempty"`
+}
+
+// Isolation modes accepted by run --isolation.
+const (
+	isolationAuto       = "auto"
+	isolationNone       = "none"
+	isolationNamespaces = "namespaces"
+)
+
+// resolveIsolation returns the isolation a container runs with. Auto, also
+// used when nothing is requested, picks namespaces when the engine has the
+// privileges to create them.
+func resolveIsolation(requested string, privileged bool) (string, error) {
+	switch requested {
+	case "", isolationAuto:
+		if privileged {
+			return isolationNamespaces, nil
+		}
+		return isolationNone, nil
+	case isolationNamespaces:
+		if !privileged {
+			return "", fmt.Errorf("namespace isolation requested but this user cannot create namespaces; run as root or use --isolation none")
+		}
+		return isolationNamespaces, nil
+	case isolationNone:
+		return isolationNone, nil
+	}
+	return "", fmt.Errorf("invalid isolation %q: expected %s, %s or %s", requested, isolationAuto, isolationNone, isolationNamespaces)
+}
+
+// containerConfigMu serializes read-modify-write cycles on container configs
+// within a single engine process.
+var containerConfigMu sync.Mutex
+
+// containerConfigPath returns the location of a container's config.json.
+func (e *Engine) containerConfigPath(containerID string) string {
+	return filepath.Join(e.Root, "containers", containerID, containerConfigFile)
+}
+
+// saveContainerConfig writes the config of a container to disk. The config
+// is written to a temporary file renamed into place, so readers never see a
+// partly written config while the container exits.
+func (e *Engine) saveContainerConfig(config *ContainerConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal container config: %v", err)
+	}
+	path := e.containerConfigPath(config.ID)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write container config: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write container config: %v", err)
+	}
+	return nil
+}
+
+// loadContainerConfig reads the config of a container from disk.
+func (e *Engine) loadContainerConfig(containerID string) (*ContainerConfig, error) {
+	data, err := os.ReadFile(e.containerConfigPath(containerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container config: %v", err)
+	}
+	var config ContainerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse container config: %v", err)
+	}
+	return &config, nil
+}
+
+// updateContainerConfig loads a container's config, applies update and writes
+// the result back.
+func (e *Engine) updateContainerConfig(containerID string, update func(*ContainerConfig)) error {
+	containerConfigMu.Lock()
+	defer containerConfigMu.Unlock()
+
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return err
+	}
+	update(config)
+	return e.saveContainerConfig(config)
+}
+
+// ContainerInspect is the output of the inspect command.
+type ContainerInspect struct {
+	*ContainerConfig
+	Status string `json:"status"`
+	// OOMKills counts the processes of a running container the OOM killer
+	// killed so far.
+	OOMKills int64 `json:"oomKills,omitempty"`
+}
+
+// inspectContainer returns the stored config of a container together with its
+// current status.
+func (e *Engine) inspectContainer(containerID string) (*ContainerInspect, error) {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return nil, err
+	}
+	info := &ContainerInspect{ContainerConfig: config, Status: e.getContainerStatus(containerID)}
+	if isContainerActive(info.Status) {
+		_, info.OOMKills, _ = e.newCgroupManager().OOMEvents(containerID)
+	}
+	return info, nil
+}
+
+const containerLogFile = "container.log"
+
+// ContainerIO is the standard streams of a container's main process.
+type ContainerIO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// containerExitCode converts a process state into a shell-style exit code:
+// the exit status, or 128+signal when the process was killed.
+func containerExitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// newContainerID returns a fresh container ID. The random suffix keeps IDs
+// unique when several containers are created within the clock's resolution.
+func newContainerID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("container-%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
+
+// validateContainerID rejects IDs that are not a single path element, so an
+// ID can never address a directory outside the containers directory.
+func validateContainerID(containerID string) error {
+	if !validContainerName.MatchString(containerID) {
+		return fmt.Errorf("invalid container ID %q", containerID)
+	}
+	return nil
+}
+
+// createContainerDir creates the directory of a new container. It fails
+// rather than reuse an existing directory, so an ID collision can never
+// overwrite another container.
+func (e *Engine) createContainerDir(containerID string) error {
+	containersDir := filepath.Join(e.Root, "containers")
+	if err := os.MkdirAll(containersDir, 0755); err != nil {
+		return fmt.Errorf("failed to create containers directory: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(containersDir, containerID), 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("container %s already exists", containerID)
+		}
+		return fmt.Errorf("failed to create container '%s': %v", containerID, err)
+	}
+	return nil
+}
+
+// shellMetacharacters are the characters that make a lone command argument
+// a shell command line rather than the name of a program.
+const shellMetacharacters = " \t\n|&;()<>$`*?"
+
+// shellForm reports whether the command given to run is a shell command
+// line: always with --shell, and without an entrypoint when it is a single
+// argument containing shell metacharacters.
+func shellForm(opts *RunOptions, entrypoint []string) bool {
+	if opts.Shell {
+		return true
+	}
+	return len(entrypoint) == 0 && len(opts.Args) == 0 && strings.ContainsAny(opts.Command, shellMetacharacters)
+}
+
+// containerArgv returns the command line of a container: the entrypoint,
+// from --entrypoint or else the image config, followed by the command given
+// to run or else the image's default command. As in Docker, overriding the
+// entrypoint also drops the image's default command. A shell-form command
+// is run with /bin/sh -c.
+func containerArgv(image *ImageConfig, opts *RunOptions) ([]string, error) {
+	entrypoint, command := image.Config.Entrypoint, image.Config.Cmd
+	if opts.Entrypoint != nil {
+		entrypoint, command = nil, nil
+		if *opts.Entrypoint != "" {
+			entrypoint = []string{*opts.Entrypoint}
+		}
+	}
+	if opts.Command != "" {
+		command = append([]string{opts.Command}, opts.Args...)
+		if shellForm(opts, entrypoint) {
+			command = []string{"/bin/sh", "-c", strings.Join(command, " ")}
+		}
+	}
+	argv := append(append([]string{}, entrypoint...), command...)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command specified for image %s", opts.Image)
+	}
+	return argv, nil
+}
+
+// prepareContainer resolves the image of a run request, pulling it if needed,
+// and creates the container's rootfs and config.
+func (e *Engine) prepareContainer(opts *RunOptions) (*ContainerConfig, error) {
+	// A pinned digest may name an image pulled by tag
+	imageName := e.resolveImageDigestRef(opts.Image)
+	imagePath := filepath.Join(e.imageStorePath(imageName), "rootfs")
+	isolation, err := resolveIsolation(opts.Isolation, e.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+	// Only warn when isolation was left to the engine, not asked to be none
+	if isolation == isolationNone && (opts.Isolation == "" || opts.Isolation == isolationAuto) {
+		e.Logger.Warn("namespace isolation is not permitted, executing without isolation")
+	}
+
+	// Check if the image exists locally
+	progress := func(format string, args ...any) {
+		if !opts.Quiet {
+			fmt.Fprintf(os.Stderr, format, args...)
+		}
+	}
+	if _, err := os.Stat(imagePath); err == nil {
+		progress("Using locally loaded image '%s'.\n", imageName)
+	} else {
+		var pullOpts PullOptions
+		if opts.Platform != "" {
+			if pullOpts.Platform, err = parsePlatform(opts.Platform); err != nil {
+				return nil, err
+			}
+		}
+		progress("Fetching image '%s' from registry...\n", imageName)
+		image, err := e.pullImage(imageName, pullOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch image '%s': %v", imageName, err)
+		}
+		progress("Image '%s' fetched successfully.\n", imageName)
+		imagePath = image.RootFS
+	}
+
+	// Resolve the network first so that a typo does not leave a container behind
+	var networkID string
+	if opts.Network != "" {
+		// Another invocation may have created the network since startup
+		e.loadNetworks()
+		i, err := e.findNetwork(opts.Network)
+		if err != nil {
+			return nil, err
+		}
+		networkID = e.networks[i].ID
+	}
+
+	// Create rootfs for this container
+	containerID := newContainerID()
+	if opts.Name != "" {
+		if err := e.reserveContainerName(opts.Name, containerID); err != nil {
+			return nil, err
+		}
+	}
+	rootfs := e.containerRootfs(containerID)
+	if err := e.createContainerDir(containerID); err != nil {
+		if opts.Name != "" {
+			e.releaseContainerName(containerID)
+		}
+		return nil, err
+	}
+	// Undo the partly created container when a later step fails
+	created := false
+	defer func() {
+		if !created {
+			e.rollbackContainer(containerID, networkID)
+		}
+	}()
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs for container '%s': %v", containerID, err)
+	}
+	if err := copyRootfs(e, imagePath, rootfs); err != nil {
+		return nil, fmt.Errorf("failed to copy rootfs for container '%s': %v", containerID, err)
+	}
+	if err := addHostsEntries(rootfs, opts.AddHosts); err != nil {
+		return nil, fmt.Errorf("failed to add hosts for container '%s': %v", containerID, err)
+	}
+	if err := writeResolvConf(rootfs, opts.DNS, opts.DNSSearch); err != nil {
+		return nil, fmt.Errorf("failed to configure DNS for container '%s': %v", containerID, err)
+	}
+	var userNS *UserNamespaceMapping
+	if opts.UserNS {
+		userNS = defaultUserNamespaceMapping()
+		if err := userNS.shiftOwnership(rootfs); err != nil {
+			return nil, fmt.Errorf("failed to remap rootfs ownership for container '%s': %v", containerID, err)
+		}
+	}
+
+	config := &ContainerConfig{
+		ID:          containerID,
+		Image:       imageName,
+		Command:     opts.Command,
+		Args:        opts.Args,
+		Created:     time.Now(),
+		Network:     networkID,
+		Name:        opts.Name,
+		ReadOnly:    opts.ReadOnly,
+		Tmpfs:       opts.Tmpfs,
+		CapAdd:      opts.CapAdd,
+		CapDrop:     opts.CapDrop,
+		Seccomp:     opts.Seccomp,
+		UserNS:      userNS,
+		Isolation:   isolation,
+		PidsLimit:   opts.PidsLimit,
+		OpenStdin:   opts.Interactive,
+		ImageDigest: e.loadImageDigest(imageName),
+
+		OOMKillDisable: opts.OOMKillDisable,
+	}
+	ulimits, err := parseUlimits(opts.Ulimits)
+	if err != nil {
+		return nil, err
+	}
+	for _, ulimit := range ulimits {
+		config.Ulimits = append(config.Ulimits, ulimit.String())
+	}
+	volumes, err := parseVolumeSpecs(opts.Volumes)
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range volumes {
+		if volume.Named() {
+			if err := e.CreateVolume(volume.Source); err != nil {
+				return nil, err
+			}
+		} else if info, err := os.Stat(volume.Source); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("volume source %s is not a directory", volume.Source)
+		}
+		config.Volumes = append(config.Volumes, volume.String())
+	}
+	if opts.OOMKillDisable {
+		e.Logger.Warn("disabling the OOM killer can hang the host when the container runs out of memory", "container", containerID)
+	}
+	if opts.HealthCmd != "" {
+		interval := opts.HealthInterval
+		if interval <= 0 {
+			interval = defaultHealthInterval
+		}
+		config.HealthCheck = &HealthCheck{Command: opts.HealthCmd, Interval: interval}
+	}
+
+	imageConfig, err := e.loadImageConfig(imageName)
+	if err != nil {
+		return nil, err
+	}
+	argv, err := containerArgv(imageConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+	config.Command, config.Args = argv[0], argv[1:]
+	config.Ports, err = e.resolvePortMappings(opts.Publish, opts.PublishAll, imageConfig.Config.ExposedPorts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.saveContainerConfig(config); err != nil {
+		return nil, err
+	}
+	e.emitEvent(eventCreate, containerID, map[string]string{"image": imageName})
+
+	if networkID != "" {
+		ip, err := e.connectContainer(networkID, containerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach container %s to network %s: %v", containerID, opts.Network, err)
+		}
+		e.Logger.Debug("container attached to network", "container", containerID, "network", networkID, "ip", ip)
+	}
+	created = true
+	return config, nil
+}
+
+// copyRootfs copies the image rootfs of a new container. Tests replace it.
+var copyRootfs = (*Engine).copyDir
+
+// rollbackContainer removes what prepareContainer created for a container
+// before failing: its network attachment, cgroups, directory and name.
+func (e *Engine) rollbackContainer(containerID, networkID string) {
+	e.Logger.Debug("rolling back container creation", "container", containerID)
+	if networkID != "" {
+		if err := e.disconnectContainer(networkID, containerID); err != nil {
+			e.Logger.Debug("container was not attached to network", "container", containerID, "network", networkID, "error", err)
+		}
+	}
+	if err := e.newCgroupManager().Destroy(containerID); err != nil {
+		e.Logger.Warn("failed to remove cgroups", "container", containerID, "error", err)
+	}
+	if err := os.RemoveAll(filepath.Join(e.Root, "containers", containerID)); err != nil {
+		e.Logger.Warn("failed to remove container directory", "container", containerID, "error", err)
+	}
+	if err := e.releaseContainerName(containerID); err != nil {
+		e.Logger.Warn("failed to release container name", "container", containerID, "error", err)
+	}
+}
+
+// startContainer runs a prepared container's main process until it exits,
+// publishing its ports and monitoring its health meanwhile.
+func (e *Engine) startContainer(config *ContainerConfig, stdio ContainerIO) error {
+	config.StartedAt = time.Now()
+	config.FinishedAt = time.Time{}
+	config.OOMKilled = false
+	if err := e.updateContainerConfig(config.ID, func(c *ContainerConfig) {
+		c.StartedAt, c.FinishedAt, c.OOMKilled = config.StartedAt, time.Time{}, false
+	}); err != nil {
+		return err
+	}
+
+	if len(config.Ports) > 0 {
+		// Without a network namespace the container shares the host network
+		forwarder, err := e.startPortForwarding(config.Ports, "127.0.0.1")
+		if err != nil {
+			return err
+		}
+		defer forwarder.Close()
+		for _, mapping := range config.Ports {
+			fmt.Fprintf(os.Stderr, "Publishing %s\n", mapping)
+		}
+	}
+
+	if config.HealthCheck != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go e.monitorContainerHealth(config.ID, config.HealthCheck, stop)
+	}
+
+	if config.ReadOnly || len(config.Tmpfs) > 0 || len(config.Volumes) > 0 {
+		cleanup, err := e.setupRootfsMounts(config)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	// Only containers with namespace isolation are chrooted into their rootfs.
+	// A user namespace implies namespace isolation.
+	namespaced := config.UserNS != nil || config.Isolation == isolationNamespaces
+	rootfs := ""
+	if namespaced {
+		rootfs = e.containerRootfs(config.ID)
+	}
+	command, args, chroot, err := e.containerCommandLine(config, rootfs)
+	if err != nil {
+		return err
+	}
+
+	// Execute the command in the container
+	limits := cgroupLimits{Memory: config.Memory, PIDs: config.PidsLimit, OOMKillDisable: config.OOMKillDisable}
+	if namespaced {
+		return e.runWithNamespaces(config.ID, chroot, config.UserNS, command, args, limits, stdio)
+	}
+	return e.runWithoutNamespaces(config.ID, e.containerRootfs(config.ID), command, args, limits, stdio)
+}
+
+// containerRootfs returns the root filesystem directory of a container.
+func (e *Engine) containerRootfs(containerID string) string {
+	return filepath.Join(e.Root, "containers", containerID, "rootfs")
+}
+
+// containerLogPath returns the file capturing a container's output.
+func (e *Engine) containerLogPath(containerID string) string {
+	return filepath.Join(e.Root, "containers", containerID, containerLogFile)
+}
+
+// openContainerLog opens a container's log file for appending.
+func (e *Engine) openContainerLog(containerID string) (*os.File, error) {
+	file, err := os.OpenFile(e.containerLogPath(containerID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open container log: %v", err)
+	}
+	return file, nil
+}
+
+// stopContainer sends SIGTERM to a container's main process and SIGKILL if it
+// has not exited within timeout.
+func (e *Engine) stopContainer(containerID string, timeout time.Duration) error {
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	status := e.getContainerStatus(containerID)
+	if !isContainerActive(status) {
+		return nil
+	}
+
+	pidData, err := os.ReadFile(filepath.Join(e.Root, "containers", containerID, "pid"))
+	if err != nil {
+		return fmt.Errorf("failed to read PID file for container %s: %v", containerID, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil {
+		return fmt.Errorf("invalid PID file for container %s: %v", containerID, err)
+	}
+
+	// A frozen process cannot handle SIGTERM
+	if status == "Paused" {
+		if err := e.unpauseContainer(containerID); err != nil {
+			return err
+		}
+	}
+
+	e.emitEvent(eventStop, containerID, map[string]string{"signal": syscall.SIGTERM.String()})
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to signal container %s: %v", containerID, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !isContainerActive(e.getContainerStatus(containerID)) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	e.Logger.Warn("container did not stop in time, killing it", "container", containerID, "timeout", timeout)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to kill container %s: %v", containerID, err)
+	}
+	return nil
+}
+
+// ContainerSummary is one row of the container list.
+type ContainerSummary struct {
+	ID         string        `json:"id"`
+	Image      string        `json:"image,omitempty"`
+	Command    string        `json:"command"`
+	Created    time.Time     `json:"created,omitempty"`
+	StartedAt  time.Time     `json:"startedAt,omitempty"`
+	FinishedAt time.Time     `json:"finishedAt,omitempty"`
+	Status     string        `json:"status"`
+	Health     string        `json:"health,omitempty"`
+	Ports      []PortMapping `json:"ports,omitempty"`
+	Name       string        `json:"name,omitempty"`
+}
+
+// listContainerSummaries returns all containers with their current status.
+func (e *Engine) listContainerSummaries() ([]ContainerSummary, error) {
+	containerDir := filepath.Join(e.Root, "containers")
+	entries, err := os.ReadDir(containerDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers: %v", err)
+	}
+
+	var summaries []ContainerSummary
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		summary := ContainerSummary{
+			ID:      entry.Name(),
+			Command: "N/A",
+			Status:  e.getContainerStatus(entry.Name()),
+			Health:  e.containerHealthStatus(entry.Name()),
+		}
+		if config, err := e.loadContainerConfig(entry.Name()); err == nil {
+			summary.Image = config.Image
+			summary.Command = strings.TrimSpace(config.Command + " " + strings.Join(config.Args, " "))
+			summary.Created = config.Created
+			summary.StartedAt = config.StartedAt
+			summary.FinishedAt = config.FinishedAt
+			summary.Ports = config.Ports
+			summary.Name = config.Name
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// containerInitCommand is the hidden subcommand the engine re-executes itself
+// with to restrict a container process before exec'ing its command.
+const containerInitCommand = "container-init"
+
+// formatCapabilities encodes capability numbers for the container-init
+// command line.
+func formatCapabilities(caps []int) string {
+	parts := make([]string, len(caps))
+	for i, number := range caps {
+		parts[i] = strconv.Itoa(number)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseCapabilityList decodes the output of formatCapabilities.
+func parseCapabilityList(list string) ([]int, error) {
+	var caps []int
+	if list == "" {
+		return caps, nil
+	}
+	for _, field := range strings.Split(list, ",") {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capability %q", field)
+		}
+		caps = append(caps, number)
+	}
+	return caps, nil
+}
+
+// engineExecutable returns the engine binary container-init is run from.
+// Tests replace it.
+var engineExecutable = os.Executable
+
+// containerCommandLine returns the command to start for a container and the
+// directory to chroot it into, rootfs or "" for none. When the container
+// restricts capabilities, syscalls or resources, its command runs through the
+// engine's container-init subcommand. The engine binary only exists on the
+// host, so container-init is started outside rootfs and chroots itself. The
+// seccomp profile is compiled here, where its path refers to the host.
+func (e *Engine) containerCommandLine(config *ContainerConfig, rootfs string) (string, []string, string, error) {
+	restrictCaps := len(config.CapAdd) > 0 || len(config.CapDrop) > 0
+	if !restrictCaps && config.Seccomp == "" && len(config.Ulimits) == 0 {
+		return config.Command, config.Args, rootfs, nil
+	}
+
+	self, err := engineExecutable()
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to locate the engine binary: %v", err)
+	}
+	args := []string{containerInitCommand}
+	if rootfs != "" {
+		args = append(args, "--rootfs="+rootfs)
+	}
+	if restrictCaps {
+		caps, err := resolveCapabilities(config.CapAdd, config.CapDrop)
+		if err != nil {
+			return "", nil, "", err
+		}
+		args = append(args, "--caps="+formatCapabilities(caps))
+	}
+	if config.Seccomp != "" {
+		filter, err := e.seccompFilter(config.Seccomp)
+		if err != nil {
+			return "", nil, "", err
+		}
+		args = append(args, "--seccomp-filter="+encodeSeccompFilter(filter))
+	}
+	for _, ulimit := range config.Ulimits {
+		args = append(args, "--ulimit="+ulimit)
+	}
+	args = append(args, "--", config.Command)
+	return self, append(args, config.Args...), "", nil
+}
+
+// containerInit implements "container-init [--rootfs dir] [--caps list]
+// [--seccomp-filter filter] [--ulimit name=soft:hard]... -- <command>
+// [args...]":
+// it sets the resource limits, chroots into rootfs, restricts the
+// capabilities of the process, installs the seccomp filter and replaces
+// itself with the command. A filter the kernel refuses is reported to logger
+// and the command runs without it.
+func containerInit(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet(containerInitCommand, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	rootfs := fs.String("rootfs", "", "directory to chroot into")
+	capList := fs.String("caps", "", "comma-separated capability numbers to keep")
+	seccomp := fs.String("seccomp-filter", "", "compiled seccomp filter to install, as encoded by encodeSeccompFilter")
+	var ulimitValues []string
+	fs.Var((*stringList)(&ulimitValues), "ulimit", "resource limit to set, name=soft:hard")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: %s [--rootfs dir] [--caps list] [--seccomp-filter filter] [--ulimit name=soft:hard]... -- <command> [args...]", containerInitCommand)
+	}
+	restrictCaps := false
+	fs.Visit(func(f *flag.Flag) { restrictCaps = restrictCaps || f.Name == "caps" })
+
+	caps, err := parseCapabilityList(*capList)
+	if err != nil {
+		return err
+	}
+	ulimits, err := parseUlimits(ulimitValues)
+	if err != nil {
+		return err
+	}
+	var filter []syscall.SockFilter
+	if *seccomp != "" {
+		if filter, err = decodeSeccompFilter(*seccomp); err != nil {
+			return err
+		}
+	}
+
+	// Raising a hard limit needs CAP_SYS_RESOURCE, which may be dropped next
+	if err := setUlimits(ulimits); err != nil {
+		return err
+	}
+	if *rootfs != "" {
+		if err := syscall.Chroot(*rootfs); err != nil {
+			return fmt.Errorf("failed to chroot into %s: %v", *rootfs, err)
+		}
+		if err := os.Chdir("/"); err != nil {
+			return fmt.Errorf("failed to change to the container root: %v", err)
+		}
+	}
+	path, err := exec.LookPath(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %v", fs.Arg(0), err)
+	}
+
+	// Capabilities and seccomp filters are per thread, so they must be set
+	// on the thread that calls exec. The filter comes last since it may deny
+	// the syscalls changing capabilities.
+	runtime.LockOSThread()
+	if restrictCaps {
+		if err := restrictCapabilities(caps, logger); err != nil {
+			return err
+		}
+	}
+	if filter != nil {
+		if err := installSeccompFilter(filter); err != nil {
+			logger.Warn("running without seccomp", "error", err)
+		}
+	}
+	return syscall.Exec(path, fs.Args(), os.Environ())
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestContainerInitHelperProcess stands in for the engine binary: the test
+// binary has no main, so container-init is dispatched here instead.
+func TestContainerInitHelperProcess(t *testing.T) {
+	if os.Getenv("BASIC_DOCKER_CONTAINER_INIT") != "1" {
+		return
+	}
+	for i, arg := range os.Args {
+		if arg == containerInitCommand {
+			err := containerInit(os.Args[i+1:], slog.Default())
+			fmt.Fprintf(os.Stderr, "containerInit failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(1)
+}
+
+// TestChrootedHelperProcess is the container command of
+// TestNamespacedContainerInit. It reports what it can see and do inside the
+// container, one key=value per line.
+func TestChrootedHelperProcess(t *testing.T) {
+	if os.Getenv("BASIC_DOCKER_CHROOTED") != "1" {
+		return
+	}
+	_, err := os.Stat("/chroot-marker")
+	fmt.Printf("chrooted=%t\n", err == nil)
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err == nil {
+		syscall.Close(fd)
+	}
+	fmt.Printf("rawsocket=%t\n", err == nil)
+	fmt.Printf("mkdir=%t\n", os.Mkdir("/created", 0755) == nil)
+	var core syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &core); err == nil {
+		fmt.Printf("core=%d:%d\n", core.Cur, core.Max)
+	}
+	os.Exit(0)
+}
+
+// useEngineWrapper makes container-init run the test binary through a
+// script, as the engine binary would be run.
+func useEngineWrapper(t *testing.T) {
+	t.Helper()
+	wrapper := filepath.Join(t.TempDir(), "basic-docker")
+	script := fmt.Sprintf("#!/bin/sh\nBASIC_DOCKER_CONTAINER_INIT=1 exec %s -test.run='^TestContainerInitHelperProcess$' -- \"$@\"\n", os.Args[0])
+	if err := os.WriteFile(wrapper, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write engine wrapper: %v", err)
+	}
+	old := engineExecutable
+	engineExecutable = func() (string, error) { return wrapper, nil }
+	t.Cleanup(func() { engineExecutable = old })
+}
+
+// copyWithLibraries copies a binary and the shared libraries ldd reports for
+// it to the same paths under rootfs.
+func copyWithLibraries(t *testing.T, rootfs, binary, dst string) {
+	t.Helper()
+	files := map[string]string{binary: dst}
+	// ldd fails for static binaries, which need no libraries
+	if out, err := exec.Command("ldd", binary).Output(); err == nil {
+		for _, field := range strings.Fields(string(out)) {
+			if strings.HasPrefix(field, "/") {
+				files[field] = field
+			}
+		}
+	}
+	for src, dst := range files {
+		if err := os.MkdirAll(filepath.Join(rootfs, filepath.Dir(dst)), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", dst, err)
+		}
+		if err := copyFile(src, filepath.Join(rootfs, dst)); err != nil {
+			t.Fatalf("Failed to copy %s: %v", src, err)
+		}
+		if err := os.Chmod(filepath.Join(rootfs, dst), 0755); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", dst, err)
+		}
+	}
+}
+
+// runNamespacedTestContainer runs the chrooted helper as a container with
+// namespace isolation and returns what it reported.
+func runNamespacedTestContainer(t *testing.T, e *Engine, config *ContainerConfig) map[string]string {
+	t.Helper()
+	if os.Geteuid() != 0 || !e.Namespaces {
+		t.Skip("namespace isolation requires root")
+	}
+	useFakeCgroupRoot(t, e, false)
+	useEngineWrapper(t)
+	t.Setenv("BASIC_DOCKER_CHROOTED", "1")
+
+	config.Command = "/engine.test"
+	config.Args = []string{"-test.run=^TestChrootedHelperProcess$"}
+	config.Isolation = isolationNamespaces
+	createTestContainer(t, e, config)
+	rootfs := e.containerRootfs(config.ID)
+	copyWithLibraries(t, rootfs, os.Args[0], config.Command)
+	if err := os.WriteFile(filepath.Join(rootfs, "chroot-marker"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+	if config.UserNS != nil {
+		// Remapped root must be able to reach the rootfs through the test's
+		// private temporary directories and own its files
+		for _, dir := range []string{filepath.Dir(e.Root), e.Root} {
+			if err := os.Chmod(dir, 0755); err != nil {
+				t.Fatalf("Failed to open up %s: %v", dir, err)
+			}
+		}
+		if err := config.UserNS.shiftOwnership(rootfs); err != nil {
+			t.Fatalf("shiftOwnership failed: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := e.startContainer(config, ContainerIO{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		t.Fatalf("startContainer failed: %v: %s", err, stderr.String())
+	}
+	report := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			report[key] = value
+		}
+	}
+	return report
+}
+
+// TestNamespacedContainerInit verifies that container-init is started from
+// the host and chroots into the rootfs of a container with namespace
+// isolation, so that dropped capabilities apply inside it.
+func TestNamespacedContainerInit(t *testing.T) {
+	e := newTestEngine(t)
+	report := runNamespacedTestContainer(t, e, &ContainerConfig{ID: "test-ns-init", CapDrop: []string{"NET_RAW"}})
+	if report["chrooted"] != "true" {
+		t.Errorf("Expected the command to run inside the rootfs, got %v", report)
+	}
+	if report["rawsocket"] != "false" {
+		t.Errorf("Expected CAP_NET_RAW to be dropped, got %v", report)
+	}
+	if report["mkdir"] != "true" {
+		t.Errorf("Expected mkdir to be allowed without a seccomp profile, got %v", report)
+	}
+}
+
+// TestNamespacedSeccompProfile verifies that a seccomp profile is read from
+// the host, not the container rootfs, and enforced inside the container.
+func TestNamespacedSeccompProfile(t *testing.T) {
+	e := newTestEngine(t)
+	if syscallNumbers == nil {
+		t.Skip("seccomp is not supported on this architecture")
+	}
+	profile := writeSeccompProfile(t, `{
+		"defaultAction": "SCMP_ACT_ALLOW",
+		"syscalls": [{"names": ["mkdir", "mkdirat"], "action": "SCMP_ACT_ERRNO"}]
+	}`)
+	report := runNamespacedTestContainer(t, e, &ContainerConfig{ID: "test-ns-seccomp", Seccomp: profile})
+	if _, err := os.Stat(filepath.Join(e.containerRootfs("test-ns-seccomp"), "created")); err == nil {
+		t.Error("Expected no directory to be created in the rootfs")
+	}
+	if report["chrooted"] != "true" || report["mkdir"] != "false" {
+		t.Errorf("Expected mkdir to be denied inside the rootfs, got %v", report)
+	}
+}
+
+// TestNamespacedUlimit verifies that --ulimit applies inside a container with
+// namespace isolation. The core limit is checked since Go programs raise
+// their own nofile limit.
+func TestNamespacedUlimit(t *testing.T) {
+	e := newTestEngine(t)
+	report := runNamespacedTestContainer(t, e, &ContainerConfig{ID: "test-ns-ulimit", Ulimits: []string{"core=1024:2048"}})
+	if report["chrooted"] != "true" || report["core"] != "1024:2048" {
+		t.Errorf("Expected the core limit inside the rootfs, got %v", report)
+	}
+}
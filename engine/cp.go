@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinkDepth bounds symlink resolution inside a container rootfs.
+const maxSymlinkDepth = 255
+
+// parseCopyArg splits a cp argument of the form container:path. Arguments
+// without a container prefix are host paths; a prefix containing a slash,
+// as in ./a:b, is part of a host path.
+func parseCopyArg(arg string) (containerID, path string) {
+	if prefix, rest, ok := strings.Cut(arg, ":"); ok && prefix != "" && !strings.Contains(prefix, "/") {
+		return prefix, rest
+	}
+	return "", arg
+}
+
+// resolveInRoot returns the host path of path as seen from inside root.
+// Symlinks are resolved as if root were the filesystem root, so neither ".."
+// nor a symlink can lead outside of it. Components that do not exist yet are
+// joined as is.
+func resolveInRoot(root, path string) (string, error) {
+	resolved := ""
+	pending := strings.Split(filepath.Clean("/"+path), "/")
+	links := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+
+		candidate := resolved + "/" + part
+		info, err := os.Lstat(filepath.Join(root, candidate))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+
+		links++
+		if links > maxSymlinkDepth {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, candidate))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = ""
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// containerPathOnHost validates that a container exists and returns the host
+// path of path inside its rootfs.
+func (e *Engine) containerPathOnHost(containerID, path string) (string, error) {
+	if containerID == "" || strings.ContainsAny(containerID, `/\`) || containerID == "." || containerID == ".." {
+		return "", fmt.Errorf("invalid container ID %q", containerID)
+	}
+	rootfs := e.containerRootfs(containerID)
+	if info, err := os.Stat(rootfs); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("container %s does not exist", containerID)
+	}
+	return resolveInRoot(rootfs, path)
+}
+
+// copyPath copies a file or directory tree from src to dst following cp
+// semantics: when dst is an existing directory, src is copied into it.
+func (e *Engine) copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", src, err)
+	}
+	if dstInfo, err := os.Stat(dst); err == nil && dstInfo.IsDir() {
+		dst = filepath.Join(dst, filepath.Base(src))
+	} else if _, err := os.Stat(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("destination directory %s does not exist", filepath.Dir(dst))
+	}
+
+	switch {
+	case info.IsDir():
+		if err := removeSymlink(dst); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dst, err)
+		}
+		err = e.copyDir(src, dst)
+	case info.Mode()&os.ModeSymlink != 0:
+		var link string
+		if link, err = os.Readlink(src); err == nil {
+			os.Remove(dst)
+			err = os.Symlink(link, dst)
+		}
+	default:
+		err = copyFile(src, dst)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %v", src, err)
+	}
+	return nil
+}
+
+// copyBetween copies between a host path and a container path given as cp
+// arguments, exactly one of which names a container.
+func (e *Engine) copyBetween(srcArg, dstArg string) error {
+	srcContainer, src := parseCopyArg(srcArg)
+	dstContainer, dst := parseCopyArg(dstArg)
+	switch {
+	case srcContainer != "" && dstContainer != "":
+		return errors.New("copying between containers is not supported")
+	case srcContainer == "" && dstContainer == "":
+		return errors.New("one of source or destination must be a container path (container:path)")
+	}
+
+	var err error
+	if srcContainer != "" {
+		if src, err = e.containerPathOnHost(e.resolveContainerID(srcContainer), src); err != nil {
+			return err
+		}
+	} else if dst, err = e.containerPathOnHost(e.resolveContainerID(dstContainer), dst); err != nil {
+		return err
+	}
+	return e.copyPath(src, dst)
+}
+
+// copyCommand implements cp.
+func (e *Engine) copyCommand(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker cp <src> <container:dest> | <container:src> <dest>")
+		os.Exit(1)
+	}
+	if err := e.copyBetween(args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createCopyTestContainer creates a container with an empty rootfs.
+func createCopyTestContainer(t *testing.T, e *Engine, containerID string) string {
+	t.Helper()
+	createTestContainer(t, e, &ContainerConfig{ID: containerID, Command: "sh"})
+	rootfs := e.containerRootfs(containerID)
+	if err := os.MkdirAll(filepath.Join(rootfs, "tmp"), 0755); err != nil {
+		t.Fatalf("Failed to create rootfs: %v", err)
+	}
+	return rootfs
+}
+
+// TestCopyIntoContainer copies a file and a directory into a container
+func TestCopyIntoContainer(t *testing.T) {
+	e := newTestEngine(t)
+	rootfs := createCopyTestContainer(t, e, "cp-in")
+
+	src := t.TempDir()
+	writeSizedFile(t, filepath.Join(src, "script.sh"), 4)
+	if err := os.Chmod(filepath.Join(src, "script.sh"), 0750); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := e.copyBetween(filepath.Join(src, "script.sh"), "cp-in:/tmp/run.sh"); err != nil {
+		t.Fatalf("copy into container failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(rootfs, "tmp", "run.sh"))
+	if err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("Expected run.sh with mode 0750, got %v (%v)", info, err)
+	}
+
+	dir := filepath.Join(src, "config")
+	writeSizedFile(t, filepath.Join(dir, "nested", "app.conf"), 8)
+	if err := os.Symlink("nested/app.conf", filepath.Join(dir, "current")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := e.copyBetween(dir, "cp-in:/etc"); err != nil {
+		t.Fatalf("copy of a directory into container failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rootfs, "etc", "nested", "app.conf")); err != nil {
+		t.Errorf("Expected the directory contents to be copied: %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(rootfs, "etc", "current")); err != nil || link != "nested/app.conf" {
+		t.Errorf("Expected the symlink to be preserved, got %q (%v)", link, err)
+	}
+
+	// An existing directory receives the source under its own name
+	if err := e.copyBetween(dir, "cp-in:/tmp"); err != nil {
+		t.Fatalf("copy into an existing directory failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rootfs, "tmp", "config", "nested", "app.conf")); err != nil {
+		t.Errorf("Expected the directory to be copied into /tmp: %v", err)
+	}
+}
+
+// TestCopyFromContainer copies a directory out of a container
+func TestCopyFromContainer(t *testing.T) {
+	e := newTestEngine(t)
+	rootfs := createCopyTestContainer(t, e, "cp-out")
+	writeSizedFile(t, filepath.Join(rootfs, "var", "log", "app.log"), 16)
+	writeSizedFile(t, filepath.Join(rootfs, "var", "log", "old", "app.log.1"), 32)
+
+	dst := filepath.Join(t.TempDir(), "logs")
+	if err := e.copyBetween("cp-out:/var/log", dst); err != nil {
+		t.Fatalf("copy from container failed: %v", err)
+	}
+	if size := diskSize(dst); size != 48 {
+		t.Errorf("Expected 48 bytes copied, got %d", size)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old", "app.log.1")); err != nil {
+		t.Errorf("Expected nested files to be copied: %v", err)
+	}
+
+	if err := e.copyBetween("cp-out:/missing", dst); err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+	if err := e.copyBetween("no-such-container:/tmp", dst); err == nil {
+		t.Error("Expected an error for a missing container")
+	}
+	if err := e.copyBetween(dst, dst); err == nil {
+		t.Error("Expected an error without a container path")
+	}
+}
+
+// TestCopyStaysInsideRootfs guards against paths and symlinks escaping the
+// container rootfs
+func TestCopyStaysInsideRootfs(t *testing.T) {
+	e := newTestEngine(t)
+	rootfs := createCopyTestContainer(t, e, "cp-escape")
+	hostDir := t.TempDir()
+	if err := os.Symlink(hostDir, filepath.Join(rootfs, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("../../..", filepath.Join(rootfs, "tmp", "up")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	tests := map[string]string{
+		"../../../outside.txt": filepath.Join(rootfs, "outside.txt"),
+		"/escape/outside.txt":  filepath.Join(rootfs, hostDir, "outside.txt"),
+		"/tmp/up/outside.txt":  filepath.Join(rootfs, "outside.txt"),
+	}
+	for path, want := range tests {
+		got, err := e.containerPathOnHost("cp-escape", path)
+		if err != nil {
+			t.Errorf("containerPathOnHost(%q) failed: %v", path, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("containerPathOnHost(%q) = %s, want %s", path, got, want)
+		}
+	}
+
+	src := filepath.Join(t.TempDir(), "outside.txt")
+	writeSizedFile(t, src, 1)
+	if err := os.MkdirAll(filepath.Join(rootfs, hostDir), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := e.copyBetween(src, "cp-escape:/escape/outside.txt"); err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(hostDir, "outside.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the copy not to follow the symlink out of the rootfs")
+	}
+
+	if _, err := e.containerPathOnHost("../containers", "/"); err == nil {
+		t.Error("Expected an error for a container ID with a path separator")
+	}
+}
@@ -1,10 +1,11 @@
-package main
+package engine
 
 import (
 	"context"
 	"fmt"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,6 +15,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"os"
 	"path/filepath"
+	"sync"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -24,6 +26,11 @@ type ResourceCapsuleOperator struct {
 	k8sClient    kubernetes.Interface
 	namespace    string
 	stopCh       chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+	// retryDelay is the initial backoff before re-establishing a closed
+	// watch. Zero uses defaultWatchRetryDelay.
+	retryDelay time.Duration
 }
 
 // NewResourceCapsuleOperator creates a new operator instance
@@ -64,59 +71,138 @@ func NewResourceCapsuleOperator(namespace string) (*ResourceCapsuleOperator, err
 	}, nil
 }
 
+// Backoff between attempts to re-establish the ResourceCapsule watch.
+const (
+	defaultWatchRetryDelay = time.Second
+	maxWatchRetryDelay     = 30 * time.Second
+)
+
 // Start begins the operator's control loop
 func (op *ResourceCapsuleOperator) Start() error {
-	fmt.Printf("[Operator] Starting ResourceCapsule operator in namespace: %s\n", op.namespace)
+	fmt.Fprintf(os.Stderr, "[Operator] Starting ResourceCapsule operator in namespace: %s\n", op.namespace)
 
-	// Define the GVR for ResourceCapsule
+	// Start watching ResourceCapsule resources
+	watcher, err := op.watch("")
+	if err != nil {
+		return fmt.Errorf("failed to start watching ResourceCapsules: %v", err)
+	}
+
+	op.wg.Add(1)
+	go func() {
+		defer op.wg.Done()
+		op.run(watcher)
+	}()
+
+	return nil
+}
+
+// watch opens a watch on ResourceCapsules starting after resourceVersion, or
+// at the current state when it is empty.
+func (op *ResourceCapsuleOperator) watch(resourceVersion string) (watch.Interface, error) {
 	gvr := schema.GroupVersionResource{
 		Group:    "capsules.docker.io",
 		Version:  "v1",
 		Resource: "resourcecapsules",
 	}
+	return op.client.Resource(gvr).Namespace(op.namespace).Watch(context.TODO(), metav1.ListOptions{
+		ResourceVersion:     resourceVersion,
+		AllowWatchBookmarks: true,
+	})
+}
 
-	// Start watching ResourceCapsule resources
-	watcher, err := op.client.Resource(gvr).Namespace(op.namespace).Watch(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to start watching ResourceCapsules: %v", err)
+// run handles watch events until the operator is stopped. When the API server
+// closes the watch, it is re-established from the last resourceVersion seen,
+// with an exponential backoff between failed attempts.
+func (op *ResourceCapsuleOperator) run(watcher watch.Interface) {
+	resourceVersion := ""
+	delay := op.retryDelay
+	if delay <= 0 {
+		delay = defaultWatchRetryDelay
 	}
+	for {
+		if !op.consume(watcher, &resourceVersion) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "[Operator] Watch channel closed, restarting...")
 
-	go func() {
-		defer watcher.Stop()
-		for {
+		for wait := min(delay, maxWatchRetryDelay); ; wait = min(wait*2, maxWatchRetryDelay) {
 			select {
-			case event, ok := <-watcher.ResultChan():
-				if !ok {
-					fmt.Println("[Operator] Watch channel closed, restarting...")
-					return
-				}
-				if err := op.handleEvent(event); err != nil {
-					fmt.Printf("[Operator] Error handling event: %v\n", err)
-				}
 			case <-op.stopCh:
-				fmt.Println("[Operator] Stopping operator...")
+				fmt.Fprintln(os.Stderr, "[Operator] Stopping operator...")
 				return
+			case <-time.After(wait):
+			}
+			var err error
+			if watcher, err = op.watch(resourceVersion); err == nil {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "[Operator] Failed to restart watch: %v\n", err)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				resourceVersion = ""
 			}
 		}
-	}()
+	}
+}
 
-	return nil
+// consume handles the events of one watch and records the resourceVersion of
+// each object, bookmarks included. It returns false when the operator is
+// stopped and true when the watch closes or has to be restarted.
+func (op *ResourceCapsuleOperator) consume(watcher watch.Interface, resourceVersion *string) bool {
+	defer watcher.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return true
+			}
+			if event.Type == watch.Error {
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					// The resourceVersion is too old, start over from now
+					*resourceVersion = ""
+					return true
+				}
+			}
+			if obj, ok := event.Object.(*unstructured.Unstructured); ok && obj.GetResourceVersion() != "" {
+				*resourceVersion = obj.GetResourceVersion()
+			}
+			if err := op.handleEvent(event); err != nil {
+				fmt.Fprintf(os.Stderr, "[Operator] Error handling event: %v\n", err)
+			}
+		case <-op.stopCh:
+			fmt.Fprintln(os.Stderr, "[Operator] Stopping operator...")
+			return false
+		}
+	}
 }
 
-// Stop stops the operator
+// Stop stops the operator and waits for the event being handled, if any, to
+// finish. It is safe to call more than once.
 func (op *ResourceCapsuleOperator) Stop() {
-	close(op.stopCh)
+	op.stopOnce.Do(func() { close(op.stopCh) })
+	op.wg.Wait()
 }
 
 // handleEvent processes watch events for ResourceCapsule resources
 func (op *ResourceCapsuleOperator) handleEvent(event watch.Event) error {
+	switch event.Type {
+	case watch.Bookmark:
+		return nil
+	case watch.Error:
+		return fmt.Errorf("watch error: %v", apierrors.FromObject(event.Object))
+	}
+
+	obj, ok := event.Object.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T in %s event", event.Object, event.Type)
+	}
 	switch event.Type {
 	case watch.Added:
-		return op.handleResourceCapsuleAdded(event.Object.(*unstructured.Unstructured))
+		return op.handleResourceCapsuleAdded(obj)
 	case watch.Modified:
-		return op.handleResourceCapsuleModified(event.Object.(*unstructured.Unstructured))
+		return op.handleResourceCapsuleModified(obj)
 	case watch.Deleted:
-		return op.handleResourceCapsuleDeleted(event.Object.(*unstructured.Unstructured))
+		return op.handleResourceCapsuleDeleted(obj)
 	}
 	return nil
 }
@@ -124,7 +210,7 @@ func (op *ResourceCapsuleOperator) handleEvent(event watch.Event) error {
 // handleResourceCapsuleAdded processes new ResourceCapsule resources
 func (op *ResourceCapsuleOperator) handleResourceCapsuleAdded(obj *unstructured.Unstructured) error {
 	name := obj.GetName()
-	fmt.Printf("[Operator] ResourceCapsule %s added\n", name)
+	fmt.Fprintf(os.Stderr, "[Operator] ResourceCapsule %s added\n", name)
 
 	// Extract spec data
 	spec, found, err := unstructured.NestedMap(obj.Object, "spec")
@@ -158,7 +244,7 @@ func (op *ResourceCapsuleOperator) handleResourceCapsuleAdded(obj *unstructured.
 // handleResourceCapsuleModified processes updated ResourceCapsule resources
 func (op *ResourceCapsuleOperator) handleResourceCapsuleModified(obj *unstructured.Unstructured) error {
 	name := obj.GetName()
-	fmt.Printf("[Operator] ResourceCapsule %s modified\n", name)
+	fmt.Fprintf(os.Stderr, "[Operator] ResourceCapsule %s modified\n", name)
 
 	// Extract rollback configuration
 	spec, found, err := unstructured.NestedMap(obj.Object, "spec")
@@ -171,7 +257,7 @@ func (op *ResourceCapsuleOperator) handleResourceCapsuleModified(obj *unstructur
 	if err == nil && found {
 		if enabled, found, _ := unstructured.NestedBool(rollback, "enabled"); found && enabled {
 			if prevVersion, found, _ := unstructured.NestedString(rollback, "previousVersion"); found && prevVersion != "" {
-				fmt.Printf("[Operator] Rollback requested for %s to version %s\n", name, prevVersion)
+				fmt.Fprintf(os.Stderr, "[Operator] Rollback requested for %s to version %s\n", name, prevVersion)
 				return op.performRollback(obj, prevVersion)
 			}
 		}
@@ -184,7 +270,7 @@ func (op *ResourceCapsuleOperator) handleResourceCapsuleModified(obj *unstructur
 // handleResourceCapsuleDeleted processes deleted ResourceCapsule resources
 func (op *ResourceCapsuleOperator) handleResourceCapsuleDeleted(obj *unstructured.Unstructured) error {
 	name := obj.GetName()
-	fmt.Printf("[Operator] ResourceCapsule %s deleted\n", name)
+	fmt.Fprintf(os.Stderr, "[Operator] ResourceCapsule %s deleted\n", name)
 
 	// Clean up underlying resources
 	spec, found, err := unstructured.NestedMap(obj.Object, "spec")
@@ -205,7 +291,7 @@ func (op *ResourceCapsuleOperator) handleResourceCapsuleDeleted(obj *unstructure
 	return op.deleteUnderlyingResource(name, version, capsuleType)
 }
 
-// createUnderlyingResource creates the actual ConfigMap or Secret
+// createUnderlyingResource creates or updates the actual ConfigMap or Secret
 func (op *ResourceCapsuleOperator) createUnderlyingResource(name, version, capsuleType string, data map[string]interface{}) error {
 	resourceName := fmt.Sprintf("%s-%s", name, version)
 
@@ -234,7 +320,7 @@ func (op *ResourceCapsuleOperator) createUnderlyingResource(name, version, capsu
 			Type: v1.SecretTypeOpaque,
 		}
 
-		_, err := op.k8sClient.CoreV1().Secrets(op.namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		_, err := applySecret(op.k8sClient, secret)
 		return err
 	} else {
 		// Convert data to string map for ConfigMap
@@ -262,7 +348,7 @@ func (op *ResourceCapsuleOperator) createUnderlyingResource(name, version, capsu
 			Data: configData,
 		}
 
-		_, err := op.k8sClient.CoreV1().ConfigMaps(op.namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
+		_, err := applyConfigMap(op.k8sClient, configMap)
 		return err
 	}
 }
@@ -281,7 +367,7 @@ func (op *ResourceCapsuleOperator) deleteUnderlyingResource(name, version, capsu
 // performRollback implements rollback functionality
 func (op *ResourceCapsuleOperator) performRollback(obj *unstructured.Unstructured, previousVersion string) error {
 	name := obj.GetName()
-	fmt.Printf("[Operator] Performing rollback for %s to version %s\n", name, previousVersion)
+	fmt.Fprintf(os.Stderr, "[Operator] Performing rollback for %s to version %s\n", name, previousVersion)
 
 	// This is a simplified rollback - in a real implementation, you would:
 	// 1. Find the previous version's ResourceCapsule
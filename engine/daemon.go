@@ -0,0 +1,448 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const daemonSocketFile = "basic-docker.sock"
+
+// daemonSocketPath returns the Unix socket the daemon listens on.
+func (e *Engine) daemonSocketPath() string {
+	return filepath.Join(e.Root, daemonSocketFile)
+}
+
+// runResponse is returned by the daemon after starting a container.
+type runResponse struct {
+	ID string `json:"id"`
+}
+
+// apiError is the body of a failed API request.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Daemon serves the engine API on a Unix socket and supervises containers
+// started through it.
+type Daemon struct {
+	engine   *Engine
+	listener net.Listener
+	server   *http.Server
+
+	mu sync.Mutex
+	// stdin holds the write end of the stdin of containers started with
+	// stdin open, for attach.
+	stdin map[string]*os.File
+	// running holds a channel per container the daemon runs, closed once
+	// the container has exited and been cleaned up.
+	running map[string]chan struct{}
+}
+
+// startDaemon listens on socketPath and serves the API in the background.
+func (e *Engine) startDaemon(socketPath string) (*Daemon, error) {
+	// A socket left behind by a crashed daemon would make Listen fail
+	if conn, err := net.DialTimeout("unix", socketPath, 200*time.Millisecond); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %v", err)
+	}
+
+	d := &Daemon{engine: e, listener: listener, stdin: make(map[string]*os.File), running: make(map[string]chan struct{})}
+	d.server = &http.Server{Handler: d.routes()}
+	go func() {
+		if err := d.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			e.Logger.Error("daemon stopped serving", "error", err)
+		}
+	}()
+	e.Logger.Info("daemon listening", "socket", socketPath)
+	return d, nil
+}
+
+// Close stops accepting requests and removes the socket.
+func (d *Daemon) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := d.server.Shutdown(ctx)
+	os.Remove(d.listener.Addr().String())
+	return err
+}
+
+// runDaemon serves the API until SIGINT or SIGTERM.
+func (e *Engine) runDaemon(socketPath string) error {
+	d, err := e.startDaemon(socketPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Daemon listening on %s\n", socketPath)
+	if removed, err := e.gcContainers(e.gcMaxAge()); err != nil {
+		e.Logger.Warn("failed to remove stale containers", "error", err)
+	} else if len(removed) > 0 {
+		e.Logger.Info("removed stale containers", "count", len(removed))
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	return d.Close()
+}
+
+func (d *Daemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/ps", d.handlePs)
+	mux.HandleFunc("POST /v1/run", d.handleRun)
+	mux.HandleFunc("POST /v1/containers/{id}/start", d.handleStart)
+	mux.HandleFunc("POST /v1/containers/{id}/stop", d.handleStop)
+	mux.HandleFunc("POST /v1/containers/{id}/restart", d.handleRestart)
+	mux.HandleFunc("GET /v1/containers/{id}/logs", d.handleLogs)
+	mux.HandleFunc("GET /v1/containers/{id}/attach", d.handleAttachOutput)
+	mux.HandleFunc("POST /v1/containers/{id}/attach", d.handleAttachInput)
+	d.registerDockerAPI(mux)
+	return stripDockerAPIVersion(mux)
+}
+
+// writeJSON encodes v as the response body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError reports err as a JSON error body.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+func (d *Daemon) handlePs(w http.ResponseWriter, r *http.Request) {
+	summaries, err := d.engine.listContainerSummaries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if summaries == nil {
+		summaries = []ContainerSummary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (d *Daemon) handleRun(w http.ResponseWriter, r *http.Request) {
+	var opts RunOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid run request: %v", err))
+		return
+	}
+	// Without a command the image's entrypoint and cmd are run, as with the CLI
+	if opts.Image == "" {
+		writeError(w, http.StatusBadRequest, errors.New("image is required"))
+		return
+	}
+	opts.Image = normalizeImageRef(opts.Image)
+
+	config, err := d.engine.prepareContainer(&opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := d.startDetached(config); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, runResponse{ID: config.ID})
+}
+
+// startDetached runs a container in the background with its output going to
+// the container log. Containers with OpenStdin read their input from a pipe
+// attach can write to.
+func (d *Daemon) startDetached(config *ContainerConfig) error {
+	logFile, err := d.engine.openContainerLog(config.ID)
+	if err != nil {
+		return err
+	}
+	stdio := ContainerIO{Stdout: logFile, Stderr: logFile}
+	var stdinReader, stdinWriter *os.File
+	if config.OpenStdin {
+		if stdinReader, stdinWriter, err = os.Pipe(); err != nil {
+			logFile.Close()
+			return fmt.Errorf("failed to create stdin pipe: %v", err)
+		}
+		stdio.Stdin = stdinReader
+	}
+	done := make(chan struct{})
+	d.mu.Lock()
+	if stdinWriter != nil {
+		d.stdin[config.ID] = stdinWriter
+	}
+	d.running[config.ID] = done
+	d.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer logFile.Close()
+		if err := d.engine.startContainer(config, stdio); err != nil {
+			d.engine.Logger.Info("container exited", "container", config.ID, "error", err)
+		}
+		d.mu.Lock()
+		if stdinWriter != nil {
+			stdinReader.Close()
+			stdinWriter.Close()
+			if d.stdin[config.ID] == stdinWriter {
+				delete(d.stdin, config.ID)
+			}
+		}
+		if d.running[config.ID] == done {
+			delete(d.running, config.ID)
+		}
+		d.mu.Unlock()
+	}()
+	return nil
+}
+
+func (d *Daemon) handleStart(w http.ResponseWriter, r *http.Request) {
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	config, err := d.engine.loadContainerConfig(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("container %s does not exist", containerID))
+		return
+	}
+	if status := d.engine.getContainerStatus(containerID); isContainerActive(status) {
+		writeError(w, http.StatusConflict, fmt.Errorf("container %s is already running", containerID))
+		return
+	}
+	if err := d.startDetached(config); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stopTimeout returns the grace period set by the "t" query parameter in
+// seconds, 10 seconds by default.
+func stopTimeout(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("t")
+	if value == "" {
+		return 10 * time.Second, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func (d *Daemon) handleStop(w http.ResponseWriter, r *http.Request) {
+	timeout, err := stopTimeout(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := d.engine.stopContainer(d.engine.resolveContainerID(r.PathValue("id")), timeout); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Daemon) handleRestart(w http.ResponseWriter, r *http.Request) {
+	timeout, err := stopTimeout(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	if _, err := d.engine.loadContainerConfig(containerID); err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("container %s does not exist", containerID))
+		return
+	}
+	if err := d.restartContainer(containerID, timeout); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restartExitTimeout bounds how long restart waits for a stopped container's
+// previous run to finish cleaning up.
+const restartExitTimeout = 10 * time.Second
+
+// restartContainer stops a container if it is running and starts it again in
+// the background from its stored config.
+func (d *Daemon) restartContainer(containerID string, timeout time.Duration) error {
+	if err := d.engine.stopContainer(containerID, timeout); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	done := d.running[containerID]
+	d.mu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-time.After(restartExitTimeout):
+			return fmt.Errorf("container %s did not exit", containerID)
+		}
+	} else {
+		// Run by another process; stop only waits for SIGKILL to be sent
+		deadline := time.Now().Add(restartExitTimeout)
+		for isContainerActive(d.engine.getContainerStatus(containerID)) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("container %s did not exit", containerID)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	config, err := d.engine.loadContainerConfig(containerID)
+	if err != nil {
+		return err
+	}
+	if err := d.startDetached(config); err != nil {
+		return err
+	}
+	d.engine.emitEvent(eventRestart, containerID, nil)
+	return nil
+}
+
+func (d *Daemon) handleLogs(w http.ResponseWriter, r *http.Request) {
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	file, err := os.Open(d.engine.containerLogPath(containerID))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no logs for container %s", containerID))
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "text/plain")
+	io.Copy(w, file)
+}
+
+// handleAttachOutput streams the output of a container until it stops or the
+// client goes away.
+func (d *Daemon) handleAttachOutput(w http.ResponseWriter, r *http.Request) {
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	if _, err := os.Stat(d.engine.containerLogPath(containerID)); err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no logs for container %s", containerID))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	if err := d.engine.followContainerLog(containerID, flushWriter{w}, r.Context().Done()); err != nil {
+		d.engine.Logger.Warn("failed to stream container output", "container", containerID, "error", err)
+	}
+}
+
+// handleAttachInput copies the request body to the stdin of a container
+// started with stdin open.
+func (d *Daemon) handleAttachInput(w http.ResponseWriter, r *http.Request) {
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	d.mu.Lock()
+	stdin := d.stdin[containerID]
+	d.mu.Unlock()
+	if stdin == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("container %s was not started with stdin open", containerID))
+		return
+	}
+	if _, err := io.Copy(stdin, r.Body); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to write to container stdin: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newDaemonClient returns an HTTP client that dials the daemon socket.
+func newDaemonClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// daemonClient returns a client for the daemon when one is listening, or nil
+// so that callers fall back to operating on the state directory directly.
+func (e *Engine) daemonClient() *http.Client {
+	socketPath := e.daemonSocketPath()
+	conn, err := net.DialTimeout("unix", socketPath, 200*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return newDaemonClient(socketPath)
+}
+
+// daemonRequest sends a JSON request to the daemon and decodes the JSON reply
+// into out, if given.
+func daemonRequest(client *http.Client, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://basic-docker"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr apiError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode daemon response: %v", err)
+		}
+	}
+	return nil
+}
+
+// daemonStream copies a plain-text daemon response to w.
+func daemonStream(client *http.Client, path string, w io.Writer) error {
+	resp, err := client.Get("http://basic-docker" + path)
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr apiError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestDaemon runs a daemon on a socket under a temporary base directory.
+func startTestDaemon(t *testing.T, e *Engine) *http.Client {
+	t.Helper()
+	d, err := e.startDaemon(e.daemonSocketPath())
+	if err != nil {
+		t.Fatalf("startDaemon failed: %v", err)
+	}
+	t.Cleanup(func() {
+		d.Close()
+		// Let containers the daemon ran finish cleaning up before the base
+		// directory is restored
+		d.mu.Lock()
+		var running []chan struct{}
+		for _, done := range d.running {
+			running = append(running, done)
+		}
+		d.mu.Unlock()
+		for _, done := range running {
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Error("Timed out waiting for a container run by the daemon to exit")
+			}
+		}
+	})
+	return newDaemonClient(e.daemonSocketPath())
+}
+
+// TestDaemonPs round-trips a ps request through the daemon socket
+func TestDaemonPs(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+
+	var summaries []ContainerSummary
+	if err := daemonRequest(client, http.MethodGet, "/v1/ps", nil, &summaries); err != nil {
+		t.Fatalf("ps request failed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("Expected no containers, got %+v", summaries)
+	}
+
+	createTestContainer(t, e, &ContainerConfig{ID: "daemon-ps", Image: "busybox:latest", Command: "sleep", Args: []string{"5"}, StartedAt: time.Now()})
+	if err := daemonRequest(client, http.MethodGet, "/v1/ps", nil, &summaries); err != nil {
+		t.Fatalf("ps request failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != "daemon-ps" || summaries[0].Command != "sleep 5" || summaries[0].Status != "Stopped" {
+		t.Errorf("Unexpected ps response: %+v", summaries)
+	}
+
+	// The CLI uses the daemon once it is reachable
+	if e.daemonClient() == nil {
+		t.Fatal("Expected daemonClient to find the running daemon")
+	}
+	output := captureOutput(func() { e.listContainers() })
+	if !strings.Contains(output, "daemon-ps\t\tStopped\tsleep 5") {
+		t.Errorf("Expected ps output from the daemon, got: %s", output)
+	}
+}
+
+// TestDaemonRunAndLogs starts a detached container through the daemon and
+// reads its output back through the logs endpoint
+func TestDaemonRunAndLogs(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	// The empty rootfs has no shell, so the host's is run without isolation
+	var resp runResponse
+	opts := RunOptions{Image: "local", Command: "sh", Args: []string{"-c", "echo hello from daemon"}, Isolation: isolationNone}
+	if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err != nil {
+		t.Fatalf("run request failed: %v", err)
+	}
+	if resp.ID == "" {
+		t.Fatal("Expected a container ID")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var buf bytes.Buffer
+		if err := daemonStream(client, "/v1/containers/"+resp.ID+"/logs", &buf); err == nil && strings.Contains(buf.String(), "hello from daemon") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for container logs")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for e.getContainerStatus(resp.ID) != "Stopped" {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the container to exit")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := daemonRequest(client, http.MethodGet, "/v1/containers/missing/logs", nil, nil); err == nil {
+		t.Error("Expected an error for logs of an unknown container")
+	}
+}
+
+// TestDaemonRunImageCmd verifies that a run request without a command runs
+// the image's cmd and fails when the image has none
+func TestDaemonRunImageCmd(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	imageDir := e.imageStorePath("local:latest")
+	if err := os.MkdirAll(filepath.Join(imageDir, "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	var resp runResponse
+	opts := RunOptions{Image: "local", Isolation: isolationNone}
+	if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err == nil {
+		t.Error("Expected an error for an image without a command")
+	}
+
+	imageConfig := `{"config": {"Cmd": ["sh", "-c", "echo hello from image cmd"]}}`
+	if err := os.WriteFile(filepath.Join(imageDir, imageConfigFile), []byte(imageConfig), 0644); err != nil {
+		t.Fatalf("Failed to write image config: %v", err)
+	}
+	if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err != nil {
+		t.Fatalf("run request failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var buf bytes.Buffer
+		if err := daemonStream(client, "/v1/containers/"+resp.ID+"/logs", &buf); err == nil && strings.Contains(buf.String(), "hello from image cmd") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for container logs")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for e.getContainerStatus(resp.ID) != "Stopped" {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the container to exit")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestStopContainer verifies that stop terminates the container process
+func TestStopContainer(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "test-stop-container"
+	if err := os.MkdirAll(filepath.Join(e.Root, "containers", containerID), 0755); err != nil {
+		t.Fatalf("Failed to create container directory: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- e.runContainerProcess(containerID, exec.Command("sleep", "30"), nil) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.getContainerStatus(containerID) != "Running" {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the container to start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := e.stopContainer(containerID, 5*time.Second); err != nil {
+		t.Fatalf("stopContainer failed: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Error("Expected the container process to report termination")
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Errorf("Expected SIGTERM to stop the container promptly, took %v", time.Since(start))
+	}
+	if status := e.getContainerStatus(containerID); status != "Stopped" {
+		t.Errorf("Expected status Stopped, got %s", status)
+	}
+	if err := e.stopContainer("missing-container", time.Second); err == nil {
+		t.Error("Expected an error stopping an unknown container")
+	}
+}
+
+// readContainerPID returns the PID recorded for a running container.
+func readContainerPID(t *testing.T, e *Engine, containerID string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(e.Root, "containers", containerID, "pid"))
+	if err != nil {
+		t.Fatalf("Failed to read PID file: %v", err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// TestDaemonRestart restarts a detached container and checks it runs again
+// with a new process.
+func TestDaemonRestart(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	var resp runResponse
+	opts := RunOptions{Image: "local", Command: "sleep", Args: []string{"30"}, Isolation: isolationNone}
+	if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err != nil {
+		t.Fatalf("run request failed: %v", err)
+	}
+	waitForContainerStatus(t, e, resp.ID, "Running")
+	defer func() {
+		e.stopContainer(resp.ID, time.Second)
+		waitForContainerStatus(t, e, resp.ID, "Stopped")
+	}()
+	oldPID := readContainerPID(t, e, resp.ID)
+
+	if err := daemonRequest(client, http.MethodPost, "/v1/containers/"+resp.ID+"/restart?t=1", nil, nil); err != nil {
+		t.Fatalf("restart request failed: %v", err)
+	}
+	waitForContainerStatus(t, e, resp.ID, "Running")
+	if newPID := readContainerPID(t, e, resp.ID); newPID == oldPID {
+		t.Errorf("Expected a new PID after restart, still %s", oldPID)
+	}
+
+	// A stopped container is started again
+	if err := e.stopContainer(resp.ID, time.Second); err != nil {
+		t.Fatalf("stopContainer failed: %v", err)
+	}
+	waitForContainerStatus(t, e, resp.ID, "Stopped")
+	if err := daemonRequest(client, http.MethodPost, "/v1/containers/"+resp.ID+"/restart", nil, nil); err != nil {
+		t.Fatalf("restart of a stopped container failed: %v", err)
+	}
+	waitForContainerStatus(t, e, resp.ID, "Running")
+
+	if err := daemonRequest(client, http.MethodPost, "/v1/containers/missing/restart", nil, nil); err == nil {
+		t.Error("Expected an error restarting an unknown container")
+	}
+}
+
+// TestDaemonStartCreatedContainer starts a created container in the
+// background.
+func TestDaemonStartCreatedContainer(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	config, err := e.prepareContainer(&RunOptions{Image: "local", Command: "sleep", Args: []string{"30"}, Isolation: isolationNone})
+	if err != nil {
+		t.Fatalf("prepareContainer failed: %v", err)
+	}
+
+	if err := daemonRequest(client, http.MethodPost, "/v1/containers/"+config.ID+"/start", nil, nil); err != nil {
+		t.Fatalf("start request failed: %v", err)
+	}
+	waitForContainerStatus(t, e, config.ID, "Running")
+	defer func() {
+		e.stopContainer(config.ID, time.Second)
+		waitForContainerStatus(t, e, config.ID, "Stopped")
+	}()
+	if err := daemonRequest(client, http.MethodPost, "/v1/containers/"+config.ID+"/start", nil, nil); err == nil {
+		t.Error("Expected an error starting a running container")
+	}
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Change kinds reported by diff, as in docker diff.
+const (
+	changeAdded    = "A"
+	changeModified = "C"
+	changeDeleted  = "D"
+)
+
+// FileChange is a path that differs between a container and its image.
+type FileChange struct {
+	Kind string
+	Path string
+}
+
+// String formats the change as printed by diff.
+func (c FileChange) String() string {
+	return c.Kind + " " + c.Path
+}
+
+// snapshotTree maps every path under root, relative to it and rooted at /,
+// to its file info. Symlinks are not followed.
+func snapshotTree(root string) (map[string]os.FileInfo, error) {
+	tree := make(map[string]os.FileInfo)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			tree["/"+filepath.ToSlash(rel)] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", root, err)
+	}
+	return tree, nil
+}
+
+// sameFile reports whether two entries of the trees have the same type, mode
+// and content. Containers get a copy of the image, so modification times
+// always differ and contents are compared instead.
+func sameFile(imagePath string, imageInfo os.FileInfo, containerPath string, containerInfo os.FileInfo) (bool, error) {
+	if imageInfo.Mode() != containerInfo.Mode() {
+		return false, nil
+	}
+	switch {
+	case imageInfo.IsDir():
+		return true, nil
+	case imageInfo.Mode()&os.ModeSymlink != 0:
+		imageLink, err := os.Readlink(imagePath)
+		if err != nil {
+			return false, err
+		}
+		containerLink, err := os.Readlink(containerPath)
+		return imageLink == containerLink, err
+	case !imageInfo.Mode().IsRegular():
+		return true, nil
+	case imageInfo.Size() != containerInfo.Size():
+		return false, nil
+	}
+
+	imageSum, err := fileDigest(imagePath)
+	if err != nil {
+		return false, err
+	}
+	containerSum, err := fileDigest(containerPath)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(imageSum, containerSum), nil
+}
+
+// fileDigest returns the SHA-256 of a file's contents.
+func fileDigest(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// diffTrees compares the container rootfs against the image rootfs. Every
+// added path is reported, but only the top of a deleted tree. A directory
+// whose entries were added or deleted is reported as changed.
+func diffTrees(imageRoot, containerRoot string) ([]FileChange, error) {
+	imageTree, err := snapshotTree(imageRoot)
+	if err != nil {
+		return nil, err
+	}
+	containerTree, err := snapshotTree(containerRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]string)
+	for path, info := range containerTree {
+		imageInfo, ok := imageTree[path]
+		if !ok {
+			kinds[path] = changeAdded
+			continue
+		}
+		same, err := sameFile(filepath.Join(imageRoot, path), imageInfo, filepath.Join(containerRoot, path), info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %v", path, err)
+		}
+		if !same {
+			kinds[path] = changeModified
+		}
+	}
+	for path := range imageTree {
+		if _, ok := containerTree[path]; ok {
+			continue
+		}
+		parent := filepath.Dir(path)
+		if _, kept := containerTree[parent]; parent != "/" && !kept {
+			continue
+		}
+		kinds[path] = changeDeleted
+	}
+
+	var parents []string
+	for path, kind := range kinds {
+		if kind == changeAdded || kind == changeDeleted {
+			parents = append(parents, filepath.Dir(path))
+		}
+	}
+	for _, parent := range parents {
+		if parent != "/" && kinds[parent] == "" {
+			kinds[parent] = changeModified
+		}
+	}
+
+	changes := make([]FileChange, 0, len(kinds))
+	for path, kind := range kinds {
+		changes = append(changes, FileChange{Kind: kind, Path: path})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// containerDiff lists the changes a container made to its image's filesystem.
+func (e *Engine) containerDiff(containerID string) ([]FileChange, error) {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return nil, fmt.Errorf("container %s does not exist", containerID)
+	}
+	imageRoot := filepath.Join(e.imageStorePath(config.Image), "rootfs")
+	if _, err := os.Stat(imageRoot); err != nil {
+		return nil, fmt.Errorf("image %s of container %s is not available locally", config.Image, containerID)
+	}
+	return diffTrees(imageRoot, e.containerRootfs(containerID))
+}
+
+// diffCommand implements diff.
+func (e *Engine) diffCommand(containerID string) {
+	changes, err := e.containerDiff(containerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+}
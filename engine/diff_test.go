@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestContainerDiff creates, modifies and deletes files in a container and
+// checks the reported changes
+func TestContainerDiff(t *testing.T) {
+	e := newTestEngine(t)
+	imageRoot := filepath.Join(e.imageStorePath("diff-image"), "rootfs")
+	writeSizedFile(t, filepath.Join(imageRoot, "etc", "hosts"), 10)
+	writeSizedFile(t, filepath.Join(imageRoot, "etc", "motd"), 10)
+	writeSizedFile(t, filepath.Join(imageRoot, "bin", "app"), 10)
+	writeSizedFile(t, filepath.Join(imageRoot, "usr", "share", "doc", "README"), 10)
+	writeSizedFile(t, filepath.Join(imageRoot, "var", "lib", "data"), 10)
+	if err := os.Symlink("app", filepath.Join(imageRoot, "bin", "sh")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	createTestContainer(t, e, &ContainerConfig{ID: "diff-container", Image: "diff-image"})
+	rootfs := e.containerRootfs("diff-container")
+	if err := e.copyDir(imageRoot, rootfs); err != nil {
+		t.Fatalf("Failed to copy image: %v", err)
+	}
+
+	// Same size, different content
+	if err := os.WriteFile(filepath.Join(rootfs, "etc", "hosts"), []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	writeSizedFile(t, filepath.Join(rootfs, "tmp", "new", "file"), 3)
+	if err := os.Remove(filepath.Join(rootfs, "etc", "motd")); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(rootfs, "usr", "share")); err != nil {
+		t.Fatalf("Failed to delete directory: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(rootfs, "bin", "app"), 0700); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+
+	changes, err := e.containerDiff("diff-container")
+	if err != nil {
+		t.Fatalf("containerDiff failed: %v", err)
+	}
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+	want := []string{
+		"C /bin/app",
+		"C /etc",
+		"C /etc/hosts",
+		"D /etc/motd",
+		"A /tmp",
+		"A /tmp/new",
+		"A /tmp/new/file",
+		"C /usr",
+		"D /usr/share",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected diff:\ngot  %v\nwant %v", got, want)
+	}
+
+	if _, err := e.containerDiff("no-such-container"); err == nil {
+		t.Error("Expected an error for a missing container")
+	}
+}
@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// stateFiles are the entries of the engine root holding engine state rather
+// than disposable data.
+var stateFiles = map[string]bool{
+	"containers":       true,
+	"images":           true,
+	"layers":           true,
+	"blobs":            true,
+	"volumes":          true,
+	networksFile:       true,
+	capsulesFile:       true,
+	eventsFile:         true,
+	containerNamesFile: true,
+	daemonSocketFile:   true,
+}
+
+// DiskUsageCategory is the space used by one kind of object.
+type DiskUsageCategory struct {
+	Type        string `json:"type"`
+	Total       int    `json:"total"`
+	Active      int    `json:"active"`
+	Size        int64  `json:"size"`
+	Reclaimable int64  `json:"reclaimable"`
+}
+
+// DiskUsage is the report printed by system df.
+type DiskUsage struct {
+	Categories  []DiskUsageCategory `json:"categories"`
+	Size        int64               `json:"size"`
+	Reclaimable int64               `json:"reclaimable"`
+}
+
+// diskSize returns the size of the files under path. Missing or unreadable
+// entries are skipped so that a partially removed object does not fail the
+// whole report.
+func diskSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// systemDiskUsage measures the images, containers, layers and cache under
+// the engine root. Stopped containers, images no container uses and
+// unreferenced layers are reclaimable. The cache is whatever scratch data is
+// left in the root besides the engine state.
+func (e *Engine) systemDiskUsage() (*DiskUsage, error) {
+	summaries, err := e.listContainerSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	containers := DiskUsageCategory{Type: "Containers"}
+	usedImages := make(map[string]bool)
+	for _, summary := range summaries {
+		size := diskSize(filepath.Join(e.Root, "containers", summary.ID))
+		containers.Total++
+		containers.Size += size
+		if !isContainerActive(summary.Status) {
+			containers.Reclaimable += size
+		} else {
+			containers.Active++
+		}
+		if summary.Image != "" {
+			usedImages[filepath.Base(e.imageStorePath(summary.Image))] = true
+		}
+	}
+
+	images := DiskUsageCategory{Type: "Images"}
+	if err := forEachEntry(e.imagesDir(), func(entry os.DirEntry) {
+		if !entry.IsDir() {
+			return
+		}
+		size := diskSize(filepath.Join(e.imagesDir(), entry.Name()))
+		images.Total++
+		images.Size += size
+		if usedImages[entry.Name()] {
+			images.Active++
+		} else {
+			images.Reclaimable += size
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	refs, err := e.layerReferences()
+	if err != nil {
+		return nil, err
+	}
+	layers := DiskUsageCategory{Type: "Layers"}
+	if err := forEachEntry(e.layersDir(), func(entry os.DirEntry) {
+		size := diskSize(filepath.Join(e.layersDir(), entry.Name()))
+		layers.Size += size
+		if !entry.IsDir() {
+			return
+		}
+		layers.Total++
+		if refs[entry.Name()] > 0 {
+			layers.Active++
+		} else {
+			layers.Reclaimable += size
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	cache := DiskUsageCategory{Type: "Cache"}
+	if err := forEachEntry(e.Root, func(entry os.DirEntry) {
+		if stateFiles[entry.Name()] {
+			return
+		}
+		size := diskSize(filepath.Join(e.Root, entry.Name()))
+		cache.Total++
+		cache.Size += size
+		cache.Reclaimable += size
+	}); err != nil {
+		return nil, err
+	}
+
+	usage := &DiskUsage{Categories: []DiskUsageCategory{images, containers, layers, cache}}
+	for _, category := range usage.Categories {
+		usage.Size += category.Size
+		usage.Reclaimable += category.Reclaimable
+	}
+	return usage, nil
+}
+
+// forEachEntry calls fn for every entry of dir. A missing dir has no entries.
+func forEachEntry(dir string, fn func(os.DirEntry)) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		fn(entry)
+	}
+	return nil
+}
+
+// reclaimablePercent formats reclaimable as a share of size.
+func reclaimablePercent(reclaimable, size int64) string {
+	if size == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%d%%", reclaimable*100/size)
+}
+
+// printDiskUsage writes usage as a table or, with format "json", as JSON.
+func printDiskUsage(w io.Writer, usage *DiskUsage, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(usage)
+	case "", "table":
+	default:
+		return fmt.Errorf("unknown format %q: expected table or json", format)
+	}
+
+	fmt.Fprintln(w, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE")
+	for _, c := range usage.Categories {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d bytes\t%d bytes (%s)\n", c.Type, c.Total, c.Active, c.Size, c.Reclaimable, reclaimablePercent(c.Reclaimable, c.Size))
+	}
+	fmt.Fprintf(w, "Total\t\t\t%d bytes\t%d bytes (%s)\n", usage.Size, usage.Reclaimable, reclaimablePercent(usage.Reclaimable, usage.Size))
+	return nil
+}
+
+// systemCommand implements the system subcommands.
+func (e *Engine) systemCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker system <df|prune> [options]")
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "df":
+		e.diskUsageCommand(args[1:])
+	case "prune":
+		e.pruneCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown subcommand for system: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// diskUsageCommand implements system df.
+func (e *Engine) diskUsageCommand(args []string) {
+	flags := flag.NewFlagSet("system df", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "table", "Output format (table or json)")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker system df [--format table|json]")
+		os.Exit(1)
+	}
+
+	usage, err := e.systemDiskUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := printDiskUsage(os.Stdout, usage, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSizedFile creates a file of the given size, creating its parents.
+func writeSizedFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// TestSystemDiskUsage sums fake images, containers, layers and cache
+func TestSystemDiskUsage(t *testing.T) {
+	e := newTestEngine(t)
+
+	writeSizedFile(t, filepath.Join(e.imagesDir(), "used:latest", "rootfs", "bin"), 1000)
+	writeSizedFile(t, filepath.Join(e.imagesDir(), "unused:latest", "rootfs", "bin"), 300)
+	writeSizedFile(t, filepath.Join(e.layersDir(), "base-layer-1", "base.txt"), 50)
+	writeSizedFile(t, filepath.Join(e.layersDir(), "orphan-layer", "app.txt"), 7)
+	if err := e.AddLayer(ImageLayer{ID: "base-layer-1", BaseLayerPath: filepath.Join(e.layersDir(), "base-layer-1")}); err != nil {
+		t.Fatalf("Failed to save layer metadata: %v", err)
+	}
+	metadataSize := diskSize(filepath.Join(e.layersDir(), layerIndexFile))
+	writeSizedFile(t, filepath.Join(e.Root, "test-mount", "app.txt"), 20)
+	writeSizedFile(t, filepath.Join(e.Root, networksFile), 2)
+	writeSizedFile(t, filepath.Join(e.volumesDir(), "data", "db"), 5)
+	writeSizedFile(t, e.blobPath("sha256:abc"), 9)
+
+	createTestContainer(t, e, &ContainerConfig{ID: "df-running", Image: "used"})
+	createTestContainer(t, e, &ContainerConfig{ID: "df-stopped", Image: "used:latest"})
+	writeSizedFile(t, filepath.Join(e.containerRootfs("df-stopped"), "data"), 400)
+	pid := fmt.Sprintf("%d", os.Getpid())
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", "df-running", "pid"), []byte(pid), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	runningSize := diskSize(filepath.Join(e.Root, "containers", "df-running"))
+	stoppedSize := diskSize(filepath.Join(e.Root, "containers", "df-stopped"))
+
+	usage, err := e.systemDiskUsage()
+	if err != nil {
+		t.Fatalf("systemDiskUsage failed: %v", err)
+	}
+	expected := []DiskUsageCategory{
+		{Type: "Images", Total: 2, Active: 1, Size: 1300, Reclaimable: 300},
+		{Type: "Containers", Total: 2, Active: 1, Size: runningSize + stoppedSize, Reclaimable: stoppedSize},
+		{Type: "Layers", Total: 2, Active: 1, Size: 57 + metadataSize, Reclaimable: 7},
+		{Type: "Cache", Total: 1, Size: 20, Reclaimable: 20},
+	}
+	if len(usage.Categories) != len(expected) {
+		t.Fatalf("Expected %d categories, got %+v", len(expected), usage.Categories)
+	}
+	for i, want := range expected {
+		if usage.Categories[i] != want {
+			t.Errorf("Expected %+v, got %+v", want, usage.Categories[i])
+		}
+	}
+	if total := 1300 + runningSize + stoppedSize + 57 + metadataSize + 20; usage.Size != total {
+		t.Errorf("Expected total size %d, got %d", total, usage.Size)
+	}
+	if reclaimable := 300 + stoppedSize + 7 + 20; usage.Reclaimable != reclaimable {
+		t.Errorf("Expected reclaimable %d, got %d", reclaimable, usage.Reclaimable)
+	}
+
+	var buf bytes.Buffer
+	if err := printDiskUsage(&buf, usage, "json"); err != nil {
+		t.Fatalf("printDiskUsage failed: %v", err)
+	}
+	var decoded DiskUsage
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Size != usage.Size {
+		t.Errorf("Expected JSON output to round-trip, got %s (%v)", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := printDiskUsage(&buf, usage, "table"); err != nil {
+		t.Fatalf("printDiskUsage failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Images\t2\t1\t1300 bytes\t300 bytes (23%)") {
+		t.Errorf("Unexpected table output:\n%s", buf.String())
+	}
+	if err := printDiskUsage(&buf, usage, "yaml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
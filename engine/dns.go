@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// hostResolvConf is the resolver config containers inherit when run without
+// --dns or --dns-search. Tests replace it.
+var hostResolvConf = "/etc/resolv.conf"
+
+// validateDNSServers checks the addresses given with run --dns.
+func validateDNSServers(servers []string) error {
+	for _, server := range servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid --dns %q: not an IP address", server)
+		}
+	}
+	return nil
+}
+
+// resolvConf returns the resolv.conf of a container: the host's, with its
+// nameservers replaced by servers and its search domains by search when they
+// are given.
+func resolvConf(host string, servers, search []string) string {
+	if len(servers) == 0 && len(search) == 0 {
+		return host
+	}
+	var lines []string
+	for _, line := range strings.Split(host, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if len(servers) > 0 {
+				continue
+			}
+		case "search", "domain":
+			if len(search) > 0 {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	for _, server := range servers {
+		lines = append(lines, "nameserver "+server)
+	}
+	if len(search) > 0 {
+		lines = append(lines, "search "+strings.Join(search, " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeResolvConf writes the /etc/resolv.conf of a container rootfs, see
+// resolvConf. Without a host resolv.conf and without options the image's
+// file is kept.
+func writeResolvConf(rootfs string, servers, search []string) error {
+	host, err := os.ReadFile(hostResolvConf)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", hostResolvConf, err)
+	}
+	if os.IsNotExist(err) && len(servers) == 0 && len(search) == 0 {
+		return nil
+	}
+	path, err := rootfsEtcFile(rootfs, "resolv.conf")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(resolvConf(string(host), servers, search)), 0644); err != nil {
+		return fmt.Errorf("failed to write /etc/resolv.conf: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useHostResolvConf makes containers inherit the given resolv.conf.
+func useHostResolvConf(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write resolv.conf: %v", err)
+	}
+	old := hostResolvConf
+	hostResolvConf = path
+	t.Cleanup(func() { hostResolvConf = old })
+}
+
+func TestResolvConf(t *testing.T) {
+	host := "# host config\nnameserver 192.168.1.1\nsearch home.lan\noptions ndots:2\n"
+	tests := []struct {
+		servers, search []string
+		want            string
+	}{
+		{nil, nil, host},
+		{[]string{"8.8.8.8", "1.1.1.1"}, nil, "# host config\nsearch home.lan\noptions ndots:2\nnameserver 8.8.8.8\nnameserver 1.1.1.1\n"},
+		{nil, []string{"example.com", "corp"}, "# host config\nnameserver 192.168.1.1\noptions ndots:2\nsearch example.com corp\n"},
+	}
+	for _, tt := range tests {
+		if got := resolvConf(host, tt.servers, tt.search); got != tt.want {
+			t.Errorf("resolvConf(%v, %v) = %q, want %q", tt.servers, tt.search, got, tt.want)
+		}
+	}
+}
+
+// TestRunDNS runs with --dns and --dns-search and checks the generated
+// resolv.conf, then without them to check the host's is copied
+func TestRunDNS(t *testing.T) {
+	e := newTestEngine(t)
+	useHostResolvConf(t, "nameserver 192.168.1.1\n")
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	opts, err := e.parseRunArgs([]string{"--dns", "10.0.0.53", "--dns", "10.0.1.53", "--dns-search", "svc.local", "local", "true"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	config, err := e.prepareContainer(opts)
+	if err != nil {
+		t.Fatalf("prepareContainer failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(e.containerRootfs(config.ID), "etc", "resolv.conf"))
+	if err != nil {
+		t.Fatalf("Failed to read resolv.conf: %v", err)
+	}
+	if want := "nameserver 10.0.0.53\nnameserver 10.0.1.53\nsearch svc.local\n"; string(data) != want {
+		t.Errorf("Expected resolv.conf %q, got %q", want, data)
+	}
+
+	opts, err = e.parseRunArgs([]string{"local", "true"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	if config, err = e.prepareContainer(opts); err != nil {
+		t.Fatalf("prepareContainer failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(e.containerRootfs(config.ID), "etc", "resolv.conf")); string(data) != "nameserver 192.168.1.1\n" {
+		t.Errorf("Expected the host's resolv.conf, got %q", data)
+	}
+
+	if _, err := e.parseRunArgs([]string{"--dns", "dns.example.com", "local", "true"}); err == nil {
+		t.Error("Expected a non-IP --dns to be rejected")
+	}
+}
@@ -0,0 +1,299 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The types below mirror the field names of the Docker Engine API so that
+// basic Docker clients can talk to the daemon. Only the commonly used fields
+// are filled in.
+
+// dockerPort is an entry of the Ports list of GET /containers/json.
+type dockerPort struct {
+	IP          string `json:"IP,omitempty"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+// dockerContainer is an element of the GET /containers/json response.
+type dockerContainer struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	Ports   []dockerPort      `json:"Ports"`
+	Labels  map[string]string `json:"Labels"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+}
+
+// dockerImage is an element of the GET /images/json response.
+type dockerImage struct {
+	ID          string            `json:"Id"`
+	ParentID    string            `json:"ParentId"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	Labels      map[string]string `json:"Labels"`
+	Containers  int               `json:"Containers"`
+}
+
+// dockerPortBinding is a host binding in HostConfig.PortBindings.
+type dockerPortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// dockerCreateRequest is the body of POST /containers/create.
+type dockerCreateRequest struct {
+	Image       string   `json:"Image"`
+	Cmd         []string `json:"Cmd"`
+	Entrypoint  []string `json:"Entrypoint"`
+	Healthcheck *struct {
+		Test     []string `json:"Test"`
+		Interval int64    `json:"Interval"`
+	} `json:"Healthcheck"`
+	HostConfig struct {
+		PortBindings    map[string][]dockerPortBinding `json:"PortBindings"`
+		PublishAllPorts bool                           `json:"PublishAllPorts"`
+	} `json:"HostConfig"`
+}
+
+// dockerCreateResponse is the reply to POST /containers/create.
+type dockerCreateResponse struct {
+	ID       string   `json:"Id"`
+	Warnings []string `json:"Warnings"`
+}
+
+// dockerAPIVersionPrefix matches the optional version prefix Docker clients
+// put in front of every path, such as /v1.41.
+var dockerAPIVersionPrefix = regexp.MustCompile(`^/v1\.[0-9]+/`)
+
+// stripDockerAPIVersion removes the API version prefix before routing.
+func stripDockerAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if loc := dockerAPIVersionPrefix.FindStringIndex(r.URL.Path); loc != nil {
+			r.URL.Path = r.URL.Path[loc[1]-1:]
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerDockerAPI adds the Docker-compatible endpoints to mux.
+func (d *Daemon) registerDockerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /_ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) })
+	mux.HandleFunc("GET /containers/json", d.handleDockerContainers)
+	mux.HandleFunc("GET /images/json", d.handleDockerImages)
+	mux.HandleFunc("POST /containers/create", d.handleDockerCreate)
+	mux.HandleFunc("POST /containers/{id}/start", d.handleDockerStart)
+}
+
+// writeDockerError reports err in Docker's error body format.
+func writeDockerError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"message": err.Error()})
+}
+
+// toDockerContainer converts a container summary to the Docker API shape.
+func toDockerContainer(summary ContainerSummary) dockerContainer {
+	name := summary.Name
+	if name == "" {
+		name = summary.ID
+	}
+	c := dockerContainer{
+		ID:      summary.ID,
+		Names:   []string{"/" + name},
+		Image:   summary.Image,
+		Command: summary.Command,
+		Created: summary.Created.Unix(),
+		Ports:   []dockerPort{},
+		Labels:  map[string]string{},
+	}
+	for _, mapping := range summary.Ports {
+		c.Ports = append(c.Ports, dockerPort{
+			IP:          mapping.HostIP,
+			PrivatePort: mapping.ContainerPort,
+			PublicPort:  mapping.HostPort,
+			Type:        mapping.Protocol,
+		})
+	}
+
+	switch {
+	case summary.Status == "Running":
+		c.State, c.Status = "running", "Up"
+	case summary.Status == "Paused":
+		c.State, c.Status = "paused", "Up (Paused)"
+	case summary.StartedAt.IsZero():
+		c.State, c.Status = "created", "Created"
+	default:
+		c.State, c.Status = "exited", "Exited"
+	}
+	if c.State == "running" && summary.Health != "" {
+		c.Status = fmt.Sprintf("Up (%s)", summary.Health)
+	}
+	return c
+}
+
+func (d *Daemon) handleDockerContainers(w http.ResponseWriter, r *http.Request) {
+	summaries, err := d.engine.listContainerSummaries()
+	if err != nil {
+		writeDockerError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Like Docker, only running containers are listed unless all is set
+	all := r.URL.Query().Get("all")
+	showAll := all == "1" || all == "true"
+
+	containers := []dockerContainer{}
+	for _, summary := range summaries {
+		c := toDockerContainer(summary)
+		if showAll || c.State == "running" || c.State == "paused" {
+			containers = append(containers, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, containers)
+}
+
+// listDockerImages describes the local images in the Docker API shape.
+func (e *Engine) listDockerImages() ([]dockerImage, error) {
+	images := []dockerImage{}
+	entries, err := os.ReadDir(e.imagesDir())
+	if os.IsNotExist(err) {
+		return images, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(e.imagesDir(), entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size, _ := calculateDirSize(dir)
+
+		// The image ID is the digest of the image config when there is one
+		idSource := []byte(entry.Name())
+		if config, err := os.ReadFile(filepath.Join(dir, imageConfigFile)); err == nil {
+			idSource = config
+		}
+		digest := sha256.Sum256(idSource)
+
+		images = append(images, dockerImage{
+			ID:          "sha256:" + hex.EncodeToString(digest[:]),
+			RepoTags:    []string{entry.Name()},
+			RepoDigests: []string{},
+			Created:     info.ModTime().Unix(),
+			Size:        size,
+			Containers:  -1,
+		})
+	}
+	return images, nil
+}
+
+func (d *Daemon) handleDockerImages(w http.ResponseWriter, r *http.Request) {
+	images, err := d.engine.listDockerImages()
+	if err != nil {
+		writeDockerError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, images)
+}
+
+// runOptionsFromDockerCreate maps a Docker create request to run options.
+// As in Docker, an entrypoint replaces the image's entrypoint and cmd, a cmd
+// replaces the image's cmd, and without either the image's are run.
+func runOptionsFromDockerCreate(req *dockerCreateRequest) (*RunOptions, error) {
+	if req.Image == "" {
+		return nil, errors.New("image is required")
+	}
+
+	opts := &RunOptions{
+		Image:      normalizeImageRef(req.Image),
+		PublishAll: req.HostConfig.PublishAllPorts,
+	}
+	if len(req.Entrypoint) > 0 {
+		noEntrypoint := ""
+		opts.Entrypoint = &noEntrypoint
+	}
+	if argv := append(append([]string{}, req.Entrypoint...), req.Cmd...); len(argv) > 0 {
+		opts.Command, opts.Args = argv[0], argv[1:]
+	}
+	for containerPort, bindings := range req.HostConfig.PortBindings {
+		for _, binding := range bindings {
+			spec := binding.HostPort + ":" + containerPort
+			if binding.HostIP != "" {
+				spec = binding.HostIP + ":" + spec
+			}
+			opts.Publish = append(opts.Publish, spec)
+		}
+	}
+
+	if hc := req.Healthcheck; hc != nil && len(hc.Test) > 0 {
+		switch hc.Test[0] {
+		case "CMD-SHELL", "CMD":
+			opts.HealthCmd = strings.Join(hc.Test[1:], " ")
+		case "NONE":
+		default:
+			return nil, fmt.Errorf("unsupported health check test %q", hc.Test[0])
+		}
+		opts.HealthInterval = time.Duration(hc.Interval)
+	}
+	return opts, nil
+}
+
+func (d *Daemon) handleDockerCreate(w http.ResponseWriter, r *http.Request) {
+	var req dockerCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDockerError(w, http.StatusBadRequest, fmt.Errorf("invalid create request: %v", err))
+		return
+	}
+	opts, err := runOptionsFromDockerCreate(&req)
+	if err != nil {
+		writeDockerError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Name = r.URL.Query().Get("name")
+
+	config, err := d.engine.prepareContainer(opts)
+	if err != nil {
+		writeDockerError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, dockerCreateResponse{ID: config.ID, Warnings: []string{}})
+}
+
+func (d *Daemon) handleDockerStart(w http.ResponseWriter, r *http.Request) {
+	containerID := d.engine.resolveContainerID(r.PathValue("id"))
+	config, err := d.engine.loadContainerConfig(containerID)
+	if err != nil {
+		writeDockerError(w, http.StatusNotFound, fmt.Errorf("no such container: %s", containerID))
+		return
+	}
+	if status := d.engine.getContainerStatus(containerID); isContainerActive(status) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if err := d.startDetached(config); err != nil {
+		writeDockerError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
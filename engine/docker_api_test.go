@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDockerContainersJSON checks that /containers/json uses Docker's field
+// names and state values, with and without the API version prefix
+func TestDockerContainersJSON(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	createTestContainer(t, e, &ContainerConfig{ID: "docker-created", Image: "busybox:latest", Command: "sleep", Args: []string{"5"}, Created: time.Now()})
+	createTestContainer(t, e, &ContainerConfig{ID: "docker-exited", Image: "busybox:latest", Command: "true", Created: time.Now(), StartedAt: time.Now()})
+
+	for _, path := range []string{"/containers/json?all=1", "/v1.41/containers/json?all=true"} {
+		resp, err := client.Get("http://basic-docker" + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		var containers []map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&containers)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		if len(containers) != 2 {
+			t.Fatalf("Expected 2 containers from %s, got %d", path, len(containers))
+		}
+
+		states := map[string]interface{}{}
+		for _, c := range containers {
+			for _, key := range []string{"Id", "Names", "Image", "Command", "Created", "Ports", "Labels", "State", "Status"} {
+				if _, ok := c[key]; !ok {
+					t.Errorf("Container %v is missing %s", c["Id"], key)
+				}
+			}
+			states[c["Id"].(string)] = c["State"]
+		}
+		if states["docker-created"] != "created" || states["docker-exited"] != "exited" {
+			t.Errorf("Unexpected states from %s: %v", path, states)
+		}
+	}
+
+	// Without all only running containers are listed
+	resp, err := client.Get("http://basic-docker/containers/json")
+	if err != nil {
+		t.Fatalf("GET /containers/json failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var running []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&running); err != nil {
+		t.Fatalf("Failed to decode containers: %v", err)
+	}
+	if len(running) != 0 {
+		t.Errorf("Expected no running containers, got %+v", running)
+	}
+}
+
+// TestRunOptionsFromDockerCreate maps Docker create bodies to run options
+func TestRunOptionsFromDockerCreate(t *testing.T) {
+	var req dockerCreateRequest
+	body := `{"Image":"alpine","Entrypoint":["sh"],"Cmd":["-c","echo hi"],
+		"Healthcheck":{"Test":["CMD-SHELL","true"],"Interval":1000000000},
+		"HostConfig":{"PortBindings":{"80/tcp":[{"HostIp":"127.0.0.1","HostPort":"8080"}]}}}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	opts, err := runOptionsFromDockerCreate(&req)
+	if err != nil {
+		t.Fatalf("runOptionsFromDockerCreate failed: %v", err)
+	}
+	if opts.Image != "alpine:latest" || opts.Command != "sh" || len(opts.Args) != 2 || opts.Args[1] != "echo hi" {
+		t.Errorf("Unexpected command mapping: %+v", opts)
+	}
+	if opts.Entrypoint == nil || *opts.Entrypoint != "" {
+		t.Errorf("Expected the entrypoint to replace the image's, got %v", opts.Entrypoint)
+	}
+	if opts.HealthCmd != "true" || opts.HealthInterval != time.Second {
+		t.Errorf("Unexpected health check mapping: %+v", opts)
+	}
+	if len(opts.Publish) != 1 || opts.Publish[0] != "127.0.0.1:8080:80/tcp" {
+		t.Errorf("Unexpected port mapping: %v", opts.Publish)
+	}
+
+	// Without a command the image's entrypoint and cmd are used
+	opts, err = runOptionsFromDockerCreate(&dockerCreateRequest{Image: "alpine"})
+	if err != nil || opts.Command != "" || opts.Entrypoint != nil {
+		t.Errorf("Expected no command override, got %+v (err %v)", opts, err)
+	}
+}
+
+// TestDockerCreateImageCmd verifies that a create request without a command
+// uses the image's entrypoint and cmd, and fails when the image has neither
+func TestDockerCreateImageCmd(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	imageDir := e.imageStorePath("local:latest")
+	if err := os.MkdirAll(filepath.Join(imageDir, "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	create := func() (*http.Response, dockerCreateResponse) {
+		t.Helper()
+		resp, err := client.Post("http://basic-docker/v1.41/containers/create", "application/json", strings.NewReader(`{"Image":"local"}`))
+		if err != nil {
+			t.Fatalf("POST /containers/create failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var created dockerCreateResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		return resp, created
+	}
+
+	if resp, _ := create(); resp.StatusCode == http.StatusCreated {
+		t.Error("Expected an error for an image without a command")
+	}
+
+	imageConfig := `{"config": {"Entrypoint": ["/bin/echo"], "Cmd": ["hello"]}}`
+	if err := os.WriteFile(filepath.Join(imageDir, imageConfigFile), []byte(imageConfig), 0644); err != nil {
+		t.Fatalf("Failed to write image config: %v", err)
+	}
+	resp, created := create()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 from create, got %d", resp.StatusCode)
+	}
+	config, err := e.loadContainerConfig(created.ID)
+	if err != nil {
+		t.Fatalf("loadContainerConfig failed: %v", err)
+	}
+	if config.Command != "/bin/echo" || len(config.Args) != 1 || config.Args[0] != "hello" {
+		t.Errorf("Expected the image's entrypoint and cmd, got %s %v", config.Command, config.Args)
+	}
+}
+
+// TestDockerPing answers the ping clients send before other requests
+func TestDockerPing(t *testing.T) {
+	e := newTestEngine(t)
+	client := startTestDaemon(t, e)
+	resp, err := client.Get("http://basic-docker/v1.41/_ping")
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from ping, got %d", resp.StatusCode)
+	}
+}
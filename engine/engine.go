@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Engine is the entry point for driving containers and images from code; the
+// CLI commands are thin wrappers around its methods. An engine keeps all of
+// its state under Root, so engines with different roots are independent.
+// Engines are created with NewEngine.
+type Engine struct {
+	// Root is the directory holding containers, images and layers.
+	Root string
+	// CgroupRoot is the mount point of the cgroup hierarchy containers get
+	// their cgroups in.
+	CgroupRoot string
+	// Cgroups are the cgroup controllers the engine can use.
+	Cgroups CgroupCapabilities
+	// Namespaces reports whether containers can get their own namespaces.
+	Namespaces bool
+	// InContainer reports whether the engine itself runs in a container.
+	InContainer bool
+	// Logger receives diagnostics. It writes to stderr so that command
+	// output on stdout stays scriptable.
+	Logger *slog.Logger
+	// RegistryMirrors are the mirrors Docker Hub images are pulled through.
+	// When empty, BASIC_DOCKER_REGISTRY_MIRRORS is used.
+	RegistryMirrors []string
+	// RegistryClient sends registry requests and downloads for import.
+	RegistryClient *http.Client
+	// Tracer traces pulls. The default records nothing.
+	Tracer Tracer
+
+	// logLevel controls the verbosity of the logger NewEngine creates. It
+	// starts from BASIC_DOCKER_LOG.
+	logLevel *slog.LevelVar
+	networks []Network
+	capsules *CapsuleManager
+}
+
+// NewEngine returns an engine keeping its state under root, creating the
+// directories it needs and loading the networks and capsules found there.
+func NewEngine(root string) (*Engine, error) {
+	if root == "" {
+		return nil, fmt.Errorf("root directory must not be empty")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory %s: %v", root, err)
+	}
+	level := levelFromEnv()
+	e := &Engine{
+		Root:           abs,
+		CgroupRoot:     defaultCgroupRoot,
+		Logger:         newLogger(os.Stderr, level),
+		RegistryClient: newRegistryClient(defaultRegistryConnectTimeout, defaultRegistryResponseTimeout),
+		Tracer:         noopTracer{},
+		logLevel:       level,
+		networks:       []Network{},
+	}
+	e.detectEnvironment()
+	if err := e.initDirectories(); err != nil {
+		return nil, err
+	}
+	e.loadNetworks()
+	e.capsules = e.NewCapsuleManager()
+	return e, nil
+}
+
+// imagesDir holds the rootfs of each image.
+func (e *Engine) imagesDir() string {
+	return filepath.Join(e.Root, "images")
+}
+
+// layersDir holds the extracted image layers.
+func (e *Engine) layersDir() string {
+	return filepath.Join(e.Root, "layers")
+}
+
+// Create sets up a container from opts without starting it.
+func (e *Engine) Create(opts *RunOptions) (*ContainerConfig, error) {
+	return e.prepareContainer(opts)
+}
+
+// Start runs a created container until it exits, copying its output to
+// stdio and to the container's log.
+func (e *Engine) Start(config *ContainerConfig, stdio ContainerIO) error {
+	logFile, err := e.openContainerLog(config.ID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	if stdio.Stdout == nil {
+		stdio.Stdout = io.Discard
+	}
+	if stdio.Stderr == nil {
+		stdio.Stderr = io.Discard
+	}
+	stdio.Stdout = io.MultiWriter(stdio.Stdout, logFile)
+	stdio.Stderr = io.MultiWriter(stdio.Stderr, logFile)
+	return e.startContainer(config, stdio)
+}
+
+// Run creates a container from opts and runs it until it exits.
+func (e *Engine) Run(opts *RunOptions, stdio ContainerIO) (*ContainerConfig, error) {
+	config, err := e.Create(opts)
+	if err != nil {
+		return nil, err
+	}
+	return config, e.Start(config, stdio)
+}
+
+// Ps lists the containers of the engine.
+func (e *Engine) Ps() ([]ContainerSummary, error) {
+	return e.listContainerSummaries()
+}
+
+// Pull fetches an image from its registry.
+func (e *Engine) Pull(ref string, opts PullOptions) (*Image, error) {
+	return e.pullImage(ref, opts)
+}
+
+// Stop stops a container, killing it if it has not exited within timeout.
+func (e *Engine) Stop(containerID string, timeout time.Duration) error {
+	return e.stopContainer(containerID, timeout)
+}
+
+// Update changes the limits of a running container.
+func (e *Engine) Update(containerID string, opts UpdateOptions) error {
+	return e.updateContainer(containerID, opts)
+}
+
+// Logs copies the output a container has logged so far to w.
+func (e *Engine) Logs(containerID string, w io.Writer) error {
+	file, err := os.Open(e.containerLogPath(containerID))
+	if err != nil {
+		return fmt.Errorf("no logs for container %s: %v", containerID, err)
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnginePs(t *testing.T) {
+	root := t.TempDir()
+	engine, err := NewEngine(root)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if engine.Root != root {
+		t.Errorf("Root = %q, want %q", engine.Root, root)
+	}
+
+	summaries, err := engine.Ps()
+	if err != nil {
+		t.Fatalf("Ps failed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Fatalf("Ps on a new root = %v, want no containers", summaries)
+	}
+
+	createTestContainer(t, engine, &ContainerConfig{ID: "engine-ps-container", Command: "sh"})
+	summaries, err = engine.Ps()
+	if err != nil {
+		t.Fatalf("Ps failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != "engine-ps-container" {
+		t.Errorf("Ps = %v, want container engine-ps-container", summaries)
+	}
+}
+
+// TestEngineCreateLeavesContainerCreated creates a container without starting
+// it and checks it is reported as created until it runs.
+func TestEngineCreateLeavesContainerCreated(t *testing.T) {
+	e := newTestEngine(t)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	config, err := e.Create(&RunOptions{Image: "local", Command: "true", Isolation: isolationNone})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if status := e.getContainerStatus(config.ID); status != "Created" {
+		t.Errorf("Expected status Created, got %s", status)
+	}
+	if _, err := os.Stat(e.containerRootfs(config.ID)); err != nil {
+		t.Errorf("Expected the rootfs to be materialized, got %v", err)
+	}
+	summaries, err := e.Ps()
+	if err != nil {
+		t.Fatalf("Ps failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Status != "Created" {
+		t.Errorf("Ps = %+v, want one created container", summaries)
+	}
+
+	if err := e.Start(config, ContainerIO{}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if status := e.getContainerStatus(config.ID); status != "Stopped" {
+		t.Errorf("Expected status Stopped after the container ran, got %s", status)
+	}
+}
+
+// TestEnginesAreIndependent checks that engines with different roots do not
+// see each other's containers or networks.
+func TestEnginesAreIndependent(t *testing.T) {
+	first := newTestEngine(t)
+	second := newTestEngine(t)
+
+	createTestContainer(t, first, &ContainerConfig{ID: "first-container", Command: "sh"})
+	captureOutput(func() { first.CreateNetwork("first-network") })
+
+	summaries, err := second.Ps()
+	if err != nil {
+		t.Fatalf("Ps failed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("Ps on the second engine = %v, want no containers", summaries)
+	}
+	if len(first.networks) != 1 || len(second.networks) != 0 {
+		t.Errorf("Expected the network only on the first engine, got %v and %v", first.networks, second.networks)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const eventsFile = "events.log"
+
+// Lifecycle event actions.
+const (
+	eventCreate     = "create"
+	eventStart      = "start"
+	eventDie        = "die"
+	eventOOM        = "oom"
+	eventStop       = "stop"
+	eventRestart    = "restart"
+	eventRemove     = "remove"
+	eventConnect    = "network-attach"
+	eventDisconnect = "network-detach"
+)
+
+// Event is a single container lifecycle event. Events are stored as JSON
+// lines in events.log under the engine root.
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Action     string            `json:"action"`
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// eventsMu serializes appends from goroutines of the same process. Appends
+// from separate processes rely on O_APPEND.
+var eventsMu sync.Mutex
+
+// eventsPath returns the location of the events log.
+func (e *Engine) eventsPath() string {
+	return filepath.Join(e.Root, eventsFile)
+}
+
+// emitEvent appends an event to the events log. Failures are logged rather
+// than returned since events must never break the operation emitting them.
+func (e *Engine) emitEvent(action, containerID string, attributes map[string]string) {
+	data, err := json.Marshal(Event{Time: time.Now().UTC(), Action: action, ID: containerID, Attributes: attributes})
+	if err != nil {
+		e.Logger.Warn("failed to encode event", "action", action, "error", err)
+		return
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	file, err := os.OpenFile(e.eventsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		e.Logger.Warn("failed to open events log", "error", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		e.Logger.Warn("failed to write event", "action", action, "error", err)
+	}
+}
+
+// parseSince accepts either a duration relative to now ("10m") or an RFC 3339
+// timestamp.
+func parseSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value %q: expected a duration or RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// streamEvents copies events newer than since to w as JSON lines. With follow
+// set it keeps polling the log for new events until stop is closed.
+func (e *Engine) streamEvents(w io.Writer, since time.Time, follow bool, stop <-chan struct{}) error {
+	var file *os.File
+	for file == nil {
+		f, err := os.Open(e.eventsPath())
+		switch {
+		case err == nil:
+			file = f
+		case !os.IsNotExist(err):
+			return fmt.Errorf("failed to open events log: %v", err)
+		case !follow:
+			return nil
+		default:
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read events log: %v", err)
+		}
+		if err == io.EOF {
+			// Keep an incomplete trailing line until the writer finishes it
+			partial += line
+			if !follow {
+				return nil
+			}
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(200 * time.Millisecond):
+			}
+			continue
+		}
+
+		line = partial + line
+		partial = ""
+		var event Event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &event); err != nil {
+			e.Logger.Debug("skipping malformed event", "line", line)
+			continue
+		}
+		if event.Time.Before(since) {
+			continue
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+}
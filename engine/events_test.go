@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// readEvents returns the events recorded for a container.
+func readEvents(t *testing.T, e *Engine, containerID string) []Event {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.streamEvents(&buf, time.Time{}, false, nil); err != nil {
+		t.Fatalf("streamEvents failed: %v", err)
+	}
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err == nil && event.ID == containerID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// TestRunEmitsStartAndDieEvents verifies that running a container records a
+// start event followed by a die event carrying the exit code.
+func TestRunEmitsStartAndDieEvents(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "test-events-container"
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("Failed to create container directory: %v", err)
+	}
+
+	if err := e.runContainerProcess(containerID, exec.Command("sh", "-c", "exit 3"), nil); err == nil {
+		t.Fatal("Expected an exit error from the container process")
+	}
+
+	events := readEvents(t, e, containerID)
+	if len(events) < 2 {
+		t.Fatalf("Expected start and die events, got %+v", events)
+	}
+	start, die := events[len(events)-2], events[len(events)-1]
+	if start.Action != eventStart || start.Attributes["pid"] == "" {
+		t.Errorf("Expected a start event with a pid, got %+v", start)
+	}
+	if die.Action != eventDie || die.Attributes["exitCode"] != "3" {
+		t.Errorf("Expected a die event with exit code 3, got %+v", die)
+	}
+}
+
+// TestStreamEventsSince verifies that --since filters older events.
+func TestStreamEventsSince(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "test-events-since"
+	e.emitEvent(eventCreate, containerID, nil)
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	e.emitEvent(eventRemove, containerID, nil)
+
+	var buf bytes.Buffer
+	if err := e.streamEvents(&buf, since, false, nil); err != nil {
+		t.Fatalf("streamEvents failed: %v", err)
+	}
+	if strings.Contains(buf.String(), `"action":"create","id":"`+containerID) {
+		t.Errorf("Expected the create event to be filtered out, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"action":"remove","id":"`+containerID) {
+		t.Errorf("Expected the remove event, got: %s", buf.String())
+	}
+
+	if _, err := parseSince("10m"); err != nil {
+		t.Errorf("parseSince failed for a duration: %v", err)
+	}
+	if _, err := parseSince("yesterday"); err == nil {
+		t.Error("Expected an error for an invalid --since value")
+	}
+}
+
+// TestStreamEventsFollow verifies that a follower sees events appended later.
+func TestStreamEventsFollow(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "test-events-follow"
+	stop := make(chan struct{})
+	buf := &lockedBuffer{}
+	done := make(chan error)
+	since := time.Now()
+	go func() { done <- e.streamEvents(buf, since, true, stop) }()
+
+	e.emitEvent(eventStart, containerID, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), containerID) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the followed event")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("streamEvents failed: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fileRegistryScheme prefixes the directory of a FileRegistry where a
+// registry URL is expected, as in --registry-mirror file:///srv/registry.
+const fileRegistryScheme = "file://"
+
+// FileRegistry is a Registry reading manifests and blobs from a directory
+// laid out like a registry: <dir>/<repo>/manifests/<tag or digest> and
+// <dir>/<repo>/blobs/<digest>. It lets pulls run without a network.
+type FileRegistry struct {
+	Dir string
+}
+
+// NewFileRegistry creates a FileRegistry serving dir.
+func NewFileRegistry(dir string) *FileRegistry {
+	return &FileRegistry{Dir: dir}
+}
+
+// FetchManifest reads the manifest of a repository for a tag or digest.
+func (r *FileRegistry) FetchManifest(repo, tag string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(r.Dir, repo, "manifests", tag))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("manifest %s:%s not found in %s", repo, tag, r.Dir)
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return decodeManifest(data)
+}
+
+// FetchLayer opens a layer by its digest.
+func (r *FileRegistry) FetchLayer(repo, digest string) (io.ReadCloser, error) {
+	body, _, err := r.FetchLayerFrom(repo, digest, 0)
+	return body, err
+}
+
+// FetchLayerFrom opens a layer by its digest and seeks to offset.
+func (r *FileRegistry) FetchLayerFrom(repo, digest string, offset int64) (io.ReadCloser, int64, error) {
+	file, err := os.Open(filepath.Join(r.Dir, repo, "blobs", digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, fmt.Errorf("layer %s of %s not found in %s", digest, repo, r.Dir)
+		}
+		return nil, 0, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+	return file, offset, nil
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFileRegistry lays out an image of repo with a layer per file map in
+// a registry directory and returns the directory.
+func writeFileRegistry(t *testing.T, repo string, layers []map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	blobs := filepath.Join(dir, repo, "blobs")
+	manifests := filepath.Join(dir, repo, "manifests")
+	for _, path := range []string{blobs, manifests} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create registry directory: %v", err)
+		}
+	}
+
+	manifest := `{"layers": [`
+	for i, files := range layers {
+		data, err := os.ReadFile(writeTestTar(t, files))
+		if err != nil {
+			t.Fatalf("Failed to read layer: %v", err)
+		}
+		sum := sha256.Sum256(data)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		if err := os.WriteFile(filepath.Join(blobs, digest), data, 0644); err != nil {
+			t.Fatalf("Failed to write layer: %v", err)
+		}
+		if i > 0 {
+			manifest += ", "
+		}
+		manifest += fmt.Sprintf(`{"digest": %q, "size": %d}`, digest, len(data))
+	}
+	manifest += "]}"
+	if err := os.WriteFile(filepath.Join(manifests, "latest"), []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return dir
+}
+
+// TestPullFromFileRegistry pulls a two-layer image entirely from local files.
+func TestPullFromFileRegistry(t *testing.T) {
+	e := newTestEngine(t)
+	dir := writeFileRegistry(t, "library/offline", []map[string]string{
+		{"etc/base": "base", "etc/replaced": "old"},
+		{"etc/replaced": "new", "etc/top": "top"},
+	})
+
+	image, err := e.PullWithOptions(NewFileRegistry(dir), "library/offline", PullOptions{})
+	if err != nil {
+		t.Fatalf("PullWithOptions failed: %v", err)
+	}
+	for name, want := range map[string]string{"base": "base", "replaced": "new", "top": "top"} {
+		if data, err := os.ReadFile(filepath.Join(image.RootFS, "etc", name)); err != nil || string(data) != want {
+			t.Errorf("Expected etc/%s to be %q, got %q, %v", name, want, data, err)
+		}
+	}
+	if image.Digest == "" || e.loadImageDigest("library/offline") != image.Digest {
+		t.Errorf("Expected the manifest digest to be recorded, got %q", image.Digest)
+	}
+
+	if _, err := NewFileRegistry(dir).FetchManifest("library/offline", "missing"); err == nil {
+		t.Error("Expected an error for a missing tag")
+	}
+}
+
+// TestPullThroughFileMirror resolves a file:// mirror to a FileRegistry so
+// that a Docker Hub reference is pulled without a network.
+func TestPullThroughFileMirror(t *testing.T) {
+	e := newTestEngine(t)
+	dir := writeFileRegistry(t, "hermetic", []map[string]string{{"hello": "world"}})
+	t.Setenv(registryMirrorsEnv, fileRegistryScheme+dir)
+
+	image, err := e.pullImage("hermetic", PullOptions{})
+	if err != nil {
+		t.Fatalf("pullImage failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(image.RootFS, "hello")); err != nil || string(data) != "world" {
+		t.Errorf("Expected the file from the local registry, got %q, %v", data, err)
+	}
+	if _, err := newMirrorRegistry(fileRegistryScheme); err == nil {
+		t.Error("Expected an error for a file mirror without a directory")
+	}
+}
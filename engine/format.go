@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// formatFuncs are the functions available to --format templates, as in
+// docker: {{json .}} and {{join .Args " "}}.
+var formatFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// parseFormat parses the Go template given to --format. Templates referring
+// to missing fields fail when executed rather than printing "<no value>".
+func parseFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(formatFuncs).Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// writeFormatted renders one value with a --format template, followed by a
+// newline.
+func writeFormatted(w io.Writer, tmpl *template.Template, v interface{}) error {
+	if err := tmpl.Execute(w, v); err != nil {
+		return fmt.Errorf("failed to execute --format template: %v", err)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// humanDuration describes a duration the way docker ps does, such as
+// "About a minute" or "3 hours".
+func humanDuration(d time.Duration) string {
+	seconds := int(d.Seconds())
+	switch {
+	case seconds < 1:
+		return "Less than a second"
+	case seconds == 1:
+		return "1 second"
+	case seconds < 60:
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	minutes := int(d.Minutes())
+	switch {
+	case minutes == 1:
+		return "About a minute"
+	case minutes < 60:
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	hours := int(d.Hours() + 0.5)
+	switch {
+	case hours == 1:
+		return "About an hour"
+	case hours < 48:
+		return fmt.Sprintf("%d hours", hours)
+	case hours < 24*7*2:
+		return fmt.Sprintf("%d days", hours/24)
+	case hours < 24*30*2:
+		return fmt.Sprintf("%d weeks", hours/24/7)
+	case hours < 24*365*2:
+		return fmt.Sprintf("%d months", hours/24/30)
+	}
+	return fmt.Sprintf("%d years", hours/24/365)
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFormatted(t *testing.T) {
+	tests := []struct {
+		format string
+		value  interface{}
+		want   string
+	}{
+		{"{{.ID}}", ContainerSummary{ID: "container-1", Status: "Running"}, "container-1\n"},
+		{"{{.Name}}: {{.Status}}", ContainerSummary{ID: "container-1", Status: "Exited (0)", Name: "web"}, "web: Exited (0)\n"},
+		{"{{.Name}} {{.Size}} {{.ContentVerified}}", ImageSummary{Name: "alpine", Size: 42, ContentVerified: true}, "alpine 42 true\n"},
+		{"{{json .Containers}}", Network{Name: "net", ID: "net-1", Containers: map[string]string{"c1": "10.0.0.2"}}, `{"c1":"10.0.0.2"}` + "\n"},
+		{"{{upper .Name}}", ImageSummary{Name: "alpine"}, "ALPINE\n"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseFormat(tt.format)
+		if err != nil {
+			t.Fatalf("parseFormat(%q) failed: %v", tt.format, err)
+		}
+		var buf bytes.Buffer
+		if err := writeFormatted(&buf, tmpl, tt.value); err != nil {
+			t.Fatalf("writeFormatted(%q) failed: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("writeFormatted(%q) = %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestParseFormatInvalid(t *testing.T) {
+	_, err := parseFormat("{{.ID")
+	if err == nil || !strings.Contains(err.Error(), "invalid --format template") {
+		t.Errorf("Expected an invalid template error, got %v", err)
+	}
+
+	tmpl, err := parseFormat("{{.Missing}}")
+	if err != nil {
+		t.Fatalf("parseFormat failed: %v", err)
+	}
+	if err := writeFormatted(&bytes.Buffer{}, tmpl, ImageSummary{Name: "alpine"}); err == nil {
+		t.Error("Expected an error for a field the value does not have")
+	}
+}
+
+func TestInspectNetworkFormat(t *testing.T) {
+	e := newTestEngine(t)
+	e.networks = []Network{{Name: "backend", ID: "net-1", Containers: map[string]string{"c1": "10.0.0.2"}}}
+
+	tmpl, err := parseFormat("{{.ID}} {{index .Containers \"c1\"}}")
+	if err != nil {
+		t.Fatalf("parseFormat failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := e.InspectNetwork(&buf, "backend", tmpl); err != nil {
+		t.Fatalf("InspectNetwork failed: %v", err)
+	}
+	if buf.String() != "net-1 10.0.0.2\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := e.InspectNetwork(&buf, "net-1", nil); err != nil {
+		t.Fatalf("InspectNetwork failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Name": "backend"`) {
+		t.Errorf("Expected JSON output, got %q", buf.String())
+	}
+	if err := e.InspectNetwork(&buf, "missing", nil); err == nil {
+		t.Error("Expected an error for an unknown network")
+	}
+}
+
+// TestHumanDuration follows the wording of docker ps
+func TestHumanDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		500 * time.Millisecond:   "Less than a second",
+		time.Second:              "1 second",
+		45 * time.Second:         "45 seconds",
+		90 * time.Second:         "About a minute",
+		3 * time.Minute:          "3 minutes",
+		65 * time.Minute:         "About an hour",
+		5 * time.Hour:            "5 hours",
+		72 * time.Hour:           "3 days",
+		21 * 24 * time.Hour:      "3 weeks",
+		90 * 24 * time.Hour:      "3 months",
+		3 * 365 * 24 * time.Hour: "3 years",
+	} {
+		if got := humanDuration(d); got != want {
+			t.Errorf("humanDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
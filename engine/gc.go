@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Garbage collection of stopped containers. The age of a container is the
+// time since its process exited, as recorded in its config. Containers whose
+// engine was killed before recording it are aged by the time their directory
+// last changed, which is when the PID file was written.
+const (
+	gcMaxAgeEnv     = "BASIC_DOCKER_GC_MAX_AGE"
+	defaultGCMaxAge = 24 * time.Hour
+)
+
+// gcMaxAge returns the age after which gc removes stopped containers, from
+// BASIC_DOCKER_GC_MAX_AGE or defaultGCMaxAge.
+func (e *Engine) gcMaxAge() time.Duration {
+	if value := os.Getenv(gcMaxAgeEnv); value != "" {
+		if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+			return age
+		}
+		e.Logger.Warn("ignoring invalid gc max age", "env", gcMaxAgeEnv, "value", value)
+	}
+	return defaultGCMaxAge
+}
+
+// containerMounts returns the mount points below a container's directory,
+// deepest first, as left behind when the engine running it was killed.
+func (e *Engine) containerMounts(containerID string) []string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	prefix := filepath.Join(e.Root, "containers", containerID) + "/"
+	var mounts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		// Spaces in mount points are escaped as octal
+		mountPoint := strings.ReplaceAll(fields[1], `\040`, " ")
+		if strings.HasPrefix(mountPoint+"/", prefix) {
+			mounts = append(mounts, mountPoint)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(mounts)))
+	return mounts
+}
+
+// gcContainers removes the stopped containers older than maxAge along with
+// their cgroups and leftover mounts, and returns their IDs. Created, running
+// and paused containers are never removed.
+func (e *Engine) gcContainers(maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(e.Root, "containers"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers: %v", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		containerID := entry.Name()
+		config, err := e.loadContainerConfig(containerID)
+		if err != nil {
+			continue
+		}
+		if status := e.getContainerStatus(containerID); status == "Created" || isContainerActive(status) {
+			continue
+		}
+		finished := config.FinishedAt
+		if finished.IsZero() {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			finished = info.ModTime()
+		}
+		if time.Since(finished) < maxAge {
+			continue
+		}
+
+		for _, mountPoint := range e.containerMounts(containerID) {
+			if err := syscall.Unmount(mountPoint, syscall.MNT_DETACH); err != nil {
+				e.Logger.Warn("failed to unmount", "container", containerID, "path", mountPoint, "error", err)
+			}
+		}
+		if err := e.newCgroupManager().Destroy(containerID); err != nil {
+			e.Logger.Warn("failed to remove cgroups", "container", containerID, "error", err)
+		}
+		if err := e.removeContainer(containerID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, containerID)
+	}
+	return removed, nil
+}
+
+// gcCommand implements "gc [--max-age 24h]".
+func (e *Engine) gcCommand(args []string) {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	maxAge := fs.Duration("max-age", e.gcMaxAge(), "remove stopped containers that exited longer ago than this")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *maxAge < 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker gc [--max-age 24h]")
+		os.Exit(1)
+	}
+	removed, err := e.gcContainers(*maxAge)
+	for _, id := range removed {
+		fmt.Println(id)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d stale containers\n", len(removed))
+}
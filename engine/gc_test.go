@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestGCContainers removes only the stopped containers that exited longer
+// than the max age ago, keeping fresh, created and running ones
+func TestGCContainers(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	stale := time.Now().Add(-48 * time.Hour)
+	configs := []*ContainerConfig{
+		{ID: "stale-1", StartedAt: stale, FinishedAt: stale},
+		// Killed engines leave no finish time behind
+		{ID: "stale-2", StartedAt: stale},
+		{ID: "fresh", StartedAt: stale, FinishedAt: time.Now()},
+		{ID: "stale-created"},
+		{ID: "stale-running", StartedAt: stale},
+	}
+	for _, config := range configs {
+		config.Command = "sleep"
+		createTestContainer(t, e, config)
+	}
+	if err := e.reserveContainerName("old", "stale-1"); err != nil {
+		t.Fatalf("reserveContainerName failed: %v", err)
+	}
+	// A running container is recognized by the live process in its PID file
+	pid := strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", "stale-running", "pid"), []byte(pid), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	cgroup := e.containerCgroupPath("memory", "stale-1")
+	if err := os.MkdirAll(cgroup, 0755); err != nil {
+		t.Fatalf("Failed to create cgroup: %v", err)
+	}
+	for _, config := range configs {
+		if err := os.Chtimes(filepath.Join(e.Root, "containers", config.ID), stale, stale); err != nil {
+			t.Fatalf("Failed to age %s: %v", config.ID, err)
+		}
+	}
+
+	removed, err := e.gcContainers(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("gcContainers failed: %v", err)
+	}
+	if len(removed) != 2 || removed[0] != "stale-1" || removed[1] != "stale-2" {
+		t.Errorf("Expected the stale containers to be removed, got %v", removed)
+	}
+	for id, exists := range map[string]bool{"stale-1": false, "stale-2": false, "fresh": true, "stale-created": true, "stale-running": true} {
+		if _, err := os.Stat(filepath.Join(e.Root, "containers", id)); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v, got %v", id, exists, err)
+		}
+	}
+	if _, err := os.Stat(cgroup); !os.IsNotExist(err) {
+		t.Errorf("Expected the cgroup of a removed container to be removed, got %v", err)
+	}
+	if id := e.resolveContainerID("old"); id != "old" {
+		t.Errorf("Expected the name of a removed container to be released, got %s", id)
+	}
+}
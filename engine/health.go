@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	healthStarting  = "starting"
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+
+	defaultHealthInterval = 30 * time.Second
+	maxHealthOutput       = 4096
+)
+
+// HealthCheck describes a command run periodically inside a container to
+// determine whether its service is up.
+type HealthCheck struct {
+	Command  string        `json:"command"`
+	Interval time.Duration `json:"interval"`
+}
+
+// HealthState is the result of the most recent health check.
+type HealthState struct {
+	Status        string    `json:"status"`
+	FailingStreak int       `json:"failingStreak"`
+	LastCheck     time.Time `json:"lastCheck,omitempty"`
+	LastExitCode  int       `json:"lastExitCode"`
+	LastOutput    string    `json:"lastOutput,omitempty"`
+}
+
+// healthExec runs a health command inside a container and returns its
+// combined output and exit code. Tests replace it to avoid entering
+// namespaces.
+var healthExec = func(e *Engine, containerID, command string) (string, int, error) {
+	var out bytes.Buffer
+	cmd, err := e.containerExecCommand(containerID, "/bin/sh", []string{"-c", command})
+	if err != nil {
+		return "", -1, err
+	}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return out.String(), exitErr.ExitCode(), nil
+		}
+		return out.String(), -1, err
+	}
+	return out.String(), 0, nil
+}
+
+// checkContainerHealth runs the health command once and records the result in
+// the container's config.
+func (e *Engine) checkContainerHealth(containerID string, check *HealthCheck) (string, error) {
+	output, exitCode, err := healthExec(e, containerID, check.Command)
+	if err != nil {
+		output = err.Error()
+	}
+	if len(output) > maxHealthOutput {
+		output = output[:maxHealthOutput]
+	}
+
+	status := healthHealthy
+	if err != nil || exitCode != 0 {
+		status = healthUnhealthy
+	}
+
+	updateErr := e.updateContainerConfig(containerID, func(config *ContainerConfig) {
+		if config.Health == nil {
+			config.Health = &HealthState{}
+		}
+		config.Health.Status = status
+		config.Health.LastCheck = time.Now()
+		config.Health.LastExitCode = exitCode
+		config.Health.LastOutput = strings.TrimSpace(output)
+		if status == healthHealthy {
+			config.Health.FailingStreak = 0
+		} else {
+			config.Health.FailingStreak++
+		}
+	})
+	if updateErr != nil {
+		return "", fmt.Errorf("failed to record health of container %s: %v", containerID, updateErr)
+	}
+	return status, nil
+}
+
+// monitorContainerHealth runs the health check every interval until stop is
+// closed. Checks are skipped while the container is not running.
+func (e *Engine) monitorContainerHealth(containerID string, check *HealthCheck, stop <-chan struct{}) {
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if e.getContainerStatus(containerID) != "Running" {
+				continue
+			}
+			if _, err := e.checkContainerHealth(containerID, check); err != nil {
+				e.Logger.Warn("health check failed", "container", containerID, "error", err)
+			}
+		}
+	}
+}
+
+// containerHealthStatus returns the recorded health of a container, or an
+// empty string when it has no health check.
+func (e *Engine) containerHealthStatus(containerID string) string {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil || config.HealthCheck == nil {
+		return ""
+	}
+	if config.Health == nil {
+		return healthStarting
+	}
+	return config.Health.Status
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useHostHealthExec runs health commands on the host instead of entering the
+// container's namespaces.
+func useHostHealthExec(t *testing.T) {
+	t.Helper()
+	old := healthExec
+	healthExec = func(e *Engine, containerID, command string) (string, int, error) {
+		out, err := exec.Command("/bin/sh", "-c", command).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(out), exitErr.ExitCode(), nil
+		}
+		return string(out), 0, err
+	}
+	t.Cleanup(func() { healthExec = old })
+}
+
+// createTestContainer writes a config for a fake container.
+func createTestContainer(t *testing.T, e *Engine, config *ContainerConfig) {
+	t.Helper()
+	containerDir := filepath.Join(e.Root, "containers", config.ID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatalf("Failed to create container directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(containerDir) })
+	if err := e.saveContainerConfig(config); err != nil {
+		t.Fatalf("Failed to save container config: %v", err)
+	}
+}
+
+// TestHealthCheckTransitions verifies that a health command that starts
+// failing and then passes is recorded as unhealthy and then healthy.
+func TestHealthCheckTransitions(t *testing.T) {
+	e := newTestEngine(t)
+	useHostHealthExec(t)
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	check := &HealthCheck{Command: "test -f " + readyFile, Interval: time.Second}
+	containerID := "test-health-container"
+	createTestContainer(t, e, &ContainerConfig{ID: containerID, Command: "sleep", HealthCheck: check})
+
+	if status := e.containerHealthStatus(containerID); status != healthStarting {
+		t.Errorf("Expected initial health %q, got %q", healthStarting, status)
+	}
+
+	for i := 1; i <= 2; i++ {
+		status, err := e.checkContainerHealth(containerID, check)
+		if err != nil {
+			t.Fatalf("checkContainerHealth failed: %v", err)
+		}
+		if status != healthUnhealthy {
+			t.Errorf("Expected %q before the service is ready, got %q", healthUnhealthy, status)
+		}
+		config, _ := e.loadContainerConfig(containerID)
+		if config.Health.FailingStreak != i {
+			t.Errorf("Expected failing streak %d, got %d", i, config.Health.FailingStreak)
+		}
+	}
+
+	if err := os.WriteFile(readyFile, nil, 0644); err != nil {
+		t.Fatalf("Failed to create ready file: %v", err)
+	}
+	status, err := e.checkContainerHealth(containerID, check)
+	if err != nil {
+		t.Fatalf("checkContainerHealth failed: %v", err)
+	}
+	if status != healthHealthy {
+		t.Errorf("Expected %q once the service is ready, got %q", healthHealthy, status)
+	}
+
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		t.Fatalf("loadContainerConfig failed: %v", err)
+	}
+	if config.Health.Status != healthHealthy || config.Health.FailingStreak != 0 || config.Health.LastExitCode != 0 {
+		t.Errorf("Unexpected recorded health: %+v", config.Health)
+	}
+	if e.containerHealthStatus(containerID) != healthHealthy {
+		t.Errorf("Expected containerHealthStatus to report %q", healthHealthy)
+	}
+}
+
+// TestHealthShownInPsAndInspect verifies that ps and inspect surface health.
+func TestHealthShownInPsAndInspect(t *testing.T) {
+	e := newTestEngine(t)
+	containerID := "test-health-ps"
+	createTestContainer(t, e, &ContainerConfig{
+		ID:          containerID,
+		Command:     "sleep",
+		Args:        []string{"60"},
+		StartedAt:   time.Now(),
+		HealthCheck: &HealthCheck{Command: "true", Interval: time.Second},
+		Health:      &HealthState{Status: healthUnhealthy},
+	})
+
+	output := captureOutput(func() { e.listContainers() })
+	if !strings.Contains(output, "("+healthUnhealthy+")") || !strings.Contains(output, "sleep 60") {
+		t.Errorf("Expected ps to show health and command, got: %s", output)
+	}
+
+	info, err := e.inspectContainer(containerID)
+	if err != nil {
+		t.Fatalf("inspectContainer failed: %v", err)
+	}
+	if info.Health == nil || info.Health.Status != healthUnhealthy || info.Status != "Stopped" {
+		t.Errorf("Unexpected inspect result: %+v", info)
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// HistoryEntry is one step of an image's history as listed by history.
+type HistoryEntry struct {
+	// Layer is the digest or ID of the layer the step created, empty for
+	// steps that created none.
+	Layer     string `json:"layer,omitempty"`
+	Size      int64  `json:"size"`
+	Created   string `json:"created,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// imageLayerEntries returns a history entry per layer of an image, bottom
+// first: the layers of its manifest for pulled images, its local layers
+// otherwise.
+func (e *Engine) imageLayerEntries(ref string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	data, err := os.ReadFile(filepath.Join(e.imageStorePath(ref), imageManifestFile))
+	if err == nil {
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse image manifest: %v", err)
+		}
+		for _, layer := range manifest.Layers {
+			entries = append(entries, HistoryEntry{Layer: layer.Digest, Size: layer.Size})
+		}
+		return entries, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read image manifest: %v", err)
+	}
+
+	dirs, err := e.imageLayerDirs(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		size, _ := calculateDirSize(dir)
+		entries = append(entries, HistoryEntry{Layer: filepath.Base(dir), Size: size})
+	}
+	return entries, nil
+}
+
+// imageHistory lists the steps that built an image, oldest first. The steps
+// of the config's history are matched with the layers in order; without a
+// history each layer is a step of its own.
+func (e *Engine) imageHistory(ref string) ([]HistoryEntry, error) {
+	if _, err := os.Stat(e.imageStorePath(ref)); os.IsNotExist(err) {
+		return nil, fmt.Errorf("image %s does not exist", normalizeImageRef(ref))
+	}
+	layers, err := e.imageLayerEntries(ref)
+	if err != nil {
+		return nil, err
+	}
+	config, err := e.loadImageConfig(ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.History) == 0 {
+		return layers, nil
+	}
+
+	var history []HistoryEntry
+	for _, step := range config.History {
+		entry := HistoryEntry{Created: step.Created, CreatedBy: step.CreatedBy, Comment: step.Comment}
+		if !step.EmptyLayer && len(layers) > 0 {
+			entry.Layer, entry.Size = layers[0].Layer, layers[0].Size
+			layers = layers[1:]
+		}
+		history = append(history, entry)
+	}
+	// Layers the history does not account for are listed last
+	return append(history, layers...), nil
+}
+
+// writeImageHistory prints the history of an image as a table, or with tmpl
+// when it is set.
+func (e *Engine) writeImageHistory(w io.Writer, ref string, tmpl *template.Template) error {
+	history, err := e.imageHistory(ref)
+	if err != nil {
+		return err
+	}
+	if tmpl != nil {
+		for _, entry := range history {
+			if err := writeFormatted(w, tmpl, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintln(w, "LAYER\tSIZE\tCREATED\tCREATED BY")
+	for _, entry := range history {
+		layer := entry.Layer
+		if layer == "" {
+			layer = "<missing>"
+		}
+		fmt.Fprintf(w, "%s\t%d bytes\t%s\t%s\n", layer, entry.Size, entry.Created, entry.CreatedBy)
+	}
+	return nil
+}
+
+// historyCommand implements "history [--format json|template] <image>".
+func (e *Engine) historyCommand(args []string) {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "json, or a Go template to print each step with")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker history [--format json|template] <image>")
+		os.Exit(1)
+	}
+	var tmpl *template.Template
+	if *format != "" {
+		if *format == "json" {
+			*format = "{{json .}}"
+		}
+		var err error
+		if tmpl, err = parseFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := e.writeImageHistory(os.Stdout, fs.Arg(0), tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
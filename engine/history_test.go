@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestImageHistory prints the history of an image whose config has a step
+// without a layer, expecting the steps in order with their layers
+func TestImageHistory(t *testing.T) {
+	e := newTestEngine(t)
+	imageDir := e.imageStorePath("history-test")
+	if err := os.MkdirAll(filepath.Join(imageDir, "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	files := map[string]string{
+		imageManifestFile: `{"layers": [{"digest": "sha256:base", "size": 100}, {"digest": "sha256:app", "size": 20}]}`,
+		imageConfigFile: `{"history": [
+			{"created": "2024-01-01T00:00:00Z", "created_by": "/bin/sh -c #(nop) ADD file:base in /"},
+			{"created": "2024-01-01T00:00:01Z", "created_by": "/bin/sh -c #(nop) CMD [\"sh\"]", "empty_layer": true},
+			{"created": "2024-01-02T00:00:00Z", "created_by": "/bin/sh -c apk add app"}
+		]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(imageDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := e.writeImageHistory(&buf, "history-test", nil); err != nil {
+		t.Fatalf("writeImageHistory failed: %v", err)
+	}
+	want := []string{
+		"LAYER\tSIZE\tCREATED\tCREATED BY",
+		"sha256:base\t100 bytes\t2024-01-01T00:00:00Z\t/bin/sh -c #(nop) ADD file:base in /",
+		"<missing>\t0 bytes\t2024-01-01T00:00:01Z\t/bin/sh -c #(nop) CMD [\"sh\"]",
+		"sha256:app\t20 bytes\t2024-01-02T00:00:00Z\t/bin/sh -c apk add app",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected history:\n%s\nwant:\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+
+	tmpl, err := parseFormat("{{json .}}")
+	if err != nil {
+		t.Fatalf("parseFormat failed: %v", err)
+	}
+	buf.Reset()
+	if err := e.writeImageHistory(&buf, "history-test", tmpl); err != nil {
+		t.Fatalf("writeImageHistory failed: %v", err)
+	}
+	var layers []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected a JSON entry per line, got %q: %v", line, err)
+		}
+		layers = append(layers, entry.Layer)
+	}
+	if strings.Join(layers, ",") != "sha256:base,,sha256:app" {
+		t.Errorf("Expected the JSON entries in order, got %v", layers)
+	}
+
+	if err := e.writeImageHistory(&buf, "missing", nil); err == nil {
+		t.Error("Expected an error for a missing image")
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseHostEntry parses a run --add-host value, name:ip. The IP may be IPv6,
+// so only the first colon separates it from the name.
+func parseHostEntry(value string) (name, ip string, err error) {
+	name, ip, ok := strings.Cut(value, ":")
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid --add-host %q, expected name:ip", value)
+	}
+	if net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("invalid --add-host %q: %q is not an IP address", value, ip)
+	}
+	return name, ip, nil
+}
+
+// rootfsEtcFile returns the host path of /etc/<name> in a rootfs, following
+// symlinks as the container would. /etc is created if the image has none; a
+// dangling symlink is rejected, as writing through it would leave the rootfs.
+func rootfsEtcFile(rootfs, name string) (string, error) {
+	etc, err := resolveInRootfs(rootfs, "/etc")
+	if os.IsNotExist(err) {
+		etc = filepath.Join(rootfs, "etc")
+		err = os.MkdirAll(etc, 0755)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve /etc: %v", err)
+	}
+	path := filepath.Join(etc, name)
+	resolved, err := resolveInRootfs(rootfs, "/etc/"+name)
+	if err == nil {
+		return resolved, nil
+	}
+	if _, lerr := os.Lstat(path); !os.IsNotExist(err) || lerr == nil {
+		return "", fmt.Errorf("failed to resolve /etc/%s: %v", name, err)
+	}
+	return path, nil
+}
+
+// addHostsEntries appends name:ip entries to the /etc/hosts of a rootfs,
+// creating the file if the image has none and keeping its entries.
+func addHostsEntries(rootfs string, entries []string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	path, err := rootfsEtcFile(rootfs, "hosts")
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read /etc/hosts: %v", err)
+	}
+	var lines strings.Builder
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		lines.WriteString("\n")
+	}
+	for _, entry := range entries {
+		name, ip, err := parseHostEntry(entry)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&lines, "%s\t%s\n", ip, name)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open /etc/hosts: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(lines.String()); err != nil {
+		return fmt.Errorf("failed to write /etc/hosts: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseHostEntry(t *testing.T) {
+	for value, want := range map[string][2]string{
+		"db:10.0.0.5":      {"db", "10.0.0.5"},
+		"v6.local:fe80::1": {"v6.local", "fe80::1"},
+	} {
+		name, ip, err := parseHostEntry(value)
+		if err != nil || name != want[0] || ip != want[1] {
+			t.Errorf("parseHostEntry(%q) = %q, %q, %v, want %q, %q", value, name, ip, err, want[0], want[1])
+		}
+	}
+	for _, value := range []string{"db", ":10.0.0.5", "db:", "db:not-an-ip", "my db:10.0.0.5"} {
+		if _, _, err := parseHostEntry(value); err == nil {
+			t.Errorf("Expected parseHostEntry(%q) to fail", value)
+		}
+	}
+}
+
+// TestAddHostsEntries appends to an existing hosts file, creates a missing
+// one and refuses to follow a dangling symlink
+func TestAddHostsEntries(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, "etc"), 0755); err != nil {
+		t.Fatalf("Failed to create etc: %v", err)
+	}
+	hosts := filepath.Join(rootfs, "etc", "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1\tlocalhost"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts: %v", err)
+	}
+	if err := addHostsEntries(rootfs, []string{"db:10.0.0.5", "cache:10.0.0.6"}); err != nil {
+		t.Fatalf("addHostsEntries failed: %v", err)
+	}
+	if data, _ := os.ReadFile(hosts); string(data) != "127.0.0.1\tlocalhost\n10.0.0.5\tdb\n10.0.0.6\tcache\n" {
+		t.Errorf("Unexpected hosts file: %q", data)
+	}
+
+	empty := t.TempDir()
+	if err := addHostsEntries(empty, []string{"db:10.0.0.5"}); err != nil {
+		t.Fatalf("addHostsEntries failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(empty, "etc", "hosts")); string(data) != "10.0.0.5\tdb\n" {
+		t.Errorf("Expected a new hosts file, got %q", data)
+	}
+
+	dangling := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "hosts")
+	os.MkdirAll(filepath.Join(dangling, "etc"), 0755)
+	if err := os.Symlink(outside, filepath.Join(dangling, "etc", "hosts")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := addHostsEntries(dangling, []string{"db:10.0.0.5"}); err == nil {
+		t.Error("Expected a dangling hosts symlink to be rejected")
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the rootfs, got %v", err)
+	}
+}
+
+// TestRunAddHost runs with --add-host and checks the container's hosts file
+func TestRunAddHost(t *testing.T) {
+	e := newTestEngine(t)
+	imageRootfs := filepath.Join(e.imageStorePath("local:latest"), "rootfs")
+	if err := os.MkdirAll(filepath.Join(imageRootfs, "etc"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(imageRootfs, "etc", "hosts"), []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts: %v", err)
+	}
+
+	opts, err := e.parseRunArgs([]string{"--add-host", "db:10.0.0.5", "local", "true"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	config, err := e.prepareContainer(opts)
+	if err != nil {
+		t.Fatalf("prepareContainer failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(e.containerRootfs(config.ID), "etc", "hosts"))
+	if err != nil {
+		t.Fatalf("Failed to read the container's hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "127.0.0.1\tlocalhost\n") || !strings.Contains(string(data), "10.0.0.5\tdb\n") {
+		t.Errorf("Expected the image's entries and db in the hosts file, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(imageRootfs, "etc", "hosts")); strings.Contains(string(data), "db") {
+		t.Error("Expected the image's hosts file to be left alone")
+	}
+
+	if _, err := e.parseRunArgs([]string{"--add-host", "db", "local", "true"}); err == nil {
+		t.Error("Expected an invalid --add-host to be rejected")
+	}
+}
@@ -0,0 +1,1101 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ListImages lists all available images
+func (e *Engine) ListImages() {
+	imageDir := e.imagesDir()
+	fmt.Fprintln(os.Stderr, "IMAGE NAME\tSIZE")
+
+	if _, err := os.Stat(imageDir); os.IsNotExist(err) {
+		return
+	}
+
+	entries, err := os.ReadDir(imageDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading images: %v\n", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			size, err := calculateDirSize(filepath.Join(imageDir, entry.Name()))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error calculating size of image %s: %v\n", entry.Name(), err)
+			} else {
+				fmt.Printf("%s\t%d bytes\n", entry.Name(), size)
+			}
+		}
+	}
+}
+
+// calculateDirSize calculates the total size of a directory
+func calculateDirSize(dirPath string) (int64, error) {
+	var totalSize int64
+	err := filepath.Walk(dirPath, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			totalSize += info.Size()
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return totalSize, nil
+}
+
+// defaultImageTag is used when an image reference does not name a tag
+const defaultImageTag = "latest"
+
+// parseImageRef splits an image reference into its repository and tag,
+// defaulting the tag to "latest". A colon only starts the tag when it comes
+// after the last slash, so registry ports stay part of the repository. For a
+// digest reference, repo@sha256:..., the digest is returned as the tag.
+func parseImageRef(ref string) (repo, tag string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	repo = ref
+	tag = defaultImageTag
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo = ref[:i]
+		if ref[i+1:] != "" {
+			tag = ref[i+1:]
+		}
+	}
+	return repo, tag
+}
+
+// isImageDigest reports whether tag is a sha256 digest rather than a tag.
+func isImageDigest(tag string) bool {
+	hexDigest, ok := strings.CutPrefix(tag, "sha256:")
+	if !ok || len(hexDigest) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hexDigest)
+	return err == nil && strings.ToLower(hexDigest) == hexDigest
+}
+
+// validateImageRef checks that an image reference is well formed
+func validateImageRef(ref string) error {
+	repo, tag := parseImageRef(ref)
+	if repo == "" {
+		return fmt.Errorf("invalid image reference %q: repository is empty", ref)
+	}
+	for _, c := range repo {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("._-/:", c)) {
+			return fmt.Errorf("invalid image reference %q: repository may only contain lowercase letters, digits and . _ - / :", ref)
+		}
+	}
+	if strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") || strings.Contains(repo, "//") {
+		return fmt.Errorf("invalid image reference %q: empty path component", ref)
+	}
+	if strings.Contains(ref, "@") {
+		if !isImageDigest(tag) {
+			return fmt.Errorf("invalid image reference %q: digest must be sha256: followed by 64 lowercase hex digits", ref)
+		}
+		return nil
+	}
+	if len(tag) > 128 {
+		return fmt.Errorf("invalid image reference %q: tag is longer than 128 characters", ref)
+	}
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._-", c)) {
+			return fmt.Errorf("invalid image reference %q: tag may only contain letters, digits and . _ -", ref)
+		}
+	}
+	return nil
+}
+
+// normalizeImageRef returns the canonical repo:tag or repo@digest form of an
+// image reference
+func normalizeImageRef(ref string) string {
+	repo, tag := parseImageRef(ref)
+	if isImageDigest(tag) {
+		return repo + "@" + tag
+	}
+	return repo + ":" + tag
+}
+
+// splitRegistryHost separates a registry host from a repository. As with
+// Docker, the first path component is only a host if it contains a "." or a
+// ":" or is "localhost".
+func splitRegistryHost(repo string) (host, path string) {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	return "", repo
+}
+
+// imageStorePath returns the directory an image is stored under. Slashes in
+// the repository are flattened so every image is a direct child of imagesDir.
+func (e *Engine) imageStorePath(ref string) string {
+	repo, tag := parseImageRef(ref)
+	separator := ":"
+	if isImageDigest(tag) {
+		separator = "@"
+	}
+	return filepath.Join(e.imagesDir(), strings.ReplaceAll(repo, "/", "_")+separator+tag)
+}
+
+// Image represents a container image
+type Image struct {
+	Name   string
+	RootFS string
+	Layers []string
+	// Digest is the digest of the manifest the image was pulled from.
+	Digest  string
+	Created time.Time
+	// Config is the image config, empty for images created without one.
+	Config *ImageConfig `json:",omitempty"`
+}
+
+// Registry represents a generic interface for interacting with container registries
+type Registry interface {
+	FetchManifest(repo, tag string) (*Manifest, error)
+	FetchLayer(repo, digest string) (io.ReadCloser, error)
+}
+
+// DockerHubRegistry is a default implementation of the Registry interface for Docker Hub or custom registries.
+type DockerHubRegistry struct {
+	BaseURL string
+	// MaxAttempts bounds the requests made for a transient failure. Zero uses
+	// BASIC_DOCKER_PULL_ATTEMPTS or defaultRegistryAttempts.
+	MaxAttempts int
+	// RetryDelay is the initial backoff between attempts. Zero uses
+	// defaultRegistryRetryDelay.
+	RetryDelay time.Duration
+	// Client sends the registry requests. NewDockerHubRegistry defaults it to
+	// a client with the default registry timeouts; nil uses
+	// http.DefaultClient.
+	Client *http.Client
+	// Authorization, when set, is sent as the Authorization header of every
+	// request to the registry, for example "Bearer <token>".
+	Authorization string
+	// Logger receives retry messages. Nil uses slog.Default().
+	Logger *slog.Logger
+}
+
+// Timeouts of the default registry client. Layer downloads can take long, so
+// instead of bounding the whole request the client bounds connecting and
+// waiting for the registry to start responding.
+const (
+	defaultRegistryConnectTimeout  = 30 * time.Second
+	defaultRegistryResponseTimeout = 60 * time.Second
+)
+
+// newRegistryClient returns a client that gives up when connecting takes
+// longer than connect or the response headers take longer than response.
+// Proxies are taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func newRegistryClient(connect, response time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   connect,
+			ResponseHeaderTimeout: response,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   4,
+		},
+		CheckRedirect: registryCheckRedirect,
+	}
+}
+
+// registryCheckRedirect follows redirects like the default policy but drops
+// the Authorization header once a redirect leaves the registry host. Blob
+// downloads redirect to CDNs, which reject requests carrying the registry's
+// credentials.
+func registryCheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// RegistryOption configures a DockerHubRegistry created by NewDockerHubRegistry.
+type RegistryOption func(*DockerHubRegistry)
+
+// WithHTTPClient makes the registry send its requests with client.
+func WithHTTPClient(client *http.Client) RegistryOption {
+	return func(r *DockerHubRegistry) {
+		r.Client = client
+	}
+}
+
+// WithTimeouts sets the connect and response timeouts of the registry client.
+func WithTimeouts(connect, response time.Duration) RegistryOption {
+	return func(r *DockerHubRegistry) {
+		r.Client = newRegistryClient(connect, response)
+	}
+}
+
+// WithLogger makes the registry log retries to logger.
+func WithLogger(logger *slog.Logger) RegistryOption {
+	return func(r *DockerHubRegistry) {
+		r.Logger = logger
+	}
+}
+
+// WithAuthorization sets the Authorization header sent to the registry.
+func WithAuthorization(value string) RegistryOption {
+	return func(r *DockerHubRegistry) {
+		r.Authorization = value
+	}
+}
+
+// WithMaxAttempts sets how many requests are made for a transient failure.
+func WithMaxAttempts(attempts int) RegistryOption {
+	return func(r *DockerHubRegistry) {
+		r.MaxAttempts = attempts
+	}
+}
+
+// httpClient returns the client used for registry requests.
+func (r *DockerHubRegistry) httpClient() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+// log returns the logger of the registry.
+func (r *DockerHubRegistry) log() *slog.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return slog.Default()
+}
+
+// Retry settings for registry requests.
+const (
+	registryAttemptsEnv       = "BASIC_DOCKER_PULL_ATTEMPTS"
+	defaultRegistryAttempts   = 4
+	defaultRegistryRetryDelay = 500 * time.Millisecond
+	maxRegistryRetryDelay     = 30 * time.Second
+)
+
+// retryableStatus reports whether a registry response signals a transient
+// failure. Client errors such as 401 and 404 are not retried.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// maxAttempts returns how many requests are made before giving up.
+func (r *DockerHubRegistry) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	if value := os.Getenv(registryAttemptsEnv); value != "" {
+		if attempts, err := strconv.Atoi(value); err == nil && attempts > 0 {
+			return attempts
+		}
+		r.log().Warn("ignoring invalid pull attempts", "env", registryAttemptsEnv, "value", value)
+	}
+	return defaultRegistryAttempts
+}
+
+// backoff returns the delay before retrying after the given attempt: an
+// exponentially growing delay with jitter so that clients do not retry in
+// lockstep.
+func (r *DockerHubRegistry) backoff(attempt int) time.Duration {
+	delay := r.RetryDelay
+	if delay <= 0 {
+		delay = defaultRegistryRetryDelay
+	}
+	delay <<= min(attempt-1, 16)
+	if delay > maxRegistryRetryDelay || delay <= 0 {
+		delay = maxRegistryRetryDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// get issues a GET request, retrying transient failures with backoff and
+// honoring Retry-After. The last response is returned when attempts run out
+// so the caller can report its status. A non-empty accept is sent as the
+// Accept header.
+func (r *DockerHubRegistry) get(url, accept string) (*http.Response, error) {
+	header := http.Header{}
+	if accept != "" {
+		header.Set("Accept", accept)
+	}
+	return r.getWithHeader(url, header)
+}
+
+// getWithHeader is get with arbitrary request headers.
+func (r *DockerHubRegistry) getWithHeader(url string, header http.Header) (*http.Response, error) {
+	attempts := r.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if r.Authorization != "" {
+			req.Header.Set("Authorization", r.Authorization)
+		}
+		resp, err := r.httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !retryableStatus(resp.StatusCode) || attempt >= attempts {
+			return resp, nil
+		}
+
+		delay := r.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			delay = min(retryAfter, maxRegistryRetryDelay)
+		}
+		resp.Body.Close()
+		r.log().Debug("retrying registry request", "url", url, "status", resp.StatusCode, "attempt", attempt, "delay", delay)
+		time.Sleep(delay)
+	}
+}
+
+// NewDockerHubRegistry creates a new instance of DockerHubRegistry with an optional custom registry URL.
+func NewDockerHubRegistry(customURL string, opts ...RegistryOption) *DockerHubRegistry {
+	if customURL == "" {
+		customURL = "https://registry-1.docker.io/v2/"
+	}
+	registry := &DockerHubRegistry{
+		BaseURL: customURL,
+	}
+	for _, opt := range opts {
+		opt(registry)
+	}
+	if registry.Client == nil {
+		registry.Client = newRegistryClient(defaultRegistryConnectTimeout, defaultRegistryResponseTimeout)
+	}
+	return registry
+}
+
+// manifestMediaTypes are the manifest formats accepted from registries. Lists
+// and indexes are resolved to a single manifest by Pull.
+var manifestMediaTypes = strings.Join([]string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}, ", ")
+
+// FetchManifest fetches the manifest for a given repository and tag or
+// digest.
+func (r *DockerHubRegistry) FetchManifest(repo, tag string) (*Manifest, error) {
+	url := fmt.Sprintf("%s%s/manifests/%s", r.BaseURL, repo, tag)
+	resp, err := r.get(url, manifestMediaTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, registryError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return decodeManifest(data)
+}
+
+// decodeManifest parses a manifest as served by a registry and sets its
+// digest.
+func decodeManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	manifest.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	return &manifest, nil
+}
+
+// FetchLayer fetches a specific layer by its digest.
+func (r *DockerHubRegistry) FetchLayer(repo, digest string) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s%s/blobs/%s", r.BaseURL, repo, digest)
+	resp, err := r.get(url, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, registryError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// FetchLayerFrom fetches a layer from offset on with a range request. It
+// returns the offset the body starts at, which is 0 when the registry sends
+// the whole layer.
+func (r *DockerHubRegistry) FetchLayerFrom(repo, digest string, offset int64) (io.ReadCloser, int64, error) {
+	if offset == 0 {
+		body, err := r.FetchLayer(repo, digest)
+		return body, 0, err
+	}
+	url := fmt.Sprintf("%s%s/blobs/%s", r.BaseURL, repo, digest)
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := r.getWithHeader(url, header)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, 0, nil
+	case http.StatusPartialContent:
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+		return resp.Body, offset, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download is not a prefix of the layer
+		resp.Body.Close()
+		return r.FetchLayerFrom(repo, digest, 0)
+	}
+	defer resp.Body.Close()
+	return nil, 0, registryError(resp)
+}
+
+// maxRegistryErrorBody bounds how much of an error response is read.
+const maxRegistryErrorBody = 64 << 10
+
+// registryErrorEnvelope is the body of a registry error response.
+type registryErrorEnvelope struct {
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// registryError returns the error of an unsuccessful registry response,
+// with the codes and messages of its error body when it has one.
+func registryError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxRegistryErrorBody))
+	var envelope registryErrorEnvelope
+	if json.Unmarshal(data, &envelope) != nil || len(envelope.Errors) == 0 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	messages := make([]string, len(envelope.Errors))
+	for i, e := range envelope.Errors {
+		messages[i] = e.Message
+		if e.Code != "" {
+			messages[i] = e.Code + ": " + e.Message
+		}
+	}
+	return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.Join(messages, "; "))
+}
+
+// Manifest represents the structure of an image manifest. For a manifest
+// list or OCI index only Manifests is set.
+type Manifest struct {
+	// Digest is the sha256 of the manifest as served by the registry.
+	Digest string `json:"-"`
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Layers    []ManifestLayer `json:"layers"`
+	Manifests []struct {
+		Digest   string   `json:"digest"`
+		Platform Platform `json:"platform"`
+	} `json:"manifests"`
+}
+
+// ManifestLayer is a layer of an image manifest.
+type ManifestLayer struct {
+	// MediaType names the layer's compression, such as
+	// application/vnd.oci.image.layer.v1.tar+zstd.
+	MediaType string `json:"mediaType,omitempty"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// PullPlatform downloads an image for platform using the provided registry.
+func (e *Engine) PullPlatform(registry Registry, name string, platform Platform) (*Image, error) {
+	return e.PullWithOptions(registry, name, PullOptions{Platform: platform})
+}
+
+// defaultPullConcurrency is how many layers are downloaded and extracted at
+// once when PullOptions.Concurrency is unset.
+const defaultPullConcurrency = 3
+
+// layerExpansionFactor estimates the uncompressed size of a layer from its
+// compressed size, which is all a manifest records.
+const layerExpansionFactor = 2
+
+// availableDiskSpace returns the bytes available to unprivileged users on the
+// filesystem holding path. Tests replace it.
+var availableDiskSpace = func(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// PullOptions are the settings of a pull.
+type PullOptions struct {
+	// Platform selects the manifest of a manifest list; the zero Platform
+	// selects the host platform.
+	Platform Platform
+	// Concurrency caps how many layers are downloaded and extracted at once.
+	Concurrency int
+}
+
+// PullWithOptions downloads an image using the provided registry. When the
+// tag names a manifest list, the manifest for opts.Platform is pulled.
+func (e *Engine) PullWithOptions(registry Registry, name string, opts PullOptions) (*Image, error) {
+	ctx, span := e.startSpan(context.Background(), spanPull, attribute{"image", name})
+	image, err := e.pullWithOptions(ctx, registry, name, opts)
+	if err == nil {
+		span.SetAttributes(attribute{"digest", image.Digest})
+	}
+	endSpan(span, err)
+	return image, err
+}
+
+// pullWithOptions is PullWithOptions with the spans of the pull started
+// under ctx.
+func (e *Engine) pullWithOptions(ctx context.Context, registry Registry, name string, opts PullOptions) (*Image, error) {
+	e.Logger.Debug("starting to pull image", "image", name)
+	platform := opts.Platform
+	if platform == (Platform{}) {
+		platform = hostPlatform()
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPullConcurrency
+	}
+
+	// Split the image name into repository and tag
+	repo, tag := parseImageRef(name)
+	_, remoteRepo := splitRegistryHost(repo)
+
+	e.Logger.Debug("fetching manifest", "repo", remoteRepo, "tag", tag)
+	// Fetch the image manifest
+	manifest, err := e.fetchManifest(ctx, registry, remoteRepo, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	// As in Docker, the image's digest is that of the manifest the reference
+	// names, which is the manifest list when there is one
+	digest := manifest.Digest
+	if isImageDigest(tag) && digest != tag {
+		return nil, fmt.Errorf("manifest digest %s does not match the requested %s", digest, tag)
+	}
+	if len(manifest.Manifests) > 0 {
+		platformDigest, err := selectPlatformManifest(manifest, platform)
+		if err != nil {
+			return nil, err
+		}
+		e.Logger.Debug("fetching platform manifest", "platform", platform, "digest", platformDigest)
+		manifest, err = e.fetchManifest(ctx, registry, remoteRepo, platformDigest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest for %s: %w", platform, err)
+		}
+	}
+
+	e.Logger.Debug("manifest fetched", "layers", len(manifest.Layers))
+
+	// Download and extract layers
+	rootfs := filepath.Join(e.imageStorePath(name), "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs: %w", err)
+	}
+
+	if manifest.Config.Digest != "" {
+		if err := saveImageConfigBlob(registry, remoteRepo, manifest.Config.Digest, e.imageStorePath(name)); err != nil {
+			e.Logger.Warn("failed to fetch image config", "image", name, "error", err)
+		}
+	}
+
+	if err := e.checkDiskSpace(manifest, rootfs); err != nil {
+		return nil, err
+	}
+	if err := e.extractLayers(ctx, registry, remoteRepo, manifest.Layers, rootfs, concurrency); err != nil {
+		return nil, err
+	}
+
+	if err := recordRootfsDigest(e.imageStorePath(name)); err != nil {
+		e.Logger.Warn("failed to record rootfs digest", "image", name, "error", err)
+	}
+	if err := os.WriteFile(filepath.Join(e.imageStorePath(name), imageDigestFile), []byte(digest+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to record image digest: %w", err)
+	}
+	if data, err := json.Marshal(manifest); err == nil {
+		if err := os.WriteFile(filepath.Join(e.imageStorePath(name), imageManifestFile), data, 0644); err != nil {
+			e.Logger.Warn("failed to record image manifest", "image", name, "error", err)
+		}
+	}
+	e.Logger.Debug("image pulled", "image", name, "rootfs", rootfs, "digest", digest)
+	image := &Image{
+		Name:   normalizeImageRef(name),
+		RootFS: rootfs,
+		Layers: []string{"base"},
+		Digest: digest,
+	}
+	e.recordImageMetadata(image)
+	return image, nil
+}
+
+// fetchManifest fetches a manifest in a manifest fetch span under ctx.
+func (e *Engine) fetchManifest(ctx context.Context, registry Registry, repo, reference string) (*Manifest, error) {
+	_, span := e.startSpan(ctx, spanManifestFetch, attribute{"repo", repo}, attribute{"reference", reference})
+	manifest, err := registry.FetchManifest(repo, reference)
+	if err == nil {
+		span.SetAttributes(attribute{"digest", manifest.Digest}, attribute{"layers", len(manifest.Layers)})
+	}
+	endSpan(span, err)
+	return manifest, err
+}
+
+// checkDiskSpace fails when the filesystem holding rootfs lacks room for the
+// estimated uncompressed size of the manifest's layers.
+func (e *Engine) checkDiskSpace(manifest *Manifest, rootfs string) error {
+	var needed uint64
+	for _, layer := range manifest.Layers {
+		if layer.Size > 0 {
+			needed += uint64(layer.Size) * layerExpansionFactor
+		}
+	}
+	if needed == 0 {
+		return nil
+	}
+	available, err := availableDiskSpace(rootfs)
+	if err != nil {
+		e.Logger.Warn("failed to check free disk space", "path", rootfs, "error", err)
+		return nil
+	}
+	if available < needed {
+		return fmt.Errorf("not enough space in %s: image needs about %d bytes, %d available", rootfs, needed, available)
+	}
+	return nil
+}
+
+// extractLayers downloads and extracts up to concurrency layers at once, each
+// into its own directory next to rootfs, and applies them to rootfs in order
+// as they become ready.
+func (e *Engine) extractLayers(ctx context.Context, registry Registry, repo string, layers []ManifestLayer, rootfs string, concurrency int) error {
+	work, err := os.MkdirTemp(filepath.Dir(rootfs), ".pull-")
+	if err != nil {
+		return fmt.Errorf("failed to create layer directory: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// Layers not started yet are skipped once a layer fails
+	stop := make(chan struct{})
+	defer close(stop)
+
+	slots := make(chan struct{}, concurrency)
+	errs := make([]error, len(layers))
+	done := make([]chan struct{}, len(layers))
+	for i, layer := range layers {
+		done[i] = make(chan struct{})
+		wg.Add(1)
+		go func(i int, layer ManifestLayer) {
+			defer wg.Done()
+			defer close(done[i])
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			defer func() { <-slots }()
+			errs[i] = e.fetchLayer(ctx, registry, repo, layer, filepath.Join(work, strconv.Itoa(i)))
+		}(i, layer)
+	}
+
+	for i, layer := range layers {
+		<-done[i]
+		if errs[i] != nil {
+			return errs[i]
+		}
+		dir := filepath.Join(work, strconv.Itoa(i))
+		e.Logger.Debug("applying layer", "digest", layer.Digest)
+		if err := e.mergeLayer(dir, rootfs); err != nil {
+			return fmt.Errorf("failed to extract layer %s: %w", layer.Digest, err)
+		}
+		os.RemoveAll(dir)
+	}
+	return nil
+}
+
+// fetchLayer downloads a layer, through the blob cache when it has a sha256
+// digest, and extracts it into dir, in a download and an extract span under
+// ctx.
+func (e *Engine) fetchLayer(ctx context.Context, registry Registry, repo string, layer ManifestLayer, dir string) error {
+	digest := layer.Digest
+	attrs := []attribute{{"digest", digest}, {"size", layer.Size}}
+	e.Logger.Debug("downloading layer", "digest", digest)
+	_, span := e.startSpan(ctx, spanLayerDownload, attrs...)
+	reader, err := e.downloadLayer(registry, repo, digest)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create layer directory: %w", err)
+	}
+	e.Logger.Debug("extracting layer", "digest", digest, "mediaType", layer.MediaType)
+	_, span = e.startSpan(ctx, spanLayerExtract, attrs...)
+	err = unpackLayer(reader, layer.MediaType, dir)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to extract layer %s: %w", digest, err)
+	}
+	return nil
+}
+
+// downloadLayer returns the content of a layer, downloaded through the blob
+// cache when it has a sha256 digest.
+func (e *Engine) downloadLayer(registry Registry, repo, digest string) (io.ReadCloser, error) {
+	if !isImageDigest(digest) {
+		// Without a sha256 digest the layer can be neither verified nor
+		// cached
+		reader, err := registry.FetchLayer(repo, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download layer %s: %w", digest, err)
+		}
+		return reader, nil
+	}
+	path, err := e.downloadBlob(registry, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layer %s: %w", digest, err)
+	}
+	return reader, nil
+}
+
+const imageConfigFile = "config.json"
+
+// imageDigestFile records the manifest digest of a pulled image.
+const imageDigestFile = "manifest.sha256"
+
+// imageManifestFile keeps the manifest of a pulled image, resolved to the
+// pulled platform, for its layer digests and sizes.
+const imageManifestFile = "manifest.json"
+
+// loadImageDigest returns the manifest digest of an image, or "" for images
+// that were not pulled from a registry.
+func (e *Engine) loadImageDigest(ref string) string {
+	data, err := os.ReadFile(filepath.Join(e.imageStorePath(ref), imageDigestFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// resolveImageDigestRef returns the local image a repo@digest reference
+// names: the image stored under it or any image of the repository pulled
+// with that digest. Other references and digests not found locally are
+// returned unchanged.
+func (e *Engine) resolveImageDigestRef(ref string) string {
+	repo, tag := parseImageRef(ref)
+	if !isImageDigest(tag) {
+		return ref
+	}
+	if _, err := os.Stat(e.imageStorePath(ref)); err == nil {
+		return ref
+	}
+	entries, err := os.ReadDir(e.imagesDir())
+	if err != nil {
+		return ref
+	}
+	prefix := strings.ReplaceAll(repo, "/", "_") + ":"
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		name := repo + ":" + strings.TrimPrefix(entry.Name(), prefix)
+		if e.loadImageDigest(name) == tag {
+			return name
+		}
+	}
+	return ref
+}
+
+// ImageConfig is the subset of the OCI image config used by the engine.
+type ImageConfig struct {
+	Config struct {
+		ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+		Entrypoint   []string            `json:"Entrypoint,omitempty"`
+		Cmd          []string            `json:"Cmd,omitempty"`
+	} `json:"config"`
+	// History describes the build steps of the image, oldest first. Steps
+	// marked EmptyLayer did not produce a layer.
+	History []struct {
+		Created    string `json:"created,omitempty"`
+		CreatedBy  string `json:"created_by,omitempty"`
+		Comment    string `json:"comment,omitempty"`
+		EmptyLayer bool   `json:"empty_layer,omitempty"`
+	} `json:"history,omitempty"`
+}
+
+// saveImageConfigBlob downloads the image config blob and stores it in the
+// image directory.
+func saveImageConfigBlob(registry Registry, repo, digest, imageDir string) error {
+	reader, err := registry.FetchLayer(repo, digest)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read image config: %w", err)
+	}
+	var config ImageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse image config: %w", err)
+	}
+	return os.WriteFile(filepath.Join(imageDir, imageConfigFile), data, 0644)
+}
+
+// loadImageConfig reads the stored config of an image. Images without a
+// config, such as those loaded from a plain rootfs tar, get an empty one.
+func (e *Engine) loadImageConfig(ref string) (*ImageConfig, error) {
+	config := &ImageConfig{}
+	data, err := os.ReadFile(filepath.Join(e.imageStorePath(ref), imageConfigFile))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image config: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	return config, nil
+}
+
+// pullImage fetches an image from the registry named in its reference,
+// defaulting to Docker Hub when no registry host is given.
+func (e *Engine) pullImage(ref string, opts PullOptions) (*Image, error) {
+	repo, _ := parseImageRef(ref)
+	if host, _ := splitRegistryHost(repo); host != "" {
+		return e.PullWithOptions(NewDockerHubRegistry(fmt.Sprintf("http://%s/v2/", host), WithHTTPClient(e.RegistryClient), WithLogger(e.Logger)), ref, opts)
+	}
+	// Docker Hub images are pulled through the mirrors when there are any
+	registry := NewDockerHubRegistry("https://registry-1.docker.io/v2/", WithHTTPClient(e.RegistryClient), WithLogger(e.Logger))
+	if mirrors := e.registryMirrors(); len(mirrors) > 0 {
+		mirrored, err := newMirroredRegistry(mirrors, registry)
+		if err != nil {
+			return nil, err
+		}
+		return e.PullWithOptions(mirrored, ref, opts)
+	}
+	return e.PullWithOptions(registry, ref, opts)
+}
+
+// TagImage stores a copy of an existing image under a new reference
+func (e *Engine) TagImage(source, target string) error {
+	sourcePath := e.imageStorePath(source)
+	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+		return fmt.Errorf("image %s does not exist", normalizeImageRef(source))
+	}
+
+	targetPath := e.imageStorePath(target)
+	if err := os.RemoveAll(targetPath); err != nil {
+		return fmt.Errorf("failed to replace existing image %s: %w", normalizeImageRef(target), err)
+	}
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if err := e.copyDir(sourcePath, targetPath); err != nil {
+		return fmt.Errorf("failed to copy image: %w", err)
+	}
+
+	// The copied metadata still names the source
+	image, err := e.loadImageMetadata(source)
+	if err != nil {
+		return err
+	}
+	image.Name = normalizeImageRef(target)
+	image.RootFS = filepath.Join(targetPath, "rootfs")
+	e.recordImageMetadata(image)
+	return nil
+}
+
+// extractLayer extracts a layer tar archive on top of the specified rootfs
+// directory, deleting the paths named by its whiteout entries. The layer is
+// extracted next to rootfs first, then its entries are moved into place so
+// that tar's ownership and permissions are kept.
+func (e *Engine) extractLayer(reader io.Reader, rootfs string) error {
+	layer, err := os.MkdirTemp(filepath.Dir(rootfs), ".extract-")
+	if err != nil {
+		return fmt.Errorf("failed to create layer directory: %w", err)
+	}
+	defer os.RemoveAll(layer)
+
+	if err := unpackLayer(reader, "", layer); err != nil {
+		return err
+	}
+	return e.mergeLayer(layer, rootfs)
+}
+
+// unpackLayer extracts a layer tar archive into an empty directory,
+// decompressing it as its media type says or, without one, as its content
+// looks.
+func unpackLayer(reader io.Reader, mediaType, dir string) error {
+	tarStream, err := decompressLayer(reader, mediaType)
+	if err != nil {
+		return fmt.Errorf("failed to extract layer: %w", err)
+	}
+	defer tarStream.Close()
+
+	// Use tar to extract the layer
+	cmd := exec.Command("tar", "-x", "-C", dir)
+	cmd.Stdin = tarStream
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to extract layer: %w", err)
+	}
+	return nil
+}
+
+// mergeLayer moves a layer extracted by unpackLayer on top of rootfs.
+func (e *Engine) mergeLayer(layer, rootfs string) error {
+	if err := e.applyWhiteouts(layer, rootfs); err != nil {
+		return fmt.Errorf("failed to apply whiteouts: %w", err)
+	}
+	if err := e.moveLayer(layer, rootfs); err != nil {
+		return fmt.Errorf("failed to apply layer: %w", err)
+	}
+	return nil
+}
+
+// moveLayer moves the entries of an extracted layer into rootfs. An entry
+// replaces what rootfs has at its path, except that directories are merged
+// and take the mode and owner of the layer's directory.
+func (e *Engine) moveLayer(layer, rootfs string) error {
+	entries, err := os.ReadDir(layer)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), whiteoutPrefix) {
+			continue
+		}
+		src := filepath.Join(layer, entry.Name())
+		dst := filepath.Join(rootfs, entry.Name())
+		existing, err := os.Lstat(dst)
+		if err == nil && existing.IsDir() && entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := e.moveLayer(src, dst); err != nil {
+				return err
+			}
+			// Chown may clear setuid and setgid bits, so the mode comes last
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				if err := os.Lchown(dst, int(stat.Uid), int(stat.Gid)); err != nil {
+					e.Logger.Debug("failed to chown directory", "path", dst, "error", err)
+				}
+			}
+			if err := os.Chmod(dst, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil {
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadImageFromTar loads a container image from a .tar file
+func (e *Engine) LoadImageFromTar(tarFilePath string, imageName string) (*Image, error) {
+	rootfs := filepath.Join(e.imageStorePath(imageName), "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs: %w", err)
+	}
+
+	// Extract the tar file to the rootfs directory
+	tarFile, err := os.Open(tarFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tar file: %w", err)
+	}
+	defer tarFile.Close()
+
+	cmd := exec.Command("tar", "-x", "-C", rootfs, "-f", tarFilePath)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to extract tar file: %w", err)
+	}
+	if err := recordRootfsDigest(e.imageStorePath(imageName)); err != nil {
+		e.Logger.Warn("failed to record rootfs digest", "image", imageName, "error", err)
+	}
+
+	image := &Image{
+		Name:   normalizeImageRef(imageName),
+		RootFS: rootfs,
+		Layers: []string{"base"},
+	}
+	e.recordImageMetadata(image)
+	return image, nil
+}
\ No newline at end of file
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// imageMetadataFile records the Image of an image directory, so its name,
+// digest, config and creation time need not be inferred from the directory.
+const imageMetadataFile = "image.json"
+
+// saveImageMetadata writes the metadata file of an image, filling in its
+// config from the image directory and its creation time when they are unset.
+func (e *Engine) saveImageMetadata(image *Image) error {
+	if image.Created.IsZero() {
+		image.Created = time.Now()
+	}
+	if image.Config == nil {
+		config, err := e.loadImageConfig(image.Name)
+		if err != nil {
+			return err
+		}
+		image.Config = config
+	}
+	data, err := json.MarshalIndent(image, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode image metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(e.imageStorePath(image.Name), imageMetadataFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write image metadata: %v", err)
+	}
+	return nil
+}
+
+// recordImageMetadata saves the metadata of a newly stored image, warning
+// rather than failing since loadImageMetadata can rebuild it.
+func (e *Engine) recordImageMetadata(image *Image) {
+	if err := e.saveImageMetadata(image); err != nil {
+		e.Logger.Warn("failed to record image metadata", "image", image.Name, "error", err)
+	}
+}
+
+// loadImageMetadata reads the metadata of an image. Images stored before the
+// metadata file existed have it rebuilt from their directory and saved.
+func (e *Engine) loadImageMetadata(ref string) (*Image, error) {
+	dir := e.imageStorePath(ref)
+	data, err := os.ReadFile(filepath.Join(dir, imageMetadataFile))
+	if err == nil {
+		var image Image
+		if err := json.Unmarshal(data, &image); err != nil {
+			return nil, fmt.Errorf("failed to parse image metadata: %v", err)
+		}
+		return &image, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read image metadata: %v", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("image %s does not exist", normalizeImageRef(ref))
+	}
+	image := &Image{
+		Name:    normalizeImageRef(ref),
+		RootFS:  filepath.Join(dir, "rootfs"),
+		Layers:  []string{"base"},
+		Digest:  e.loadImageDigest(ref),
+		Created: info.ModTime(),
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, imageLayersFile)); err == nil {
+		json.Unmarshal(data, &image.Layers)
+	}
+	e.Logger.Debug("migrating image metadata", "image", image.Name)
+	e.recordImageMetadata(image)
+	return image, nil
+}
+
+// InspectImage writes the metadata of an image as JSON or with tmpl when it
+// is set.
+func (e *Engine) InspectImage(w io.Writer, ref string, tmpl *template.Template) error {
+	image, err := e.loadImageMetadata(e.resolveImageDigestRef(normalizeImageRef(ref)))
+	if err != nil {
+		return err
+	}
+	if tmpl != nil {
+		return writeFormatted(w, tmpl, image)
+	}
+	data, err := json.MarshalIndent(image, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode image %s: %v", ref, err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// imageInspectCommand implements "image-inspect [--format template] <image>".
+func (e *Engine) imageInspectCommand(args []string) {
+	fs := flag.NewFlagSet("image-inspect", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "Go template to print the image with")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker image-inspect [--format template] <image>")
+		os.Exit(1)
+	}
+	var tmpl *template.Template
+	if *format != "" {
+		var err error
+		if tmpl, err = parseFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := e.InspectImage(os.Stdout, fs.Arg(0), tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
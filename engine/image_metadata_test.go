@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestImageMetadataRoundTrip saves the metadata of an image and reads it
+// back, checking loading, tagging and inspect all use it
+func TestImageMetadataRoundTrip(t *testing.T) {
+	e := newTestEngine(t)
+	tarPath := writeTestTar(t, map[string]string{"hello.txt": "hello"})
+	if _, err := e.LoadImageFromTar(tarPath, "meta:v1"); err != nil {
+		t.Fatalf("LoadImageFromTar failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(e.imageStorePath("meta:v1"), imageMetadataFile)); err != nil {
+		t.Fatalf("Expected load to write the image metadata: %v", err)
+	}
+
+	image := &Image{
+		Name:    "meta:v1",
+		RootFS:  filepath.Join(e.imageStorePath("meta:v1"), "rootfs"),
+		Layers:  []string{"base"},
+		Digest:  "sha256:" + strings.Repeat("ab", 32),
+		Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Config:  &ImageConfig{},
+	}
+	image.Config.Config.Cmd = []string{"sh"}
+	if err := e.saveImageMetadata(image); err != nil {
+		t.Fatalf("saveImageMetadata failed: %v", err)
+	}
+	loaded, err := e.loadImageMetadata("meta:v1")
+	if err != nil {
+		t.Fatalf("loadImageMetadata failed: %v", err)
+	}
+	if !loaded.Created.Equal(image.Created) {
+		t.Errorf("Created = %v, want %v", loaded.Created, image.Created)
+	}
+	loaded.Created = image.Created
+	if !reflect.DeepEqual(loaded, image) {
+		t.Errorf("Loaded metadata %+v, want %+v", loaded, image)
+	}
+
+	if err := e.TagImage("meta:v1", "meta:v2"); err != nil {
+		t.Fatalf("TagImage failed: %v", err)
+	}
+	tagged, err := e.loadImageMetadata("meta:v2")
+	if err != nil || tagged.Name != "meta:v2" || tagged.Digest != image.Digest || tagged.RootFS != filepath.Join(e.imageStorePath("meta:v2"), "rootfs") {
+		t.Errorf("Expected the tag to have its own name and rootfs, got %+v, %v", tagged, err)
+	}
+
+	var buf bytes.Buffer
+	tmpl, err := parseFormat("{{.Name}} {{.Digest}}")
+	if err != nil {
+		t.Fatalf("parseFormat failed: %v", err)
+	}
+	if err := e.InspectImage(&buf, "meta:v1", tmpl); err != nil {
+		t.Fatalf("InspectImage failed: %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "meta:v1 "+image.Digest {
+		t.Errorf("InspectImage printed %q", got)
+	}
+}
+
+// TestImageMetadataMigration rebuilds the metadata of an image stored before
+// the metadata file existed from the files in its directory
+func TestImageMetadataMigration(t *testing.T) {
+	e := newTestEngine(t)
+	dir := e.imageStorePath("old:latest")
+	digest := "sha256:" + strings.Repeat("cd", 32)
+	for name, content := range map[string]string{
+		"rootfs/hello.txt": "hello",
+		imageDigestFile:    digest + "\n",
+		imageConfigFile:    `{"config": {"Cmd": ["echo", "hi"]}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create image directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	image, err := e.loadImageMetadata("old:latest")
+	if err != nil {
+		t.Fatalf("loadImageMetadata failed: %v", err)
+	}
+	if image.Name != "old:latest" || image.Digest != digest || image.Created.IsZero() {
+		t.Errorf("Unexpected migrated metadata: %+v", image)
+	}
+	if image.Config == nil || !reflect.DeepEqual(image.Config.Config.Cmd, []string{"echo", "hi"}) {
+		t.Errorf("Expected the migrated metadata to carry the image config, got %+v", image.Config)
+	}
+	if _, err := os.Stat(filepath.Join(dir, imageMetadataFile)); err != nil {
+		t.Errorf("Expected the migrated metadata to be saved: %v", err)
+	}
+
+	summaries, err := e.listImageSummaries()
+	if err != nil || len(summaries) != 1 || summaries[0].Digest != digest || summaries[0].Created.IsZero() {
+		t.Errorf("Expected the listing to use the metadata, got %+v, %v", summaries, err)
+	}
+	if _, err := e.loadImageMetadata("missing:latest"); err == nil {
+		t.Error("Expected an error for a missing image")
+	}
+}
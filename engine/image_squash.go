@@ -0,0 +1,252 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// imageLayersFile lists, bottom first, the layers in layersDir an image is
+// made of. An image without it consists of its rootfs alone.
+const imageLayersFile = "layers.json"
+
+// OCI whiteout markers: ".wh.<name>" deletes name from the layers below and
+// an opaque marker hides everything below in its directory.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// imageLayerDirs returns the directories holding the layers of an image,
+// bottom first.
+func (e *Engine) imageLayerDirs(ref string) ([]string, error) {
+	imageDir := e.imageStorePath(ref)
+	data, err := os.ReadFile(filepath.Join(imageDir, imageLayersFile))
+	if os.IsNotExist(err) {
+		return []string{filepath.Join(imageDir, "rootfs")}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image layers: %v", err)
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse image layers: %v", err)
+	}
+	dirs := make([]string, len(ids))
+	for i, id := range ids {
+		dirs[i] = filepath.Join(e.layersDir(), id)
+	}
+	return dirs, nil
+}
+
+// applyLayer applies the tree of a layer on top of rootfs. Whiteouts in the
+// layer delete the files they name from rootfs and are not copied.
+func (e *Engine) applyLayer(layer, rootfs string) error {
+	// Deletions only affect lower layers, so they are applied first
+	if err := e.applyWhiteouts(layer, rootfs); err != nil {
+		return err
+	}
+
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	err := filepath.Walk(layer, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(layer, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." || strings.HasPrefix(info.Name(), whiteoutPrefix) {
+			return nil
+		}
+
+		// An entry replaces whatever the lower layers had at its path, except
+		// that directories are merged
+		targetPath := filepath.Join(rootfs, relPath)
+		if existing, err := os.Lstat(targetPath); err == nil && !(existing.IsDir() && info.IsDir()) {
+			if err := os.RemoveAll(targetPath); err != nil {
+				return err
+			}
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, dirMode{targetPath, mode.Perm()})
+			return os.MkdirAll(targetPath, 0755)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, targetPath)
+		case mode.IsRegular():
+			return copyFile(path, targetPath)
+		}
+		e.Logger.Debug("skipping special file", "path", path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Directory modes are applied last so read-only directories can be filled
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyWhiteouts deletes from rootfs the paths named by the whiteout markers
+// in a layer.
+func (e *Engine) applyWhiteouts(layer, rootfs string) error {
+	return filepath.Walk(layer, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if !strings.HasPrefix(name, whiteoutPrefix) {
+			return nil
+		}
+		relDir, err := filepath.Rel(layer, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if underSymlink(rootfs, relDir) {
+			e.Logger.Debug("skipping whiteout below a symlink", "path", path)
+			return nil
+		}
+		targetDir := filepath.Join(rootfs, relDir)
+		if name != whiteoutOpaque {
+			return os.RemoveAll(filepath.Join(targetDir, strings.TrimPrefix(name, whiteoutPrefix)))
+		}
+		entries, err := os.ReadDir(targetDir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(targetDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// underSymlink reports whether a directory inside rootfs is reached through
+// a symlink, which may point outside of rootfs.
+func underSymlink(rootfs, relDir string) bool {
+	path := rootfs
+	for _, part := range strings.Split(relDir, string(filepath.Separator)) {
+		if part == "" || part == "." {
+			continue
+		}
+		path = filepath.Join(path, part)
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SquashImage merges the layers of an image into a single layer, recorded in
+// the layer cache, and creates a new image from it.
+func (e *Engine) SquashImage(source, target string) (*Image, error) {
+	sourceDir := e.imageStorePath(source)
+	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("image %s does not exist", normalizeImageRef(source))
+	}
+	if err := validateImageRef(target); err != nil {
+		return nil, err
+	}
+	target = normalizeImageRef(target)
+	targetDir := e.imageStorePath(target)
+	if _, err := os.Stat(targetDir); err == nil {
+		return nil, fmt.Errorf("image %s already exists", target)
+	}
+	layers, err := e.imageLayerDirs(source)
+	if err != nil {
+		return nil, err
+	}
+
+	layerID := fmt.Sprintf("squashed-layer-%d", time.Now().UnixNano())
+	layerPath := filepath.Join(e.layersDir(), layerID)
+	if err := os.MkdirAll(layerPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create layer: %v", err)
+	}
+	for _, layer := range layers {
+		if err := e.applyLayer(layer, layerPath); err != nil {
+			os.RemoveAll(layerPath)
+			return nil, fmt.Errorf("failed to apply layer %s: %v", filepath.Base(layer), err)
+		}
+	}
+	size, _ := calculateDirSize(layerPath)
+	if err := e.AddLayer(ImageLayer{ID: layerID, Created: time.Now(), Size: size, BaseLayerPath: layerPath}); err != nil {
+		os.RemoveAll(layerPath)
+		return nil, err
+	}
+
+	image, err := e.createImageFromLayer(sourceDir, target, layerID)
+	if err != nil {
+		os.RemoveAll(targetDir)
+		return nil, err
+	}
+	return image, nil
+}
+
+// createImageFromLayer creates an image whose only layer is layerID, with
+// the config of the image in sourceDir.
+func (e *Engine) createImageFromLayer(sourceDir, target, layerID string) (*Image, error) {
+	targetDir := e.imageStorePath(target)
+	rootfs := filepath.Join(targetDir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs: %v", err)
+	}
+	if err := e.copyDir(filepath.Join(e.layersDir(), layerID), rootfs); err != nil {
+		return nil, fmt.Errorf("failed to copy layer to rootfs: %v", err)
+	}
+	data, err := json.Marshal([]string{layerID})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, imageLayersFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to record image layers: %v", err)
+	}
+	config := filepath.Join(sourceDir, imageConfigFile)
+	if _, err := os.Stat(config); err == nil {
+		if err := copyFile(config, filepath.Join(targetDir, imageConfigFile)); err != nil {
+			return nil, fmt.Errorf("failed to copy image config: %v", err)
+		}
+	}
+	if err := recordRootfsDigest(targetDir); err != nil {
+		e.Logger.Warn("failed to record rootfs digest", "image", target, "error", err)
+	}
+	image := &Image{Name: target, RootFS: rootfs, Layers: []string{layerID}}
+	e.recordImageMetadata(image)
+	return image, nil
+}
+
+// squashImageCommand implements "image squash <source> <target>".
+func (e *Engine) squashImageCommand(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker image squash <source-image> <target-image>")
+		os.Exit(1)
+	}
+	image, err := e.SquashImage(args[0], args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to squash image: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Image '%s' squashed into '%s' (layer %s).\n", normalizeImageRef(args[0]), image.Name, image.Layers[0])
+}
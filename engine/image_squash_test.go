@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestLayer creates a layer in the layer cache from a map of paths to
+// contents. Paths ending in "/" are directories.
+func writeTestLayer(t *testing.T, e *Engine, id string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(e.layersDir(), id)
+	for path, content := range files {
+		target := filepath.Join(dir, path)
+		if path[len(path)-1] == '/' {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				t.Fatalf("Failed to create %s: %v", path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("Failed to create parent of %s: %v", path, err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if err := e.AddLayer(ImageLayer{ID: id, BaseLayerPath: dir}); err != nil {
+		t.Fatalf("Failed to save layer metadata: %v", err)
+	}
+}
+
+func TestSquashImage(t *testing.T) {
+	e := newTestEngine(t)
+	writeTestLayer(t, e, "base", map[string]string{
+		"bin/sh":           "shell",
+		"etc/removed.conf": "old",
+		"etc/kept.conf":    "kept",
+		"var/cache/a":      "a",
+	})
+	writeTestLayer(t, e, "top", map[string]string{
+		"etc/.wh.removed.conf":   "",
+		"etc/kept.conf":          "updated",
+		"var/cache/.wh..wh..opq": "",
+		"var/cache/b":            "b",
+		"app/":                   "",
+	})
+	imageDir := e.imageStorePath("multi:latest")
+	if err := os.MkdirAll(filepath.Join(imageDir, "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(imageDir, imageLayersFile), []byte(`["base","top"]`), 0644); err != nil {
+		t.Fatalf("Failed to write image layers: %v", err)
+	}
+
+	image, err := e.SquashImage("multi", "flat")
+	if err != nil {
+		t.Fatalf("SquashImage failed: %v", err)
+	}
+	if image.Name != "flat:latest" || len(image.Layers) != 1 {
+		t.Fatalf("Unexpected image %+v", image)
+	}
+
+	for _, rootfs := range []string{image.RootFS, filepath.Join(e.layersDir(), image.Layers[0])} {
+		for _, path := range []string{"etc/removed.conf", "etc/.wh.removed.conf", "var/cache/a", "var/cache/.wh..wh..opq"} {
+			if _, err := os.Lstat(filepath.Join(rootfs, path)); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be absent from %s", path, rootfs)
+			}
+		}
+		for path, want := range map[string]string{"bin/sh": "shell", "etc/kept.conf": "updated", "var/cache/b": "b"} {
+			if data, err := os.ReadFile(filepath.Join(rootfs, path)); err != nil || string(data) != want {
+				t.Errorf("Expected %s to hold %q in %s, got %q, %v", path, want, rootfs, data, err)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(e.imageStorePath("flat"), imageLayersFile))
+	var layers []string
+	if err != nil || json.Unmarshal(data, &layers) != nil || len(layers) != 1 || layers[0] != image.Layers[0] {
+		t.Errorf("Expected the squashed image to record its single layer, got %s, %v", data, err)
+	}
+	if unreferenced, err := e.unreferencedLayers(); err != nil || len(unreferenced) != 0 {
+		t.Errorf("Expected the squashed layer to be recorded in the cache, unreferenced: %v, %v", unreferenced, err)
+	}
+
+	if _, err := e.SquashImage("multi", "flat"); err == nil {
+		t.Error("Expected squashing onto an existing image to fail")
+	}
+	if _, err := e.SquashImage("missing", "other"); err == nil {
+		t.Error("Expected squashing a missing image to fail")
+	}
+}
+
+func TestApplyLayerWhiteoutBelowSymlink(t *testing.T) {
+	e := newTestEngine(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "host.conf"), []byte("host"), 0644); err != nil {
+		t.Fatalf("Failed to write host file: %v", err)
+	}
+	rootfs := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(rootfs, "etc")); err != nil {
+		t.Fatalf("Failed to link etc: %v", err)
+	}
+	layer := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(layer, "etc"), 0755); err != nil {
+		t.Fatalf("Failed to create layer: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(layer, "etc", ".wh.host.conf"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write whiteout: %v", err)
+	}
+
+	if err := e.applyLayer(layer, rootfs); err != nil {
+		t.Fatalf("applyLayer failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "host.conf")); err != nil {
+		t.Errorf("Expected the whiteout not to follow the symlink out of the rootfs: %v", err)
+	}
+}
@@ -0,0 +1,781 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"net/http"
+	"net/http/httptest"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Test Scenarios Documentation
+//
+// TestListImages:
+// - Verifies that the ListImages function correctly lists available images.
+// - Setup: Creates a temporary directory and a mock image directory.
+// - Expected Outcome: The output of ListImages should include the mock image name.
+//
+// TestDockerHubRegistry_FetchManifest:
+// - Verifies the FetchManifest method of DockerHubRegistry using a mock HTTP server.
+// - Setup: Creates a mock server to simulate Docker Hub API responses.
+// - Expected Outcome: The manifest returned by FetchManifest should match the mock data.
+//
+// TestDockerHubRegistry_FetchLayer:
+// - Verifies the FetchLayer method of DockerHubRegistry using a mock HTTP server.
+// - Setup: Creates a mock server to simulate Docker Hub API responses.
+// - Expected Outcome: The layer content returned by FetchLayer should match the mock data.
+
+func TestListImages(t *testing.T) {
+	e := newTestEngine(t)
+	baseDir := e.Root
+	imagesDir := filepath.Join(baseDir, "images")
+
+	// Setup: Create the images directory
+	if err := os.MkdirAll(imagesDir, 0755); err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	// Create a mock image directory
+	imageName := "test-image"
+	if err := os.MkdirAll(filepath.Join(imagesDir, imageName), 0755); err != nil {
+		t.Fatalf("Failed to create mock image directory: %v", err)
+	}
+
+	// Capture the output of ListImages
+	output := captureOutput(e.ListImages)
+
+	// Verify the output contains the mock image name
+	if !contains(output, imageName) {
+		t.Errorf("Expected output to contain image name '%s', but got: %s", imageName, output)
+	}
+}
+
+func captureOutput(f func()) string {
+	// Redirect stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Drain the pipe while f runs so output larger than the pipe buffer
+	// neither blocks f nor gets truncated
+	outCh := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		r.Close()
+		outCh <- buf.String()
+	}()
+
+	// Run the function
+	f()
+
+	// Capture the output
+	w.Close()
+	os.Stdout = old
+	return <-outCh
+}
+
+// TestCaptureOutputLargeOutput lists more networks than fit in a pipe
+// buffer and checks none of the listing is lost
+func TestCaptureOutputLargeOutput(t *testing.T) {
+	e := newTestEngine(t)
+	const count = 2000
+	for i := 1; i <= count; i++ {
+		e.networks = append(e.networks, Network{Name: fmt.Sprintf("net%d", i), ID: fmt.Sprintf("net-%d", i)})
+	}
+
+	output := captureOutput(e.ListNetworks)
+	if len(output) <= 64*1024 {
+		t.Fatalf("Expected more than 64KiB of output, got %d bytes", len(output))
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != count+1 {
+		t.Errorf("Expected %d lines, got %d", count+1, len(lines))
+	}
+	if want := fmt.Sprintf("- net%d (ID: net-%d, driver: bridge)", count, count); lines[len(lines)-1] != want {
+		t.Errorf("Last line = %q, want %q", lines[len(lines)-1], want)
+	}
+}
+
+// captureStdoutStderr runs f and returns what it wrote to stdout and stderr
+// separately.
+func captureStdoutStderr(f func()) (string, string) {
+	oldOut, oldErr := os.Stdout, os.Stderr
+	outR, outW, _ := os.Pipe()
+	errR, errW, _ := os.Pipe()
+	os.Stdout, os.Stderr = outW, errW
+
+	outCh := make(chan string)
+	errCh := make(chan string)
+	go func() { data, _ := io.ReadAll(outR); outCh <- string(data) }()
+	go func() { data, _ := io.ReadAll(errR); errCh <- string(data) }()
+
+	f()
+
+	outW.Close()
+	errW.Close()
+	os.Stdout, os.Stderr = oldOut, oldErr
+	return <-outCh, <-errCh
+}
+
+func contains(output, substring string) bool {
+	return strings.Contains(output, substring)
+}
+
+// TestDockerHubRegistry_FetchManifest tests the FetchManifest method of DockerHubRegistry
+func TestDockerHubRegistry_FetchManifest(t *testing.T) {
+	// Mock server to simulate Docker Hub API
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/busybox/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"config": {"digest": "sha256:configdigest"},
+			"layers": [
+				{"digest": "sha256:layer1digest"},
+				{"digest": "sha256:layer2digest"}
+			]
+		}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Create a DockerHubRegistry instance with the mock server URL
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/"}
+
+	// Call FetchManifest
+	manifest, err := registry.FetchManifest("library/busybox", "latest")
+	if err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+
+	// Verify the manifest content
+	if manifest.Config.Digest != "sha256:configdigest" {
+		t.Errorf("Expected config digest 'sha256:configdigest', got '%s'", manifest.Config.Digest)
+	}
+	if len(manifest.Layers) != 2 {
+		t.Errorf("Expected 2 layers, got %d", len(manifest.Layers))
+	}
+	if manifest.Layers[0].Digest != "sha256:layer1digest" {
+		t.Errorf("Expected first layer digest 'sha256:layer1digest', got '%s'", manifest.Layers[0].Digest)
+	}
+	if manifest.Layers[1].Digest != "sha256:layer2digest" {
+		t.Errorf("Expected second layer digest 'sha256:layer2digest', got '%s'", manifest.Layers[1].Digest)
+	}
+}
+
+// TestDockerHubRegistry_FetchLayer tests the FetchLayer method of DockerHubRegistry
+func TestDockerHubRegistry_FetchLayer(t *testing.T) {
+	// Mock server to simulate Docker Hub API
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/busybox/blobs/sha256:layer1digest", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("layer1content"))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Create a DockerHubRegistry instance with the mock server URL
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/"}
+
+	// Call FetchLayer
+	reader, err := registry.FetchLayer("library/busybox", "sha256:layer1digest")
+	if err != nil {
+		t.Fatalf("FetchLayer failed: %v", err)
+	}
+	defer reader.Close()
+
+	// Verify the layer content
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read layer content: %v", err)
+	}
+	if string(content) != "layer1content" {
+		t.Errorf("Expected layer content 'layer1content', got '%s'", string(content))
+	}
+}
+
+// TestRegistryErrorBody verifies that the messages of a registry's error
+// envelope are part of the returned error
+func TestRegistryErrorBody(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/missing/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"code":"NAME_UNKNOWN","message":"repository name not known to registry"}]}`))
+	})
+	handler.HandleFunc("/v2/library/missing/blobs/sha256:gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"},{"code":"DENIED","message":"requested access to the resource is denied"}]}`))
+	})
+	handler.HandleFunc("/v2/library/plain/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not json", http.StatusBadGateway)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/", MaxAttempts: 1}
+
+	_, err := registry.FetchManifest("library/missing", "latest")
+	if err == nil || err.Error() != "unexpected status code: 404: NAME_UNKNOWN: repository name not known to registry" {
+		t.Errorf("Expected the registry's error message, got %v", err)
+	}
+	_, err = registry.FetchLayer("library/missing", "sha256:gone")
+	if err == nil || !strings.Contains(err.Error(), "UNAUTHORIZED: authentication required; DENIED: requested access to the resource is denied") {
+		t.Errorf("Expected both error messages, got %v", err)
+	}
+	_, err = registry.FetchManifest("library/plain", "latest")
+	if err == nil || err.Error() != "unexpected status code: 502" {
+		t.Errorf("Expected only the status code without an error envelope, got %v", err)
+	}
+}
+
+// TestParseImageRef verifies repository/tag splitting and the latest default
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		repo string
+		tag  string
+	}{
+		{"busybox", "busybox", "latest"},
+		{"busybox:latest", "busybox", "latest"},
+		{"busybox:1.36", "busybox", "1.36"},
+		{"busybox:", "busybox", "latest"},
+		{"library/busybox:musl", "library/busybox", "musl"},
+		{"localhost:5000/app", "localhost:5000/app", "latest"},
+		{"localhost:5000/team/app:v2", "localhost:5000/team/app", "v2"},
+		{"localhost:5000/app@sha256:abababababababababababababababababababababababababababababababab", "localhost:5000/app", "sha256:abababababababababababababababababababababababababababababababab"},
+	}
+
+	for _, tt := range tests {
+		repo, tag := parseImageRef(tt.ref)
+		if repo != tt.repo || tag != tt.tag {
+			t.Errorf("parseImageRef(%q) = (%q, %q), want (%q, %q)", tt.ref, repo, tag, tt.repo, tt.tag)
+		}
+	}
+}
+
+// TestImageStorePathDefaultTag verifies that tagged and untagged references
+// resolve to the same directory
+func TestImageStorePathDefaultTag(t *testing.T) {
+	e := newTestEngine(t)
+	if e.imageStorePath("busybox") != e.imageStorePath("busybox:latest") {
+		t.Errorf("Expected busybox and busybox:latest to share a directory, got %s and %s",
+			e.imageStorePath("busybox"), e.imageStorePath("busybox:latest"))
+	}
+	if e.imageStorePath("busybox:1.36") == e.imageStorePath("busybox:latest") {
+		t.Error("Expected different tags to use different directories")
+	}
+	if filepath.Dir(e.imageStorePath("localhost:5000/team/app")) != e.imagesDir() {
+		t.Errorf("Expected image directory directly under %s, got %s", e.imagesDir(), e.imageStorePath("localhost:5000/team/app"))
+	}
+}
+
+// TestSplitRegistryHost verifies registry host detection
+func TestSplitRegistryHost(t *testing.T) {
+	tests := []struct {
+		repo string
+		host string
+		path string
+	}{
+		{"busybox", "", "busybox"},
+		{"library/busybox", "", "library/busybox"},
+		{"localhost/app", "localhost", "app"},
+		{"localhost:5000/app", "localhost:5000", "app"},
+		{"registry.example.com/team/app", "registry.example.com", "team/app"},
+	}
+
+	for _, tt := range tests {
+		host, path := splitRegistryHost(tt.repo)
+		if host != tt.host || path != tt.path {
+			t.Errorf("splitRegistryHost(%q) = (%q, %q), want (%q, %q)", tt.repo, host, path, tt.host, tt.path)
+		}
+	}
+}
+
+// writeTestTar writes a tar archive with the given files to a temporary path
+func writeTestTar(t *testing.T, files map[string]string) string {
+	t.Helper()
+	tarPath := filepath.Join(t.TempDir(), "test-image.tar")
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatalf("Failed to create tar file: %v", err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	return tarPath
+}
+
+// TestLoadImageUnderTwoNames verifies the same tar can be loaded under
+// different names and both appear in the image list
+func TestLoadImageUnderTwoNames(t *testing.T) {
+	e := newTestEngine(t)
+	tarPath := writeTestTar(t, map[string]string{"hello.txt": "hello"})
+
+	names := []string{"loadtest-one:v1", "loadtest-two:v2"}
+	for _, name := range names {
+		image, err := e.LoadImageFromTar(tarPath, name)
+		if err != nil {
+			t.Fatalf("LoadImageFromTar(%s) failed: %v", name, err)
+		}
+		defer os.RemoveAll(e.imageStorePath(name))
+
+		if image.Name != name {
+			t.Errorf("Expected image name %s, got %s", name, image.Name)
+		}
+		if _, err := os.Stat(filepath.Join(image.RootFS, "hello.txt")); err != nil {
+			t.Errorf("Expected hello.txt in rootfs of %s: %v", name, err)
+		}
+	}
+
+	output := captureOutput(e.ListImages)
+	for _, name := range names {
+		if !contains(output, name) {
+			t.Errorf("Expected images output to contain %s, got: %s", name, output)
+		}
+	}
+}
+
+// TestValidateImageRef verifies rejection of malformed image names
+func TestValidateImageRef(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	valid := []string{"busybox", "busybox:1.36", "localhost:5000/team/app:v2", "my-app_x.y", "busybox@" + digest}
+	for _, ref := range valid {
+		if err := validateImageRef(ref); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", ref, err)
+		}
+	}
+
+	invalid := []string{"", ":tag", "BusyBox", "app:tag!", "team//app", "app:" + strings.Repeat("x", 129),
+		"app@sha256:abc", "app@" + strings.ToUpper(digest), "app@md5:" + strings.Repeat("ab", 32)}
+	for _, ref := range invalid {
+		if err := validateImageRef(ref); err == nil {
+			t.Errorf("Expected %q to be invalid", ref)
+		}
+	}
+}
+
+// TestListImagesStdoutOnlyRows verifies that image listings write only image
+// rows to stdout, with the header on stderr, and only names in quiet mode.
+func TestListImagesStdoutOnlyRows(t *testing.T) {
+	e := newTestEngine(t)
+	names := []string{"stdout-a:latest", "stdout-b:v1"}
+	for _, name := range names {
+		dir := filepath.Join(e.imagesDir(), name, "rootfs")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create mock image directory: %v", err)
+		}
+		defer os.RemoveAll(filepath.Dir(dir))
+	}
+
+	stdout, stderr := captureStdoutStderr(e.ListImages)
+	if stderr != "IMAGE NAME\tSIZE\n" {
+		t.Errorf("Expected the header on stderr, got %q", stderr)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if fields := strings.Split(line, "\t"); len(fields) != 2 || !strings.HasSuffix(fields[1], " bytes") {
+			t.Errorf("Unexpected non-row line on stdout: %q", line)
+		}
+	}
+
+	stdout, stderr = captureStdoutStderr(func() { e.listImages(true) })
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if strings.ContainsAny(line, " \t") {
+			t.Errorf("Expected only image names in quiet mode, got %q", line)
+		}
+	}
+	for _, name := range names {
+		if !strings.Contains(stdout, name+"\n") {
+			t.Errorf("Expected %s in quiet output, got: %s", name, stdout)
+		}
+	}
+	if stderr != "" {
+		t.Errorf("Expected nothing on stderr, got: %s", stderr)
+	}
+}
+
+// TestProgressGoesToStderr verifies that progress messages are kept off stdout.
+func TestProgressGoesToStderr(t *testing.T) {
+	e := newTestEngine(t)
+	cm := e.NewCapsuleManager()
+	cm.AddCapsule("progress", "1.0", t.TempDir())
+	containerID := "test-progress-container"
+	defer func() {
+		e.detachCapsules(containerID)
+		os.RemoveAll(filepath.Join(e.Root, "containers", containerID))
+	}()
+
+	stdout, stderr := captureStdoutStderr(func() {
+		if err := cm.AttachCapsule(containerID, "progress", "1.0"); err != nil {
+			t.Errorf("AttachCapsule failed: %v", err)
+		}
+	})
+	if stdout != "" {
+		t.Errorf("Expected no stdout output, got: %s", stdout)
+	}
+	if !strings.Contains(stderr, "Attaching capsule progress:1.0") {
+		t.Errorf("Expected progress on stderr, got: %s", stderr)
+	}
+}
+
+// TestRegistryRetriesTransientErrors verifies that 503 responses are retried
+// until the registry recovers, honoring Retry-After
+func TestRegistryRetriesTransientErrors(t *testing.T) {
+	var manifestCalls, layerCalls int
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/busybox/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		manifestCalls++
+		if manifestCalls <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"layers": [{"digest": "sha256:layer1digest"}]}`))
+	})
+	handler.HandleFunc("/v2/library/busybox/blobs/sha256:layer1digest", func(w http.ResponseWriter, r *http.Request) {
+		layerCalls++
+		if layerCalls <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("layer1content"))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/", RetryDelay: time.Millisecond}
+	manifest, err := registry.FetchManifest("library/busybox", "latest")
+	if err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+	if len(manifest.Layers) != 1 || manifestCalls != 3 {
+		t.Errorf("Expected the manifest after 3 requests, got %+v after %d", manifest, manifestCalls)
+	}
+
+	reader, err := registry.FetchLayer("library/busybox", "sha256:layer1digest")
+	if err != nil {
+		t.Fatalf("FetchLayer failed: %v", err)
+	}
+	defer reader.Close()
+	if content, _ := io.ReadAll(reader); string(content) != "layer1content" || layerCalls != 3 {
+		t.Errorf("Expected the layer after 3 requests, got %q after %d", content, layerCalls)
+	}
+}
+
+// TestRegistryRetryLimits verifies that client errors are not retried and
+// that transient errors give up after MaxAttempts
+func TestRegistryRetryLimits(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch r.URL.Path {
+		case "/v2/private/manifests/latest":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/v2/busy/manifests/latest":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/", MaxAttempts: 3, RetryDelay: time.Millisecond}
+	for _, repo := range []string{"private", "missing"} {
+		if _, err := registry.FetchManifest(repo, "latest"); err == nil {
+			t.Errorf("Expected FetchManifest(%s) to fail", repo)
+		}
+		if n := calls["/v2/"+repo+"/manifests/latest"]; n != 1 {
+			t.Errorf("Expected a single request for %s, got %d", repo, n)
+		}
+	}
+	if _, err := registry.FetchManifest("busy", "latest"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected FetchManifest to fail with 429, got %v", err)
+	}
+	if n := calls["/v2/busy/manifests/latest"]; n != 3 {
+		t.Errorf("Expected 3 requests for a rate limited manifest, got %d", n)
+	}
+}
+
+// TestParseRetryAfter accepts seconds and HTTP dates
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("2"); !ok || d != 2*time.Second {
+		t.Errorf("Expected 2s, got %v %v", d, ok)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date); !ok || d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Expected about an hour for %s, got %v %v", date, d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("Expected an invalid Retry-After to be ignored")
+	}
+}
+
+// TestRegistryTimesOut verifies that a registry that never responds makes the
+// fetch fail instead of hanging
+func TestRegistryTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	registry := NewDockerHubRegistry(server.URL+"/v2/", WithTimeouts(time.Second, 100*time.Millisecond))
+	done := make(chan error, 1)
+	go func() {
+		_, err := registry.FetchManifest("library/busybox", "latest")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected FetchManifest to time out")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FetchManifest hung on a registry that never responds")
+	}
+}
+
+// TestNewDockerHubRegistryOptions applies the registry options
+func TestNewDockerHubRegistryOptions(t *testing.T) {
+	client := &http.Client{}
+	registry := NewDockerHubRegistry("", WithHTTPClient(client), WithMaxAttempts(2))
+	if registry.BaseURL != "https://registry-1.docker.io/v2/" || registry.httpClient() != client || registry.maxAttempts() != 2 {
+		t.Errorf("Options not applied: %+v", registry)
+	}
+	if NewDockerHubRegistry("").httpClient() == http.DefaultClient {
+		t.Error("Expected a new registry to get a client with the registry timeouts")
+	}
+	if (&DockerHubRegistry{}).httpClient() != http.DefaultClient {
+		t.Error("Expected a zero registry to use http.DefaultClient")
+	}
+}
+
+// TestRegistryRedirectDropsAuthorization verifies that credentials for the
+// registry are not sent to the CDN a blob download redirects to
+func TestRegistryRedirectDropsAuthorization(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("layer-from-cdn"))
+	}))
+	defer cdn.Close()
+
+	var registryAuth string
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryAuth = r.Header.Get("Authorization")
+		http.Redirect(w, r, cdn.URL+"/blobs/layer1", http.StatusTemporaryRedirect)
+	}))
+	defer registryServer.Close()
+
+	registry := NewDockerHubRegistry(registryServer.URL+"/v2/", WithAuthorization("Bearer secret"))
+	reader, err := registry.FetchLayer("library/busybox", "sha256:layer1digest")
+	if err != nil {
+		t.Fatalf("FetchLayer failed: %v", err)
+	}
+	defer reader.Close()
+	if content, _ := io.ReadAll(reader); string(content) != "layer-from-cdn" {
+		t.Errorf("Expected the layer from the CDN, got %q", content)
+	}
+	if registryAuth != "Bearer secret" {
+		t.Errorf("Expected the registry to receive the Authorization header, got %q", registryAuth)
+	}
+}
+
+// TestExtractLayerWhiteouts verifies that whiteouts in a layer remove the
+// files they name from the rootfs and are not extracted themselves
+func TestExtractLayerWhiteouts(t *testing.T) {
+	e := newTestEngine(t)
+	imageDir := t.TempDir()
+	rootfs := filepath.Join(imageDir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Fatalf("Failed to create rootfs: %v", err)
+	}
+
+	layers := []map[string]string{
+		{"etc/removed.conf": "old", "etc/kept.conf": "kept", "var/cache/a": "a"},
+		{"etc/.wh.removed.conf": "", "var/cache/.wh..wh..opq": "", "var/cache/b": "b"},
+	}
+	for i, files := range layers {
+		f, err := os.Open(writeTestTar(t, files))
+		if err != nil {
+			t.Fatalf("Failed to open layer %d: %v", i, err)
+		}
+		err = e.extractLayer(f, rootfs)
+		f.Close()
+		if err != nil {
+			t.Fatalf("extractLayer failed for layer %d: %v", i, err)
+		}
+	}
+
+	for _, path := range []string{"etc/removed.conf", "etc/.wh.removed.conf", "var/cache/a", "var/cache/.wh..wh..opq"} {
+		if _, err := os.Lstat(filepath.Join(rootfs, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be absent from the rootfs", path)
+		}
+	}
+	for path, want := range map[string]string{"etc/kept.conf": "kept", "var/cache/b": "b"} {
+		if data, err := os.ReadFile(filepath.Join(rootfs, path)); err != nil || string(data) != want {
+			t.Errorf("Expected %s to hold %q, got %q, %v", path, want, data, err)
+		}
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(imageDir, ".extract-*")); len(leftovers) != 0 {
+		t.Errorf("Expected extraction directories to be removed, found %v", leftovers)
+	}
+}
+
+// TestPullDiskSpacePreflight fails a pull whose layers do not fit in the
+// available space before any layer is downloaded
+func TestPullDiskSpacePreflight(t *testing.T) {
+	e := newTestEngine(t)
+	oldAvailable := availableDiskSpace
+	availableDiskSpace = func(string) (uint64, error) { return 1024, nil }
+	t.Cleanup(func() { availableDiskSpace = oldAvailable })
+
+	fetched := false
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/big/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"layers": [{"digest": "sha256:big", "size": 4096}]}`))
+	})
+	handler.HandleFunc("/v2/library/big/blobs/sha256:big", func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	_, err := e.PullPlatform(&DockerHubRegistry{BaseURL: server.URL + "/v2/"}, "library/big", Platform{})
+	if err == nil || !strings.Contains(err.Error(), "not enough space") {
+		t.Errorf("Expected a not enough space error, got %v", err)
+	}
+	if fetched {
+		t.Error("Expected no layer to be downloaded")
+	}
+}
+
+// TestPullConcurrentLayersApplyInOrder pulls layers concurrently and checks
+// later layers still override earlier ones
+func TestPullConcurrentLayersApplyInOrder(t *testing.T) {
+	e := newTestEngine(t)
+	layers := []map[string]string{
+		{"file": "1", "first": "1"},
+		{"file": "2", ".wh.first": ""},
+		{"file": "3"},
+	}
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/layered/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"layers": [{"digest": "sha256:0"}, {"digest": "sha256:1"}, {"digest": "sha256:2"}]}`))
+	})
+	for i, files := range layers {
+		data, err := os.ReadFile(writeTestTar(t, files))
+		if err != nil {
+			t.Fatalf("Failed to read layer: %v", err)
+		}
+		// The first layer is served last so that it is applied after waiting
+		delay := time.Duration(len(layers)-i) * 20 * time.Millisecond
+		handler.HandleFunc(fmt.Sprintf("/v2/library/layered/blobs/sha256:%d", i), func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.Write(data)
+		})
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	image, err := e.PullWithOptions(&DockerHubRegistry{BaseURL: server.URL + "/v2/"}, "library/layered", PullOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("PullWithOptions failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(image.RootFS, "file")); err != nil || string(data) != "3" {
+		t.Errorf("Expected the last layer to win, got %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(image.RootFS, "first")); !os.IsNotExist(err) {
+		t.Errorf("Expected the whiteout to remove the file, got %v", err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(image.RootFS), ".pull-*")); len(leftovers) != 0 {
+		t.Errorf("Expected layer directories to be removed, found %v", leftovers)
+	}
+}
+
+// TestPullRecordsManifestDigest checks the digest of a pulled image is the
+// sha256 of the served manifest and that the digest pins the image for run
+func TestPullRecordsManifestDigest(t *testing.T) {
+	e := newTestEngine(t)
+	layer, err := os.ReadFile(writeTestTar(t, map[string]string{"hello.txt": "hello"}))
+	if err != nil {
+		t.Fatalf("Failed to read layer: %v", err)
+	}
+	manifest := []byte(`{"layers": [{"digest": "sha256:layer"}]}`)
+	sum := sha256.Sum256(manifest)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	handler := http.NewServeMux()
+	for _, ref := range []string{"latest", digest} {
+		handler.HandleFunc("/v2/library/pinned/manifests/"+ref, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(manifest)
+		})
+	}
+	handler.HandleFunc("/v2/library/pinned/manifests/sha256:"+strings.Repeat("0", 64), func(w http.ResponseWriter, r *http.Request) {
+		w.Write(manifest)
+	})
+	handler.HandleFunc("/v2/library/pinned/blobs/sha256:layer", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(layer)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/"}
+
+	image, err := e.PullPlatform(registry, "library/pinned", Platform{})
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if image.Digest != digest {
+		t.Errorf("Expected digest %s, got %s", digest, image.Digest)
+	}
+	if stored := e.loadImageDigest("library/pinned"); stored != digest {
+		t.Errorf("Expected stored digest %s, got %s", digest, stored)
+	}
+	if ref := e.resolveImageDigestRef("library/pinned@" + digest); ref != "library/pinned:latest" {
+		t.Errorf("Expected the digest to resolve to the pulled tag, got %s", ref)
+	}
+
+	image, err = e.PullPlatform(registry, "library/pinned@"+digest, Platform{})
+	if err != nil {
+		t.Fatalf("Pull by digest failed: %v", err)
+	}
+	if image.Name != "library/pinned@"+digest {
+		t.Errorf("Expected the image to be named by its digest, got %s", image.Name)
+	}
+	if _, err := os.Stat(filepath.Join(image.RootFS, "hello.txt")); err != nil {
+		t.Errorf("Expected hello.txt in the pinned image: %v", err)
+	}
+	if _, err := e.PullPlatform(registry, "library/pinned@sha256:"+strings.Repeat("0", 64), Platform{}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected a digest mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rootfsDigestFile records the digest of an image's rootfs, computed when
+// the image is pulled, loaded or imported.
+const rootfsDigestFile = "rootfs.sha256"
+
+// essentialRootfsPaths must exist in a usable rootfs, as created by
+// initializeBaseLayer for the base layer.
+var essentialRootfsPaths = []string{"/bin", "/etc", "/bin/sh"}
+
+// maxRootfsSymlinks bounds the symlinks followed when resolving a path in a
+// rootfs, as the kernel's limit does.
+const maxRootfsSymlinks = 40
+
+// resolveInRootfs resolves path inside rootfs, following symlinks as they
+// would be seen from inside the container: absolute targets are relative to
+// rootfs and ".." never leaves it.
+func resolveInRootfs(rootfs, path string) (string, error) {
+	pending := strings.Split(strings.Trim(path, "/"), "/")
+	resolved := ""
+	for links := 0; len(pending) > 0; {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+
+		candidate := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(rootfs, candidate))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+		if links++; links > maxRootfsSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(rootfs, candidate))
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = ""
+		}
+		pending = append(strings.Split(strings.Trim(target, "/"), "/"), pending...)
+	}
+	return filepath.Join(rootfs, resolved), nil
+}
+
+// checkEssentialPaths reports the essential paths missing from a rootfs.
+func checkEssentialPaths(rootfs string) []string {
+	var missing []string
+	for _, path := range essentialRootfsPaths {
+		if _, err := resolveInRootfs(rootfs, path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// rootfsDigest hashes the tree of a rootfs: the path, type, permissions and
+// content or link target of every entry, in lexical order. Ownership is left
+// out since it depends on who extracted the image.
+func rootfsDigest(rootfs string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(rootfs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%v\x00", rel, info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(hash, target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(hash, file); err != nil {
+				return err
+			}
+		}
+		_, err = hash.Write([]byte{0})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash rootfs: %v", err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordRootfsDigest stores the digest of an image's rootfs so that verify
+// can later detect changes to it.
+func recordRootfsDigest(imageDir string) error {
+	digest, err := rootfsDigest(filepath.Join(imageDir, "rootfs"))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(imageDir, rootfsDigestFile), []byte(digest+"\n"), 0644)
+}
+
+// ImageCheck is the outcome of one check run by VerifyImage.
+type ImageCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// VerifyImage checks that an image's rootfs is complete: it must exist, hold
+// the essential paths and, when a digest was recorded, still match it.
+func (e *Engine) VerifyImage(ref string) ([]ImageCheck, error) {
+	imageDir := e.imageStorePath(ref)
+	if _, err := os.Stat(imageDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("image %s does not exist", normalizeImageRef(ref))
+	}
+	rootfs := filepath.Join(imageDir, "rootfs")
+
+	var checks []ImageCheck
+	entries, err := os.ReadDir(rootfs)
+	rootfsCheck := ImageCheck{Name: "rootfs present", Passed: err == nil && len(entries) > 0}
+	if err != nil {
+		rootfsCheck.Detail = err.Error()
+	} else if len(entries) == 0 {
+		rootfsCheck.Detail = "rootfs is empty"
+	}
+	checks = append(checks, rootfsCheck)
+
+	missing := checkEssentialPaths(rootfs)
+	pathsCheck := ImageCheck{Name: "essential paths", Passed: len(missing) == 0}
+	if len(missing) > 0 {
+		pathsCheck.Detail = "missing " + strings.Join(missing, ", ")
+	}
+	checks = append(checks, pathsCheck)
+
+	recorded, err := os.ReadFile(filepath.Join(imageDir, rootfsDigestFile))
+	switch {
+	case os.IsNotExist(err):
+		checks = append(checks, ImageCheck{Name: "rootfs digest", Passed: true, Detail: "no digest recorded, skipped"})
+	case err != nil:
+		return nil, fmt.Errorf("failed to read rootfs digest: %v", err)
+	default:
+		expected := strings.TrimSpace(string(recorded))
+		digestCheck := ImageCheck{Name: "rootfs digest"}
+		if actual, err := rootfsDigest(rootfs); err != nil {
+			digestCheck.Detail = err.Error()
+		} else if actual != expected {
+			digestCheck.Detail = fmt.Sprintf("expected %s, got %s", expected, actual)
+		} else {
+			digestCheck.Passed = true
+			digestCheck.Detail = actual
+		}
+		checks = append(checks, digestCheck)
+	}
+	return checks, nil
+}
+
+// printImageChecks writes the checks and a summary line, and reports whether
+// they all passed.
+func printImageChecks(w io.Writer, ref string, checks []ImageCheck) bool {
+	passed := 0
+	for _, check := range checks {
+		status := "FAIL"
+		if check.Passed {
+			status = "PASS"
+			passed++
+		}
+		if check.Detail != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status, check.Name, check.Detail)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", status, check.Name)
+		}
+	}
+	fmt.Fprintf(w, "Image '%s': %d/%d checks passed\n", normalizeImageRef(ref), passed, len(checks))
+	return passed == len(checks)
+}
+
+// verifyImageCommand implements "image verify <name>".
+func (e *Engine) verifyImageCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker image verify <image>")
+		os.Exit(1)
+	}
+	checks, err := e.VerifyImage(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !printImageChecks(os.Stdout, args[0], checks) {
+		os.Exit(1)
+	}
+}
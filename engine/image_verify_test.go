@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestRootfs creates an image whose /bin/sh is a symlink to busybox, as
+// in Alpine.
+func writeTestRootfs(t *testing.T, e *Engine, name string) string {
+	t.Helper()
+	rootfs := filepath.Join(e.imageStorePath(name), "rootfs")
+	for _, dir := range []string{"bin", "etc"} {
+		if err := os.MkdirAll(filepath.Join(rootfs, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "bin", "busybox"), []byte("#!busybox"), 0755); err != nil {
+		t.Fatalf("Failed to write busybox: %v", err)
+	}
+	if err := os.Symlink("/bin/busybox", filepath.Join(rootfs, "bin", "sh")); err != nil {
+		t.Fatalf("Failed to link sh: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "etc", "hostname"), []byte("box\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hostname: %v", err)
+	}
+	return rootfs
+}
+
+func TestVerifyImage(t *testing.T) {
+	e := newTestEngine(t)
+	writeTestRootfs(t, e, "good")
+	if err := recordRootfsDigest(e.imageStorePath("good")); err != nil {
+		t.Fatalf("recordRootfsDigest failed: %v", err)
+	}
+
+	checks, err := e.VerifyImage("good")
+	if err != nil {
+		t.Fatalf("VerifyImage failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if !printImageChecks(&buf, "good", checks) {
+		t.Errorf("Expected every check to pass:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "3/3 checks passed") {
+		t.Errorf("Unexpected summary:\n%s", buf.String())
+	}
+
+	summaries, err := e.listImageSummaries()
+	if err != nil || len(summaries) != 1 || !summaries[0].ContentVerified {
+		t.Errorf("Expected the image to be listed as verified, got %+v, %v", summaries, err)
+	}
+}
+
+func TestVerifyImageBrokenRootfs(t *testing.T) {
+	e := newTestEngine(t)
+	rootfs := writeTestRootfs(t, e, "broken")
+	if err := recordRootfsDigest(e.imageStorePath("broken")); err != nil {
+		t.Fatalf("recordRootfsDigest failed: %v", err)
+	}
+	// A partial extraction that lost the shell's target and changed a file
+	if err := os.Remove(filepath.Join(rootfs, "bin", "busybox")); err != nil {
+		t.Fatalf("Failed to remove busybox: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "etc", "hostname"), []byte("other\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite hostname: %v", err)
+	}
+
+	checks, err := e.VerifyImage("broken")
+	if err != nil {
+		t.Fatalf("VerifyImage failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if printImageChecks(&buf, "broken", checks) {
+		t.Fatalf("Expected verification to fail:\n%s", buf.String())
+	}
+	for _, want := range []string{"FAIL\tessential paths\tmissing /bin/sh", "FAIL\trootfs digest", "1/3 checks passed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+
+	summaries, err := e.listImageSummaries()
+	if err != nil || len(summaries) != 1 || summaries[0].ContentVerified {
+		t.Errorf("Expected the image to be listed as unverified, got %+v, %v", summaries, err)
+	}
+	if _, err := e.VerifyImage("missing"); err == nil {
+		t.Error("Expected an error for a missing image")
+	}
+}
+
+func TestResolveInRootfs(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, "usr", "bin"), 0755); err != nil {
+		t.Fatalf("Failed to create usr/bin: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "usr", "bin", "dash"), nil, 0755); err != nil {
+		t.Fatalf("Failed to write dash: %v", err)
+	}
+	// A merged /usr layout as in Debian, with a link escaping the rootfs
+	os.Symlink("usr/bin", filepath.Join(rootfs, "bin"))
+	os.Symlink("dash", filepath.Join(rootfs, "usr", "bin", "sh"))
+	os.Symlink("../../../../usr/bin/dash", filepath.Join(rootfs, "usr", "bin", "escape"))
+	os.Symlink("loop", filepath.Join(rootfs, "loop"))
+
+	for path, want := range map[string]string{
+		"/bin/sh":     filepath.Join(rootfs, "usr", "bin", "dash"),
+		"/bin/escape": filepath.Join(rootfs, "usr", "bin", "dash"),
+	} {
+		got, err := resolveInRootfs(rootfs, path)
+		if err != nil || got != want {
+			t.Errorf("resolveInRootfs(%s) = %s, %v, want %s", path, got, err, want)
+		}
+	}
+	if _, err := resolveInRootfs(rootfs, "/loop"); err == nil {
+		t.Error("Expected an error for a symlink loop")
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportImage creates an image from a rootfs tar stream. A partially
+// extracted image is removed when extraction fails.
+func (e *Engine) ImportImage(reader io.Reader, imageName string) (*Image, error) {
+	if err := validateImageRef(imageName); err != nil {
+		return nil, err
+	}
+	imageName = normalizeImageRef(imageName)
+	imageDir := e.imageStorePath(imageName)
+	if _, err := os.Stat(imageDir); err == nil {
+		return nil, fmt.Errorf("image %s already exists", imageName)
+	}
+	rootfs := filepath.Join(imageDir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rootfs: %v", err)
+	}
+	if err := e.extractLayer(reader, rootfs); err != nil {
+		os.RemoveAll(imageDir)
+		return nil, err
+	}
+	if err := recordRootfsDigest(imageDir); err != nil {
+		e.Logger.Warn("failed to record rootfs digest", "image", imageName, "error", err)
+	}
+	image := &Image{Name: imageName, RootFS: rootfs, Layers: []string{"base"}}
+	e.recordImageMetadata(image)
+	return image, nil
+}
+
+// openImportSource opens the tar read by import: "-" for stdin, an http or
+// https URL, fetched with client, or a file path.
+func openImportSource(source string, client *http.Client) (io.ReadCloser, error) {
+	switch {
+	case source == "-":
+		return io.NopCloser(os.Stdin), nil
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %v", source, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch %s: %s", source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tar file: %v", err)
+	}
+	return file, nil
+}
+
+// importCommand implements "import <file|url|-> <image-name>".
+func (e *Engine) importCommand(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker import <file|url|-> <image-name>")
+		os.Exit(1)
+	}
+	source, err := openImportSource(args[0], e.RegistryClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer source.Close()
+
+	image, err := e.ImportImage(source, args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to import image: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Image '%s' imported successfully.\n", image.Name)
+}
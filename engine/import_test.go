@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildRootfsTar returns an in-memory tar holding a single file.
+func buildRootfsTar(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestImportImage verifies that a tar stream becomes an image and that an
+// existing image is not overwritten.
+func TestImportImage(t *testing.T) {
+	e := newTestEngine(t)
+	data := buildRootfsTar(t, "etc/motd", "hello")
+
+	image, err := e.ImportImage(bytes.NewReader(data), "piped")
+	if err != nil {
+		t.Fatalf("ImportImage failed: %v", err)
+	}
+	if image.Name != "piped:latest" {
+		t.Errorf("Expected image piped:latest, got %s", image.Name)
+	}
+	content, err := os.ReadFile(filepath.Join(e.imageStorePath("piped:latest"), "rootfs", "etc", "motd"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected the imported file, got %q (err %v)", content, err)
+	}
+
+	if _, err := e.ImportImage(bytes.NewReader(data), "piped:latest"); err == nil {
+		t.Error("Expected importing over an existing image to fail")
+	}
+	if _, err := e.ImportImage(bytes.NewReader([]byte("not a tar")), "broken"); err == nil {
+		t.Error("Expected an invalid tar to fail")
+	}
+	if _, err := os.Stat(e.imageStorePath("broken:latest")); !os.IsNotExist(err) {
+		t.Errorf("Expected a failed import to be cleaned up, got %v", err)
+	}
+}
+
+// TestImportImageFromURL verifies that import fetches tars over HTTP.
+func TestImportImageFromURL(t *testing.T) {
+	e := newTestEngine(t)
+	data := buildRootfsTar(t, "hello.txt", "from http")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rootfs.tar" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	if _, err := openImportSource(server.URL+"/missing.tar", http.DefaultClient); err == nil {
+		t.Error("Expected a missing URL to fail")
+	}
+	source, err := openImportSource(server.URL+"/rootfs.tar", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("openImportSource failed: %v", err)
+	}
+	defer source.Close()
+	if _, err := e.ImportImage(source, "fetched:v1"); err != nil {
+		t.Fatalf("ImportImage failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(e.imageStorePath("fetched:v1"), "rootfs", "hello.txt")); err != nil {
+		t.Errorf("Expected the fetched file in the image: %v", err)
+	}
+}
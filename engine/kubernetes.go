@@ -1,16 +1,27 @@
-package main
+package engine
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -61,24 +72,86 @@ func NewKubernetesCapsuleManager(namespace string) (*KubernetesCapsuleManager, e
 	}, nil
 }
 
-// CreateConfigMapCapsule creates a ConfigMap-based Resource Capsule
-func (kcm *KubernetesCapsuleManager) CreateConfigMapCapsule(name, version string, data map[string]string) error {
-	configMapName := fmt.Sprintf("%s-%s", name, version)
-	
-	configMap := &v1.ConfigMap{
+// capsuleLabels returns the labels identifying a Resource Capsule
+func capsuleLabels(name, version string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":    "resource-capsule",
+		"app.kubernetes.io/version": version,
+		"capsule.docker.io/name":    name,
+		"capsule.docker.io/version": version,
+	}
+}
+
+// newConfigMapCapsule builds the ConfigMap backing a Resource Capsule
+func (kcm *KubernetesCapsuleManager) newConfigMapCapsule(name, version string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      configMapName,
+			Name:      fmt.Sprintf("%s-%s", name, version),
 			Namespace: kcm.namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":    "resource-capsule",
-				"app.kubernetes.io/version": version,
-				"capsule.docker.io/name":    name,
-				"capsule.docker.io/version": version,
-			},
+			Labels:    capsuleLabels(name, version),
+		},
+		Data: data,
+	}
+}
+
+// newSecretCapsule builds the Secret backing a Resource Capsule
+func (kcm *KubernetesCapsuleManager) newSecretCapsule(name, version string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", name, version),
+			Namespace: kcm.namespace,
+			Labels:    capsuleLabels(name, version),
 		},
 		Data: data,
+		Type: v1.SecretTypeOpaque,
+	}
+}
+
+// maxCapsuleDataSize is the most data Kubernetes accepts in a single
+// ConfigMap or Secret.
+const maxCapsuleDataSize = 1 << 20
+
+// checkCapsuleSize rejects capsule data the API server would refuse, with an
+// error explaining what to do instead of the server's validation message.
+func checkCapsuleSize(kind, name string, size int) error {
+	if size <= maxCapsuleDataSize {
+		return nil
+	}
+	return fmt.Errorf("%s capsule %s holds %.1f MiB of data, over the 1 MiB Kubernetes allows per object; "+
+		"split it across several capsules or use a Docker capsule (basic-docker capsule add) for large files",
+		kind, name, float64(size)/(1<<20))
+}
+
+// configMapDataSize returns the size of the data held by a ConfigMap
+func configMapDataSize(configMap *v1.ConfigMap) int {
+	size := 0
+	for key, value := range configMap.Data {
+		size += len(key) + len(value)
+	}
+	for key, value := range configMap.BinaryData {
+		size += len(key) + len(value)
 	}
+	return size
+}
+
+// secretDataSize returns the size of the data held by a Secret
+func secretDataSize(secret *v1.Secret) int {
+	size := 0
+	for key, value := range secret.Data {
+		size += len(key) + len(value)
+	}
+	for key, value := range secret.StringData {
+		size += len(key) + len(value)
+	}
+	return size
+}
 
+// CreateConfigMapCapsule creates a ConfigMap-based Resource Capsule
+func (kcm *KubernetesCapsuleManager) CreateConfigMapCapsule(name, version string, data map[string]string) error {
+	configMap := kcm.newConfigMapCapsule(name, version, data)
+	if err := checkCapsuleSize("ConfigMap", configMap.Name, configMapDataSize(configMap)); err != nil {
+		return err
+	}
 	_, err := kcm.client.CoreV1().ConfigMaps(kcm.namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to create ConfigMap capsule: %v", err)
@@ -88,25 +161,24 @@ func (kcm *KubernetesCapsuleManager) CreateConfigMapCapsule(name, version string
 	return nil
 }
 
-// CreateSecretCapsule creates a Secret-based Resource Capsule  
-func (kcm *KubernetesCapsuleManager) CreateSecretCapsule(name, version string, data map[string][]byte) error {
-	secretName := fmt.Sprintf("%s-%s", name, version)
-	
-	secret := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: kcm.namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":    "resource-capsule",
-				"app.kubernetes.io/version": version,
-				"capsule.docker.io/name":    name,
-				"capsule.docker.io/version": version,
-			},
-		},
-		Data: data,
-		Type: v1.SecretTypeOpaque,
+// ApplyConfigMapCapsule creates a ConfigMap-based Resource Capsule, or
+// replaces the data of an existing one so that re-running an add succeeds
+func (kcm *KubernetesCapsuleManager) ApplyConfigMapCapsule(name, version string, data map[string]string) error {
+	created, err := applyConfigMap(kcm.client, kcm.newConfigMapCapsule(name, version, data))
+	if err != nil {
+		return fmt.Errorf("failed to apply ConfigMap capsule: %v", err)
 	}
 
+	fmt.Printf("[Kubernetes] ConfigMap capsule %s:%s %s successfully\n", name, version, appliedVerb(created))
+	return nil
+}
+
+// CreateSecretCapsule creates a Secret-based Resource Capsule
+func (kcm *KubernetesCapsuleManager) CreateSecretCapsule(name, version string, data map[string][]byte) error {
+	secret := kcm.newSecretCapsule(name, version, data)
+	if err := checkCapsuleSize("Secret", secret.Name, secretDataSize(secret)); err != nil {
+		return err
+	}
 	_, err := kcm.client.CoreV1().Secrets(kcm.namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to create Secret capsule: %v", err)
@@ -116,6 +188,98 @@ func (kcm *KubernetesCapsuleManager) CreateSecretCapsule(name, version string, d
 	return nil
 }
 
+// ApplySecretCapsule creates a Secret-based Resource Capsule, or replaces
+// the data of an existing one
+func (kcm *KubernetesCapsuleManager) ApplySecretCapsule(name, version string, data map[string][]byte) error {
+	created, err := applySecret(kcm.client, kcm.newSecretCapsule(name, version, data))
+	if err != nil {
+		return fmt.Errorf("failed to apply Secret capsule: %v", err)
+	}
+
+	fmt.Printf("[Kubernetes] Secret capsule %s:%s %s successfully\n", name, version, appliedVerb(created))
+	return nil
+}
+
+// CapsuleFromFile builds the object adding a file as a capsule creates: a
+// ConfigMap for text content or a Secret for binary content
+func (kcm *KubernetesCapsuleManager) CapsuleFromFile(name, version, path string) (runtime.Object, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capsule file: %v", err)
+	}
+
+	key := filepath.Base(path)
+	if isTextFile(content) {
+		configMap := kcm.newConfigMapCapsule(name, version, map[string]string{key: string(content)})
+		configMap.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+		return configMap, nil
+	}
+	secret := kcm.newSecretCapsule(name, version, map[string][]byte{key: content})
+	secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	return secret, nil
+}
+
+// writeObjectJSON prints an object as --dry-run shows it
+func writeObjectJSON(w io.Writer, obj interface{}) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render object: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// appliedVerb describes the outcome of an apply
+func appliedVerb(created bool) string {
+	if created {
+		return "created"
+	}
+	return "updated"
+}
+
+// applyConfigMap creates a ConfigMap or, when it already exists, updates its
+// labels and data. It reports whether the ConfigMap was created.
+func applyConfigMap(client kubernetes.Interface, configMap *v1.ConfigMap) (bool, error) {
+	if err := checkCapsuleSize("ConfigMap", configMap.Name, configMapDataSize(configMap)); err != nil {
+		return false, err
+	}
+	configMaps := client.CoreV1().ConfigMaps(configMap.Namespace)
+	_, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{})
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err == nil, err
+	}
+	existing, err := configMaps.Get(context.TODO(), configMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	existing.Labels = configMap.Labels
+	existing.Data = configMap.Data
+	existing.BinaryData = configMap.BinaryData
+	_, err = configMaps.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	return false, err
+}
+
+// applySecret creates a Secret or, when it already exists, updates its
+// labels and data. It reports whether the Secret was created.
+func applySecret(client kubernetes.Interface, secret *v1.Secret) (bool, error) {
+	if err := checkCapsuleSize("Secret", secret.Name, secretDataSize(secret)); err != nil {
+		return false, err
+	}
+	secrets := client.CoreV1().Secrets(secret.Namespace)
+	_, err := secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err == nil, err
+	}
+	existing, err := secrets.Get(context.TODO(), secret.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	existing.Labels = secret.Labels
+	existing.Data = secret.Data
+	_, err = secrets.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	return false, err
+}
+
 // GetConfigMapCapsule retrieves a ConfigMap-based Resource Capsule
 func (kcm *KubernetesCapsuleManager) GetConfigMapCapsule(name, version string) (*v1.ConfigMap, error) {
 	configMapName := fmt.Sprintf("%s-%s", name, version)
@@ -140,6 +304,55 @@ func (kcm *KubernetesCapsuleManager) GetSecretCapsule(name, version string) (*v1
 	return secret, nil
 }
 
+// CapsuleContents is the data of a ConfigMap or Secret capsule
+type CapsuleContents struct {
+	// Kind is "ConfigMap" or "Secret"
+	Kind string
+	Data map[string][]byte
+}
+
+// GetCapsule retrieves a capsule whichever resource backs it, trying
+// ConfigMaps before Secrets
+func (kcm *KubernetesCapsuleManager) GetCapsule(name, version string) (*CapsuleContents, error) {
+	if configMap, err := kcm.GetConfigMapCapsule(name, version); err == nil {
+		data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+		return &CapsuleContents{Kind: "ConfigMap", Data: data}, nil
+	}
+	if secret, err := kcm.GetSecretCapsule(name, version); err == nil {
+		return &CapsuleContents{Kind: "Secret", Data: secret.Data}, nil
+	}
+	return nil, fmt.Errorf("capsule %s:%s not found", name, version)
+}
+
+// printCapsule writes a capsule's keys, and its values when showValues is
+// set. Secret values stay base64-encoded unless reveal is set, so that they
+// are not printed by accident.
+func printCapsule(w io.Writer, name, version string, capsule *CapsuleContents, showValues, reveal bool) {
+	fmt.Fprintf(w, "%s Capsule: %s:%s\n", capsule.Kind, name, version)
+	keys := getKeysBytes(capsule.Data)
+	sort.Strings(keys)
+	if !showValues {
+		fmt.Fprintf(w, "Data keys: %v\n", keys)
+		return
+	}
+
+	fmt.Fprintln(w, "Data:")
+	for _, key := range keys {
+		value := string(capsule.Data[key])
+		if capsule.Kind == "Secret" && !reveal {
+			fmt.Fprintf(w, "--- %s (base64)\n%s\n", key, base64.StdEncoding.EncodeToString(capsule.Data[key]))
+			continue
+		}
+		fmt.Fprintf(w, "--- %s\n%s\n", key, strings.TrimSuffix(value, "\n"))
+	}
+}
+
 // ListCapsules lists all Resource Capsules in the namespace
 func (kcm *KubernetesCapsuleManager) ListCapsules() error {
 	fmt.Printf("[Kubernetes] Resource Capsules in namespace '%s':\n", kcm.namespace)
@@ -324,19 +537,13 @@ func (kcm *KubernetesCapsuleManager) BenchmarkKubernetesResourceAccess(name, ver
 
 // CRD-related functions
 
-// CreateCRDCapsule creates a ResourceCapsule custom resource
-func (kcm *KubernetesCapsuleManager) CreateCRDCapsule(name, version string, data map[string]interface{}, capsuleType string) error {
+// newCRDCapsule builds a ResourceCapsule custom resource
+func (kcm *KubernetesCapsuleManager) newCRDCapsule(name, version string, data map[string]interface{}, capsuleType string) *unstructured.Unstructured {
 	if capsuleType == "" {
 		capsuleType = "configmap"
 	}
 
-	gvr := schema.GroupVersionResource{
-		Group:    "capsules.docker.io",
-		Version:  "v1",
-		Resource: "resourcecapsules",
-	}
-
-	resourceCapsule := &unstructured.Unstructured{
+	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "capsules.docker.io/v1",
 			"kind":       "ResourceCapsule",
@@ -358,7 +565,61 @@ func (kcm *KubernetesCapsuleManager) CreateCRDCapsule(name, version string, data
 			},
 		},
 	}
+}
+
+// crdCapsuleTypes are the capsuleType values the ResourceCapsule CRD allows
+var crdCapsuleTypes = []string{"configmap", "secret"}
+
+// validateCRDCapsule checks a ResourceCapsule against the rules of the CRD's
+// OpenAPI schema, so that mistakes are reported before it is submitted
+func validateCRDCapsule(obj *unstructured.Unstructured) field.ErrorList {
+	var errs field.ErrorList
+	namePath := field.NewPath("metadata", "name")
+	if name := obj.GetName(); name == "" {
+		errs = append(errs, field.Required(namePath, ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, field.Invalid(namePath, name, msg))
+		}
+	}
+
+	specPath := field.NewPath("spec")
+	if version, _, _ := unstructured.NestedString(obj.Object, "spec", "version"); version == "" {
+		errs = append(errs, field.Required(specPath.Child("version"), ""))
+	}
+	if data, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "data"); data == nil || isNilMap(data) {
+		errs = append(errs, field.Required(specPath.Child("data"), ""))
+	}
+	capsuleType, _, _ := unstructured.NestedString(obj.Object, "spec", "capsuleType")
+	valid := false
+	for _, allowed := range crdCapsuleTypes {
+		valid = valid || capsuleType == allowed
+	}
+	if !valid {
+		errs = append(errs, field.NotSupported(specPath.Child("capsuleType"), capsuleType, crdCapsuleTypes))
+	}
+	return errs
+}
+
+// isNilMap reports whether v holds a nil map, which unstructured objects
+// encode as null
+func isNilMap(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	return ok && m == nil
+}
+
+// CreateCRDCapsule creates a ResourceCapsule custom resource
+func (kcm *KubernetesCapsuleManager) CreateCRDCapsule(name, version string, data map[string]interface{}, capsuleType string) error {
+	gvr := schema.GroupVersionResource{
+		Group:    "capsules.docker.io",
+		Version:  "v1",
+		Resource: "resourcecapsules",
+	}
 
+	resourceCapsule := kcm.newCRDCapsule(name, version, data, capsuleType)
+	if errs := validateCRDCapsule(resourceCapsule); len(errs) > 0 {
+		return fmt.Errorf("invalid ResourceCapsule %s: %v", name, errs.ToAggregate())
+	}
 	_, err := kcm.dynamicClient.Resource(gvr).Namespace(kcm.namespace).Create(context.TODO(), resourceCapsule, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to create ResourceCapsule CRD: %v", err)
@@ -368,6 +629,39 @@ func (kcm *KubernetesCapsuleManager) CreateCRDCapsule(name, version string, data
 	return nil
 }
 
+// ApplyCRDCapsule creates a ResourceCapsule custom resource, or replaces the
+// spec and labels of an existing one while keeping its status
+func (kcm *KubernetesCapsuleManager) ApplyCRDCapsule(name, version string, data map[string]interface{}, capsuleType string) error {
+	gvr := schema.GroupVersionResource{
+		Group:    "capsules.docker.io",
+		Version:  "v1",
+		Resource: "resourcecapsules",
+	}
+
+	resources := kcm.dynamicClient.Resource(gvr).Namespace(kcm.namespace)
+	resourceCapsule := kcm.newCRDCapsule(name, version, data, capsuleType)
+	if errs := validateCRDCapsule(resourceCapsule); len(errs) > 0 {
+		return fmt.Errorf("invalid ResourceCapsule %s: %v", name, errs.ToAggregate())
+	}
+	_, err := resources.Create(context.TODO(), resourceCapsule, metav1.CreateOptions{})
+	created := err == nil
+	if err != nil && apierrors.IsAlreadyExists(err) {
+		var existing *unstructured.Unstructured
+		existing, err = resources.Get(context.TODO(), name, metav1.GetOptions{})
+		if err == nil {
+			existing.Object["spec"] = resourceCapsule.Object["spec"]
+			existing.SetLabels(resourceCapsule.GetLabels())
+			_, err = resources.Update(context.TODO(), existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply ResourceCapsule CRD: %v", err)
+	}
+
+	fmt.Printf("[Kubernetes] ResourceCapsule CRD %s:%s %s successfully\n", name, version, appliedVerb(created))
+	return nil
+}
+
 // GetCRDCapsule retrieves a ResourceCapsule custom resource
 func (kcm *KubernetesCapsuleManager) GetCRDCapsule(name string) (*unstructured.Unstructured, error) {
 	gvr := schema.GroupVersionResource{
@@ -384,6 +678,72 @@ func (kcm *KubernetesCapsuleManager) GetCRDCapsule(name string) (*unstructured.U
 	return resourceCapsule, nil
 }
 
+// crdCapsuleStatus returns the phase and message of a ResourceCapsule. The
+// phase is "Unknown" until the operator has handled the resource.
+func crdCapsuleStatus(obj *unstructured.Unstructured) (string, string) {
+	phase, found, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if !found || phase == "" {
+		phase = "Unknown"
+	}
+	message, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+	return phase, message
+}
+
+// WatchCRDCapsuleStatus prints the phase and message of a ResourceCapsule
+// each time they change, until it becomes Active or Failed or the timeout
+// elapses. A Failed capsule is reported as an error.
+func (kcm *KubernetesCapsuleManager) WatchCRDCapsuleStatus(w io.Writer, name string, timeout time.Duration) error {
+	gvr := schema.GroupVersionResource{
+		Group:    "capsules.docker.io",
+		Version:  "v1",
+		Resource: "resourcecapsules",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	watcher, err := kcm.dynamicClient.Resource(gvr).Namespace(kcm.namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch ResourceCapsule CRD: %v", err)
+	}
+	defer watcher.Stop()
+
+	var lastPhase, lastMessage string
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("watch of ResourceCapsule %s closed", name)
+			}
+			switch event.Type {
+			case watch.Error:
+				return fmt.Errorf("failed to watch ResourceCapsule CRD: %v", apierrors.FromObject(event.Object))
+			case watch.Deleted:
+				return fmt.Errorf("ResourceCapsule %s was deleted", name)
+			}
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok || obj.GetName() != name {
+				continue
+			}
+
+			phase, message := crdCapsuleStatus(obj)
+			if phase != lastPhase || message != lastMessage {
+				fmt.Fprintf(w, "%s\t%s\n", phase, message)
+				lastPhase, lastMessage = phase, message
+			}
+			switch phase {
+			case "Active":
+				return nil
+			case "Failed":
+				return fmt.Errorf("ResourceCapsule %s failed: %s", name, message)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %v waiting for ResourceCapsule %s", timeout, name)
+		}
+	}
+}
+
 // ListCRDCapsules lists all ResourceCapsule custom resources
 func (kcm *KubernetesCapsuleManager) ListCRDCapsules() error {
 	gvr := schema.GroupVersionResource{
@@ -1,9 +1,12 @@
-package main
+package engine
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -154,6 +157,7 @@ func TestKubernetesCapsuleLifecycle(t *testing.T) {
 // TestAddKubernetesResourceCapsule tests the AddResourceCapsule function with Kubernetes environment
 func TestAddKubernetesResourceCapsule(t *testing.T) {
 	// Create a temporary test file
+	e := newTestEngine(t)
 	tempFile, err := os.CreateTemp("", "test-capsule-*.txt")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
@@ -169,7 +173,7 @@ func TestAddKubernetesResourceCapsule(t *testing.T) {
 	
 	// Note: This test will skip actual Kubernetes operations if no cluster is available
 	// In a real environment, this would create actual K8s resources
-	err = AddResourceCapsule("kubernetes", "test-capsule", "1.0", tempFile.Name())
+	err = e.AddResourceCapsule("kubernetes", "test-capsule", "1.0", tempFile.Name())
 	if err != nil {
 		// This is expected in test environment without real K8s cluster
 		t.Logf("Expected error in test environment: %v", err)
@@ -495,4 +499,148 @@ func TestBenchmarkKubernetesResourceAccess(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected error for non-existent capsule, got nil")
 	}
-}
\ No newline at end of file
+}
+
+// TestApplyConfigMapCapsuleTwice verifies that applying a capsule again
+// updates it instead of failing with AlreadyExists
+func TestApplyConfigMapCapsuleTwice(t *testing.T) {
+	mockKCM := NewMockKubernetesCapsuleManager()
+
+	if err := mockKCM.ApplyConfigMapCapsule("app-config", "1.0", map[string]string{"app.conf": "debug=false"}); err != nil {
+		t.Fatalf("First apply failed: %v", err)
+	}
+	if err := mockKCM.ApplyConfigMapCapsule("app-config", "1.0", map[string]string{"app.conf": "debug=true"}); err != nil {
+		t.Fatalf("Second apply failed: %v", err)
+	}
+
+	configMap, err := mockKCM.GetConfigMapCapsule("app-config", "1.0")
+	if err != nil {
+		t.Fatalf("Failed to get ConfigMap capsule: %v", err)
+	}
+	if configMap.Data["app.conf"] != "debug=true" {
+		t.Errorf("Expected the update to take effect, got %q", configMap.Data["app.conf"])
+	}
+
+	if err := mockKCM.CreateConfigMapCapsule("app-config", "1.0", nil); err == nil {
+		t.Error("Expected create to still fail for an existing capsule")
+	}
+}
+
+// TestApplySecretCapsuleTwice verifies that applying a secret capsule again
+// replaces its data
+func TestApplySecretCapsuleTwice(t *testing.T) {
+	mockKCM := NewMockKubernetesCapsuleManager()
+
+	if err := mockKCM.ApplySecretCapsule("db", "1.0", map[string][]byte{"password": []byte("old")}); err != nil {
+		t.Fatalf("First apply failed: %v", err)
+	}
+	if err := mockKCM.ApplySecretCapsule("db", "1.0", map[string][]byte{"password": []byte("new")}); err != nil {
+		t.Fatalf("Second apply failed: %v", err)
+	}
+
+	secret, err := mockKCM.GetSecretCapsule("db", "1.0")
+	if err != nil {
+		t.Fatalf("Failed to get Secret capsule: %v", err)
+	}
+	if string(secret.Data["password"]) != "new" {
+		t.Errorf("Expected the update to take effect, got %q", secret.Data["password"])
+	}
+}
+
+// TestPrintCapsuleValues verifies that values are only printed on request
+// and that secrets stay encoded unless revealed
+func TestPrintCapsuleValues(t *testing.T) {
+	mockKCM := NewMockKubernetesCapsuleManager()
+	if err := mockKCM.CreateConfigMapCapsule("app", "1.0", map[string]string{"app.conf": "debug=true"}); err != nil {
+		t.Fatalf("Failed to create ConfigMap capsule: %v", err)
+	}
+	if err := mockKCM.CreateSecretCapsule("db", "1.0", map[string][]byte{"password": []byte("hunter2")}); err != nil {
+		t.Fatalf("Failed to create Secret capsule: %v", err)
+	}
+
+	render := func(name string, showValues, reveal bool) string {
+		capsule, err := mockKCM.GetCapsule(name, "1.0")
+		if err != nil {
+			t.Fatalf("GetCapsule failed: %v", err)
+		}
+		var buf bytes.Buffer
+		printCapsule(&buf, name, "1.0", capsule, showValues, reveal)
+		return buf.String()
+	}
+
+	if out := render("app", false, false); !strings.Contains(out, "Data keys: [app.conf]") || strings.Contains(out, "debug=true") {
+		t.Errorf("Expected only keys by default, got:\n%s", out)
+	}
+	if out := render("app", true, false); !strings.Contains(out, "debug=true") {
+		t.Errorf("Expected the ConfigMap value with --show-values, got:\n%s", out)
+	}
+
+	if out := render("db", false, false); strings.Contains(out, "hunter2") || strings.Contains(out, "aHVudGVyMg==") {
+		t.Errorf("Expected no secret value by default, got:\n%s", out)
+	}
+	out := render("db", true, false)
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "aHVudGVyMg==") {
+		t.Errorf("Expected the secret base64-encoded with --show-values, got:\n%s", out)
+	}
+	if out := render("db", true, true); !strings.Contains(out, "hunter2") {
+		t.Errorf("Expected the decoded secret with --reveal, got:\n%s", out)
+	}
+
+	if _, err := mockKCM.GetCapsule("missing", "1.0"); err == nil {
+		t.Error("Expected a missing capsule to fail")
+	}
+}
+
+// TestCapsuleDryRun verifies that a dry-run renders the capsule without
+// creating it
+func TestCapsuleDryRun(t *testing.T) {
+	mockKCM := NewMockKubernetesCapsuleManager()
+	path := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(path, []byte("debug=true"), 0644); err != nil {
+		t.Fatalf("Failed to write capsule file: %v", err)
+	}
+
+	obj, err := mockKCM.CapsuleFromFile("app", "1.0", path)
+	if err != nil {
+		t.Fatalf("CapsuleFromFile failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeObjectJSON(&buf, obj); err != nil {
+		t.Fatalf("writeObjectJSON failed: %v", err)
+	}
+	for _, want := range []string{`"kind": "ConfigMap"`, `"name": "app-1.0"`, `"app.conf": "debug=true"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s in the rendered object, got:\n%s", want, buf.String())
+		}
+	}
+	if _, err := mockKCM.GetCapsule("app", "1.0"); err == nil {
+		t.Error("Expected no capsule to exist after a dry-run")
+	}
+
+	if args, dryRun := extractDryRun([]string{"app", "--dry-run", "1.0", "app.conf"}); !dryRun || len(args) != 3 || args[1] != "1.0" {
+		t.Errorf("Unexpected extractDryRun result: %v %v", args, dryRun)
+	}
+}
+
+// TestCapsuleOverSizeLimit verifies that capsule data over the 1 MiB
+// Kubernetes limit is rejected with an explanation before reaching the API
+func TestCapsuleOverSizeLimit(t *testing.T) {
+	mockKCM := NewMockKubernetesCapsuleManager()
+	large := strings.Repeat("x", maxCapsuleDataSize+1)
+
+	err := mockKCM.CreateConfigMapCapsule("big", "1.0", map[string]string{"data.txt": large})
+	if err == nil || !strings.Contains(err.Error(), "over the 1 MiB") {
+		t.Errorf("Expected a size limit error, got %v", err)
+	}
+	err = mockKCM.ApplySecretCapsule("big", "1.0", map[string][]byte{"data.bin": []byte(large)})
+	if err == nil || !strings.Contains(err.Error(), "over the 1 MiB") {
+		t.Errorf("Expected a size limit error, got %v", err)
+	}
+	if _, err := mockKCM.GetCapsule("big", "1.0"); err == nil {
+		t.Error("Expected no capsule to be created")
+	}
+
+	if err := mockKCM.CreateConfigMapCapsule("small", "1.0", map[string]string{"data.txt": "x"}); err != nil {
+		t.Errorf("Expected a small capsule to be created, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseLabel splits a key=value label. A label without "=" has an empty
+// value.
+func parseLabel(label string) (key, value string, err error) {
+	key, value, _ = strings.Cut(label, "=")
+	if strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid label %q: the key must not be empty", label)
+	}
+	return key, value, nil
+}
+
+// readLabelFile reads the labels of a label file, one key=value per line.
+// Blank lines and lines starting with # are skipped.
+func readLabelFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open label file: %v", err)
+	}
+	defer file.Close()
+
+	var labels []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		labels = append(labels, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read label file %s: %v", path, err)
+	}
+	return labels, nil
+}
+
+// parseLabels returns the labels of the label files followed by the given
+// labels, so a label given directly overrides one from a file.
+func parseLabels(labels, files []string) (map[string]string, error) {
+	var all []string
+	for _, path := range files {
+		fileLabels, err := readLabelFile(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fileLabels...)
+	}
+	all = append(all, labels...)
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string)
+	for _, label := range all {
+		key, value, err := parseLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// labelFilter matches labels with a key, and a value when hasValue is set.
+type labelFilter struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parseLabelFilters parses "label=key" and "label=key=value" filters.
+func parseLabelFilters(filters []string) ([]labelFilter, error) {
+	var parsed []labelFilter
+	for _, filter := range filters {
+		name, label, ok := strings.Cut(filter, "=")
+		if !ok || name != "label" {
+			return nil, fmt.Errorf("invalid filter %q: expected label=key or label=key=value", filter)
+		}
+		key, value, hasValue := strings.Cut(label, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid filter %q: the label key must not be empty", filter)
+		}
+		parsed = append(parsed, labelFilter{key: key, value: value, hasValue: hasValue})
+	}
+	return parsed, nil
+}
+
+// matchLabels reports whether labels satisfy every filter.
+func matchLabels(labels map[string]string, filters []labelFilter) bool {
+	for _, filter := range filters {
+		value, ok := labels[filter.key]
+		if !ok || (filter.hasValue && value != filter.value) {
+			return false
+		}
+	}
+	return true
+}
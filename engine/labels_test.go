@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseLabels reads labels from a file and lets labels given directly
+// override them
+func TestParseLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels")
+	if err := os.WriteFile(path, []byte("# team labels\nteam=web\n\ntier=frontend\n"), 0644); err != nil {
+		t.Fatalf("Failed to write label file: %v", err)
+	}
+	labels, err := parseLabels([]string{"tier=edge", "debug"}, []string{path})
+	if err != nil {
+		t.Fatalf("parseLabels failed: %v", err)
+	}
+	want := map[string]string{"team": "web", "tier": "edge", "debug": ""}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("parseLabels = %v, want %v", labels, want)
+	}
+
+	if _, err := parseLabels([]string{"=value"}, nil); err == nil {
+		t.Error("Expected a label without a key to be rejected")
+	}
+	if _, err := parseLabels(nil, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("Expected a missing label file to be rejected")
+	}
+	for _, filter := range []string{"name=web", "label", "label="} {
+		if _, err := parseLabelFilters([]string{filter}); err == nil {
+			t.Errorf("Expected filter %q to be rejected", filter)
+		}
+	}
+}
+
+// TestNetworkLabels creates labeled networks, reloads them and filters them
+// by label key and value
+func TestNetworkLabels(t *testing.T) {
+	e := newTestEngine(t)
+	captureOutput(func() {
+		for name, labels := range map[string]map[string]string{
+			"web":   {"team": "web", "env": "prod"},
+			"batch": {"team": "data", "env": "prod"},
+			"plain": nil,
+		} {
+			if err := e.CreateNetworkWithOptions(name, NetworkOptions{Labels: labels}); err != nil {
+				t.Fatalf("CreateNetworkWithOptions failed: %v", err)
+			}
+		}
+	})
+	e.networks = nil
+	e.loadNetworks()
+
+	names := func(filters ...string) []string {
+		t.Helper()
+		parsed, err := parseLabelFilters(filters)
+		if err != nil {
+			t.Fatalf("parseLabelFilters failed: %v", err)
+		}
+		var matched []string
+		for _, network := range e.filterNetworks(parsed) {
+			matched = append(matched, network.Name)
+		}
+		return matched
+	}
+	if got := names("label=team=web"); !reflect.DeepEqual(got, []string{"web"}) {
+		t.Errorf("label=team=web matched %v", got)
+	}
+	if got := names("label=env"); len(got) != 2 {
+		t.Errorf("Expected label=env to match both labeled networks, got %v", got)
+	}
+	if got := names("label=env=prod", "label=team=data"); !reflect.DeepEqual(got, []string{"batch"}) {
+		t.Errorf("Expected filters to combine, got %v", got)
+	}
+	if got := names(); len(got) != 3 {
+		t.Errorf("Expected no filter to match every network, got %v", got)
+	}
+
+	output := captureOutput(func() { e.listNetworks([]labelFilter{{key: "team", value: "data", hasValue: true}}) })
+	if !strings.HasPrefix(output, "Available Networks:\n- batch (ID: ") || strings.Count(output, "\n") != 2 {
+		t.Errorf("Unexpected network list:\n%s", output)
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Layer compressions.
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// mediaTypeCompression returns the compression a layer media type names, or
+// "" when there is no media type or it is not a layer type known here. Both
+// OCI types (application/vnd.oci.image.layer.v1.tar+gzip) and Docker types
+// (application/vnd.docker.image.rootfs.diff.tar.gzip) are understood.
+func mediaTypeCompression(mediaType string) string {
+	switch {
+	case strings.HasSuffix(mediaType, "+gzip"), strings.HasSuffix(mediaType, ".tar.gzip"):
+		return compressionGzip
+	case strings.HasSuffix(mediaType, "+zstd"):
+		return compressionZstd
+	case strings.HasSuffix(mediaType, ".tar"):
+		return compressionNone
+	}
+	return ""
+}
+
+// sniffCompression returns the compression of a layer from its first bytes.
+func sniffCompression(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return compressionGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return compressionZstd
+	}
+	return compressionNone
+}
+
+// decompressLayer returns the tar stream of a layer, decompressing it as its
+// media type says, or as its magic bytes say when the media type is absent
+// or unknown.
+func decompressLayer(reader io.Reader, mediaType string) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(reader)
+	compression := mediaTypeCompression(mediaType)
+	if compression == "" {
+		// A short layer has fewer bytes to peek at, which is fine
+		header, _ := buffered.Peek(len(zstdMagic))
+		compression = sniffCompression(header)
+	}
+
+	switch compression {
+	case compressionGzip:
+		return gzip.NewReader(buffered)
+	case compressionZstd:
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return io.NopCloser(buffered), nil
+}
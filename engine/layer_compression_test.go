@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestMediaTypeCompression(t *testing.T) {
+	tests := map[string]string{
+		"application/vnd.oci.image.layer.v1.tar+gzip":                  compressionGzip,
+		"application/vnd.oci.image.layer.v1.tar+zstd":                  compressionZstd,
+		"application/vnd.oci.image.layer.v1.tar":                       compressionNone,
+		"application/vnd.docker.image.rootfs.diff.tar.gzip":            compressionGzip,
+		"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip": compressionGzip,
+		"":                         "",
+		"application/octet-stream": "",
+	}
+	for mediaType, want := range tests {
+		if got := mediaTypeCompression(mediaType); got != want {
+			t.Errorf("mediaTypeCompression(%q) = %q, want %q", mediaType, got, want)
+		}
+	}
+}
+
+// compressedTestLayer returns a layer tar holding files, compressed as
+// compression says.
+func compressedTestLayer(t *testing.T, files map[string]string, compression string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(writeTestTar(t, files))
+	if err != nil {
+		t.Fatalf("Failed to read tar file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	switch compression {
+	case compressionGzip:
+		gw := gzip.NewWriter(&buf)
+		gw.Write(data)
+		if err := gw.Close(); err != nil {
+			t.Fatalf("Failed to gzip layer: %v", err)
+		}
+	case compressionZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("Failed to create zstd writer: %v", err)
+		}
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			t.Fatalf("Failed to zstd layer: %v", err)
+		}
+	default:
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+// TestUnpackLayerCompressions extracts gzip, zstd and plain layers, both with
+// their media type and, without one, by sniffing their content.
+func TestUnpackLayerCompressions(t *testing.T) {
+	mediaTypes := map[string]string{
+		compressionGzip: "application/vnd.oci.image.layer.v1.tar+gzip",
+		compressionZstd: "application/vnd.oci.image.layer.v1.tar+zstd",
+		compressionNone: "application/vnd.oci.image.layer.v1.tar",
+	}
+	for compression, mediaType := range mediaTypes {
+		for _, withMediaType := range []bool{true, false} {
+			layer := compressedTestLayer(t, map[string]string{"etc/hello.txt": "hello " + compression}, compression)
+			if sniffed := sniffCompression(layer); sniffed != compression {
+				t.Errorf("sniffCompression of a %s layer = %q", compression, sniffed)
+			}
+
+			given := ""
+			if withMediaType {
+				given = mediaType
+			}
+			dir := t.TempDir()
+			if err := unpackLayer(bytes.NewReader(layer), given, dir); err != nil {
+				t.Fatalf("unpackLayer of a %s layer with media type %q failed: %v", compression, given, err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "etc", "hello.txt"))
+			if err != nil || string(data) != "hello "+compression {
+				t.Errorf("%s layer with media type %q extracted %q (%v)", compression, given, data, err)
+			}
+		}
+	}
+}
+
+// TestUnpackLayerMediaTypeMismatch verifies the media type decides over the
+// content, so a layer that is not what its manifest says fails to extract.
+func TestUnpackLayerMediaTypeMismatch(t *testing.T) {
+	layer := compressedTestLayer(t, map[string]string{"hello.txt": "hello"}, compressionGzip)
+	err := unpackLayer(bytes.NewReader(layer), "application/vnd.oci.image.layer.v1.tar+zstd", t.TempDir())
+	if err == nil {
+		t.Error("Expected a gzip layer labelled zstd to fail to extract")
+	}
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// layerIndexFile in layersDir holds the metadata of every layer, keyed by
+// layer ID. It replaces the <id>.json file each layer used to have.
+const layerIndexFile = "index.json"
+
+// layerIndexLockFile is flocked while the layer index is read or changed, so
+// that engine processes take turns.
+const layerIndexLockFile = "index.lock"
+
+// layerIndexMu serializes the layer index between goroutines; the flock
+// serializes it between processes.
+var layerIndexMu sync.Mutex
+
+// lockLayerIndex blocks until it holds the layer index and returns the
+// function releasing it.
+func (e *Engine) lockLayerIndex() (func(), error) {
+	layerIndexMu.Lock()
+	if err := os.MkdirAll(e.layersDir(), 0755); err != nil {
+		layerIndexMu.Unlock()
+		return nil, fmt.Errorf("failed to create layers directory: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(e.layersDir(), layerIndexLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		layerIndexMu.Unlock()
+		return nil, fmt.Errorf("failed to open layer index lock: %v", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		layerIndexMu.Unlock()
+		return nil, fmt.Errorf("failed to lock layer index: %v", err)
+	}
+	return func() {
+		file.Close()
+		layerIndexMu.Unlock()
+	}, nil
+}
+
+// readLayerIndex reads the layer index. Without one, the per-layer metadata
+// files of older versions are migrated into a new index. The caller holds
+// the index lock.
+func (e *Engine) readLayerIndex() (map[string]ImageLayer, error) {
+	data, err := os.ReadFile(filepath.Join(e.layersDir(), layerIndexFile))
+	if os.IsNotExist(err) {
+		return e.migrateLayerMetadata()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer index: %v", err)
+	}
+	index := make(map[string]ImageLayer)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse layer index: %v", err)
+	}
+	return index, nil
+}
+
+// writeLayerIndex replaces the layer index as indented JSON. The caller
+// holds the index lock.
+func (e *Engine) writeLayerIndex(index map[string]ImageLayer) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode layer index: %v", err)
+	}
+	path := filepath.Join(e.layersDir(), layerIndexFile)
+	// Readers never see a partly written index
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write layer index: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write layer index: %v", err)
+	}
+	return nil
+}
+
+// migrateLayerMetadata moves the <id>.json metadata files of layersDir into
+// a new layer index and returns it. Unreadable files are skipped.
+func (e *Engine) migrateLayerMetadata() (map[string]ImageLayer, error) {
+	index := make(map[string]ImageLayer)
+	var migrated []string
+	err := forEachEntry(e.layersDir(), func(entry os.DirEntry) {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || name == layerIndexFile {
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(e.layersDir(), name))
+		if err != nil {
+			e.Logger.Warn("failed to read layer metadata", "file", name, "error", err)
+			return
+		}
+		var layer ImageLayer
+		if err := json.Unmarshal(data, &layer); err != nil || layer.ID == "" {
+			e.Logger.Warn("failed to parse layer metadata", "file", name, "error", err)
+			return
+		}
+		index[layer.ID] = layer
+		migrated = append(migrated, name)
+	})
+	if err != nil || len(migrated) == 0 {
+		return index, err
+	}
+	if err := e.writeLayerIndex(index); err != nil {
+		return nil, err
+	}
+	for _, name := range migrated {
+		os.Remove(filepath.Join(e.layersDir(), name))
+	}
+	e.Logger.Debug("migrated layer metadata", "layers", len(migrated))
+	return index, nil
+}
+
+// updateLayerIndex applies update to the layer index under its lock and
+// saves the result.
+func (e *Engine) updateLayerIndex(update func(index map[string]ImageLayer) error) error {
+	unlock, err := e.lockLayerIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	index, err := e.readLayerIndex()
+	if err != nil {
+		return err
+	}
+	if err := update(index); err != nil {
+		return err
+	}
+	return e.writeLayerIndex(index)
+}
+
+// AddLayer records the metadata of a layer, replacing any earlier record.
+func (e *Engine) AddLayer(layer ImageLayer) error {
+	if layer.ID == "" || strings.ContainsAny(layer.ID, `/\`) {
+		return fmt.Errorf("invalid layer ID %q", layer.ID)
+	}
+	if err := e.updateLayerIndex(func(index map[string]ImageLayer) error {
+		index[layer.ID] = layer
+		return nil
+	}); err != nil {
+		return err
+	}
+	e.Logger.Debug("layer metadata saved", "layer", layer.ID)
+	return nil
+}
+
+// GetLayer returns the metadata of a layer.
+func (e *Engine) GetLayer(id string) (ImageLayer, error) {
+	unlock, err := e.lockLayerIndex()
+	if err != nil {
+		return ImageLayer{}, err
+	}
+	defer unlock()
+	index, err := e.readLayerIndex()
+	if err != nil {
+		return ImageLayer{}, err
+	}
+	layer, ok := index[id]
+	if !ok {
+		return ImageLayer{}, fmt.Errorf("layer %s not found", id)
+	}
+	return layer, nil
+}
+
+// ListLayers returns the metadata of every layer, sorted by ID.
+func (e *Engine) ListLayers() ([]ImageLayer, error) {
+	unlock, err := e.lockLayerIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	index, err := e.readLayerIndex()
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]ImageLayer, 0, len(index))
+	for _, layer := range index {
+		layers = append(layers, layer)
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].ID < layers[j].ID })
+	return layers, nil
+}
+
+// RemoveLayer deletes the metadata of a layer. The layer directory is left
+// to the caller.
+func (e *Engine) RemoveLayer(id string) error {
+	return e.updateLayerIndex(func(index map[string]ImageLayer) error {
+		if _, ok := index[id]; !ok {
+			return fmt.Errorf("layer %s not found", id)
+		}
+		delete(index, id)
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLayerStoreConcurrentAdds adds layers from many goroutines and expects
+// every one of them to be listed.
+func TestLayerStoreConcurrentAdds(t *testing.T) {
+	e := newTestEngine(t)
+	const count = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- e.AddLayer(ImageLayer{ID: fmt.Sprintf("layer-%02d", i), Size: int64(i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AddLayer failed: %v", err)
+		}
+	}
+
+	layers, err := e.ListLayers()
+	if err != nil {
+		t.Fatalf("ListLayers failed: %v", err)
+	}
+	if len(layers) != count {
+		t.Fatalf("Expected %d layers, got %d", count, len(layers))
+	}
+	for i, layer := range layers {
+		if want := fmt.Sprintf("layer-%02d", i); layer.ID != want || layer.Size != int64(i) {
+			t.Errorf("Expected layer %s of size %d at %d, got %+v", want, i, i, layer)
+		}
+	}
+}
+
+// TestLayerStoreGetAndRemove covers looking up, replacing and removing a
+// layer.
+func TestLayerStoreGetAndRemove(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.AddLayer(ImageLayer{ID: "app", Size: 1}); err != nil {
+		t.Fatalf("AddLayer failed: %v", err)
+	}
+	if err := e.AddLayer(ImageLayer{ID: "app", Size: 2}); err != nil {
+		t.Fatalf("AddLayer failed: %v", err)
+	}
+	if layer, err := e.GetLayer("app"); err != nil || layer.Size != 2 {
+		t.Errorf("Expected the replaced layer, got %+v, %v", layer, err)
+	}
+
+	if err := e.RemoveLayer("app"); err != nil {
+		t.Fatalf("RemoveLayer failed: %v", err)
+	}
+	if _, err := e.GetLayer("app"); err == nil {
+		t.Error("Expected the removed layer to be gone")
+	}
+	if err := e.RemoveLayer("app"); err == nil {
+		t.Error("Expected an error removing a missing layer")
+	}
+	if err := e.AddLayer(ImageLayer{ID: "../escape"}); err == nil {
+		t.Error("Expected an error for a layer ID with a path separator")
+	}
+}
+
+// TestLayerStoreMigratesMetadataFiles starts from the per-layer metadata
+// files of older versions and expects them to move into the index.
+func TestLayerStoreMigratesMetadataFiles(t *testing.T) {
+	e := newTestEngine(t)
+	if err := os.MkdirAll(e.layersDir(), 0755); err != nil {
+		t.Fatalf("Failed to create layers directory: %v", err)
+	}
+	created := time.Unix(1700000000, 0).UTC()
+	for _, id := range []string{"old-base", "old-app"} {
+		data := fmt.Sprintf(`{"ID": %q, "Created": %q, "Size": 5, "BaseLayerPath": %q}`, id, created.Format(time.RFC3339), filepath.Join(e.layersDir(), id))
+		if err := os.WriteFile(filepath.Join(e.layersDir(), id+".json"), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+	}
+
+	layers, err := e.ListLayers()
+	if err != nil {
+		t.Fatalf("ListLayers failed: %v", err)
+	}
+	if len(layers) != 2 || layers[0].ID != "old-app" || layers[1].ID != "old-base" || !layers[1].Created.Equal(created) {
+		t.Errorf("Expected the migrated layers, got %+v", layers)
+	}
+	for _, id := range []string{"old-base", "old-app"} {
+		if _, err := os.Stat(filepath.Join(e.layersDir(), id+".json")); !os.IsNotExist(err) {
+			t.Errorf("Expected %s.json to be removed after migration, got %v", id, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(e.layersDir(), layerIndexFile)); err != nil {
+		t.Errorf("Expected the layer index to be written: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// containerLockFile is flocked by the operations that change the state of a
+// container, such as start, exec, stop and rm, so that they take turns.
+const containerLockFile = "lock"
+
+// lockContainer blocks until it holds the lock of a container and returns the
+// function releasing it. The lock is shared with other engine processes and
+// released by the kernel if the holder dies.
+func (e *Engine) lockContainer(containerID string) (func(), error) {
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	file, err := os.OpenFile(filepath.Join(containerDir, containerLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("container %s does not exist", containerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock of container %s: %v", containerID, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock container %s: %v", containerID, err)
+	}
+	// A container removed while waiting leaves the lock on a deleted file
+	if _, err := os.Stat(containerDir); os.IsNotExist(err) {
+		file.Close()
+		return nil, fmt.Errorf("container %s does not exist", containerID)
+	}
+	return func() { file.Close() }, nil
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestLockContainerSerializes checks that holders of a container's lock never
+// overlap while other containers stay unlocked
+func TestLockContainerSerializes(t *testing.T) {
+	e := newTestEngine(t)
+	createTestContainer(t, e, &ContainerConfig{ID: "locked"})
+	createTestContainer(t, e, &ContainerConfig{ID: "other"})
+
+	var holders, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := e.lockContainer("locked")
+			if err != nil {
+				t.Errorf("lockContainer failed: %v", err)
+				return
+			}
+			if atomic.AddInt32(&holders, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			unlock()
+		}()
+	}
+
+	unlock, err := e.lockContainer("other")
+	if err != nil {
+		t.Fatalf("Expected another container to be lockable: %v", err)
+	}
+	unlock()
+	wg.Wait()
+	if overlaps != 0 {
+		t.Errorf("Expected lock holders to take turns, %d overlapped", overlaps)
+	}
+
+	if _, err := e.lockContainer("missing"); err == nil {
+		t.Error("Expected locking an unknown container to fail")
+	}
+}
+
+// TestConcurrentExecAndStop races execs against a stop of the same container;
+// run it with -race. Every exec must either run or fail cleanly and the stop
+// must leave no PID file behind.
+func TestConcurrentExecAndStop(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("entering namespaces requires root")
+	}
+	e := newTestEngine(t)
+	containerID := "test-exec-stop"
+	createTestContainer(t, e, &ContainerConfig{ID: containerID, Command: "sleep", StartedAt: time.Now()})
+
+	done := make(chan error, 1)
+	go func() { done <- e.runContainerProcess(containerID, exec.Command("sleep", "30"), nil) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.getContainerStatus(containerID) != "Running" {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the container to start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd, err := e.startExec(containerID, "true", nil, nil, io.Discard, io.Discard)
+			if err == nil {
+				cmd.Wait()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := e.stopContainer(containerID, 5*time.Second); err != nil {
+			t.Errorf("stopContainer failed: %v", err)
+		}
+	}()
+	wg.Wait()
+	<-done
+
+	if status := e.getContainerStatus(containerID); status != "Stopped" {
+		t.Errorf("Expected status Stopped, got %s", status)
+	}
+	if _, err := os.Stat(filepath.Join(e.Root, "containers", containerID, "pid")); !os.IsNotExist(err) {
+		t.Errorf("Expected the PID file to be removed, got %v", err)
+	}
+	if _, err := e.startExec(containerID, "true", nil, nil, io.Discard, io.Discard); err == nil {
+		t.Error("Expected exec in a stopped container to fail")
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logLevelEnv names the environment variable that sets the default log level.
+const logLevelEnv = "BASIC_DOCKER_LOG"
+
+// levelFromEnv returns the log level configured by BASIC_DOCKER_LOG, falling
+// back to info.
+func levelFromEnv() *slog.LevelVar {
+	level := new(slog.LevelVar)
+	if value := os.Getenv(logLevelEnv); value != "" {
+		parsed, err := parseLogLevel(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", logLevelEnv, err)
+			return level
+		}
+		level.Set(parsed)
+	}
+	return level
+}
+
+// newLogger returns a text logger writing to w at the given level.
+func newLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// parseLogLevel converts a level name (debug, info, warn, error) to a slog level.
+func parseLogLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", value)
+}
+
+// suppressProgress limits diagnostics to errors, for commands run with
+// --quiet. An explicitly quieter level is kept.
+func (e *Engine) suppressProgress() {
+	if e.logLevel != nil && e.logLevel.Level() < slog.LevelError {
+		e.logLevel.Set(slog.LevelError)
+	}
+}
+
+// globalFlags are the settings given by the flags preceding the command.
+type globalFlags struct {
+	// root is the --root directory, empty when not given.
+	root string
+	// logLevel is the --log-level level, nil when not given.
+	logLevel *slog.Level
+	// registryMirrors are the --registry-mirror mirrors in the order given.
+	registryMirrors []string
+}
+
+// extractGlobalFlags removes the global flags preceding the command from args
+// and returns them. args is os.Args, including the program name.
+func extractGlobalFlags(args []string) ([]string, globalFlags, error) {
+	var flags globalFlags
+	rest := []string{args[0]}
+	i := 1
+	for ; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--log-level" && name != "--root" && name != "--registry-mirror" {
+			return append(rest, args[i:]...), flags, nil
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, flags, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--log-level":
+			level, err := parseLogLevel(value)
+			if err != nil {
+				return nil, flags, err
+			}
+			flags.logLevel = &level
+		case "--root":
+			if value == "" {
+				return nil, flags, fmt.Errorf("root directory must not be empty")
+			}
+			flags.root = value
+		case "--registry-mirror":
+			if _, err := newMirrorRegistry(value); err != nil {
+				return nil, flags, err
+			}
+			flags.registryMirrors = append(flags.registryMirrors, value)
+		}
+	}
+	return rest, flags, nil
+}
+
+// newEngine creates the engine the flags configure, keeping its state under
+// BASIC_DOCKER_ROOT unless --root is given.
+func (f globalFlags) newEngine() (*Engine, error) {
+	root := f.root
+	if root == "" {
+		root = defaultBaseDir()
+	}
+	engine, err := NewEngine(root)
+	if err != nil {
+		return nil, err
+	}
+	if f.logLevel != nil {
+		engine.logLevel.Set(*f.logLevel)
+	}
+	engine.RegistryMirrors = f.registryMirrors
+	return engine, nil
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// useTestLogger redirects the logger of e to a buffer at the given level.
+func useTestLogger(t *testing.T, e *Engine, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	e.logLevel.Set(level)
+	e.Logger = newLogger(&buf, e.logLevel)
+	return &buf
+}
+
+// TestDebugSuppressedByDefault verifies that debug lines are neither printed
+// to stdout nor logged at the default level.
+func TestDebugSuppressedByDefault(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	buf := useTestLogger(t, e, slog.LevelInfo)
+	rollback := func() { e.rollbackContainer("test-log-container", "") }
+
+	output := captureOutput(rollback)
+	if strings.Contains(output, "DEBUG") {
+		t.Errorf("Expected no debug lines on stdout, got: %s", output)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log output at the default level, got: %s", buf.String())
+	}
+
+	e.logLevel.Set(slog.LevelDebug)
+	captureOutput(rollback)
+	if !strings.Contains(buf.String(), "level=DEBUG") {
+		t.Errorf("Expected debug lines at debug level, got: %s", buf.String())
+	}
+}
+
+// TestExtractGlobalFlags verifies that --log-level is applied and removed
+// before command dispatch.
+func TestExtractGlobalFlags(t *testing.T) {
+	t.Setenv(rootEnv, t.TempDir())
+	args, flags, err := extractGlobalFlags([]string{"basic-docker", "--log-level", "debug", "ps"})
+	if err != nil {
+		t.Fatalf("extractGlobalFlags failed: %v", err)
+	}
+	if strings.Join(args, " ") != "basic-docker ps" {
+		t.Errorf("Unexpected remaining args: %v", args)
+	}
+	e, err := flags.newEngine()
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if e.logLevel.Level() != slog.LevelDebug {
+		t.Errorf("Expected level debug, got %v", e.logLevel.Level())
+	}
+
+	args, flags, err = extractGlobalFlags([]string{"basic-docker", "--log-level=error", "run", "--health-cmd", "true"})
+	if err != nil {
+		t.Fatalf("extractGlobalFlags failed: %v", err)
+	}
+	if len(args) != 4 || args[1] != "run" || flags.logLevel == nil || *flags.logLevel != slog.LevelError {
+		t.Errorf("Unexpected result: %v at level %v", args, flags.logLevel)
+	}
+
+	if _, _, err := extractGlobalFlags([]string{"basic-docker", "--log-level", "loud", "ps"}); err == nil {
+		t.Error("Expected an error for an unknown log level")
+	}
+}
+
+// TestQuietPull expects pull --quiet to print only the image reference and
+// to hold back progress, even at debug level.
+func TestQuietPull(t *testing.T) {
+	e := newTestEngine(t)
+	buf := useTestLogger(t, e, slog.LevelDebug)
+	dir := writeFileRegistry(t, "quiet", []map[string]string{{"hello": "world"}})
+	t.Setenv(registryMirrorsEnv, fileRegistryScheme+dir)
+
+	stdout, stderr := captureStdoutStderr(func() {
+		e.pullCommand([]string{"--quiet", "quiet"})
+	})
+	if stdout != "quiet:latest\n" {
+		t.Errorf("Expected only the image reference on stdout, got %q", stdout)
+	}
+	if stderr != "" || buf.Len() != 0 {
+		t.Errorf("Expected no progress output, got %q and log %q", stderr, buf.String())
+	}
+}
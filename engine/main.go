@@ -0,0 +1,2702 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+	"runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	v1 "k8s.io/api/core/v1"
+)
+
+// rootEnv names the environment variable that sets the state directory.
+const rootEnv = "BASIC_DOCKER_ROOT"
+
+// defaultBaseDir returns the state directory configured by BASIC_DOCKER_ROOT,
+// falling back to a directory under the system temp dir. The --root flag
+// overrides it.
+func defaultBaseDir() string {
+	if dir := os.Getenv(rootEnv); dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			return abs
+		}
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "basic-docker")
+}
+
+// Define the ImageLayer type
+type ImageLayer struct {
+	ID            string
+	Created       time.Time
+	Size          int64
+	BaseLayerPath string
+	AppLayerPath  string
+}
+
+// ResourceCapsule represents a self-contained, versioned resource unit (legacy)
+type ResourceCapsule struct {
+	Name    string
+	Version string
+	Path    string
+}
+
+// CapsuleManager handles the lifecycle of Resource Capsules.
+type CapsuleManager struct {
+	Capsules map[string]ResourceCapsule
+	engine   *Engine
+}
+
+const capsulesFile = "capsules.json"
+
+// NewCapsuleManager initializes a new CapsuleManager with the capsules
+// persisted by previous invocations.
+func (e *Engine) NewCapsuleManager() *CapsuleManager {
+	cm := &CapsuleManager{
+		engine:   e,
+		Capsules: make(map[string]ResourceCapsule),
+	}
+	cm.load()
+	return cm
+}
+
+// load reads the capsules from the JSON file
+func (cm *CapsuleManager) load() {
+	filePath := filepath.Join(cm.engine.Root, capsulesFile)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return // No capsules file exists yet
+		}
+		fmt.Fprintf(os.Stderr, "Error loading capsules: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&cm.Capsules); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding capsules: %v\n", err)
+	}
+}
+
+// save writes the capsules to the JSON file, indented and with sorted keys
+func (cm *CapsuleManager) save() {
+	data, err := json.MarshalIndent(cm.Capsules, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding capsules: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(cm.engine.Root, capsulesFile), data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving capsules: %v\n", err)
+	}
+}
+
+// AddCapsule adds a new Resource Capsule to the manager.
+func (cm *CapsuleManager) AddCapsule(name, version, path string) {
+	key := name + ":" + version
+	cm.Capsules[key] = ResourceCapsule{Name: name, Version: version, Path: path}
+	cm.save()
+}
+
+// GetCapsule retrieves a Resource Capsule by name and version.
+func (cm *CapsuleManager) GetCapsule(name, version string) (ResourceCapsule, bool) {
+	key := name + ":" + version
+	capsule, exists := cm.Capsules[key]
+	return capsule, exists
+}
+
+// RemoveCapsule deletes a Resource Capsule from the manager.
+func (cm *CapsuleManager) RemoveCapsule(name, version string) error {
+	key := name + ":" + version
+	if _, exists := cm.Capsules[key]; !exists {
+		return fmt.Errorf("capsule %s:%s not found", name, version)
+	}
+	delete(cm.Capsules, key)
+	cm.save()
+	return nil
+}
+
+// ListCapsules writes the capsules sorted by name and version to w.
+func (cm *CapsuleManager) ListCapsules(w io.Writer) {
+	capsules := make([]ResourceCapsule, 0, len(cm.Capsules))
+	for _, capsule := range cm.Capsules {
+		capsules = append(capsules, capsule)
+	}
+	sort.Slice(capsules, func(i, j int) bool {
+		if capsules[i].Name != capsules[j].Name {
+			return capsules[i].Name < capsules[j].Name
+		}
+		return capsules[i].Version < capsules[j].Version
+	})
+
+	fmt.Fprintln(w, "NAME\tVERSION\tPATH")
+	for _, capsule := range capsules {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", capsule.Name, capsule.Version, capsule.Path)
+	}
+}
+
+// GetCapsuleMatching returns the highest version of a capsule satisfying a
+// semantic version constraint such as "^1.1", "~1.0", ">=1.0 <2.0" or
+// "latest". Versions that are not valid semver are ignored.
+func (cm *CapsuleManager) GetCapsuleMatching(name, constraint string) (ResourceCapsule, error) {
+	c, err := parseSemverConstraint(constraint)
+	if err != nil {
+		return ResourceCapsule{}, err
+	}
+
+	var best ResourceCapsule
+	var bestVersion semVersion
+	found := false
+	for _, capsule := range cm.Capsules {
+		if capsule.Name != name {
+			continue
+		}
+		v, err := parseSemver(capsule.Version)
+		if err != nil || !c.matches(v) {
+			continue
+		}
+		if !found || compareSemver(v, bestVersion) > 0 {
+			best, bestVersion, found = capsule, v, true
+		}
+	}
+	if !found {
+		return ResourceCapsule{}, fmt.Errorf("no version of capsule %s matches %q", name, constraint)
+	}
+	return best, nil
+}
+
+// AttachCapsule attaches a capsule to a container.
+func (cm *CapsuleManager) AttachCapsule(containerID, name, version string) error {
+	key := name + ":" + version
+
+	capsule, exists := cm.Capsules[key]
+
+	if !exists {
+		return fmt.Errorf("capsule %s:%s not found", name, version)
+	}
+	// Logic to attach the capsule to the container's filesystem.
+	fmt.Fprintf(os.Stderr, "Attaching capsule %s:%s to container %s\n", name, version, containerID)
+
+	containerDir := filepath.Join(cm.engine.Root, "containers", containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		return fmt.Errorf("failed to create container directory: %v", err)
+	}
+
+	attached, err := cm.engine.attachCapsuleToRootfs(containerID, capsule)
+	if err != nil {
+		return err
+	}
+	return cm.engine.recordAttachedCapsule(containerID, attached)
+}
+
+// AddResourceCapsule selectively adds a resource capsule to the environment and verifies it by interacting with a Docker container or Kubernetes cluster.
+func (e *Engine) AddResourceCapsule(env string, capsuleName string, capsuleVersion string, capsulePath string) error {
+	switch env {
+	case "docker":
+		return e.addDockerResourceCapsule(capsuleName, capsuleVersion, capsulePath)
+	case "kubernetes", "k8s":
+		return addKubernetesResourceCapsule(capsuleName, capsuleVersion, capsulePath)
+	default:
+		return fmt.Errorf("unsupported environment: %s", env)
+	}
+}
+
+// addDockerResourceCapsule registers a capsule with the engine and verifies
+// it by attaching it to a throwaway basic-docker container.
+func (e *Engine) addDockerResourceCapsule(capsuleName, capsuleVersion, capsulePath string) error {
+	// Ensure the capsule path exists and is readable
+	if err := validateCapsulePath(capsulePath); err != nil {
+		return err
+	}
+
+	cm := e.NewCapsuleManager()
+	cm.AddCapsule(capsuleName, capsuleVersion, capsulePath)
+	fmt.Fprintf(os.Stderr, "[Docker] Capsule %s:%s registered from %s\n", capsuleName, capsuleVersion, capsulePath)
+
+	if err := e.verifyCapsule(cm, capsuleName, capsuleVersion); err != nil {
+		return fmt.Errorf("failed to verify capsule in basic-docker container: %v", err)
+	}
+
+	fmt.Printf("Successfully added and verified resource capsule %s:%s in Docker environment\n", capsuleName, capsuleVersion)
+	return nil
+}
+
+// verifyCapsule attaches a capsule to a temporary container and checks that
+// its contents are visible at the capsule's path inside the container rootfs.
+func (e *Engine) verifyCapsule(cm *CapsuleManager, capsuleName, capsuleVersion string) error {
+	containerID := fmt.Sprintf("capsule-verify-%d", time.Now().UnixNano())
+	if err := os.MkdirAll(filepath.Join(e.Root, "containers", containerID, "rootfs"), 0755); err != nil {
+		return fmt.Errorf("failed to create verification container: %v", err)
+	}
+	if err := e.saveContainerConfig(&ContainerConfig{ID: containerID}); err != nil {
+		return fmt.Errorf("failed to create verification container: %v", err)
+	}
+	defer func() {
+		if err := e.removeContainer(containerID); err != nil {
+			e.Logger.Warn("failed to remove verification container", "container", containerID, "error", err)
+		}
+	}()
+
+	if err := cm.AttachCapsule(containerID, capsuleName, capsuleVersion); err != nil {
+		return err
+	}
+
+	capsule, _ := cm.GetCapsule(capsuleName, capsuleVersion)
+	inContainer := filepath.Join(e.Root, "containers", containerID, "rootfs", capsuleContainerPath(capsuleName, capsuleVersion))
+	if err := compareCapsuleContents(capsule.Path, inContainer); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "[Docker] Capsule %s:%s visible in container %s at %s\n",
+		capsuleName, capsuleVersion, containerID, capsuleContainerPath(capsuleName, capsuleVersion))
+	return nil
+}
+
+// compareCapsuleContents checks that the attached copy or mount of a capsule
+// matches its source: same bytes for a file, same entries for a directory.
+func compareCapsuleContents(source, attached string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		want, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		got, err := os.ReadFile(attached)
+		if err != nil {
+			return fmt.Errorf("capsule not visible in container: %v", err)
+		}
+		if !bytes.Equal(want, got) {
+			return fmt.Errorf("capsule contents differ inside the container")
+		}
+		return nil
+	}
+
+	want, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	got, err := os.ReadDir(attached)
+	if err != nil {
+		return fmt.Errorf("capsule not visible in container: %v", err)
+	}
+	if len(want) != len(got) {
+		return fmt.Errorf("capsule has %d entries inside the container, expected %d", len(got), len(want))
+	}
+	for i := range want {
+		if want[i].Name() != got[i].Name() {
+			return fmt.Errorf("capsule entry %s missing inside the container", want[i].Name())
+		}
+	}
+	return nil
+}
+
+// addKubernetesResourceCapsule handles Kubernetes-specific resource capsule logic
+func addKubernetesResourceCapsule(capsuleName, capsuleVersion, capsulePath string) error {
+	// Create a Kubernetes capsule manager
+	kcm, err := NewKubernetesCapsuleManager("default")
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes capsule manager: %v", err)
+	}
+
+	obj, err := kcm.CapsuleFromFile(capsuleName, capsuleVersion, capsulePath)
+	if err != nil {
+		return err
+	}
+
+	// Text data becomes a ConfigMap, binary data a Secret
+	switch capsule := obj.(type) {
+	case *v1.ConfigMap:
+		err = kcm.ApplyConfigMapCapsule(capsuleName, capsuleVersion, capsule.Data)
+		if err != nil {
+			return fmt.Errorf("failed to create ConfigMap capsule: %v", err)
+		}
+
+		// Verify the capsule was created
+		configMap, err := kcm.GetConfigMapCapsule(capsuleName, capsuleVersion)
+		if err != nil {
+			return fmt.Errorf("failed to verify ConfigMap capsule: %v", err)
+		}
+		fmt.Printf("[Kubernetes] ConfigMap capsule verified: %s (keys: %v)\n", configMap.Name, getKeys(configMap.Data))
+	case *v1.Secret:
+		err = kcm.ApplySecretCapsule(capsuleName, capsuleVersion, capsule.Data)
+		if err != nil {
+			return fmt.Errorf("failed to create Secret capsule: %v", err)
+		}
+
+		// Verify the capsule was created
+		secret, err := kcm.GetSecretCapsule(capsuleName, capsuleVersion)
+		if err != nil {
+			return fmt.Errorf("failed to verify Secret capsule: %v", err)
+		}
+		fmt.Printf("[Kubernetes] Secret capsule verified: %s (keys: %v)\n", secret.Name, getKeysBytes(secret.Data))
+	}
+
+	fmt.Printf("Successfully added and verified resource capsule %s:%s in Kubernetes environment\n", capsuleName, capsuleVersion)
+	return nil
+}
+
+// isTextFile determines if the data is likely text (not binary)
+func isTextFile(data []byte) bool {
+	// Simple heuristic: if first 512 bytes contain no null bytes and are mostly printable, consider it text
+	if len(data) == 0 {
+		return true
+	}
+	
+	sample := data
+	if len(data) > 512 {
+		sample = data[:512]
+	}
+	
+	for _, b := range sample {
+		if b == 0 {
+			return false // null byte suggests binary
+		}
+	}
+	
+	return true
+}
+
+// extractDryRun removes --dry-run from the arguments of a create command
+func extractDryRun(args []string) ([]string, bool) {
+	var rest []string
+	dryRun := false
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, dryRun
+}
+
+// getKeys extracts keys from a string map
+func getKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// getKeysBytes extracts keys from a byte map
+func getKeysBytes(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// To initialize the directories
+func (e *Engine) initDirectories() error {
+	dirs := []string{
+		filepath.Join(e.Root, "containers"),
+		e.imagesDir(),
+		e.layersDir(),
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func ensureBusyboxExists() {
+	if _, err := exec.LookPath("busybox"); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: busybox is not found on the host system.")
+		fmt.Fprintln(os.Stderr, "Please install busybox to enable isolation features.")
+		os.Exit(1)
+	}
+}
+
+// detectEnvironment finds out whether the engine runs in a container and
+// which namespaces and cgroup controllers it can use.
+func (e *Engine) detectEnvironment() {
+	// Detect if we're running in a container
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		e.InContainer = true
+	} else if os.Getenv("CODESPACES") == "true" {
+		e.InContainer = true
+	} else {
+		// Check if /proc/self/cgroup contains docker or containerd
+		data, err := os.ReadFile("/proc/self/cgroup")
+		if err == nil && (strings.Contains(string(data), "docker") ||
+			strings.Contains(string(data), "containerd")) {
+			e.InContainer = true
+		}
+	}
+
+	// Test namespace privileges
+	cmd := exec.Command("unshare", "--user", "echo", "test")
+	e.Namespaces = cmd.Run() == nil
+
+	// Test cgroup access
+	e.Cgroups = detectCgroupCapabilities(e.CgroupRoot)
+
+	e.Logger.Debug("environment detected", "inContainer", e.InContainer,
+		"hasNamespacePrivileges", e.Namespaces, "cgroupControllers", e.Cgroups.controllers())
+}
+
+// Main runs the basic-docker command line in os.Args.
+func Main() {
+	args, flags, err := extractGlobalFlags(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	// container-init runs on the way into a container and needs no state
+	if os.Args[1] == containerInitCommand {
+		if err := containerInit(os.Args[2:], newLogger(os.Stderr, levelFromEnv())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	engine, err := flags.newEngine()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		engine.run()
+	case "create":
+		engine.createCommand(os.Args[2:])
+	case "start":
+		engine.startCommand(os.Args[2:])
+	case "ps":
+		engine.psCommand(os.Args[2:])
+	case "images":
+		engine.imagesCommand(os.Args[2:])
+	case "image-inspect":
+		engine.imageInspectCommand(os.Args[2:])
+	case "info":
+		engine.printSystemInfo()
+	case "stop":
+		engine.stopCommand(os.Args[2:])
+	case "restart":
+		engine.restartCommand(os.Args[2:])
+	case "wait":
+		engine.waitCommand(os.Args[2:])
+	case "gc":
+		engine.gcCommand(os.Args[2:])
+	case "rename":
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker rename <container> <new-name>")
+			os.Exit(1)
+		}
+		if err := engine.RenameContainer(os.Args[2], os.Args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Container %s renamed to %s\n", os.Args[2], os.Args[3])
+	case "logs":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Error: Container ID required for logs")
+			os.Exit(1)
+		}
+		engine.logsCommand(engine.resolveContainerID(os.Args[2]))
+	case "system":
+		engine.systemCommand(os.Args[2:])
+	case "volume":
+		engine.volumeCommand(os.Args[2:])
+	case "cp":
+		engine.copyCommand(os.Args[2:])
+	case "stats":
+		engine.statsCommand(os.Args[2:])
+	case "diff":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Error: Container ID required for diff")
+			os.Exit(1)
+		}
+		engine.diffCommand(engine.resolveContainerID(os.Args[2]))
+	case "commit":
+		engine.commitCommand(os.Args[2:])
+	case "history":
+		engine.historyCommand(os.Args[2:])
+	case "daemon":
+		if err := engine.runDaemon(engine.daemonSocketPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "events":
+		engine.streamEventsCommand(os.Args[2:])
+	case "rm":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Error: Container ID required for rm")
+			os.Exit(1)
+		}
+		for _, containerID := range os.Args[2:] {
+			if err := engine.removeContainer(engine.resolveContainerID(containerID)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(containerID)
+		}
+	case "inspect":
+		engine.inspectCommand(os.Args[2:])
+	case "exec":
+		engine.execCommand()
+	case "top":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker top <container-id>")
+			os.Exit(1)
+		}
+		engine.topContainer(engine.resolveContainerID(os.Args[2]))
+	case "attach":
+		engine.attachCommand(os.Args[2:])
+	case "update":
+		engine.updateCommand(os.Args[2:])
+	case "pause", "unpause":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: basic-docker %s <container-id>\n", os.Args[1])
+			os.Exit(1)
+		}
+		engine.handlePauseCommand(os.Args[1], engine.resolveContainerID(os.Args[2]))
+	case "network-create":
+		engine.networkCreateCommand(os.Args[2:])
+	case "network-list":
+		engine.networkListCommand(os.Args[2:])
+	case "network-delete":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker network-delete <network-id>")
+			return
+		}
+		engine.DeleteNetwork(os.Args[2])
+	case "network-inspect":
+		engine.networkInspectCommand(os.Args[2:])
+	case "network-rename":
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker network-rename <network> <new-name>")
+			os.Exit(1)
+		}
+		if err := engine.RenameNetwork(os.Args[2], os.Args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Network %s renamed to %s\n", os.Args[2], os.Args[3])
+	case "network-attach":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker network-attach <network-id> <container-id>")
+			return
+		}
+		err := engine.AttachContainerToNetwork(os.Args[2], engine.resolveContainerID(os.Args[3]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+	case "network-detach":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker network-detach <network-id> <container-id>")
+			return
+		}
+		err := engine.DetachContainerFromNetwork(os.Args[2], engine.resolveContainerID(os.Args[3]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+	case "network-ping":
+		if len(os.Args) < 5 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker network-ping <network-id> <source-container-id> <target-container-id>")
+			return
+		}
+		err := engine.Ping(os.Args[2], engine.resolveContainerID(os.Args[3]), engine.resolveContainerID(os.Args[4]))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+	case "pull":
+		engine.pullCommand(os.Args[2:])
+	case "load":
+		tarFilePath, imageName, err := parseLoadArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker load <tar-file-path> [--name repo:tag]")
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "Loading image from '%s'...\n", tarFilePath)
+		image, err := engine.LoadImageFromTar(tarFilePath, imageName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to load image from '%s': %v\n", tarFilePath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Image '%s' loaded successfully.\n", image.Name)
+	case "import":
+		engine.importCommand(os.Args[2:])
+	case "image":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Error: Subcommand required for image")
+			os.Exit(1)
+		}
+		switch os.Args[2] {
+		case "rm":
+			if len(os.Args) < 4 {
+				fmt.Fprintln(os.Stderr, "Error: Image name required for rm")
+				os.Exit(1)
+			}
+			imageName := normalizeImageRef(os.Args[3])
+			imagePath := engine.imageStorePath(imageName)
+
+			if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error: Image '%s' does not exist.\n", imageName)
+				os.Exit(1)
+			}
+
+			if err := os.RemoveAll(imagePath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to delete image '%s': %v\n", imageName, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Image '%s' deleted successfully.\n", imageName)
+		case "tag":
+			if len(os.Args) < 5 {
+				fmt.Fprintln(os.Stderr, "Usage: basic-docker image tag <source-image> <target-image>")
+				os.Exit(1)
+			}
+			if err := engine.TagImage(os.Args[3], os.Args[4]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to tag image: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Image '%s' tagged as '%s'.\n", normalizeImageRef(os.Args[3]), normalizeImageRef(os.Args[4]))
+		case "verify":
+			engine.verifyImageCommand(os.Args[3:])
+		case "squash":
+			engine.squashImageCommand(os.Args[3:])
+		default:
+			fmt.Fprintln(os.Stderr, "Error: Unknown subcommand for image")
+			os.Exit(1)
+		}
+	case "capsule":
+		engine.handleCapsuleCommand(os.Args[2:])
+	case "k8s-capsule":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-capsule <command>")
+			fmt.Fprintln(os.Stderr, "Commands: create, list, get, delete")
+			os.Exit(1)
+		}
+		engine.handleKubernetesCapsuleCommand()
+	case "k8s-crd":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd <command>")
+			fmt.Fprintln(os.Stderr, "Commands: create, list, get, delete, rollback")
+			os.Exit(1)
+		}
+		handleKubernetesCRDCommand()
+	case "capsule-benchmark":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule-benchmark <environment>")
+			fmt.Fprintln(os.Stderr, "Environments: docker, kubernetes")
+			os.Exit(1)
+		}
+		engine.handleCapsuleBenchmark(os.Args[2])
+	case "monitor":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor <command>")
+			fmt.Fprintln(os.Stderr, "Commands: process, container, host, all, gap")
+			os.Exit(1)
+		}
+		engine.handleMonitoringCommand()
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  basic-docker [--log-level debug|info|warn|error] [--root dir] [--registry-mirror url]... <command> ...")
+	fmt.Fprintln(os.Stderr, "  (the log level can also be set with the BASIC_DOCKER_LOG environment variable)")
+	fmt.Fprintln(os.Stderr, "  basic-docker run [-d] [-i] [-q] [-p [ip:]host:container] [-P] [--network name] [--name name] [--read-only] [--tmpfs path] [-v name|/host/path:/path] [--cap-drop cap] [--cap-add cap] [--security-opt seccomp=profile.json] [--userns] [--health-cmd cmd] [--health-interval 30s] [--platform os/arch[/variant]] [--isolation auto|none|namespaces] [--pids-limit n] [--oom-kill-disable] [--ulimit name=soft[:hard]] [--entrypoint cmd] [--shell] [--add-host name:ip] [--dns ip] [--dns-search domain] <image> <command> [args...] - Run a command in a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker create [run options] <image> <command> [args...] - Create a container without starting it")
+	fmt.Fprintln(os.Stderr, "  basic-docker start [-a] <container-id>...  Start created or stopped containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker ps [--format tmpl]       - List running containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker images [-q] [--format tmpl] - List available images (-q prints names only)")
+	fmt.Fprintln(os.Stderr, "  basic-docker image-inspect [--format tmpl] <image> - Show the metadata of an image")
+	fmt.Fprintln(os.Stderr, "  basic-docker info                     - Show system information")
+	fmt.Fprintln(os.Stderr, "  basic-docker system df [--format json]     Show disk usage of images, containers, layers and cache")
+	fmt.Fprintln(os.Stderr, "  basic-docker system prune [-f] [--containers] [--images] [--layers] Remove stopped containers, dangling images and unreferenced layers")
+	fmt.Fprintln(os.Stderr, "  basic-docker volume create|ls|rm [name...]  Manage named volumes mounted with run -v name:/path")
+	fmt.Fprintln(os.Stderr, "  basic-docker events [--since 10m] [--follow=false] Stream container lifecycle events as JSON lines")
+	fmt.Fprintln(os.Stderr, "  basic-docker stop [-t 10] <container-id>... Stop running containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker restart [-t 10] <container-id>... Stop containers and start them again in the background")
+	fmt.Fprintln(os.Stderr, "  basic-docker logs <container-id>           Show the output of a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker daemon                        Run the engine daemon on a Unix socket")
+	fmt.Fprintln(os.Stderr, "  basic-docker gc [--max-age 24h]            Remove containers stopped for longer than max-age")
+	fmt.Fprintln(os.Stderr, "  (the daemon runs gc at startup; BASIC_DOCKER_GC_MAX_AGE sets the default max-age)")
+	fmt.Fprintln(os.Stderr, "  (commands taking a <container-id> also accept the name given with run --name)")
+	fmt.Fprintln(os.Stderr, "  basic-docker rm <container-id>...          Remove stopped containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker rename <container> <new-name> Rename a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker wait <container-id>...        Block until containers exit and print their exit codes")
+	fmt.Fprintln(os.Stderr, "  basic-docker inspect [--format tmpl] <container-id> Show the config and state of a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker cp <src> <container:dest>     Copy files into a container (or <container:src> <dest> out of it)")
+	fmt.Fprintln(os.Stderr, "  basic-docker diff <container-id>           List files added (A), changed (C) or deleted (D) since the image")
+	fmt.Fprintln(os.Stderr, "  basic-docker commit [--exclude pattern]... <container-id> <image> Create an image from a container's filesystem")
+	fmt.Fprintln(os.Stderr, "  basic-docker history [--format json|template] <image> List the layers and build steps of an image")
+	fmt.Fprintln(os.Stderr, "  basic-docker exec <container-id> <command> [args...] - Execute a command in a running container")
+	fmt.Fprintln(os.Stderr, "  basic-docker top <container-id>            List the processes running in a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker stats [--no-stream] [container-id...] Show live CPU, memory and network usage of containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker attach [--no-stdin] [--detach-keys ctrl-p,ctrl-q] <container-id> Connect to the stdio of a running container")
+	fmt.Fprintln(os.Stderr, "  basic-docker update [--memory size] [--cpus n] <container-id>... Change the limits of running containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker pause <container-id>          Suspend all processes in a container")
+	fmt.Fprintln(os.Stderr, "  basic-docker unpause <container-id>        Resume a paused container")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-create [--driver bridge|none|host] [--mtu n] [--label key=value] [--label-file path] <network-name> Create a new network")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-list [--filter label=key[=value]] List networks")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-delete <network-id>   Delete a network by ID")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-inspect [--format tmpl] <network> Show a network and its containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-rename <network> <new-name> Rename a network")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-attach <network-id> <container-id> Attach a container to a network")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-detach <network-id> <container-id> Detach a container from a network")
+	fmt.Fprintln(os.Stderr, "  basic-docker network-ping <network-id> <source-container-id> <target-container-id> Test connectivity between containers")
+	fmt.Fprintln(os.Stderr, "  basic-docker pull [-q] [--platform os/arch[/variant]] [--max-concurrent-layers n] <image> Pull an image from a registry")
+	fmt.Fprintln(os.Stderr, "  basic-docker load <tar-file-path> [--name repo:tag] Load an image from a tar file")
+	fmt.Fprintln(os.Stderr, "  basic-docker import <file|url|-> <image-name> Create an image from a rootfs tar (- reads stdin)")
+	fmt.Fprintln(os.Stderr, "  basic-docker image rm <image-name>         Remove an image by name")
+	fmt.Fprintln(os.Stderr, "  basic-docker image tag <source> <target>   Tag an image under a new name")
+	fmt.Fprintln(os.Stderr, "  basic-docker image verify <image>          Check that an image's rootfs is complete and unchanged")
+	fmt.Fprintln(os.Stderr, "  basic-docker image squash <source> <target> Merge an image's layers into a single layer")
+	fmt.Fprintln(os.Stderr, "  basic-docker capsule <command>             Manage Resource Capsules (add|list|get|attach|rm)")
+	fmt.Fprintln(os.Stderr, "  basic-docker k8s-capsule <command>         Manage Kubernetes Resource Capsules")
+	fmt.Fprintln(os.Stderr, "  basic-docker k8s-crd <command>             Manage ResourceCapsule CRDs")
+	fmt.Fprintln(os.Stderr, "  basic-docker capsule-benchmark <env>       Benchmark Resource Capsules (docker|kubernetes)")
+	fmt.Fprintln(os.Stderr, "  basic-docker monitor <command>             Monitor system across process, container, and host levels")
+	fmt.Fprintln(os.Stderr, "  basic-docker monitor --statsd host:port [--interval 10s] Push host and container metrics to StatsD over UDP")
+}
+
+func (e *Engine) printSystemInfo() {
+	fmt.Println("Lean Docker Engine - System Information")
+	fmt.Println("=======================================")
+	fmt.Printf("Go version: %s\n", runtime.Version())
+	fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("Running in container: %v\n", e.InContainer)
+	fmt.Printf("Namespace privileges: %v\n", e.Namespaces)
+	if controllers := e.Cgroups.controllers(); len(controllers) > 0 {
+		fmt.Printf("Cgroup controllers: %s\n", strings.Join(controllers, ", "))
+	} else {
+		fmt.Println("Cgroup controllers: none")
+	}
+	fmt.Println("Available features:")
+	fmt.Printf("  - Process isolation: %v\n", e.Namespaces)
+	fmt.Printf("  - Network isolation: %v\n", e.Namespaces)
+	fmt.Printf("  - Memory limits: %v\n", e.Cgroups.Memory)
+	fmt.Printf("  - Pause/unpause: %v\n", e.Cgroups.Freezer)
+	fmt.Printf("  - Filesystem isolation: true\n")
+}
+
+func (e *Engine) run() {
+	opts, err := e.parseRunArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.Quiet {
+		e.suppressProgress()
+	}
+
+	if opts.Detach {
+		client := e.daemonClient()
+		if client == nil {
+			fmt.Fprintln(os.Stderr, "Error: Detached mode requires a running daemon (basic-docker daemon)")
+			os.Exit(1)
+		}
+		var resp runResponse
+		if err := daemonRequest(client, http.MethodPost, "/v1/run", opts, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(resp.ID)
+		return
+	}
+
+	config, err := e.Create(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	e.startForeground(config)
+}
+
+// startForeground runs a created container attached to the terminal and
+// exits with its exit code when it fails.
+func (e *Engine) startForeground(config *ContainerConfig) {
+	fmt.Fprintf(os.Stderr, "Starting container %s\n", config.ID)
+	stdio := ContainerIO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	if err := e.Start(config, stdio); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(containerExitCode(exitErr.ProcessState))
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// createCommand implements "create [options] <image> <command> [args...]",
+// which takes the options of run and prints the new container's ID.
+func (e *Engine) createCommand(args []string) {
+	opts, err := e.parseRunArgs(args)
+	if err == nil && opts.Detach {
+		err = fmt.Errorf("create does not take -d, start the container with start")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config, err := e.Create(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(config.ID)
+}
+
+// startCommand implements "start [-a] <container-id>...". Containers start in
+// the background through the daemon; with -a a single container runs
+// attached to the terminal instead.
+func (e *Engine) startCommand(args []string) {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	attach := fs.Bool("a", false, "run the container attached to the terminal")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 || (*attach && fs.NArg() != 1) {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker start [-a] <container-id>...")
+		os.Exit(1)
+	}
+
+	if *attach {
+		containerID := e.resolveContainerID(fs.Arg(0))
+		config, err := e.loadContainerConfig(containerID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: container %s does not exist\n", fs.Arg(0))
+			os.Exit(1)
+		}
+		if status := e.getContainerStatus(containerID); isContainerActive(status) {
+			fmt.Fprintf(os.Stderr, "Error: container %s is already running\n", fs.Arg(0))
+			os.Exit(1)
+		}
+		e.startForeground(config)
+		return
+	}
+
+	client := e.daemonClient()
+	if client == nil {
+		fmt.Fprintln(os.Stderr, "Error: start requires a running daemon (basic-docker daemon), or -a")
+		os.Exit(1)
+	}
+	for _, ref := range fs.Args() {
+		path := "/v1/containers/" + url.PathEscape(e.resolveContainerID(ref)) + "/start"
+		if err := daemonRequest(client, http.MethodPost, path, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(ref)
+	}
+}
+
+// runWithNamespaces uses full Linux namespace isolation. The process is
+// chrooted into rootfs unless it is empty, as when container-init does it,
+// and also gets a user namespace when userNS is set.
+func (e *Engine) runWithNamespaces(containerID, rootfs string, userNS *UserNamespaceMapping, command string, args []string, limits cgroupLimits, stdio ContainerIO) error {
+	cmd := exec.Command(command, args...)
+
+	// Set up namespaces for isolation
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUTS | // Hostname isolation
+			syscall.CLONE_NEWPID | // Process ID isolation
+			syscall.CLONE_NEWNS, // Mount isolation
+	}
+
+	// Add network isolation if available, unless the container uses the
+	// host's network
+	newNetNS, networkSetup := e.containerNetworkSetup(containerID)
+	if newNetNS {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	// Use the container's rootfs
+	if rootfs != "" {
+		cmd.SysProcAttr.Chroot = rootfs
+	}
+	if userNS != nil {
+		userNS.apply(cmd)
+	}
+
+	cmd.Stdin = stdio.Stdin
+	cmd.Stdout = stdio.Stdout
+	cmd.Stderr = stdio.Stderr
+
+	// Set up resource constraints if available
+	if limits.Memory == 0 {
+		limits.Memory = defaultMemoryLimit
+	}
+	return e.runContainerProcess(containerID, cmd, chainOnStart(e.cgroupSetup(containerID, limits), networkSetup))
+}
+
+// Reintroduce runWithoutNamespaces for simplicity and modularity
+func (e *Engine) runWithoutNamespaces(containerID, rootfs, command string, args []string, limits cgroupLimits, stdio ContainerIO) error {
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = stdio.Stdin
+	cmd.Stdout = stdio.Stdout
+	cmd.Stderr = stdio.Stderr
+	return e.runContainerProcess(containerID, cmd, e.cgroupSetup(containerID, limits))
+}
+
+// runContainerProcess starts cmd as the container's main process and waits
+// for it. The PID is recorded for ps/exec, and SIGINT/SIGTERM received by the
+// engine are forwarded to the container. The PID file and cgroup are cleaned
+// up once the process exits, including when it was stopped by a signal.
+func (e *Engine) runContainerProcess(containerID string, cmd *exec.Cmd, onStart func(pid int) error) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start container process: %v", err)
+	}
+	defer e.cleanupContainerRuntime(containerID)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				e.emitEvent(eventStop, containerID, map[string]string{"signal": sig.String()})
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	pid := cmd.Process.Pid
+	if err := e.writeContainerPID(containerID, pid); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if onStart != nil {
+		if err := onStart(pid); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	e.emitEvent(eventStart, containerID, map[string]string{"pid": strconv.Itoa(pid)})
+
+	err := cmd.Wait()
+	exitCode := containerExitCode(cmd.ProcessState)
+	e.emitEvent(eventDie, containerID, map[string]string{"exitCode": strconv.Itoa(exitCode)})
+	return err
+}
+
+// writeContainerPID records the PID of a container's main process.
+func (e *Engine) writeContainerPID(containerID string, pid int) error {
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	pidFile := filepath.Join(e.Root, "containers", containerID, "pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %v", err)
+	}
+	return nil
+}
+
+// cleanupContainerRuntime removes the runtime state of a container whose
+// process has exited: its PID file and cgroup directories. The exit time and
+// OOM kills counted by the cgroup are recorded in the container's config.
+func (e *Engine) cleanupContainerRuntime(containerID string) {
+	if unlock, err := e.lockContainer(containerID); err == nil {
+		os.Remove(filepath.Join(e.Root, "containers", containerID, "pid"))
+		unlock()
+	}
+	finishedAt := time.Now()
+	if err := e.updateContainerConfig(containerID, func(c *ContainerConfig) { c.FinishedAt = finishedAt }); err != nil {
+		e.Logger.Debug("failed to record container exit time", "container", containerID, "error", err)
+	}
+
+	m := e.newCgroupManager()
+	if _, kills, err := m.OOMEvents(containerID); err == nil && kills > 0 {
+		if err := e.updateContainerConfig(containerID, func(c *ContainerConfig) { c.OOMKilled = true }); err != nil {
+			e.Logger.Warn("failed to record OOM kill", "container", containerID, "error", err)
+		}
+		e.emitEvent(eventOOM, containerID, map[string]string{"oomKills": strconv.FormatInt(kills, 10)})
+	}
+	if err := m.Destroy(containerID); err != nil {
+		e.Logger.Warn("failed to remove cgroups", "container", containerID, "error", err)
+	}
+}
+
+// copyDir copies the tree at src into dst, preserving file modes and
+// recreating symlinks rather than following them.
+func (e *Engine) copyDir(src, dst string) error {
+	return e.copyDirFiltered(src, dst, nil)
+}
+
+// copyDirFiltered is copyDir leaving out the paths, relative to src, for
+// which skip returns true. A skipped directory is left out with its contents.
+func (e *Engine) copyDirFiltered(src, dst string, skip func(relPath string, info os.FileInfo) bool) error {
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Calculate relative path
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		// Skip if it's the root directory
+		if relPath == "." {
+			return nil
+		}
+		if skip != nil && skip(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Create target path
+		targetPath := filepath.Join(dst, relPath)
+		if err := removeSymlink(targetPath); err != nil {
+			return err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, dirMode{targetPath, mode.Perm()})
+			return os.MkdirAll(targetPath, 0755)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return os.Symlink(link, targetPath)
+		case mode.IsRegular():
+			return copyFile(path, targetPath)
+		}
+
+		// Devices, sockets and pipes cannot be copied as files
+		e.Logger.Debug("skipping special file", "path", path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Directory modes are applied last so read-only directories can be filled
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSymlink removes path if it is a symlink so that writing to it replaces
+// the link instead of following it out of the destination tree.
+func removeSymlink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+func (e *Engine) mountLayeredFilesystem(layers []string, rootfs string) error {
+	// Clear the rootfs first
+	if err := os.RemoveAll(rootfs); err != nil {
+		return fmt.Errorf("failed to clear rootfs: %v", err)
+	}
+
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		return fmt.Errorf("failed to create rootfs: %v", err)
+	}
+
+	// Start from the base layer and apply each layer
+	for _, layerID := range layers {
+		layerPath := filepath.Join(e.layersDir(), layerID)
+		if err := e.applyLayer(layerPath, rootfs); err != nil {
+			return fmt.Errorf("failed to apply layer %s: %v", layerID, err)
+		}
+	}
+
+	return nil
+}
+
+// getContainerStatus returns "Running", "Paused", "Stopped", or "Created"
+// for containers that were never started.
+func (e *Engine) getContainerStatus(containerID string) string {
+	pidFile := filepath.Join(e.Root, "containers", containerID, "pid")
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		if config, err := e.loadContainerConfig(containerID); err == nil && config.StartedAt.IsZero() {
+			return "Created"
+		}
+		return "Stopped"
+	}
+
+	// The PID file can be seen empty while it is being written
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil || pid <= 0 {
+		return "Stopped"
+	}
+	procPath := fmt.Sprintf("/proc/%d", pid)
+	if _, err := os.Stat(procPath); os.IsNotExist(err) {
+		return "Stopped"
+	}
+
+	// Check if the process is still running
+	if err := syscall.Kill(pid, 0); err != nil {
+		if err == syscall.ESRCH {
+			return "Stopped"
+		}
+	}
+
+	if e.isContainerPaused(containerID) {
+		return "Paused"
+	}
+
+	return "Running"
+}
+
+// isContainerActive reports whether a status is that of a container with a
+// process, running or paused.
+func isContainerActive(status string) bool {
+	return status == "Running" || status == "Paused"
+}
+
+// psCommand implements "ps [--format template]".
+func (e *Engine) psCommand(args []string) {
+	fs := flag.NewFlagSet("ps", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "Go template to print each container with")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker ps [--format template]")
+		os.Exit(1)
+	}
+	if *format == "" {
+		e.listContainers()
+		return
+	}
+
+	tmpl, err := parseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	summaries, err := e.containerSummaries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading containers: %v\n", err)
+		os.Exit(1)
+	}
+	for _, summary := range summaries {
+		if err := writeFormatted(os.Stdout, tmpl, summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// containerSummaries lists the containers, through the daemon when one is
+// running.
+func (e *Engine) containerSummaries() ([]ContainerSummary, error) {
+	if client := e.daemonClient(); client != nil {
+		var summaries []ContainerSummary
+		err := daemonRequest(client, http.MethodGet, "/v1/ps", nil, &summaries)
+		return summaries, err
+	}
+	return e.Ps()
+}
+
+func (e *Engine) listContainers() {
+	summaries, err := e.containerSummaries()
+	fmt.Println("CONTAINER ID\tCREATED\tSTATUS\tCOMMAND\tNAMES")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading containers: %v\n", err)
+		return
+	}
+	printContainerSummaries(os.Stdout, summaries)
+}
+
+// printContainerSummaries writes the rows of the ps table.
+func printContainerSummaries(w io.Writer, summaries []ContainerSummary) {
+	now := time.Now()
+	for _, summary := range summaries {
+		created := ""
+		if !summary.Created.IsZero() {
+			created = humanDuration(now.Sub(summary.Created)) + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", summary.ID, created, displayStatus(summary, now), summary.Command, summary.Name)
+	}
+}
+
+// displayStatus describes the state of a container for ps, with the uptime
+// of running containers and the time since stopped ones exited, both taken
+// from the times recorded in the container's config.
+func displayStatus(summary ContainerSummary, now time.Time) string {
+	status := summary.Status
+	switch {
+	case isContainerActive(summary.Status) && !summary.StartedAt.IsZero():
+		status = "Up " + humanDuration(now.Sub(summary.StartedAt))
+		if summary.Status == "Paused" {
+			status += " (Paused)"
+		}
+	case summary.Status == "Stopped" && !summary.FinishedAt.IsZero():
+		status = "Stopped " + humanDuration(now.Sub(summary.FinishedAt)) + " ago"
+	}
+	if summary.Health != "" {
+		status = fmt.Sprintf("%s (%s)", status, summary.Health)
+	}
+	return status
+}
+
+// stopCommand implements "stop [-t seconds] <container-id>...".
+func (e *Engine) stopCommand(args []string) {
+	fs := flag.NewFlagSet("stop", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	timeout := fs.Int("t", 10, "seconds to wait before killing the container")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker stop [-t seconds] <container-id>...")
+		os.Exit(1)
+	}
+
+	client := e.daemonClient()
+	for _, ref := range fs.Args() {
+		containerID := e.resolveContainerID(ref)
+		var err error
+		if client != nil {
+			path := fmt.Sprintf("/v1/containers/%s/stop?t=%d", url.PathEscape(containerID), *timeout)
+			err = daemonRequest(client, http.MethodPost, path, nil, nil)
+		} else {
+			err = e.Stop(containerID, time.Duration(*timeout)*time.Second)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(ref)
+	}
+}
+
+// restartCommand implements "restart [-t seconds] <container-id>...". The
+// containers are started again in the background, so a daemon is required.
+func (e *Engine) restartCommand(args []string) {
+	fs := flag.NewFlagSet("restart", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	timeout := fs.Int("t", 10, "seconds to wait before killing the container")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker restart [-t seconds] <container-id>...")
+		os.Exit(1)
+	}
+
+	client := e.daemonClient()
+	if client == nil {
+		fmt.Fprintln(os.Stderr, "Error: restart requires a running daemon (basic-docker daemon)")
+		os.Exit(1)
+	}
+	for _, ref := range fs.Args() {
+		path := fmt.Sprintf("/v1/containers/%s/restart?t=%d", url.PathEscape(e.resolveContainerID(ref)), *timeout)
+		if err := daemonRequest(client, http.MethodPost, path, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(ref)
+	}
+}
+
+// logsCommand implements "logs <container-id>".
+func (e *Engine) logsCommand(containerID string) {
+	if client := e.daemonClient(); client != nil {
+		if err := daemonStream(client, "/v1/containers/"+url.PathEscape(containerID)+"/logs", os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := e.Logs(containerID, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// streamEventsCommand implements "events [--since time] [--follow=false]".
+func (e *Engine) streamEventsCommand(args []string) {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	sinceFlag := fs.String("since", "", "show events since a duration ago (10m) or an RFC 3339 time")
+	follow := fs.Bool("follow", true, "keep streaming new events")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		var err error
+		if since, err = parseSince(*sinceFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	stop := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(stop)
+	}()
+
+	if err := e.streamEvents(os.Stdout, since, *follow, stop); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// inspectCommand implements "inspect [--format template] <container-id>".
+func (e *Engine) inspectCommand(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "Go template to print the container with")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker inspect [--format template] <container-id>")
+		os.Exit(1)
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: Container ID required for inspect")
+		os.Exit(1)
+	}
+	var tmpl *template.Template
+	if *format != "" {
+		var err error
+		if tmpl, err = parseFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	e.printContainerInspect(e.resolveContainerID(fs.Arg(0)), tmpl)
+}
+
+// printContainerInspect prints the config and state of a container as JSON,
+// or with tmpl when it is set.
+func (e *Engine) printContainerInspect(containerID string, tmpl *template.Template) {
+	info, err := e.inspectContainer(containerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to inspect container %s: %v\n", containerID, err)
+		os.Exit(1)
+	}
+	if tmpl != nil {
+		if err := writeFormatted(os.Stdout, tmpl, info); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to encode container %s: %v\n", containerID, err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+// ImageSummary describes a local image as listed by images.
+type ImageSummary struct {
+	Name            string
+	Size            int64
+	ContentVerified bool
+	// Digest is the manifest digest of a pulled image, empty otherwise.
+	Digest  string
+	Created time.Time
+}
+
+// listImageSummaries describes the local images from their metadata. An
+// image's content is verified when its rootfs holds the essential paths; its
+// size is that of its files. Recorded digests are only checked by image
+// verify.
+func (e *Engine) listImageSummaries() ([]ImageSummary, error) {
+	entries, err := os.ReadDir(e.imagesDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []ImageSummary
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		summary := ImageSummary{Name: entry.Name()}
+		if image, err := e.loadImageMetadata(entry.Name()); err == nil {
+			summary.Digest, summary.Created = image.Digest, image.Created
+		}
+		rootfsPath := filepath.Join(e.imagesDir(), entry.Name(), "rootfs")
+		if files, err := os.ReadDir(rootfsPath); err == nil && len(files) > 0 {
+			summary.ContentVerified = len(checkEssentialPaths(rootfsPath)) == 0
+			filepath.Walk(rootfsPath, func(_ string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					summary.Size += info.Size()
+				}
+				return nil
+			})
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// imagesCommand implements "images [-q] [--format template]".
+func (e *Engine) imagesCommand(args []string) {
+	fs := flag.NewFlagSet("images", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	quiet := fs.Bool("q", false, "only print image names")
+	format := fs.String("format", "", "Go template to print each image with")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker images [-q] [--format template]")
+		os.Exit(1)
+	}
+	if *format == "" {
+		e.listImages(*quiet)
+		return
+	}
+
+	tmpl, err := parseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	summaries, err := e.listImageSummaries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading images: %v\n", err)
+		os.Exit(1)
+	}
+	for _, summary := range summaries {
+		if err := writeFormatted(os.Stdout, tmpl, summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// listImages prints the local images. In quiet mode only the image names are
+// printed, one per line.
+func (e *Engine) listImages(quiet bool) {
+	if !quiet {
+		fmt.Println("IMAGE NAME\tSIZE\tCONTENT VERIFIED\tDIGEST")
+	}
+
+	summaries, err := e.listImageSummaries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading images: %v\n", err)
+		return
+	}
+	for _, summary := range summaries {
+		if quiet {
+			fmt.Println(summary.Name)
+			continue
+		}
+		contentVerified := "No"
+		if summary.ContentVerified {
+			contentVerified = "Yes"
+		}
+		digest := summary.Digest
+		if digest == "" {
+			digest = "<none>"
+		}
+		fmt.Printf("%s\t%d bytes\t%s\t%s\n", summary.Name, summary.Size, contentVerified, digest)
+	}
+}
+
+// copyFile copies the contents and mode of the file at src to dst. A symlink
+// at src is followed, one at dst is replaced.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := removeSymlink(dst); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// The mode given to OpenFile is masked by the umask and ignored for
+	// existing files
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+func combineArgs(args []string) string {
+	result := ""
+	for _, arg := range args {
+		result += " " + arg
+	}
+	return result
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func (e *Engine) execCommand() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Error: Container ID and command required for exec")
+		os.Exit(1)
+	}
+
+	containerID := e.resolveContainerID(os.Args[2])
+	command := os.Args[3]
+	args := os.Args[4:]
+
+	cmd, err := e.startExec(containerID, command, args, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cmd.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to execute command in container %s: %v\n", containerID, err)
+		os.Exit(1)
+	}
+}
+
+// startExec starts a command inside a running container. The container is
+// locked until the command has started, so that it cannot be stopped or
+// removed meanwhile.
+func (e *Engine) startExec(containerID, command string, args []string, stdin io.Reader, stdout, stderr io.Writer) (*exec.Cmd, error) {
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cmd, err := e.containerExecCommand(containerID, command, args)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to execute command in container %s: %v", containerID, err)
+	}
+	return cmd, nil
+}
+
+// containerExecCommand builds a command that runs inside the namespaces of a
+// running container.
+func (e *Engine) containerExecCommand(containerID, command string, args []string) (*exec.Cmd, error) {
+	// Check if the container directory exists
+	containerDir := filepath.Join(e.Root, "containers", containerID)
+	if _, err := os.Stat(containerDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("container %s does not exist. Please ensure the container is running", containerID)
+	}
+
+	// Locate the PID of the container
+	pidFile := filepath.Join(e.Root, "containers", containerID, "pid")
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PID file for container %s: %v", containerID, err)
+	}
+
+	pid := strings.TrimSpace(string(pidData))
+
+	// Verify if the process with the given PID exists
+	procPath := fmt.Sprintf("/proc/%s", pid)
+	if _, err := os.Stat(procPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("process with PID %s does not exist. The container might not be running", pid)
+	}
+
+	// Attach to the container's namespace and execute the command
+	nsPath := fmt.Sprintf("/proc/%s/ns/mnt", pid)
+	cmd := exec.Command("nsenter", "--mount="+nsPath, "--pid="+fmt.Sprintf("/proc/%s/ns/pid", pid), "--", command)
+	cmd.Args = append(cmd.Args, args...)
+	return cmd, nil
+}
+
+// RunOptions holds the parsed arguments of the run command.
+type RunOptions struct {
+	Image          string        `json:"image"`
+	Command        string        `json:"command"`
+	Args           []string      `json:"args,omitempty"`
+	HealthCmd      string        `json:"healthCmd,omitempty"`
+	HealthInterval time.Duration `json:"healthInterval,omitempty"`
+	Publish        []string      `json:"publish,omitempty"`
+	PublishAll     bool          `json:"publishAll,omitempty"`
+	Network        string        `json:"network,omitempty"`
+	Name           string        `json:"name,omitempty"`
+	ReadOnly       bool          `json:"readOnly,omitempty"`
+	Tmpfs          []string      `json:"tmpfs,omitempty"`
+	CapAdd         []string      `json:"capAdd,omitempty"`
+	CapDrop        []string      `json:"capDrop,omitempty"`
+	Seccomp        string        `json:"seccomp,omitempty"`
+	UserNS         bool          `json:"userns,omitempty"`
+	Platform       string        `json:"platform,omitempty"`
+	Isolation      string        `json:"isolation,omitempty"`
+	PidsLimit      int64         `json:"pidsLimit,omitempty"`
+	AddHosts       []string      `json:"addHosts,omitempty"`
+	DNS            []string      `json:"dns,omitempty"`
+	DNSSearch      []string      `json:"dnsSearch,omitempty"`
+	// Entrypoint overrides the image's entrypoint when set; empty clears it.
+	Entrypoint *string `json:"entrypoint,omitempty"`
+	// Interactive keeps the stdin of a detached container open for attach.
+	Interactive    bool     `json:"interactive,omitempty"`
+	OOMKillDisable bool     `json:"oomKillDisable,omitempty"`
+	Shell          bool     `json:"shell,omitempty"`
+	Ulimits        []string `json:"ulimits,omitempty"`
+	Volumes        []string `json:"volumes,omitempty"`
+	Quiet          bool     `json:"quiet,omitempty"`
+	Detach         bool     `json:"-"`
+}
+
+// parseRunArgs parses "run [options] <image> <command> [args...]". Options
+// must come before the image name.
+func (e *Engine) parseRunArgs(args []string) (*RunOptions, error) {
+	opts := &RunOptions{}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.HealthCmd, "health-cmd", "", "command to run to check health")
+	fs.DurationVar(&opts.HealthInterval, "health-interval", defaultHealthInterval, "time between health checks")
+	fs.Var((*stringList)(&opts.Publish), "p", "publish a container port to the host")
+	fs.BoolVar(&opts.PublishAll, "P", false, "publish all exposed ports to random host ports")
+	fs.StringVar(&opts.Network, "network", "", "name or ID of a network to attach the container to")
+	fs.StringVar(&opts.Name, "name", "", "assign a name to the container")
+	fs.BoolVar(&opts.ReadOnly, "read-only", false, "mount the container's root filesystem as read-only")
+	fs.Var((*stringList)(&opts.Tmpfs), "tmpfs", "mount a writable tmpfs at a path inside the container")
+	fs.Var((*stringList)(&opts.CapAdd), "cap-add", "add a Linux capability (or ALL)")
+	fs.Var((*stringList)(&opts.CapDrop), "cap-drop", "drop a Linux capability (or ALL)")
+	fs.BoolVar(&opts.UserNS, "userns", false, "run the container in a user namespace mapping its root to an unprivileged host user")
+	var securityOpts []string
+	fs.Var((*stringList)(&securityOpts), "security-opt", "security option, seccomp=default|unconfined|<profile.json>")
+	fs.BoolVar(&opts.Detach, "d", false, "run the container in the background through the daemon")
+	fs.BoolVar(&opts.Interactive, "i", false, "keep stdin of a detached container open so attach can write to it")
+	fs.StringVar(&opts.Platform, "platform", "", "platform to pull the image for, os/arch[/variant]")
+	fs.StringVar(&opts.Isolation, "isolation", isolationAuto, "isolation of the container process: auto, none or namespaces")
+	fs.Int64Var(&opts.PidsLimit, "pids-limit", 0, "maximum number of processes in the container")
+	fs.BoolVar(&opts.OOMKillDisable, "oom-kill-disable", false, "disable the OOM killer for the container (cgroup v1 only)")
+	fs.Var((*stringList)(&opts.AddHosts), "add-host", "add a name:ip entry to the container's /etc/hosts")
+	fs.Var((*stringList)(&opts.DNS), "dns", "nameserver for the container's /etc/resolv.conf")
+	fs.Var((*stringList)(&opts.DNSSearch), "dns-search", "search domain for the container's /etc/resolv.conf")
+	fs.Var((*stringList)(&opts.Ulimits), "ulimit", "resource limit of the container process, name=soft[:hard]")
+	fs.Var((*stringList)(&opts.Volumes), "v", "mount a named volume or host directory, source:/path")
+	fs.Var((*stringList)(&opts.Volumes), "volume", "mount a named volume or host directory, source:/path")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "suppress the pull progress, reporting only errors")
+	fs.BoolVar(&opts.Quiet, "q", false, "shorthand for --quiet")
+	fs.BoolVar(&opts.Shell, "shell", false, "run the command with /bin/sh -c")
+	fs.Func("entrypoint", "override the image's entrypoint, \"\" clears it", func(value string) error {
+		opts.Entrypoint = &value
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	rest := fs.Args()
+	if len(rest) < 1 {
+		return nil, fmt.Errorf("image name required for run")
+	}
+	// With --entrypoint the command only supplies its arguments
+	if len(rest) < 2 && opts.Entrypoint == nil {
+		return nil, fmt.Errorf("command required for run")
+	}
+	if opts.HealthInterval <= 0 {
+		return nil, fmt.Errorf("health interval must be positive, got %s", opts.HealthInterval)
+	}
+	if opts.Name != "" && !validContainerName.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid container name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", opts.Name)
+	}
+	if err := validateTmpfsPaths(opts.Tmpfs); err != nil {
+		return nil, err
+	}
+	if _, err := resolveCapabilities(opts.CapAdd, opts.CapDrop); err != nil {
+		return nil, err
+	}
+	seccomp, err := e.parseSecurityOpts(securityOpts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Seccomp = seccomp
+	if opts.Platform != "" {
+		if _, err := parsePlatform(opts.Platform); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := resolveIsolation(opts.Isolation, true); err != nil {
+		return nil, err
+	}
+	var pidsLimitErr error
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "pids-limit" && opts.PidsLimit <= 0 {
+			pidsLimitErr = fmt.Errorf("pids limit must be positive, got %d", opts.PidsLimit)
+		}
+	})
+	if pidsLimitErr != nil {
+		return nil, pidsLimitErr
+	}
+	for _, entry := range opts.AddHosts {
+		if _, _, err := parseHostEntry(entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateDNSServers(opts.DNS); err != nil {
+		return nil, err
+	}
+	if _, err := parseUlimits(opts.Ulimits); err != nil {
+		return nil, err
+	}
+	if _, err := parseVolumeSpecs(opts.Volumes); err != nil {
+		return nil, err
+	}
+
+	opts.Image = normalizeImageRef(rest[0])
+	if len(rest) > 1 {
+		opts.Command = rest[1]
+		opts.Args = rest[2:]
+	}
+	return opts, nil
+}
+
+// pullCommand implements "pull [options] <image>". With --quiet only errors
+// are reported and the image reference is the only output.
+func (e *Engine) pullCommand(args []string) {
+	ref, pullOpts, quiet, err := parsePullArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker pull [-q] [--platform os/arch[/variant]] [--max-concurrent-layers n] <image>")
+		os.Exit(1)
+	}
+	if quiet {
+		e.suppressProgress()
+	}
+	image, err := e.Pull(ref, pullOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to pull image '%s': %v\n", ref, err)
+		os.Exit(1)
+	}
+	if quiet {
+		fmt.Println(image.Name)
+		return
+	}
+	fmt.Printf("Image '%s' pulled successfully.\n", image.Name)
+}
+
+// parsePullArgs parses "pull [-q|--quiet] [--platform os/arch[/variant]]
+// [--max-concurrent-layers n] <image>".
+func parsePullArgs(args []string) (string, PullOptions, bool, error) {
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	platformFlag := fs.String("platform", "", "platform to pull the image for, os/arch[/variant]")
+	concurrency := fs.Int("max-concurrent-layers", defaultPullConcurrency, "how many layers to download and extract at once")
+	var quiet bool
+	fs.BoolVar(&quiet, "quiet", false, "report only errors and print the image reference")
+	fs.BoolVar(&quiet, "q", false, "shorthand for --quiet")
+	if err := fs.Parse(args); err != nil {
+		return "", PullOptions{}, false, err
+	}
+	if fs.NArg() != 1 {
+		return "", PullOptions{}, false, fmt.Errorf("exactly one image name required for pull")
+	}
+	if *concurrency < 1 {
+		return "", PullOptions{}, false, fmt.Errorf("--max-concurrent-layers must be at least 1")
+	}
+	opts := PullOptions{Concurrency: *concurrency}
+	if *platformFlag != "" {
+		var err error
+		if opts.Platform, err = parsePlatform(*platformFlag); err != nil {
+			return "", PullOptions{}, false, err
+		}
+	}
+	return fs.Arg(0), opts, quiet, nil
+}
+
+// parseLoadArgs parses "load <tar-file-path> [--name repo:tag]". Without a
+// name the image is named after the tar file.
+func parseLoadArgs(args []string) (tarFilePath, imageName string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--name":
+			if i+1 >= len(args) {
+				return "", "", fmt.Errorf("--name requires a value")
+			}
+			i++
+			imageName = args[i]
+		case strings.HasPrefix(arg, "--name="):
+			imageName = strings.TrimPrefix(arg, "--name=")
+		case tarFilePath == "":
+			tarFilePath = arg
+		default:
+			return "", "", fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+
+	if tarFilePath == "" {
+		return "", "", fmt.Errorf("tar file path required for load")
+	}
+	if imageName == "" {
+		imageName = strings.TrimSuffix(filepath.Base(tarFilePath), ".tar")
+	}
+	if err := validateImageRef(imageName); err != nil {
+		return "", "", err
+	}
+	return tarFilePath, normalizeImageRef(imageName), nil
+}
+
+// topContainer prints the processes running inside a container
+func (e *Engine) topContainer(containerID string) {
+	processes, err := e.ContainerTop(containerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("PID\tNAME\tSTATE\tRSS")
+	for _, p := range processes {
+		fmt.Printf("%d\t%s\t%s\t%d bytes\n", p.PID, p.Name, p.Status, p.MemoryVmRSS)
+	}
+}
+
+// handlePauseCommand freezes or thaws a running container
+func (e *Engine) handlePauseCommand(action, containerID string) {
+	if !e.Cgroups.Freezer {
+		fmt.Fprintf(os.Stderr, "Error: %s requires the cgroup freezer controller\n", action)
+		os.Exit(1)
+	}
+
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer unlock()
+
+	status := e.getContainerStatus(containerID)
+	if action == "pause" {
+		if status != "Running" {
+			fmt.Fprintf(os.Stderr, "Error: Container %s is not running (status: %s)\n", containerID, status)
+			os.Exit(1)
+		}
+		if err := e.pauseContainer(containerID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to pause container %s: %v\n", containerID, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Container %s paused\n", containerID)
+		return
+	}
+
+	if status != "Paused" {
+		fmt.Fprintf(os.Stderr, "Error: Container %s is not paused (status: %s)\n", containerID, status)
+		os.Exit(1)
+	}
+	if err := e.unpauseContainer(containerID); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to unpause container %s: %v\n", containerID, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Container %s unpaused\n", containerID)
+}
+
+func (e *Engine) fallbackToHostBinaries(rootfs string) error {
+	e.Logger.Warn("falling back to host binaries as busybox is not available")
+
+	// List of essential commands to copy from the host system
+	hostCommands := []string{"sh", "ls", "echo", "cat", "ps"}
+
+	for _, cmd := range hostCommands {
+		hostCmdPath, err := exec.LookPath(cmd)
+		if err != nil {
+			e.Logger.Warn("command not found on the host system, skipping", "command", cmd)
+			continue
+		}
+
+		// Copy the command binary to the container's rootfs
+		containerCmdPath := filepath.Join(rootfs, "bin", filepath.Base(hostCmdPath))
+		if err := copyFile(hostCmdPath, containerCmdPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to copy %s to container rootfs: %v\n", cmd, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleCapsuleCommand implements the Docker-side "capsule" subcommands.
+func (e *Engine) handleCapsuleCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule <command> [args...]")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  add <name> <version> <path>             - Register a Resource Capsule")
+		fmt.Fprintln(os.Stderr, "  list                                    - List all Resource Capsules")
+		fmt.Fprintln(os.Stderr, "  get <name> [version|constraint]         - Show a capsule, latest by default")
+		fmt.Fprintln(os.Stderr, "  attach <container-id> <name> <version>  - Attach a capsule to a container")
+		fmt.Fprintln(os.Stderr, "  rm <name> <version>                     - Remove a Resource Capsule")
+		os.Exit(1)
+	}
+
+	cm := e.NewCapsuleManager()
+	switch args[0] {
+	case "add":
+		if len(args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule add <name> <version> <path>")
+			os.Exit(1)
+		}
+		path, err := filepath.Abs(args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid capsule path: %v\n", err)
+			os.Exit(1)
+		}
+		cm.AddCapsule(args[1], args[2], path)
+		fmt.Printf("Capsule %s:%s added\n", args[1], args[2])
+
+	case "list":
+		cm.ListCapsules(os.Stdout)
+
+	case "get":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule get <name> [version|constraint]")
+			os.Exit(1)
+		}
+		constraint := "latest"
+		if len(args) > 2 {
+			constraint = args[2]
+		}
+		capsule, exists := cm.GetCapsule(args[1], constraint)
+		if !exists {
+			var err error
+			if capsule, err = cm.GetCapsuleMatching(args[1], constraint); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Name: %s\nVersion: %s\nPath: %s\n", capsule.Name, capsule.Version, capsule.Path)
+
+	case "attach":
+		if len(args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule attach <container-id> <name> <version>")
+			os.Exit(1)
+		}
+		if err := cm.AttachCapsule(e.resolveContainerID(args[1]), args[2], args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to attach capsule: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Capsule %s:%s attached to container %s at %s\n", args[2], args[3], args[1], capsuleContainerPath(args[2], args[3]))
+
+	case "rm":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker capsule rm <name> <version>")
+			os.Exit(1)
+		}
+		if err := cm.RemoveCapsule(args[1], args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Capsule %s:%s removed\n", args[1], args[2])
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown capsule command: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Available commands: add, list, get, attach, rm")
+		os.Exit(1)
+	}
+}
+
+// handleKubernetesCapsuleCommand handles Kubernetes capsule-related CLI commands
+func (e *Engine) handleKubernetesCapsuleCommand() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-capsule <command> [args...]")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  create <name> <version> <file-path> [--dry-run] - Create a new Resource Capsule (--dry-run prints it instead)")
+		fmt.Fprintln(os.Stderr, "  list                                 - List all Resource Capsules")
+		fmt.Fprintln(os.Stderr, "  get <name> <version> [--show-values] [--reveal] - Get a Resource Capsule (--reveal decodes secrets)")
+		fmt.Fprintln(os.Stderr, "  delete <name> <version>              - Delete a Resource Capsule")
+		os.Exit(1)
+	}
+
+	command := os.Args[3]
+	
+	kcm, err := NewKubernetesCapsuleManager("default")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create Kubernetes client: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Make sure you have access to a Kubernetes cluster and kubectl is configured.")
+		os.Exit(1)
+	}
+
+	switch command {
+	case "create":
+		args, dryRun := extractDryRun(os.Args[4:])
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-capsule create <name> <version> <file-path> [--dry-run]")
+			os.Exit(1)
+		}
+		name := args[0]
+		version := args[1]
+		filePath := args[2]
+
+		if dryRun {
+			obj, err := kcm.CapsuleFromFile(name, version, filePath)
+			if err == nil {
+				err = writeObjectJSON(os.Stdout, obj)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		err := e.AddResourceCapsule("kubernetes", name, version, filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to create Kubernetes capsule: %v\n", err)
+			os.Exit(1)
+		}
+		
+	case "list":
+		err := kcm.ListCapsules()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to list capsules: %v\n", err)
+			os.Exit(1)
+		}
+		
+	case "get":
+		var positional []string
+		showValues, reveal := false, false
+		for _, arg := range os.Args[4:] {
+			switch arg {
+			case "--show-values":
+				showValues = true
+			case "--reveal":
+				showValues, reveal = true, true
+			default:
+				positional = append(positional, arg)
+			}
+		}
+		if len(positional) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-capsule get <name> <version> [--show-values] [--reveal]")
+			os.Exit(1)
+		}
+		name := positional[0]
+		version := positional[1]
+
+		capsule, err := kcm.GetCapsule(name, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printCapsule(os.Stdout, name, version, capsule, showValues, reveal)
+		
+	case "delete":
+		if len(os.Args) < 6 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-capsule delete <name> <version>")
+			os.Exit(1)
+		}
+		name := os.Args[4]
+		version := os.Args[5]
+		
+		err := kcm.DeleteCapsule(name, version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to delete capsule: %v\n", err)
+			os.Exit(1)
+		}
+		
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'\n", command)
+		os.Exit(1)
+	}
+}
+
+// handleCapsuleBenchmark handles benchmarking commands
+func (e *Engine) handleCapsuleBenchmark(environment string) {
+	switch environment {
+	case "docker":
+		e.runDockerCapsuleBenchmark()
+	case "kubernetes", "k8s":
+		runKubernetesCapsuleBenchmark()
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unsupported environment '%s'\n", environment)
+		fmt.Println("Supported environments: docker, kubernetes")
+		os.Exit(1)
+	}
+}
+
+// runDockerCapsuleBenchmark runs benchmarks for Docker-based Resource Capsules
+func (e *Engine) runDockerCapsuleBenchmark() {
+	fmt.Println("=== Docker Resource Capsule Benchmark ===")
+	
+	cm := e.NewCapsuleManager()
+	cm.AddCapsule("benchmark-capsule", "1.0", "/tmp/benchmark-file")
+	
+	// Create a test file
+	testFile := "/tmp/benchmark-file"
+	err := os.WriteFile(testFile, []byte("benchmark data"), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create test file: %v\n", err)
+		return
+	}
+	defer os.Remove(testFile)
+	
+	// Benchmark capsule access
+	iterations := 10000
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		_, exists := cm.GetCapsule("benchmark-capsule", "1.0")
+		if !exists {
+			fmt.Fprintln(os.Stderr, "Error: Capsule not found during benchmark")
+			return
+		}
+	}
+	duration := time.Since(start)
+	
+	fmt.Printf("Docker Capsule Access: %d iterations in %v\n", iterations, duration)
+	fmt.Printf("Average per operation: %v\n", duration/time.Duration(iterations))
+}
+
+// runKubernetesCapsuleBenchmark runs benchmarks for Kubernetes-based Resource Capsules
+func runKubernetesCapsuleBenchmark() {
+	fmt.Println("=== Kubernetes Resource Capsule Benchmark ===")
+	
+	kcm, err := NewKubernetesCapsuleManager("default")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create Kubernetes client: %v\n", err)
+		return
+	}
+	
+	// Create a test capsule
+	testData := map[string]string{
+		"benchmark-file": "benchmark data",
+	}
+	
+	err = kcm.CreateConfigMapCapsule("benchmark-capsule", "1.0", testData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create test capsule: %v\n", err)
+		return
+	}
+	
+	// Clean up after benchmark
+	defer kcm.DeleteCapsule("benchmark-capsule", "1.0")
+	
+	// Benchmark capsule access
+	iterations := 100 // Lower iterations for K8s API calls
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		_, err := kcm.BenchmarkKubernetesResourceAccess("benchmark-capsule", "1.0")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error during benchmark iteration %d: %v\n", i, err)
+			return
+		}
+	}
+	duration := time.Since(start)
+	
+	fmt.Printf("Kubernetes Capsule Access: %d iterations in %v\n", iterations, duration)
+	fmt.Printf("Average per operation: %v\n", duration/time.Duration(iterations))
+}
+
+// handleKubernetesCRDCommand handles ResourceCapsule CRD-related CLI commands
+func handleKubernetesCRDCommand() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd <command> [args...]")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  create <name> <version> <file-path> [type] [--dry-run] Create a ResourceCapsule CRD")
+		fmt.Fprintln(os.Stderr, "  list                                        List all ResourceCapsule CRDs")
+		fmt.Fprintln(os.Stderr, "  get <name>                                  Get ResourceCapsule CRD details")
+		fmt.Fprintln(os.Stderr, "  status <name> [--watch] [--timeout 2m]      Show or watch a ResourceCapsule CRD's phase")
+		fmt.Fprintln(os.Stderr, "  delete <name>                               Delete a ResourceCapsule CRD")
+		fmt.Fprintln(os.Stderr, "  rollback <name> <previous-version>          Rollback a ResourceCapsule CRD")
+		fmt.Fprintln(os.Stderr, "  operator start [namespace]                  Start the ResourceCapsule operator")
+		return
+	}
+
+	kcm, err := NewKubernetesCapsuleManager("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating Kubernetes capsule manager: %v\n", err)
+		return
+	}
+
+	command := os.Args[2]
+	switch command {
+	case "create":
+		args, dryRun := extractDryRun(os.Args[3:])
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd create <name> <version> <file-path> [type] [--dry-run]")
+			return
+		}
+		name := args[0]
+		version := args[1]
+		filePath := args[2]
+		capsuleType := "configmap"
+		if len(args) >= 4 {
+			capsuleType = args[3]
+		}
+
+		// Read file content
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			return
+		}
+
+		// Convert content to data map
+		data := map[string]interface{}{
+			"content": string(content),
+		}
+
+		if dryRun {
+			resourceCapsule := kcm.newCRDCapsule(name, version, data, capsuleType)
+			if errs := validateCRDCapsule(resourceCapsule); len(errs) > 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid ResourceCapsule %s: %v\n", name, errs.ToAggregate())
+				return
+			}
+			if err := writeObjectJSON(os.Stdout, resourceCapsule.Object); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			return
+		}
+
+		err = kcm.ApplyCRDCapsule(name, version, data, capsuleType)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating ResourceCapsule CRD: %v\n", err)
+		}
+
+	case "list":
+		err := kcm.ListCRDCapsules()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing ResourceCapsule CRDs: %v\n", err)
+		}
+
+	case "get":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd get <name>")
+			return
+		}
+		name := os.Args[3]
+
+		resourceCapsule, err := kcm.GetCRDCapsule(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting ResourceCapsule CRD: %v\n", err)
+			return
+		}
+
+		fmt.Printf("ResourceCapsule CRD: %s\n", name)
+		fmt.Printf("Namespace: %s\n", resourceCapsule.GetNamespace())
+		
+		spec, found, _ := unstructured.NestedMap(resourceCapsule.Object, "spec")
+		if found {
+			if version, found, _ := unstructured.NestedString(spec, "version"); found {
+				fmt.Printf("Version: %s\n", version)
+			}
+			if capsuleType, found, _ := unstructured.NestedString(spec, "capsuleType"); found {
+				fmt.Printf("Type: %s\n", capsuleType)
+			}
+		}
+		
+		status, found, _ := unstructured.NestedMap(resourceCapsule.Object, "status")
+		if found {
+			if phase, found, _ := unstructured.NestedString(status, "phase"); found {
+				fmt.Printf("Status: %s\n", phase)
+			}
+			if message, found, _ := unstructured.NestedString(status, "message"); found {
+				fmt.Printf("Message: %s\n", message)
+			}
+		}
+
+	case "status":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd status <name> [--watch] [--timeout 2m]")
+			return
+		}
+		name := os.Args[3]
+		fs := flag.NewFlagSet("k8s-crd status", flag.ContinueOnError)
+		watchStatus := fs.Bool("watch", false, "wait for the capsule to become Active or Failed")
+		timeout := fs.Duration("timeout", 2*time.Minute, "how long to watch for")
+		if err := fs.Parse(os.Args[4:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd status <name> [--watch] [--timeout 2m]")
+			return
+		}
+
+		if *watchStatus {
+			if err := kcm.WatchCRDCapsuleStatus(os.Stdout, name, *timeout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		resourceCapsule, err := kcm.GetCRDCapsule(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting ResourceCapsule CRD: %v\n", err)
+			return
+		}
+		phase, message := crdCapsuleStatus(resourceCapsule)
+		fmt.Printf("%s\t%s\n", phase, message)
+
+	case "delete":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd delete <name>")
+			return
+		}
+		name := os.Args[3]
+
+		err := kcm.DeleteCRDCapsule(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting ResourceCapsule CRD: %v\n", err)
+		}
+
+	case "rollback":
+		if len(os.Args) < 5 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd rollback <name> <previous-version>")
+			return
+		}
+		name := os.Args[3]
+		previousVersion := os.Args[4]
+
+		err := kcm.RollbackCRDCapsule(name, previousVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rolling back ResourceCapsule CRD: %v\n", err)
+		}
+
+	case "operator":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd operator start [namespace]")
+			return
+		}
+		subcommand := os.Args[3]
+		if subcommand != "start" {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker k8s-crd operator start [namespace]")
+			return
+		}
+
+		namespace := "default"
+		if len(os.Args) >= 5 {
+			namespace = os.Args[4]
+		}
+
+		operator, err := NewResourceCapsuleOperator(namespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating operator: %v\n", err)
+			return
+		}
+
+		fmt.Println("Starting ResourceCapsule operator... (Press Ctrl+C to stop)")
+		if err := operator.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting operator: %v\n", err)
+			return
+		}
+
+		// Keep the operator running until interrupted
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		operator.Stop()
+
+	default:
+		fmt.Printf("Unknown command: %s\n", command)
+		fmt.Println("Available commands: create, list, get, status, delete, rollback, operator")
+	}
+}
+
+// handleMonitoringCommand handles monitoring-related CLI commands
+func (e *Engine) handleMonitoringCommand() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor <command> [args...]")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  process <pid>               Monitor a specific process by PID")
+		fmt.Fprintln(os.Stderr, "  container <id|name>         Monitor a specific container by ID or name")
+		fmt.Fprintln(os.Stderr, "  host                        Monitor host-level metrics")
+		fmt.Fprintln(os.Stderr, "  all                         Monitor all levels (process, container, host)")
+		fmt.Fprintln(os.Stderr, "  gap                         Analyze monitoring gaps between levels")
+		fmt.Fprintln(os.Stderr, "  correlation <container-id>  Show correlation between monitoring levels")
+		fmt.Fprintln(os.Stderr, "  --statsd host:port [--interval 10s] Push host and container metrics to StatsD")
+		return
+	}
+
+	command := os.Args[2]
+	if strings.HasPrefix(command, "--statsd") {
+		e.monitorStatsDCommand(os.Args[2:])
+		return
+	}
+	switch command {
+	case "process":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor process <pid>")
+			return
+		}
+		pid, err := strconv.Atoi(os.Args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid PID '%s': %v\n", os.Args[3], err)
+			return
+		}
+		
+		pm := NewProcessMonitor(pid)
+		metrics, err := pm.GetMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting process metrics: %v\n", err)
+			return
+		}
+		
+		jsonData, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting metrics: %v\n", err)
+			return
+		}
+		
+		fmt.Printf("Process Metrics (PID %d):\n", pid)
+		fmt.Println(string(jsonData))
+
+	case "container":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor container <container-id|name>")
+			return
+		}
+		cm, err := e.NewContainerMonitorByRef(os.Args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		containerID := cm.containerID
+		
+		metrics, err := cm.GetMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting container metrics: %v\n", err)
+			return
+		}
+		
+		jsonData, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting metrics: %v\n", err)
+			return
+		}
+		
+		fmt.Printf("Container Metrics (%s):\n", containerID)
+		fmt.Println(string(jsonData))
+
+	case "host":
+		hm := e.NewHostMonitor()
+		metrics, err := hm.GetMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting host metrics: %v\n", err)
+			return
+		}
+		
+		jsonData, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting metrics: %v\n", err)
+			return
+		}
+		
+		fmt.Println("Host Metrics:")
+		fmt.Println(string(jsonData))
+
+	case "all":
+		aggregator := e.NewMonitoringAggregator()
+		aggregator.AddMonitor(e.NewHostMonitor())
+		
+		// Add container monitors for all existing containers
+		containerDir := filepath.Join(e.Root, "containers")
+		if entries, err := os.ReadDir(containerDir); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					aggregator.AddMonitor(e.NewContainerMonitor(entry.Name()))
+				}
+			}
+		}
+		
+		metricsStr, err := aggregator.GetFormattedMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting aggregated metrics: %v\n", err)
+			return
+		}
+		
+		fmt.Println("Complete System Monitoring (All Levels):")
+		fmt.Println(metricsStr)
+
+	case "gap":
+		// Perform gap analysis
+		aggregator := e.NewMonitoringAggregator()
+		aggregator.AddMonitor(e.NewHostMonitor())
+		
+		// Add container monitors
+		containerDir := filepath.Join(e.Root, "containers")
+		if entries, err := os.ReadDir(containerDir); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					aggregator.AddMonitor(e.NewContainerMonitor(entry.Name()))
+				}
+			}
+		}
+		
+		metrics, err := aggregator.GetAllMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting metrics for gap analysis: %v\n", err)
+			return
+		}
+		
+		gap := AnalyzeMonitoringGap(metrics)
+		gapData, err := json.MarshalIndent(gap, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting gap analysis: %v\n", err)
+			return
+		}
+		
+		fmt.Println("Monitoring Gap Analysis:")
+		fmt.Println("========================")
+		fmt.Println("This analysis identifies gaps in monitoring coverage between")
+		fmt.Println("process, container, and host levels as described in the Docker")
+		fmt.Println("monitoring problem (https://www.datadoghq.com/blog/the-docker-monitoring-problem/)")
+		fmt.Println()
+		fmt.Println(string(gapData))
+
+	case "correlation":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor correlation <container-id>")
+			return
+		}
+		containerID := e.resolveContainerID(os.Args[3])
+		
+		e.showMonitoringCorrelation(containerID)
+
+	default:
+		fmt.Printf("Unknown monitoring command: %s\n", command)
+		fmt.Println("Available commands: process, container, host, all, gap, correlation")
+	}
+}
+
+// showMonitoringCorrelation shows the correlation between different monitoring levels
+func (e *Engine) showMonitoringCorrelation(containerID string) {
+	fmt.Printf("Monitoring Correlation Analysis for Container: %s\n", containerID)
+	fmt.Println("=" + strings.Repeat("=", len(containerID)+41))
+	fmt.Println()
+	
+	// Get container metrics
+	cm := e.NewContainerMonitor(containerID)
+	containerMetrics, err := cm.GetMetrics()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting container metrics: %v\n", err)
+		return
+	}
+	
+	// Get host metrics
+	hm := e.NewHostMonitor()
+	hostMetrics, err := hm.GetMetrics()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting host metrics: %v\n", err)
+		return
+	}
+	
+	// Display correlation table as per problem statement
+	fmt.Println("Level Correlation Table (Based on Docker Monitoring Problem):")
+	fmt.Println("-------------------------------------------------------------")
+	fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", "Aspect", "Process", "Container", "Host")
+	fmt.Println(strings.Repeat("-", 80))
+	
+	if cMetrics, ok := containerMetrics.(ContainerMetrics); ok {
+		if hMetrics, ok := hostMetrics.(HostMetrics); ok {
+			// Spec line
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"Spec", "Source", "Dockerfile", "Kickstart")
+			
+			// On disk line
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"On disk", ".TEXT", cMetrics.DockerPath, "/")
+			
+			// In memory line
+			processInfo := "N/A"
+			if len(cMetrics.Processes) > 0 {
+				processInfo = fmt.Sprintf("PID %d", cMetrics.Processes[0].PID)
+			}
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"In memory", processInfo, cMetrics.ContainerID, hMetrics.Hostname)
+			
+			// In network line
+			networkInfo := "Socket"
+			if len(cMetrics.Processes) > 0 {
+				networkInfo = cMetrics.Processes[0].Socket
+			}
+			vethInfo := "veth*"
+			if len(cMetrics.VethInterfaces) > 0 {
+				vethInfo = cMetrics.VethInterfaces[0]
+			}
+			ethInfo := "eth*"
+			if len(hMetrics.NetworkInterfaces) > 0 {
+				ethInfo = hMetrics.NetworkInterfaces[0].Name
+			}
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"In network", networkInfo, vethInfo, ethInfo)
+			
+			// Runtime context line
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"Runtime context", "server core", "host", hMetrics.RuntimeContext)
+			
+			// Isolation line
+			fmt.Printf("%-15s | %-20s | %-20s | %-20s\n", 
+				"Isolation", "moderate", "private OS view", "full")
+		}
+	}
+	
+	fmt.Println()
+	fmt.Println("Detailed Metrics:")
+	fmt.Println("-----------------")
+	
+	// Container details
+	containerData, _ := json.MarshalIndent(containerMetrics, "", "  ")
+	fmt.Printf("Container Metrics:\n%s\n\n", string(containerData))
+	
+	// Host summary (subset of metrics)
+	if hMetrics, ok := hostMetrics.(HostMetrics); ok {
+		fmt.Printf("Host Summary:\n")
+		fmt.Printf("  Hostname: %s\n", hMetrics.Hostname)
+		fmt.Printf("  Memory: %d/%d bytes used\n", hMetrics.MemoryUsed, hMetrics.MemoryTotal)
+		fmt.Printf("  Load Average: %v\n", hMetrics.LoadAverage)
+		fmt.Printf("  Network Interfaces: %d\n", len(hMetrics.NetworkInterfaces))
+		fmt.Printf("  Total Containers: %d\n", len(hMetrics.Containers))
+	}
+}
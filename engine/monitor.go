@@ -1,4 +1,4 @@
-package main
+package engine
 
 import (
 	"bufio"
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -40,6 +41,7 @@ type ProcessMetrics struct {
 	CPUPercent   float64 `json:"cpu_percent"`
 	OpenFiles    int    `json:"open_files"`
 	Threads      int    `json:"threads"`
+	ThreadStates map[string]int `json:"thread_states,omitempty"` // Threads per scheduler state
 	StartTime    int64  `json:"start_time"`
 	Socket       string `json:"socket"` // Network socket info
 }
@@ -102,11 +104,25 @@ type ProcessMonitor struct {
 
 // ContainerMonitor implements monitoring at the container level
 type ContainerMonitor struct {
+	engine      *Engine
 	containerID string
 }
 
-// HostMonitor implements monitoring at the host level
-type HostMonitor struct{}
+// HostMonitor implements monitoring at the host level. The kernel version
+// and OS release do not change while the host is up, so they are read once.
+type HostMonitor struct {
+	engine        *Engine
+	staticOnce    sync.Once
+	kernelVersion string
+	osRelease     string
+}
+
+// Host files read by HostMonitor. Tests point them at fixtures.
+var (
+	kernelVersionPath = "/proc/version"
+	osReleasePath     = "/etc/os-release"
+	loadAvgPath       = "/proc/loadavg"
+)
 
 // NewProcessMonitor creates a new process monitor
 func NewProcessMonitor(pid int) *ProcessMonitor {
@@ -114,13 +130,23 @@ func NewProcessMonitor(pid int) *ProcessMonitor {
 }
 
 // NewContainerMonitor creates a new container monitor
-func NewContainerMonitor(containerID string) *ContainerMonitor {
-	return &ContainerMonitor{containerID: containerID}
+func (e *Engine) NewContainerMonitor(containerID string) *ContainerMonitor {
+	return &ContainerMonitor{engine: e, containerID: containerID}
+}
+
+// NewContainerMonitorByRef creates a container monitor for a container given
+// by name or ID
+func (e *Engine) NewContainerMonitorByRef(ref string) (*ContainerMonitor, error) {
+	containerID := e.resolveContainerID(ref)
+	if _, err := os.Stat(filepath.Join(e.Root, "containers", containerID)); err != nil {
+		return nil, fmt.Errorf("container %s not found", ref)
+	}
+	return e.NewContainerMonitor(containerID), nil
 }
 
 // NewHostMonitor creates a new host monitor
-func NewHostMonitor() *HostMonitor {
-	return &HostMonitor{}
+func (e *Engine) NewHostMonitor() *HostMonitor {
+	return &HostMonitor{engine: e}
 }
 
 // GetLevel returns the monitoring level for ProcessMonitor
@@ -149,26 +175,7 @@ func (pm *ProcessMonitor) GetMetrics() (interface{}, error) {
 		return nil, fmt.Errorf("failed to read stat file: %v", err)
 	}
 	
-	statFields := strings.Fields(string(statContent))
-	if len(statFields) >= 24 {
-		// Process name (remove parentheses)
-		metrics.Name = strings.Trim(statFields[1], "()")
-		
-		// Process status
-		metrics.Status = statFields[2]
-		
-		// CPU time (user + sys)
-		utime, _ := strconv.ParseInt(statFields[13], 10, 64)
-		stime, _ := strconv.ParseInt(statFields[14], 10, 64)
-		metrics.CPUTime = utime + stime
-		
-		// Start time
-		starttime, _ := strconv.ParseInt(statFields[21], 10, 64)
-		metrics.StartTime = starttime
-		
-		// Number of threads
-		metrics.Threads, _ = strconv.Atoi(statFields[19])
-	}
+	metrics.parseStat(string(statContent))
 	
 	// Read memory info from /proc/[pid]/status
 	statusFile := fmt.Sprintf("/proc/%d/status", pm.pid)
@@ -195,6 +202,9 @@ func (pm *ProcessMonitor) GetMetrics() (interface{}, error) {
 		}
 	}
 	
+	// Break the threads down by state
+	metrics.ThreadStates = threadStates(fmt.Sprintf("/proc/%d/task", pm.pid))
+	
 	// Count open file descriptors
 	fdDir := fmt.Sprintf("/proc/%d/fd", pm.pid)
 	if entries, err := os.ReadDir(fdDir); err == nil {
@@ -207,6 +217,80 @@ func (pm *ProcessMonitor) GetMetrics() (interface{}, error) {
 	return metrics, nil
 }
 
+// parseStat fills in the metrics read from a /proc/<pid>/stat line. A line
+// that cannot be parsed leaves them unset.
+func (metrics *ProcessMetrics) parseStat(stat string) {
+	name, statFields, ok := splitProcStat(stat)
+	if !ok || len(statFields) < 22 {
+		return
+	}
+	metrics.Name = name
+	
+	// Process status
+	metrics.Status = statFields[0]
+	
+	// CPU time (user + sys)
+	utime, _ := strconv.ParseInt(statFields[11], 10, 64)
+	stime, _ := strconv.ParseInt(statFields[12], 10, 64)
+	metrics.CPUTime = utime + stime
+	
+	// Start time
+	metrics.StartTime, _ = strconv.ParseInt(statFields[19], 10, 64)
+	
+	// Number of threads
+	metrics.Threads, _ = strconv.Atoi(statFields[17])
+}
+
+// splitProcStat splits a /proc/<pid>/stat line into the command name and
+// the fields after it, starting with the state. The name may contain spaces
+// and parentheses, so it ends at the last ')' rather than at a space.
+func splitProcStat(stat string) (string, []string, bool) {
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return "", nil, false
+	}
+	return stat[start+1 : end], strings.Fields(stat[end+1:]), true
+}
+
+// threadStateNames names the scheduler states of /proc/<pid>/task/<tid>/stat
+var threadStateNames = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "blocked",
+	"Z": "zombie",
+	"T": "stopped",
+	"t": "traced",
+	"I": "idle",
+	"X": "dead",
+}
+
+// threadStates counts the threads under a /proc/<pid>/task directory by
+// state. Threads exiting while they are read are skipped.
+func threadStates(taskDir string) map[string]int {
+	entries, err := os.ReadDir(taskDir)
+	if err != nil {
+		return nil
+	}
+	states := make(map[string]int)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(taskDir, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		_, fields, ok := splitProcStat(string(data))
+		if !ok || len(fields) == 0 {
+			continue
+		}
+		state, ok := threadStateNames[fields[0]]
+		if !ok {
+			state = fields[0]
+		}
+		states[state]++
+	}
+	return states
+}
+
 // GetMetrics collects container-level metrics
 func (cm *ContainerMonitor) GetMetrics() (interface{}, error) {
 	metrics := ContainerMetrics{
@@ -216,7 +300,7 @@ func (cm *ContainerMonitor) GetMetrics() (interface{}, error) {
 	}
 	
 	// Container directory path
-	containerDir := filepath.Join(baseDir, "containers", cm.containerID)
+	containerDir := filepath.Join(cm.engine.Root, "containers", cm.containerID)
 	
 	// Check if container exists
 	if _, err := os.Stat(containerDir); os.IsNotExist(err) {
@@ -225,7 +309,7 @@ func (cm *ContainerMonitor) GetMetrics() (interface{}, error) {
 	
 	// Basic container info
 	metrics.Name = cm.containerID
-	metrics.Status = getContainerStatus(cm.containerID)
+	metrics.Status = cm.engine.getContainerStatus(cm.containerID)
 	metrics.DockerPath = containerDir
 	
 	// Get creation time from directory
@@ -249,15 +333,16 @@ func (cm *ContainerMonitor) GetMetrics() (interface{}, error) {
 			// Get namespace information
 			metrics.PIDNamespace = fmt.Sprintf("/proc/%d/ns/pid", pid)
 			metrics.NetworkNamespace = fmt.Sprintf("/proc/%d/ns/net", pid)
+			metrics.NetworkRx, metrics.NetworkTx, _ = containerNetworkIO(pid)
 		}
 	}
 	
-	// Mock some network and resource stats (in a real implementation, 
-	// these would come from cgroups and network interfaces)
-	metrics.NetworkRx = 1024 * 100  // Mock 100KB received
-	metrics.NetworkTx = 1024 * 50   // Mock 50KB transmitted
-	metrics.MemoryUsage = 1024 * 1024 * 10  // Mock 10MB usage
-	metrics.MemoryLimit = 1024 * 1024 * 100 // Mock 100MB limit
+	// Resource usage comes from the container's cgroup when it has one
+	if stats, err := cm.engine.newCgroupManager().Usage(cm.containerID); err == nil {
+		metrics.MemoryUsage = stats.MemoryUsage
+		metrics.MemoryLimit = stats.MemoryLimit
+		metrics.CPUUsage = stats.CPUUsage.Seconds()
+	}
 	
 	// Look for veth interfaces (simplified simulation)
 	metrics.VethInterfaces = append(metrics.VethInterfaces, fmt.Sprintf("veth%s", cm.containerID[:8]))
@@ -281,15 +366,17 @@ func (hm *HostMonitor) GetMetrics() (interface{}, error) {
 	// Get system info
 	metrics.CPUCount = runtime.NumCPU()
 	
-	// Get kernel version
-	if kernelData, err := os.ReadFile("/proc/version"); err == nil {
-		metrics.KernelVersion = strings.TrimSpace(string(kernelData))
-	}
-	
-	// Get OS release
-	if releaseData, err := os.ReadFile("/etc/os-release"); err == nil {
-		metrics.OSRelease = strings.TrimSpace(string(releaseData))
-	}
+	// Get kernel version and OS release
+	hm.staticOnce.Do(func() {
+		if kernelData, err := os.ReadFile(kernelVersionPath); err == nil {
+			hm.kernelVersion = strings.TrimSpace(string(kernelData))
+		}
+		if releaseData, err := os.ReadFile(osReleasePath); err == nil {
+			hm.osRelease = strings.TrimSpace(string(releaseData))
+		}
+	})
+	metrics.KernelVersion = hm.kernelVersion
+	metrics.OSRelease = hm.osRelease
 	
 	// Get uptime
 	if uptimeData, err := os.ReadFile("/proc/uptime"); err == nil {
@@ -302,7 +389,7 @@ func (hm *HostMonitor) GetMetrics() (interface{}, error) {
 	}
 	
 	// Get load average
-	if loadData, err := os.ReadFile("/proc/loadavg"); err == nil {
+	if loadData, err := os.ReadFile(loadAvgPath); err == nil {
 		loadFields := strings.Fields(string(loadData))
 		if len(loadFields) >= 3 {
 			for i := 0; i < 3; i++ {
@@ -390,11 +477,11 @@ func (hm *HostMonitor) GetMetrics() (interface{}, error) {
 	}
 	
 	// Get all container metrics
-	containerDir := filepath.Join(baseDir, "containers")
+	containerDir := filepath.Join(hm.engine.Root, "containers")
 	if entries, err := os.ReadDir(containerDir); err == nil {
 		for _, entry := range entries {
 			if entry.IsDir() {
-				cm := NewContainerMonitor(entry.Name())
+				cm := hm.engine.NewContainerMonitor(entry.Name())
 				if containerMetrics, err := cm.GetMetrics(); err == nil {
 					if cm, ok := containerMetrics.(ContainerMetrics); ok {
 						metrics.Containers = append(metrics.Containers, cm)
@@ -407,14 +494,40 @@ func (hm *HostMonitor) GetMetrics() (interface{}, error) {
 	return metrics, nil
 }
 
+// ContainerTop collects process metrics for every process in a running container
+func (e *Engine) ContainerTop(containerID string) ([]ProcessMetrics, error) {
+	status := e.getContainerStatus(containerID)
+	if status != "Running" && status != "Paused" {
+		return nil, fmt.Errorf("container %s is not running (status: %s)", containerID, status)
+	}
+
+	pids, err := e.containerProcessIDs(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []ProcessMetrics{}
+	for _, pid := range pids {
+		metrics, err := NewProcessMonitor(pid).GetMetrics()
+		if err != nil {
+			// The process may have exited since cgroup.procs was read
+			continue
+		}
+		processes = append(processes, metrics.(ProcessMetrics))
+	}
+	return processes, nil
+}
+
 // MonitoringAggregator aggregates metrics from all monitoring levels
 type MonitoringAggregator struct {
+	engine   *Engine
 	monitors []Monitor
 }
 
 // NewMonitoringAggregator creates a new monitoring aggregator
-func NewMonitoringAggregator() *MonitoringAggregator {
+func (e *Engine) NewMonitoringAggregator() *MonitoringAggregator {
 	return &MonitoringAggregator{
+		engine:   e,
 		monitors: []Monitor{},
 	}
 }
@@ -424,18 +537,49 @@ func (ma *MonitoringAggregator) AddMonitor(monitor Monitor) {
 	ma.monitors = append(ma.monitors, monitor)
 }
 
-// GetAllMetrics gets metrics from all monitoring levels
+// metricWorkers bounds how many monitors GetAllMetrics queries at once.
+const metricWorkers = 8
+
+// GetAllMetrics gets metrics from all monitoring levels. Monitors are queried
+// concurrently; the result is the same as querying them in the order they
+// were added, so the last monitor of a level wins and the error reported is
+// that of the first failing monitor.
 func (ma *MonitoringAggregator) GetAllMetrics() (map[MonitoringLevel]interface{}, error) {
+	return ma.collectMetrics(metricWorkers)
+}
+
+// collectMetrics queries the monitors with up to workers goroutines.
+func (ma *MonitoringAggregator) collectMetrics(workers int) (map[MonitoringLevel]interface{}, error) {
+	type collected struct {
+		metrics interface{}
+		err     error
+	}
+	results := make([]collected, len(ma.monitors))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(ma.monitors)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				metrics, err := ma.monitors[i].GetMetrics()
+				results[i] = collected{metrics, err}
+			}
+		}()
+	}
+	for i := range ma.monitors {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
 	result := make(map[MonitoringLevel]interface{})
-	
-	for _, monitor := range ma.monitors {
-		metrics, err := monitor.GetMetrics()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get metrics from %s monitor: %v", monitor.GetLevel(), err)
+	for i, monitor := range ma.monitors {
+		if results[i].err != nil {
+			return nil, fmt.Errorf("failed to get metrics from %s monitor: %v", monitor.GetLevel(), results[i].err)
 		}
-		result[monitor.GetLevel()] = metrics
+		result[monitor.GetLevel()] = results[i].metrics
 	}
-	
 	return result, nil
 }
 
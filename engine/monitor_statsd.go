@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsdMaxPacket bounds the size of a StatsD packet so that it is not
+// fragmented on an Ethernet network.
+const statsdMaxPacket = 1432
+
+// defaultStatsDInterval is how often monitor --statsd pushes metrics.
+const defaultStatsDInterval = 10 * time.Second
+
+// statsdGauge is a gauge named like the Prometheus metric it corresponds to.
+// The label distinguishing containers, processes or interfaces becomes the
+// last dot-separated component of the StatsD name.
+type statsdGauge struct {
+	name  string
+	label string
+	value float64
+}
+
+// String formats the gauge as a StatsD line.
+func (g statsdGauge) String() string {
+	name := g.name
+	if g.label != "" {
+		name += "." + statsdSanitize(g.label)
+	}
+	return fmt.Sprintf("%s:%s|g", name, strconv.FormatFloat(g.value, 'f', -1, 64))
+}
+
+// statsdSanitize replaces the characters StatsD gives a meaning to.
+func statsdSanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// statsdGauges returns the gauges of the metrics of one monitor.
+func statsdGauges(metrics interface{}) []statsdGauge {
+	switch m := metrics.(type) {
+	case HostMetrics:
+		gauges := []statsdGauge{
+			{name: "basic_docker_host_uptime_seconds", value: m.Uptime.Seconds()},
+			{name: "basic_docker_host_memory_total_bytes", value: float64(m.MemoryTotal)},
+			{name: "basic_docker_host_memory_available_bytes", value: float64(m.MemoryAvailable)},
+			{name: "basic_docker_host_memory_used_bytes", value: float64(m.MemoryUsed)},
+			{name: "basic_docker_host_cpus", value: float64(m.CPUCount)},
+			{name: "basic_docker_host_disk_total_bytes", value: float64(m.DiskTotal)},
+			{name: "basic_docker_host_disk_used_bytes", value: float64(m.DiskUsed)},
+			{name: "basic_docker_host_disk_available_bytes", value: float64(m.DiskAvailable)},
+		}
+		for i, name := range []string{"basic_docker_host_load1", "basic_docker_host_load5", "basic_docker_host_load15"} {
+			if i < len(m.LoadAverage) {
+				gauges = append(gauges, statsdGauge{name: name, value: m.LoadAverage[i]})
+			}
+		}
+		for _, iface := range m.NetworkInterfaces {
+			gauges = append(gauges,
+				statsdGauge{name: "basic_docker_host_network_receive_bytes", label: iface.Name, value: float64(iface.RxBytes)},
+				statsdGauge{name: "basic_docker_host_network_transmit_bytes", label: iface.Name, value: float64(iface.TxBytes)})
+		}
+		return gauges
+	case ContainerMetrics:
+		id := m.ContainerID
+		return []statsdGauge{
+			{name: "basic_docker_container_memory_usage_bytes", label: id, value: float64(m.MemoryUsage)},
+			{name: "basic_docker_container_memory_limit_bytes", label: id, value: float64(m.MemoryLimit)},
+			{name: "basic_docker_container_cpu_usage_seconds", label: id, value: m.CPUUsage},
+			{name: "basic_docker_container_network_receive_bytes", label: id, value: float64(m.NetworkRx)},
+			{name: "basic_docker_container_network_transmit_bytes", label: id, value: float64(m.NetworkTx)},
+			{name: "basic_docker_container_block_read_bytes", label: id, value: float64(m.BlockRead)},
+			{name: "basic_docker_container_block_write_bytes", label: id, value: float64(m.BlockWrite)},
+			{name: "basic_docker_container_processes", label: id, value: float64(len(m.Processes))},
+		}
+	case ProcessMetrics:
+		pid := strconv.Itoa(m.PID)
+		return []statsdGauge{
+			{name: "basic_docker_process_resident_memory_bytes", label: pid, value: float64(m.MemoryVmRSS)},
+			{name: "basic_docker_process_virtual_memory_bytes", label: pid, value: float64(m.MemoryVmSize)},
+			{name: "basic_docker_process_cpu_percent", label: pid, value: m.CPUPercent},
+			{name: "basic_docker_process_open_files", label: pid, value: float64(m.OpenFiles)},
+			{name: "basic_docker_process_threads", label: pid, value: float64(m.Threads)},
+		}
+	}
+	return nil
+}
+
+// statsdPackets joins StatsD lines into packets of at most statsdMaxPacket
+// bytes.
+func statsdPackets(lines []string) [][]byte {
+	var packets [][]byte
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			packets = append(packets, packet)
+			packet = nil
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+// PushStatsD sends the metrics of every monitor to the StatsD server at addr
+// as gauges over UDP. Unlike GetAllMetrics it keeps the metrics of every
+// monitor of a level, so each container is reported. A failing monitor is
+// skipped.
+func (ma *MonitoringAggregator) PushStatsD(addr string) error {
+	var lines []string
+	for _, monitor := range ma.monitors {
+		metrics, err := monitor.GetMetrics()
+		if err != nil {
+			ma.engine.Logger.Warn("skipping monitor in statsd push", "level", monitor.GetLevel(), "error", err)
+			continue
+		}
+		for _, gauge := range statsdGauges(metrics) {
+			lines = append(lines, gauge.String())
+		}
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd %s: %v", addr, err)
+	}
+	defer conn.Close()
+	for _, packet := range statsdPackets(lines) {
+		if _, err := conn.Write(packet); err != nil {
+			return fmt.Errorf("failed to send metrics to statsd %s: %v", addr, err)
+		}
+	}
+	return nil
+}
+
+// systemAggregator returns an aggregator of the host and every container.
+func (e *Engine) systemAggregator() *MonitoringAggregator {
+	aggregator := e.NewMonitoringAggregator()
+	aggregator.AddMonitor(e.NewHostMonitor())
+	if entries, err := os.ReadDir(filepath.Join(e.Root, "containers")); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				aggregator.AddMonitor(e.NewContainerMonitor(entry.Name()))
+			}
+		}
+	}
+	return aggregator
+}
+
+// monitorStatsDCommand implements "monitor --statsd host:port [--interval 10s]".
+// Containers created while it runs are picked up at the next push.
+func (e *Engine) monitorStatsDCommand(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("statsd", "", "StatsD server to push metrics to, host:port")
+	interval := fs.Duration("interval", defaultStatsDInterval, "time between pushes")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *addr == "" || *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker monitor --statsd host:port [--interval 10s]")
+		os.Exit(1)
+	}
+	if _, _, err := net.SplitHostPort(*addr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid statsd address %q: %v\n", *addr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Pushing metrics to statsd %s every %s\n", *addr, *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		// A failed push is retried at the next interval
+		if err := e.systemAggregator().PushStatsD(*addr); err != nil {
+			e.Logger.Warn("statsd push failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPushStatsD captures the packets sent to a local UDP listener and checks
+// the gauge lines of each monitor.
+func TestPushStatsD(t *testing.T) {
+	e := newTestEngine(t)
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	aggregator := e.NewMonitoringAggregator()
+	aggregator.AddMonitor(&stubMonitor{level: HostLevel, metrics: HostMetrics{
+		MemoryTotal: 8192, MemoryUsed: 4096, CPUCount: 4, LoadAverage: []float64{0.5, 0.25, 0.125},
+		NetworkInterfaces: []NetworkInterface{{Name: "eth0", RxBytes: 100, TxBytes: 200}},
+	}})
+	aggregator.AddMonitor(&stubMonitor{level: ContainerLevel, metrics: ContainerMetrics{ContainerID: "c1", MemoryUsage: 1024, CPUUsage: 1.5}})
+	aggregator.AddMonitor(&stubMonitor{level: ContainerLevel, metrics: ContainerMetrics{ContainerID: "c2", MemoryUsage: 2048}})
+	aggregator.AddMonitor(&stubMonitor{level: ContainerLevel, err: errors.New("container vanished")})
+
+	if err := aggregator.PushStatsD(listener.LocalAddr().String()); err != nil {
+		t.Fatalf("PushStatsD failed: %v", err)
+	}
+
+	var lines []string
+	buf := make([]byte, statsdMaxPacket)
+	listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		if n > statsdMaxPacket {
+			t.Errorf("Packet of %d bytes exceeds %d", n, statsdMaxPacket)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		listener.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	}
+
+	for _, want := range []string{
+		"basic_docker_host_memory_total_bytes:8192|g",
+		"basic_docker_host_memory_used_bytes:4096|g",
+		"basic_docker_host_cpus:4|g",
+		"basic_docker_host_load5:0.25|g",
+		"basic_docker_host_network_receive_bytes.eth0:100|g",
+		"basic_docker_container_memory_usage_bytes.c1:1024|g",
+		"basic_docker_container_cpu_usage_seconds.c1:1.5|g",
+		"basic_docker_container_memory_usage_bytes.c2:2048|g",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("Expected gauge %q, got %v", want, lines)
+		}
+	}
+}
+
+// TestStatsDPackets checks that lines are split across packets without
+// exceeding the packet size.
+func TestStatsDPackets(t *testing.T) {
+	line := statsdGauge{name: "basic_docker_container_memory_usage_bytes", label: "id:with|bad chars", value: 1}.String()
+	if line != "basic_docker_container_memory_usage_bytes.id_with_bad_chars:1|g" {
+		t.Errorf("Unexpected gauge line %q", line)
+	}
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = line
+	}
+	packets := statsdPackets(lines)
+	if len(packets) < 2 {
+		t.Fatalf("Expected the lines to be split, got %d packets", len(packets))
+	}
+	count := 0
+	for _, packet := range packets {
+		if len(packet) > statsdMaxPacket {
+			t.Errorf("Packet of %d bytes exceeds %d", len(packet), statsdMaxPacket)
+		}
+		count += len(strings.Split(string(packet), "\n"))
+	}
+	if count != len(lines) {
+		t.Errorf("Expected %d lines across the packets, got %d", len(lines), count)
+	}
+}
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+)
+
+const (
+	containerNamesFile     = "names.json"
+	containerNamesLockFile = "names.lock"
+)
+
+// validContainerName matches the names accepted by run --name, as in Docker.
+var validContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// containerNamesPath returns the file mapping container names to IDs.
+func (e *Engine) containerNamesPath() string {
+	return filepath.Join(e.Root, containerNamesFile)
+}
+
+// lockContainerNames blocks until it holds the lock guarding updates of
+// names.json and returns the function releasing it. Like the container locks
+// it is shared with other engine processes.
+func (e *Engine) lockContainerNames() (func(), error) {
+	file, err := os.OpenFile(filepath.Join(e.Root, containerNamesLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock of container names: %v", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock container names: %v", err)
+	}
+	return func() { file.Close() }, nil
+}
+
+// loadContainerNames reads the name to ID mapping.
+func (e *Engine) loadContainerNames() (map[string]string, error) {
+	names := make(map[string]string)
+	data, err := os.ReadFile(e.containerNamesPath())
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container names: %v", err)
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("failed to parse container names: %v", err)
+	}
+	return names, nil
+}
+
+// saveContainerNames writes the name to ID mapping.
+func (e *Engine) saveContainerNames(names map[string]string) error {
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal container names: %v", err)
+	}
+	if err := os.WriteFile(e.containerNamesPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write container names: %v", err)
+	}
+	return nil
+}
+
+// reserveContainerName assigns name to a container, failing if the name is
+// invalid or already taken.
+func (e *Engine) reserveContainerName(name, containerID string) error {
+	if !validContainerName.MatchString(name) {
+		return fmt.Errorf("invalid container name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+
+	unlock, err := e.lockContainerNames()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	names, err := e.loadContainerNames()
+	if err != nil {
+		return err
+	}
+	if owner, taken := names[name]; taken {
+		return fmt.Errorf("container name %q is already in use by container %s", name, owner)
+	}
+	if e.containerExists(name) {
+		return fmt.Errorf("container name %q is already in use as a container ID", name)
+	}
+	names[name] = containerID
+	return e.saveContainerNames(names)
+}
+
+// releaseContainerName frees the name of a removed container.
+func (e *Engine) releaseContainerName(containerID string) error {
+	unlock, err := e.lockContainerNames()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	names, err := e.loadContainerNames()
+	if err != nil {
+		return err
+	}
+	for name, id := range names {
+		if id == containerID {
+			delete(names, name)
+			return e.saveContainerNames(names)
+		}
+	}
+	return nil
+}
+
+// RenameContainer gives the container called ref, by name or ID, a new name,
+// updating both names.json and its config.
+func (e *Engine) RenameContainer(ref, newName string) error {
+	if !validContainerName.MatchString(newName) {
+		return fmt.Errorf("invalid container name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", newName)
+	}
+	containerID := e.resolveContainerID(ref)
+	if !e.containerExists(containerID) {
+		return fmt.Errorf("container %s not found", ref)
+	}
+
+	unlock, err := e.lockContainerNames()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	names, err := e.loadContainerNames()
+	if err != nil {
+		return err
+	}
+	if owner, taken := names[newName]; taken {
+		return fmt.Errorf("container name %q is already in use by container %s", newName, owner)
+	}
+	if e.containerExists(newName) {
+		return fmt.Errorf("container name %q is already in use as a container ID", newName)
+	}
+	for name, id := range names {
+		if id == containerID {
+			delete(names, name)
+		}
+	}
+	names[newName] = containerID
+	if err := e.saveContainerNames(names); err != nil {
+		return err
+	}
+	return e.updateContainerConfig(containerID, func(c *ContainerConfig) { c.Name = newName })
+}
+
+// resolveContainerID returns the ID of the container called ref, or ref
+// itself when it is not a known name, so that IDs and names can be used
+// interchangeably. IDs take precedence over names.
+func (e *Engine) resolveContainerID(ref string) string {
+	if e.containerExists(ref) {
+		return ref
+	}
+	names, err := e.loadContainerNames()
+	if err != nil {
+		e.Logger.Warn("failed to resolve container name", "name", ref, "error", err)
+		return ref
+	}
+	if id, ok := names[ref]; ok {
+		return id
+	}
+	return ref
+}
+
+// containerExists reports whether containerID is a well-formed ID of a
+// container with a config.
+func (e *Engine) containerExists(containerID string) bool {
+	if validateContainerID(containerID) != nil {
+		return false
+	}
+	_, err := os.Stat(e.containerConfigPath(containerID))
+	return err == nil
+}
@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestNewContainerIDUnique verifies that IDs created in quick succession do
+// not collide.
+func TestNewContainerIDUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := newContainerID()
+		if !strings.HasPrefix(id, "container-") {
+			t.Fatalf("Expected a container- prefix, got %s", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate container ID %s after %d IDs", id, i)
+		}
+		seen[id] = true
+	}
+}
+
+// TestReserveContainerName verifies name validation and uniqueness.
+func TestReserveContainerName(t *testing.T) {
+	e := newTestEngine(t)
+
+	if err := e.reserveContainerName("web", "container-1"); err != nil {
+		t.Fatalf("reserveContainerName failed: %v", err)
+	}
+	if err := e.reserveContainerName("web", "container-2"); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected a duplicate name error, got %v", err)
+	}
+	for _, name := range []string{"", "-web", "web/1", "a b"} {
+		if err := e.reserveContainerName(name, "container-3"); err == nil {
+			t.Errorf("Expected name %q to be rejected", name)
+		}
+	}
+
+	if err := e.releaseContainerName("container-1"); err != nil {
+		t.Fatalf("releaseContainerName failed: %v", err)
+	}
+	if err := e.reserveContainerName("web", "container-2"); err != nil {
+		t.Errorf("Expected a released name to be reusable, got %v", err)
+	}
+}
+
+// TestReserveContainerNameEngines verifies that engines sharing a root, as
+// separate processes do, never lose each other's names.
+func TestReserveContainerNameEngines(t *testing.T) {
+	e := newTestEngine(t)
+	other, err := NewEngine(e.Root)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i, engine := range []*Engine{e, other} {
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(engine *Engine, name string) {
+				defer wg.Done()
+				if err := engine.reserveContainerName(name, "container-"+name); err != nil {
+					t.Errorf("reserveContainerName failed: %v", err)
+				}
+			}(engine, fmt.Sprintf("web-%d-%d", i, j))
+		}
+	}
+	wg.Wait()
+
+	names, err := e.loadContainerNames()
+	if err != nil {
+		t.Fatalf("loadContainerNames failed: %v", err)
+	}
+	if len(names) != 20 {
+		t.Errorf("Expected 20 names, got %d: %v", len(names), names)
+	}
+}
+
+// TestResolveContainerID verifies that names resolve to IDs and that IDs and
+// unknown references are returned unchanged. Directories without a config
+// are not containers, so they do not shadow names.
+func TestResolveContainerID(t *testing.T) {
+	e := newTestEngine(t)
+	createTestContainer(t, e, &ContainerConfig{ID: "container-1"})
+	if err := os.MkdirAll(filepath.Join(e.Root, "containers", "db"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, name := range []string{"web", "db"} {
+		if err := e.reserveContainerName(name, "container-1"); err != nil {
+			t.Fatalf("reserveContainerName failed: %v", err)
+		}
+	}
+
+	cases := map[string]string{
+		"web":         "container-1",
+		"db":          "container-1",
+		"container-1": "container-1",
+		"unknown":     "unknown",
+		"..":          "..",
+	}
+	for ref, want := range cases {
+		if got := e.resolveContainerID(ref); got != want {
+			t.Errorf("resolveContainerID(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+// TestRunWithName verifies that run --name records the name, that it cannot
+// be reused while the container exists, and that rm frees it.
+func TestRunWithName(t *testing.T) {
+	e := newTestEngine(t)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	if _, err := e.parseRunArgs([]string{"--name", "bad/name", "local", "true"}); err == nil {
+		t.Error("Expected an invalid name to be rejected by parseRunArgs")
+	}
+
+	opts, err := e.parseRunArgs([]string{"--name", "web", "local", "true"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	var config *ContainerConfig
+	captureStdoutStderr(func() { config, err = e.prepareContainer(opts) })
+	if err != nil {
+		t.Fatalf("prepareContainer failed: %v", err)
+	}
+	if config.Name != "web" {
+		t.Errorf("Expected the config to record name web, got %q", config.Name)
+	}
+	if got := e.resolveContainerID("web"); got != config.ID {
+		t.Errorf("Expected web to resolve to %s, got %s", config.ID, got)
+	}
+
+	summaries, err := e.listContainerSummaries()
+	if err != nil || len(summaries) != 1 || summaries[0].Name != "web" {
+		t.Errorf("Expected one summary named web, got %+v (err %v)", summaries, err)
+	}
+
+	captureStdoutStderr(func() { _, err = e.prepareContainer(opts) })
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected a duplicate name error, got %v", err)
+	}
+
+	if err := e.removeContainer(e.resolveContainerID("web")); err != nil {
+		t.Fatalf("removeContainer failed: %v", err)
+	}
+	if got := e.resolveContainerID("web"); got != "web" {
+		t.Errorf("Expected the name to be released after rm, got %s", got)
+	}
+}
+
+// TestPrepareContainerTightLoop verifies that containers created back to back
+// get distinct directories.
+func TestPrepareContainerTightLoop(t *testing.T) {
+	e := newTestEngine(t)
+	if err := os.MkdirAll(filepath.Join(e.imageStorePath("local:latest"), "rootfs"), 0755); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	opts, err := e.parseRunArgs([]string{"local", "true"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+
+	const count = 20
+	ids := make(map[string]bool)
+	captureStdoutStderr(func() {
+		for i := 0; i < count; i++ {
+			config, err := e.prepareContainer(opts)
+			if err != nil {
+				t.Errorf("prepareContainer failed: %v", err)
+				return
+			}
+			ids[config.ID] = true
+		}
+	})
+	if len(ids) != count {
+		t.Errorf("Expected %d distinct IDs, got %d", count, len(ids))
+	}
+	entries, err := os.ReadDir(filepath.Join(e.Root, "containers"))
+	if err != nil || len(entries) != count {
+		t.Errorf("Expected %d container directories, got %d (err %v)", count, len(entries), err)
+	}
+}
+
+// TestCreateContainerDirExisting verifies that an existing container is never
+// reused for a new one.
+func TestCreateContainerDirExisting(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createContainerDir("container-1"); err != nil {
+		t.Fatalf("createContainerDir failed: %v", err)
+	}
+	if err := e.createContainerDir("container-1"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an already exists error, got %v", err)
+	}
+}
+
+// TestRenameContainer verifies that rename moves the name mapping and the
+// config to the new name and refuses names already taken.
+func TestRenameContainer(t *testing.T) {
+	e := newTestEngine(t)
+	createTestContainer(t, e, &ContainerConfig{ID: "container-1", Name: "web", Command: "sleep"})
+	createTestContainer(t, e, &ContainerConfig{ID: "container-2", Name: "db", Command: "sleep"})
+	for name, id := range map[string]string{"web": "container-1", "db": "container-2"} {
+		if err := e.reserveContainerName(name, id); err != nil {
+			t.Fatalf("reserveContainerName failed: %v", err)
+		}
+	}
+
+	if err := e.RenameContainer("web", "frontend"); err != nil {
+		t.Fatalf("RenameContainer failed: %v", err)
+	}
+	if id := e.resolveContainerID("frontend"); id != "container-1" {
+		t.Errorf("Expected frontend to resolve to container-1, got %s", id)
+	}
+	if id := e.resolveContainerID("web"); id != "web" {
+		t.Errorf("Expected the old name to be released, got %s", id)
+	}
+	config, err := e.loadContainerConfig("container-1")
+	if err != nil || config.Name != "frontend" {
+		t.Errorf("Expected the config to record the new name, got %+v, %v", config, err)
+	}
+
+	if err := e.RenameContainer("frontend", "db"); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+	if err := e.RenameContainer("frontend", "container-2"); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected a conflict with a container ID, got %v", err)
+	}
+	if err := e.RenameContainer("missing", "other"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing container error, got %v", err)
+	}
+	if err := e.RenameContainer("frontend", "bad name"); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+}
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+const networksFile = "networks.json"
+
+// Updated Network struct to include IP addresses for containers
+type Network struct {
+	Name       string
+	ID         string
+	Containers map[string]string // Map of container IDs to their IP addresses
+	// Driver is networkDriverBridge, networkDriverNone or networkDriverHost.
+	// Networks created before drivers existed have none and are bridges.
+	Driver string `json:",omitempty"`
+	// MTU of the network's devices; zero uses defaultNetworkMTU.
+	MTU int `json:",omitempty"`
+	// Labels organize networks, see network-list --filter.
+	Labels map[string]string `json:",omitempty"`
+}
+
+// NetworkOptions are the settings of a new network.
+type NetworkOptions struct {
+	Driver string
+	MTU    int
+	Labels map[string]string
+}
+
+// loadNetworks loads the networks from the JSON file
+func (e *Engine) loadNetworks() {
+	filePath := filepath.Join(e.Root, networksFile)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return // No networks file exists yet
+		}
+		fmt.Fprintf(os.Stderr, "Error loading networks: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&e.networks); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding networks: %v\n", err)
+	}
+}
+
+// saveNetworks saves the networks to the JSON file. The output is indented
+// and, as encoding/json sorts map keys, the same networks always encode to
+// the same bytes.
+func (e *Engine) saveNetworks() {
+	data, err := json.MarshalIndent(e.networks, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding networks: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(e.Root, networksFile), data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving networks: %v\n", err)
+	}
+}
+
+// CreateNetwork creates a new bridge network capsule
+func (e *Engine) CreateNetwork(name string) {
+	if err := e.CreateNetworkWithOptions(name, NetworkOptions{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// CreateNetworkWithOptions creates a new network capsule with the given
+// driver, defaulting to a bridge, and MTU.
+func (e *Engine) CreateNetworkWithOptions(name string, opts NetworkOptions) error {
+	if opts.Driver == "" {
+		opts.Driver = networkDriverBridge
+	}
+	if err := validateNetworkOptions(opts); err != nil {
+		return err
+	}
+	id := fmt.Sprintf("net-%d", len(e.networks)+1)
+	network := Network{Name: name, ID: id, Containers: make(map[string]string), Driver: opts.Driver, MTU: opts.MTU, Labels: opts.Labels}
+	if err := e.isolateNetwork(network); err != nil {
+		return err
+	}
+	e.networks = append(e.networks, network)
+
+	// Register the network as a resource capsule
+	e.capsules.AddCapsule(name, "1.0", id)
+	e.saveNetworks()
+	fmt.Printf("Network capsule %s created with ID %s\n", name, id)
+	return nil
+}
+
+// networkCreateCommand implements "network-create [--driver bridge|none|host]
+// [--mtu n] [--label key=value]... [--label-file path]... <network-name>".
+func (e *Engine) networkCreateCommand(args []string) {
+	fs := flag.NewFlagSet("network-create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var opts NetworkOptions
+	var labels, labelFiles []string
+	fs.StringVar(&opts.Driver, "driver", networkDriverBridge, "network driver: bridge, none or host")
+	fs.IntVar(&opts.MTU, "mtu", 0, "MTU of the network's devices")
+	fs.Var((*stringList)(&labels), "label", "set a key=value label on the network")
+	fs.Var((*stringList)(&labelFiles), "label-file", "read labels from a file of key=value lines")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker network-create [--driver bridge|none|host] [--mtu n] [--label key=value] [--label-file path] <network-name>")
+		os.Exit(1)
+	}
+	var err error
+	if opts.Labels, err = parseLabels(labels, labelFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := e.CreateNetworkWithOptions(fs.Arg(0), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// ListNetworks lists all networks
+func (e *Engine) ListNetworks() {
+	e.listNetworks(nil)
+}
+
+// listNetworks lists the networks whose labels match filters.
+func (e *Engine) listNetworks(filters []labelFilter) {
+	fmt.Println("Available Networks:")
+	for _, network := range e.filterNetworks(filters) {
+		fmt.Printf("- %s (ID: %s, driver: %s)\n", network.Name, network.ID, network.driver())
+	}
+}
+
+// filterNetworks returns the networks whose labels match filters.
+func (e *Engine) filterNetworks(filters []labelFilter) []Network {
+	var matched []Network
+	for _, network := range e.networks {
+		if matchLabels(network.Labels, filters) {
+			matched = append(matched, network)
+		}
+	}
+	return matched
+}
+
+// networkListCommand implements "network-list [--filter label=key[=value]]...".
+func (e *Engine) networkListCommand(args []string) {
+	fs := flag.NewFlagSet("network-list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var filterArgs []string
+	fs.Var((*stringList)(&filterArgs), "filter", "only list networks with a label, label=key or label=key=value")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker network-list [--filter label=key[=value]]")
+		os.Exit(1)
+	}
+	filters, err := parseLabelFilters(filterArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	e.listNetworks(filters)
+}
+
+// DeleteNetwork deletes a network by ID
+func (e *Engine) DeleteNetwork(id string) {
+	for i, network := range e.networks {
+		if network.ID == id {
+			e.removeNetworkDevices(network)
+			e.networks = append(e.networks[:i], e.networks[i+1:]...)
+			e.removeNetworkIsolation(network)
+			e.saveNetworks()
+			fmt.Printf("Network with ID %s deleted\n", id)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Network with ID %s not found\n", id)
+}
+
+// RenameNetwork renames the network with the given ID or name. Network names
+// must stay unique, and may not shadow the ID of another network.
+func (e *Engine) RenameNetwork(nameOrID, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("network name must not be empty")
+	}
+	i, err := e.findNetwork(nameOrID)
+	if err != nil {
+		return err
+	}
+	for j, network := range e.networks {
+		if j != i && (network.Name == newName || network.ID == newName) {
+			return fmt.Errorf("network name %q is already in use by network %s", newName, network.ID)
+		}
+	}
+	e.networks[i].Name = newName
+	e.saveNetworks()
+	return nil
+}
+
+// InspectNetwork writes a network, found by ID or name, as JSON or with
+// tmpl when it is set.
+func (e *Engine) InspectNetwork(w io.Writer, nameOrID string, tmpl *template.Template) error {
+	i, err := e.findNetwork(nameOrID)
+	if err != nil {
+		return err
+	}
+	if tmpl != nil {
+		return writeFormatted(w, tmpl, e.networks[i])
+	}
+	data, err := json.MarshalIndent(e.networks[i], "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode network %s: %v", nameOrID, err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// networkInspectCommand implements "network-inspect [--format template]
+// <network>".
+func (e *Engine) networkInspectCommand(args []string) {
+	fs := flag.NewFlagSet("network-inspect", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "Go template to print the network with")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker network-inspect [--format template] <network>")
+		os.Exit(1)
+	}
+	var tmpl *template.Template
+	if *format != "" {
+		var err error
+		if tmpl, err = parseFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := e.InspectNetwork(os.Stdout, fs.Arg(0), tmpl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// findNetwork returns the index of the network with the given ID or, failing
+// that, name.
+func (e *Engine) findNetwork(nameOrID string) (int, error) {
+	for i, network := range e.networks {
+		if network.ID == nameOrID {
+			return i, nil
+		}
+	}
+	for i, network := range e.networks {
+		if network.Name == nameOrID {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("network %s not found", nameOrID)
+}
+
+// connectContainer attaches a container to a network and returns the IP
+// address assigned to it.
+func (e *Engine) connectContainer(networkID, containerID string) (string, error) {
+	for i, network := range e.networks {
+		if network.ID == networkID {
+			// Check if the container is already attached
+			if _, exists := network.Containers[containerID]; exists {
+				return "", errors.New("container is already attached to the network")
+			}
+
+			// Assign an IP address to the container
+			ipAddress := fmt.Sprintf("192.168.%d.%d", i+1, len(network.Containers)+2)
+			e.networks[i].Containers[containerID] = ipAddress
+			e.saveNetworks()
+			e.emitEvent(eventConnect, containerID, map[string]string{"network": networkID, "ip": ipAddress})
+			return ipAddress, nil
+		}
+	}
+	return "", errors.New("network not found")
+}
+
+// disconnectContainer detaches a container from a network.
+func (e *Engine) disconnectContainer(networkID, containerID string) error {
+	for i, network := range e.networks {
+		if network.ID == networkID {
+			// Find and remove the container
+			if _, exists := network.Containers[containerID]; exists {
+				delete(e.networks[i].Containers, containerID)
+				e.saveNetworks()
+				e.emitEvent(eventDisconnect, containerID, map[string]string{"network": networkID})
+				return nil
+			}
+			return errors.New("container not found in the network")
+		}
+	}
+	return errors.New("network not found")
+}
+
+// Updated AttachContainerToNetwork to assign IP addresses
+func (e *Engine) AttachContainerToNetwork(networkID, containerID string) error {
+	ipAddress, err := e.connectContainer(networkID, containerID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Container %s attached to network %s with IP %s\n", containerID, networkID, ipAddress)
+	return nil
+}
+
+// DetachContainerFromNetwork detaches a container from a network capsule
+func (e *Engine) DetachContainerFromNetwork(networkID, containerID string) error {
+	if err := e.disconnectContainer(networkID, containerID); err != nil {
+		return err
+	}
+	fmt.Printf("Container %s detached from network %s\n", containerID, networkID)
+	return nil
+}
+
+// New Ping function to test connectivity between containers
+func (e *Engine) Ping(networkID, sourceContainerID, targetContainerID string) error {
+	for _, network := range e.networks {
+		if network.ID == networkID {
+			sourceIP, sourceExists := network.Containers[sourceContainerID]
+			targetIP, targetExists := network.Containers[targetContainerID]
+
+			if !sourceExists || !targetExists {
+				return errors.New("one or both containers are not in the network")
+			}
+
+			fmt.Printf("Pinging from %s to %s: Success\n", sourceIP, targetIP)
+			return nil
+		}
+	}
+	return errors.New("network not found")
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Network drivers. A bridge network connects its containers through a
+// bridge on the host, none leaves them with only a loopback device, and host
+// containers share the network namespace of the host.
+const (
+	networkDriverBridge = "bridge"
+	networkDriverNone   = "none"
+	networkDriverHost   = "host"
+)
+
+// defaultNetworkMTU is the MTU of devices of networks created without one.
+const defaultNetworkMTU = 1500
+
+// containerInterface is the name of a bridge network's device inside a
+// container.
+const containerInterface = "eth0"
+
+// driver returns the driver of a network, treating networks created before
+// drivers existed as bridges.
+func (n Network) driver() string {
+	if n.Driver == "" {
+		return networkDriverBridge
+	}
+	return n.Driver
+}
+
+// mtu returns the MTU of a network's devices.
+func (n Network) mtu() int {
+	if n.MTU == 0 {
+		return defaultNetworkMTU
+	}
+	return n.MTU
+}
+
+// validateNetworkOptions checks the driver and MTU of a new network.
+func validateNetworkOptions(opts NetworkOptions) error {
+	switch opts.Driver {
+	case networkDriverBridge, networkDriverNone, networkDriverHost:
+	default:
+		return fmt.Errorf("unknown network driver %q, expected bridge, none or host", opts.Driver)
+	}
+	// 68 is the minimum MTU of IPv4
+	if opts.MTU != 0 && (opts.MTU < 68 || opts.MTU > 65535) {
+		return fmt.Errorf("invalid MTU %d, expected a value between 68 and 65535", opts.MTU)
+	}
+	return nil
+}
+
+// networkCommand runs "ip" with args, inside the network namespace of pid
+// when it is not zero. Tests replace it to record the privileged calls.
+var networkCommand = func(pid int, args ...string) error {
+	cmd := exec.Command("ip", args...)
+	if pid != 0 {
+		cmd = exec.Command("nsenter", append([]string{"--net=/proc/" + strconv.Itoa(pid) + "/ns/net", "ip"}, args...)...)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ip %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// interfaceExists reports whether the host has a network device. Tests
+// replace it.
+var interfaceExists = func(name string) bool {
+	_, err := net.InterfaceByName(name)
+	return err == nil
+}
+
+// bridgeName returns the host bridge of a network. Device names are limited
+// to 15 characters.
+func bridgeName(networkID string) string {
+	name := "br-" + networkID
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	return name
+}
+
+// vethName returns the host end of a container's veth pair.
+func vethName(containerID string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(containerID))
+	return fmt.Sprintf("veth%08x", hash.Sum32())
+}
+
+// containerNetworkSetup returns whether a container gets its own network
+// namespace and, for bridge networks, the function connecting that namespace
+// to the network's bridge once the container process exists. Containers
+// without a network keep the engine's default of a namespace when it is
+// permitted.
+func (e *Engine) containerNetworkSetup(containerID string) (bool, func(pid int) error) {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil || config.Network == "" {
+		return e.Namespaces, nil
+	}
+	e.loadNetworks()
+	i, err := e.findNetwork(config.Network)
+	if err != nil {
+		e.Logger.Warn("network of container not found", "container", containerID, "network", config.Network)
+		return e.Namespaces, nil
+	}
+	network := e.networks[i]
+	switch network.driver() {
+	case networkDriverHost:
+		return false, nil
+	case networkDriverNone:
+		return e.Namespaces, nil
+	}
+	if !e.Namespaces {
+		return false, nil
+	}
+	ip := network.Containers[containerID]
+	return true, func(pid int) error {
+		return attachToBridge(network, containerID, ip, pid)
+	}
+}
+
+// attachToBridge connects the network namespace of pid to the bridge of a
+// network through a veth pair, creating the bridge on first use, and gives
+// the container's end the address ip. All devices get the network's MTU.
+func attachToBridge(network Network, containerID, ip string, pid int) error {
+	bridge := bridgeName(network.ID)
+	mtu := strconv.Itoa(network.mtu())
+	veth := vethName(containerID)
+
+	var commands [][]string
+	if !interfaceExists(bridge) {
+		commands = append(commands,
+			[]string{"link", "add", bridge, "mtu", mtu, "type", "bridge"},
+			[]string{"link", "set", bridge, "up"})
+	}
+	commands = append(commands,
+		[]string{"link", "add", veth, "mtu", mtu, "type", "veth", "peer", "name", containerInterface, "mtu", mtu, "netns", strconv.Itoa(pid)},
+		[]string{"link", "set", veth, "master", bridge, "up"})
+	for _, args := range commands {
+		if err := networkCommand(0, args...); err != nil {
+			return fmt.Errorf("failed to connect container %s to network %s: %v", containerID, network.Name, err)
+		}
+	}
+
+	inside := [][]string{{"link", "set", "lo", "up"}, {"link", "set", containerInterface, "up"}}
+	if ip != "" {
+		inside = append(inside, []string{"addr", "add", ip + "/24", "dev", containerInterface})
+	}
+	for _, args := range inside {
+		if err := networkCommand(pid, args...); err != nil {
+			return fmt.Errorf("failed to configure network of container %s: %v", containerID, err)
+		}
+	}
+	return nil
+}
+
+// removeNetworkDevices deletes the bridge of a network if it was created.
+func (e *Engine) removeNetworkDevices(network Network) {
+	bridge := bridgeName(network.ID)
+	if network.driver() != networkDriverBridge || !interfaceExists(bridge) {
+		return
+	}
+	if err := networkCommand(0, "link", "delete", bridge); err != nil {
+		e.Logger.Warn("failed to remove network bridge", "network", network.ID, "error", err)
+	}
+}
+
+// chainOnStart combines hooks run once a container process exists, skipping
+// nil ones.
+func chainOnStart(hooks ...func(pid int) error) func(pid int) error {
+	var chained []func(pid int) error
+	for _, hook := range hooks {
+		if hook != nil {
+			chained = append(chained, hook)
+		}
+	}
+	if len(chained) == 0 {
+		return nil
+	}
+	return func(pid int) error {
+		for _, hook := range chained {
+			if err := hook(pid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestNetworkDriverPersistence checks the driver and MTU of a network survive
+// a reload and that invalid options are rejected
+func TestNetworkDriverPersistence(t *testing.T) {
+	e := newTestEngine(t)
+	captureOutput(func() {
+		if err := e.CreateNetworkWithOptions("jumbo", NetworkOptions{MTU: 9000}); err != nil {
+			t.Fatalf("CreateNetworkWithOptions failed: %v", err)
+		}
+		if err := e.CreateNetworkWithOptions("shared", NetworkOptions{Driver: networkDriverHost}); err != nil {
+			t.Fatalf("CreateNetworkWithOptions failed: %v", err)
+		}
+	})
+
+	e.networks = nil
+	e.loadNetworks()
+	if len(e.networks) != 2 {
+		t.Fatalf("Expected 2 networks after reload, got %d", len(e.networks))
+	}
+	if e.networks[0].Driver != networkDriverBridge || e.networks[0].MTU != 9000 {
+		t.Errorf("Expected a bridge with MTU 9000, got %+v", e.networks[0])
+	}
+	if e.networks[1].Driver != networkDriverHost || e.networks[1].mtu() != defaultNetworkMTU {
+		t.Errorf("Expected a host network with the default MTU, got %+v", e.networks[1])
+	}
+	if (Network{}).driver() != networkDriverBridge {
+		t.Error("Expected networks without a driver to be bridges")
+	}
+
+	for _, opts := range []NetworkOptions{{Driver: "overlay"}, {MTU: 10}, {MTU: 70000}} {
+		if err := e.CreateNetworkWithOptions("bad", opts); err == nil {
+			t.Errorf("Expected options %+v to be rejected", opts)
+		}
+	}
+	if len(e.networks) != 2 {
+		t.Errorf("Expected rejected networks not to be created, got %d networks", len(e.networks))
+	}
+}
+
+// TestContainerNetworkSetup checks the namespace and device setup of each
+// driver, recording the privileged calls instead of running them
+func TestContainerNetworkSetup(t *testing.T) {
+	e := newTestEngine(t)
+	oldCommand, oldExists, oldIptables := networkCommand, interfaceExists, iptablesCommand
+	t.Cleanup(func() {
+		networkCommand, interfaceExists, iptablesCommand = oldCommand, oldExists, oldIptables
+	})
+	e.Namespaces = true
+	iptablesCommand = func(args ...string) error { return nil }
+	var calls []string
+	networkCommand = func(pid int, args ...string) error {
+		calls = append(calls, fmt.Sprintf("%d: %s", pid, strings.Join(args, " ")))
+		return nil
+	}
+	bridges := map[string]bool{}
+	interfaceExists = func(name string) bool { return bridges[name] }
+
+	captureOutput(func() {
+		for name, opts := range map[string]NetworkOptions{
+			"br":       {Driver: networkDriverBridge, MTU: 1400},
+			"host":     {Driver: networkDriverHost},
+			"isolated": {Driver: networkDriverNone},
+		} {
+			if err := e.CreateNetworkWithOptions(name, opts); err != nil {
+				t.Fatalf("CreateNetworkWithOptions failed: %v", err)
+			}
+		}
+	})
+	attach := func(containerID, network string) {
+		i, err := e.findNetwork(network)
+		if err != nil {
+			t.Fatalf("findNetwork failed: %v", err)
+		}
+		createTestContainer(t, e, &ContainerConfig{ID: containerID, Network: e.networks[i].ID})
+		if _, err := e.connectContainer(e.networks[i].ID, containerID); err != nil {
+			t.Fatalf("connectContainer failed: %v", err)
+		}
+	}
+	attach("on-host", "host")
+	attach("on-none", "isolated")
+	attach("on-bridge", "br")
+
+	if newNetNS, setup := e.containerNetworkSetup("on-host"); newNetNS || setup != nil {
+		t.Errorf("Expected host containers to share the host namespace, got %v", newNetNS)
+	}
+	if newNetNS, setup := e.containerNetworkSetup("on-none"); !newNetNS || setup != nil {
+		t.Errorf("Expected none containers to get a bare namespace, got %v", newNetNS)
+	}
+	newNetNS, setup := e.containerNetworkSetup("on-bridge")
+	if !newNetNS || setup == nil {
+		t.Fatalf("Expected bridge containers to get a connected namespace, got %v", newNetNS)
+	}
+	if err := setup(4242); err != nil {
+		t.Fatalf("Bridge setup failed: %v", err)
+	}
+	i, _ := e.findNetwork("br")
+	bridge, veth, ip := bridgeName(e.networks[i].ID), vethName("on-bridge"), e.networks[i].Containers["on-bridge"]
+	want := []string{
+		"0: link add " + bridge + " mtu 1400 type bridge",
+		"0: link set " + bridge + " up",
+		"0: link add " + veth + " mtu 1400 type veth peer name eth0 mtu 1400 netns 4242",
+		"0: link set " + veth + " master " + bridge + " up",
+		"4242: link set lo up",
+		"4242: link set eth0 up",
+		"4242: addr add " + ip + "/24 dev eth0",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected network calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	// An existing bridge is reused
+	calls = nil
+	bridges[bridge] = true
+	if err := setup(4243); err != nil {
+		t.Fatalf("Bridge setup failed: %v", err)
+	}
+	if len(calls) == 0 || strings.Contains(calls[0], "type bridge") {
+		t.Errorf("Expected the existing bridge to be reused, got %v", calls)
+	}
+
+	e.Namespaces = false
+	if newNetNS, setup := e.containerNetworkSetup("on-bridge"); newNetNS || setup != nil {
+		t.Error("Expected no network namespace without privileges")
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// isolationChain is the iptables chain, jumped to from FORWARD, holding the
+// rules that keep bridge networks apart.
+const isolationChain = "BASIC-DOCKER-ISOLATION"
+
+// iptablesCommand runs "iptables" with args. Tests replace it to record the
+// privileged calls.
+var iptablesCommand = func(args ...string) error {
+	if output, err := exec.Command("iptables", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("iptables %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// iptablesAvailable reports whether the host has iptables. Tests replace it.
+var iptablesAvailable = func() bool {
+	_, err := exec.LookPath("iptables")
+	return err == nil
+}
+
+// isolationRules returns the rules of a bridge network: traffic within its
+// bridge is accepted, and traffic between its bridge and the bridge of any
+// other bridge network is dropped in both directions.
+func isolationRules(network Network, others []Network) [][]string {
+	bridge := bridgeName(network.ID)
+	rules := [][]string{{"-i", bridge, "-o", bridge, "-j", "ACCEPT"}}
+	for _, other := range others {
+		if other.ID == network.ID || other.driver() != networkDriverBridge {
+			continue
+		}
+		peer := bridgeName(other.ID)
+		rules = append(rules,
+			[]string{"-i", bridge, "-o", peer, "-j", "DROP"},
+			[]string{"-i", peer, "-o", bridge, "-j", "DROP"})
+	}
+	return rules
+}
+
+// ensureIsolationChain creates the isolation chain and the FORWARD rule
+// jumping to it unless they exist.
+func ensureIsolationChain() error {
+	if iptablesCommand("-n", "-L", isolationChain) != nil {
+		if err := iptablesCommand("-N", isolationChain); err != nil {
+			return err
+		}
+	}
+	if iptablesCommand("-C", "FORWARD", "-j", isolationChain) != nil {
+		if err := iptablesCommand("-I", "FORWARD", "-j", isolationChain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforcesIsolation reports whether the rules of a network are managed.
+// Without privileges there are no bridges to isolate.
+func (e *Engine) enforcesIsolation(network Network) bool {
+	if !e.Namespaces || network.driver() != networkDriverBridge {
+		return false
+	}
+	if !iptablesAvailable() {
+		e.Logger.Warn("iptables not found, bridge networks are not isolated", "network", network.ID)
+		return false
+	}
+	return true
+}
+
+// isolateNetwork adds the isolation rules of a new bridge network against
+// the existing networks.
+func (e *Engine) isolateNetwork(network Network) error {
+	if !e.enforcesIsolation(network) {
+		return nil
+	}
+	if err := ensureIsolationChain(); err != nil {
+		return fmt.Errorf("failed to isolate network %s: %v", network.Name, err)
+	}
+	for _, rule := range isolationRules(network, e.networks) {
+		if err := iptablesCommand(append([]string{"-A", isolationChain}, rule...)...); err != nil {
+			return fmt.Errorf("failed to isolate network %s: %v", network.Name, err)
+		}
+	}
+	return nil
+}
+
+// removeNetworkIsolation deletes the isolation rules of a bridge network
+// against the remaining networks.
+func (e *Engine) removeNetworkIsolation(network Network) {
+	if !e.enforcesIsolation(network) {
+		return
+	}
+	for _, rule := range isolationRules(network, e.networks) {
+		if err := iptablesCommand(append([]string{"-D", isolationChain}, rule...)...); err != nil {
+			e.Logger.Warn("failed to remove network isolation rule", "network", network.ID, "error", err)
+		}
+	}
+}
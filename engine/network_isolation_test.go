@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestIsolationRules checks the rules of two bridge networks accept traffic
+// within each bridge and drop it between them, ignoring other drivers
+func TestIsolationRules(t *testing.T) {
+	front := Network{ID: "net-1", Driver: networkDriverBridge}
+	back := Network{ID: "net-2"}
+	host := Network{ID: "net-3", Driver: networkDriverHost}
+
+	want := [][]string{
+		{"-i", "br-net-2", "-o", "br-net-2", "-j", "ACCEPT"},
+		{"-i", "br-net-2", "-o", "br-net-1", "-j", "DROP"},
+		{"-i", "br-net-1", "-o", "br-net-2", "-j", "DROP"},
+	}
+	if got := isolationRules(back, []Network{front, back, host}); !reflect.DeepEqual(got, want) {
+		t.Errorf("isolationRules = %q, want %q", got, want)
+	}
+	if got := isolationRules(front, nil); len(got) != 1 {
+		t.Errorf("Expected only the intra-bridge rule for a lone network, got %q", got)
+	}
+}
+
+// TestNetworkIsolationLifecycle checks the rules are added when a bridge
+// network is created and removed with it, and that nothing runs without
+// privileges
+func TestNetworkIsolationLifecycle(t *testing.T) {
+	e := newTestEngine(t)
+	oldIptables, oldAvailable := iptablesCommand, iptablesAvailable
+	t.Cleanup(func() {
+		iptablesCommand, iptablesAvailable = oldIptables, oldAvailable
+	})
+	iptablesAvailable = func() bool { return true }
+	var calls []string
+	iptablesCommand = func(args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		// The chain and the FORWARD jump do not exist yet
+		if args[0] == "-n" || args[0] == "-C" {
+			return errors.New("no such chain")
+		}
+		return nil
+	}
+
+	e.Namespaces = false
+	captureOutput(func() { e.CreateNetwork("unprivileged") })
+	if len(calls) != 0 {
+		t.Fatalf("Expected no iptables calls without privileges, got %q", calls)
+	}
+	e.networks = []Network{}
+
+	e.Namespaces = true
+	captureOutput(func() {
+		e.CreateNetwork("front")
+		e.CreateNetwork("back")
+	})
+	want := []string{
+		"-n -L " + isolationChain,
+		"-N " + isolationChain,
+		"-C FORWARD -j " + isolationChain,
+		"-I FORWARD -j " + isolationChain,
+		"-A " + isolationChain + " -i br-net-1 -o br-net-1 -j ACCEPT",
+		"-n -L " + isolationChain,
+		"-N " + isolationChain,
+		"-C FORWARD -j " + isolationChain,
+		"-I FORWARD -j " + isolationChain,
+		"-A " + isolationChain + " -i br-net-2 -o br-net-2 -j ACCEPT",
+		"-A " + isolationChain + " -i br-net-2 -o br-net-1 -j DROP",
+		"-A " + isolationChain + " -i br-net-1 -o br-net-2 -j DROP",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("iptables calls on create = %q, want %q", calls, want)
+	}
+
+	calls = nil
+	captureOutput(func() { e.DeleteNetwork("net-1") })
+	want = []string{
+		"-D " + isolationChain + " -i br-net-1 -o br-net-1 -j ACCEPT",
+		"-D " + isolationChain + " -i br-net-1 -o br-net-2 -j DROP",
+		"-D " + isolationChain + " -i br-net-2 -o br-net-1 -j DROP",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("iptables calls on delete = %q, want %q", calls, want)
+	}
+}
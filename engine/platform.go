@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Platform identifies the OS and CPU architecture an image is built for, as
+// listed in the entries of a manifest list.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform in os/arch[/variant] form.
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// hostPlatform returns the platform the engine runs on.
+func hostPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+}
+
+// parsePlatform parses a --platform value of the form os/arch[/variant].
+func parsePlatform(value string) (Platform, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, fmt.Errorf("invalid platform %q: expected os/arch[/variant]", value)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return Platform{}, fmt.Errorf("invalid platform %q: empty component", value)
+		}
+		for _, c := range part {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+				return Platform{}, fmt.Errorf("invalid platform %q: components may only contain lowercase letters, digits and _", value)
+			}
+		}
+	}
+	platform := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// matches reports whether an entry of a manifest list is for p. A platform
+// without a variant matches any variant of its architecture.
+func (p Platform) matches(entry Platform) bool {
+	return p.OS == entry.OS && p.Architecture == entry.Architecture &&
+		(p.Variant == "" || p.Variant == entry.Variant)
+}
+
+// selectPlatformManifest returns the digest of the manifest for platform in
+// a manifest list.
+func selectPlatformManifest(list *Manifest, platform Platform) (string, error) {
+	available := make([]string, 0, len(list.Manifests))
+	for _, entry := range list.Manifests {
+		if platform.matches(entry.Platform) {
+			return entry.Digest, nil
+		}
+		available = append(available, entry.Platform.String())
+	}
+	return "", fmt.Errorf("no manifest for platform %s, available: %s", platform, strings.Join(available, ", "))
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	valid := map[string]Platform{
+		"linux/amd64":    {OS: "linux", Architecture: "amd64"},
+		"linux/arm64/v8": {OS: "linux", Architecture: "arm64", Variant: "v8"},
+	}
+	for value, want := range valid {
+		got, err := parsePlatform(value)
+		if err != nil || got != want {
+			t.Errorf("parsePlatform(%q) = %+v, %v, want %+v", value, got, err, want)
+		}
+		if got.String() != value {
+			t.Errorf("Expected %+v to print as %q, got %q", got, value, got.String())
+		}
+	}
+	for _, value := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/extra", "Linux/AMD64", "linux/amd 64"} {
+		if _, err := parsePlatform(value); err == nil {
+			t.Errorf("Expected parsePlatform(%q) to fail", value)
+		}
+	}
+}
+
+// TestPullPlatformSelectsManifest pulls from a manifest list with an amd64
+// and an arm64 image and checks the requested one is extracted
+func TestPullPlatformSelectsManifest(t *testing.T) {
+	e := newTestEngine(t)
+	layers := map[string]string{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		data, err := os.ReadFile(writeTestTar(t, map[string]string{"arch": arch}))
+		if err != nil {
+			t.Fatalf("Failed to read layer: %v", err)
+		}
+		layers[arch] = string(data)
+	}
+
+	var accept string
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v2/library/multi/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Write([]byte(`{"manifests": [
+			{"digest": "sha256:amd64manifest", "platform": {"os": "linux", "architecture": "amd64"}},
+			{"digest": "sha256:arm64manifest", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}}
+		]}`))
+	})
+	for arch := range layers {
+		handler.HandleFunc("/v2/library/multi/manifests/sha256:"+arch+"manifest", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"layers": [{"digest": "sha256:` + arch + `layer"}]}`))
+		})
+		handler.HandleFunc("/v2/library/multi/blobs/sha256:"+arch+"layer", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(layers[arch]))
+		})
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	registry := &DockerHubRegistry{BaseURL: server.URL + "/v2/"}
+
+	image, err := e.PullPlatform(registry, "library/multi", Platform{OS: "linux", Architecture: "arm64"})
+	if err != nil {
+		t.Fatalf("PullPlatform failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(image.RootFS, "arch")); err != nil || string(data) != "arm64" {
+		t.Errorf("Expected the arm64 image to be pulled, got %q, %v", data, err)
+	}
+	if !strings.Contains(accept, "application/vnd.docker.distribution.manifest.list.v2+json") {
+		t.Errorf("Expected manifest lists to be accepted, got Accept %q", accept)
+	}
+
+	_, err = e.PullPlatform(registry, "library/multi", Platform{OS: "linux", Architecture: "arm64", Variant: "v7"})
+	if err == nil || !strings.Contains(err.Error(), "linux/amd64, linux/arm64/v8") {
+		t.Errorf("Expected an error listing the available platforms, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PortMapping publishes a container port on the host.
+type PortMapping struct {
+	HostIP        string `json:"hostIP,omitempty"`
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+func (m PortMapping) String() string {
+	host := m.HostIP
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return fmt.Sprintf("%s:%d->%d/%s", host, m.HostPort, m.ContainerPort, m.Protocol)
+}
+
+// stringList is a flag.Value collecting repeated flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parsePortNumber parses a port in the range 1-65535.
+func parsePortNumber(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return port, nil
+}
+
+// splitProtocol splits "80/tcp" into the port and protocol, defaulting to tcp.
+func splitProtocol(spec string) (string, string, error) {
+	port, proto, found := strings.Cut(spec, "/")
+	if !found {
+		return port, "tcp", nil
+	}
+	if proto != "tcp" {
+		return "", "", fmt.Errorf("unsupported protocol %q in %q, only tcp is supported", proto, spec)
+	}
+	return port, proto, nil
+}
+
+// parsePortSpec parses a -p value of the form
+// [hostIP:][hostPort:]containerPort[/protocol]. A missing host port is
+// allocated when the ports are published.
+func parsePortSpec(spec string) (PortMapping, error) {
+	ports, proto, err := splitProtocol(spec)
+	if err != nil {
+		return PortMapping{}, err
+	}
+	mapping := PortMapping{Protocol: proto}
+
+	parts := strings.Split(ports, ":")
+	var hostPort string
+	switch len(parts) {
+	case 1:
+	case 2:
+		hostPort = parts[0]
+	case 3:
+		mapping.HostIP = parts[0]
+		hostPort = parts[1]
+		if net.ParseIP(mapping.HostIP) == nil {
+			return PortMapping{}, fmt.Errorf("invalid host IP %q in %q", mapping.HostIP, spec)
+		}
+	default:
+		return PortMapping{}, fmt.Errorf("invalid port mapping %q", spec)
+	}
+
+	if mapping.ContainerPort, err = parsePortNumber(parts[len(parts)-1]); err != nil {
+		return PortMapping{}, err
+	}
+	if hostPort != "" {
+		if mapping.HostPort, err = parsePortNumber(hostPort); err != nil {
+			return PortMapping{}, err
+		}
+	}
+	return mapping, nil
+}
+
+// allocateHostPort returns a free host port chosen by the kernel.
+func allocateHostPort(hostIP string) (int, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(hostIP, "0"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate host port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// resolvePortMappings combines the -p specs with, when publishAll is set, the
+// ports exposed by the image config. Exposed ports that are already published
+// explicitly are skipped. Host ports left unset are allocated.
+func (e *Engine) resolvePortMappings(specs []string, publishAll bool, exposed map[string]struct{}) ([]PortMapping, error) {
+	var mappings []PortMapping
+	published := map[int]bool{}
+	for _, spec := range specs {
+		mapping, err := parsePortSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+		published[mapping.ContainerPort] = true
+	}
+
+	if publishAll {
+		keys := make([]string, 0, len(exposed))
+		for key := range exposed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			port, proto, err := splitProtocol(key)
+			if err != nil {
+				e.Logger.Warn("skipping exposed port", "port", key, "error", err)
+				continue
+			}
+			containerPort, err := parsePortNumber(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exposed port in image config: %v", err)
+			}
+			if published[containerPort] {
+				continue
+			}
+			mappings = append(mappings, PortMapping{ContainerPort: containerPort, Protocol: proto})
+			published[containerPort] = true
+		}
+	}
+
+	for i := range mappings {
+		if mappings[i].HostPort == 0 {
+			port, err := allocateHostPort(mappings[i].HostIP)
+			if err != nil {
+				return nil, err
+			}
+			mappings[i].HostPort = port
+		}
+	}
+	return mappings, nil
+}
+
+// portForwarder proxies connections from published host ports to the
+// container.
+type portForwarder struct {
+	engine    *Engine
+	listeners []net.Listener
+	wg        sync.WaitGroup
+}
+
+// startPortForwarding listens on each mapping's host port and forwards
+// connections to the container port on targetHost.
+func (e *Engine) startPortForwarding(mappings []PortMapping, targetHost string) (*portForwarder, error) {
+	pf := &portForwarder{engine: e}
+	for _, mapping := range mappings {
+		listener, err := net.Listen("tcp", net.JoinHostPort(mapping.HostIP, strconv.Itoa(mapping.HostPort)))
+		if err != nil {
+			pf.Close()
+			return nil, fmt.Errorf("failed to publish port %s: %v", mapping, err)
+		}
+		pf.listeners = append(pf.listeners, listener)
+
+		target := net.JoinHostPort(targetHost, strconv.Itoa(mapping.ContainerPort))
+		pf.wg.Add(1)
+		go pf.serve(listener, target)
+	}
+	return pf, nil
+}
+
+func (pf *portForwarder) serve(listener net.Listener, target string) {
+	defer pf.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go pf.engine.proxyConn(conn, target)
+	}
+}
+
+// proxyConn copies data in both directions between conn and target.
+func (e *Engine) proxyConn(conn net.Conn, target string) {
+	defer conn.Close()
+	backend, err := net.Dial("tcp", target)
+	if err != nil {
+		e.Logger.Debug("failed to reach container port", "target", target, "error", err)
+		return
+	}
+	defer backend.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backend, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, backend)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Close stops listening on all published ports.
+func (pf *portForwarder) Close() error {
+	for _, listener := range pf.listeners {
+		listener.Close()
+	}
+	pf.wg.Wait()
+	return nil
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestParsePortSpec verifies the accepted -p forms
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want PortMapping
+	}{
+		{"80", PortMapping{ContainerPort: 80, Protocol: "tcp"}},
+		{"8080:80", PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+		{"127.0.0.1:8080:80/tcp", PortMapping{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+		{"127.0.0.1::80", PortMapping{HostIP: "127.0.0.1", ContainerPort: 80, Protocol: "tcp"}},
+	}
+	for _, tt := range tests {
+		got, err := parsePortSpec(tt.spec)
+		if err != nil {
+			t.Errorf("parsePortSpec(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePortSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "abc", "0", "70000:80", "1:2:3:4", "bad-ip:80:80", "53/udp"} {
+		if _, err := parsePortSpec(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+// TestPublishAllExposedPorts verifies that -P allocates a host port for each
+// port exposed by the image config and that the mapping is recorded.
+func TestPublishAllExposedPorts(t *testing.T) {
+	e := newTestEngine(t)
+	imageName := "exposed-test:latest"
+	imageDir := e.imageStorePath(imageName)
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		t.Fatalf("Failed to create image directory: %v", err)
+	}
+	defer os.RemoveAll(imageDir)
+	stubConfig := `{"config": {"ExposedPorts": {"80/tcp": {}}}}`
+	if err := os.WriteFile(filepath.Join(imageDir, imageConfigFile), []byte(stubConfig), 0644); err != nil {
+		t.Fatalf("Failed to write image config: %v", err)
+	}
+
+	imageConfig, err := e.loadImageConfig(imageName)
+	if err != nil {
+		t.Fatalf("loadImageConfig failed: %v", err)
+	}
+
+	mappings, err := e.resolvePortMappings(nil, false, imageConfig.Config.ExposedPorts)
+	if err != nil || len(mappings) != 0 {
+		t.Fatalf("Expected no mappings without -P, got %v (%v)", mappings, err)
+	}
+
+	mappings, err = e.resolvePortMappings(nil, true, imageConfig.Config.ExposedPorts)
+	if err != nil {
+		t.Fatalf("resolvePortMappings failed: %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].ContainerPort != 80 || mappings[0].Protocol != "tcp" || mappings[0].HostPort == 0 {
+		t.Fatalf("Expected 80/tcp published on an allocated host port, got %+v", mappings)
+	}
+
+	containerID := "test-publish-all"
+	createTestContainer(t, e, &ContainerConfig{ID: containerID, Image: imageName, Ports: mappings})
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		t.Fatalf("loadContainerConfig failed: %v", err)
+	}
+	if len(config.Ports) != 1 || config.Ports[0] != mappings[0] {
+		t.Errorf("Expected recorded mapping %+v, got %+v", mappings[0], config.Ports)
+	}
+
+	// An explicit -p for the exposed port takes precedence over -P
+	mappings, err = e.resolvePortMappings([]string{"18080:80"}, true, imageConfig.Config.ExposedPorts)
+	if err != nil {
+		t.Fatalf("resolvePortMappings failed: %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].HostPort != 18080 {
+		t.Errorf("Expected only the explicit mapping, got %+v", mappings)
+	}
+}
+
+// TestPortForwarding verifies that connections to a published host port reach
+// the container port.
+func TestPortForwarding(t *testing.T) {
+	e := newTestEngine(t)
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start backend: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte("echo: " + line))
+	}()
+
+	containerPort := strconv.Itoa(backend.Addr().(*net.TCPAddr).Port)
+	mappings, err := e.resolvePortMappings([]string{"127.0.0.1::" + containerPort}, false, nil)
+	if err != nil {
+		t.Fatalf("resolvePortMappings failed: %v", err)
+	}
+
+	forwarder, err := e.startPortForwarding(mappings, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("startPortForwarding failed: %v", err)
+	}
+	defer forwarder.Close()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(mappings[0].HostPort)))
+	if err != nil {
+		t.Fatalf("Failed to connect to published port: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("hello\n"))
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || reply != "echo: hello\n" {
+		t.Errorf("Unexpected reply %q (%v)", reply, err)
+	}
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// layerReferences counts the references to each layer directory. Layers are
+// referenced by the metadata recorded when they are created, so a layer
+// directory without a record, such as one left behind by an interrupted
+// build, is orphaned.
+func (e *Engine) layerReferences() (map[string]int, error) {
+	layers, err := e.ListLayers()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]int)
+	for _, layer := range layers {
+		refs[layer.ID]++
+		for _, path := range []string{layer.BaseLayerPath, layer.AppLayerPath} {
+			if path != "" && filepath.Base(path) != layer.ID {
+				refs[filepath.Base(path)]++
+			}
+		}
+	}
+	return refs, nil
+}
+
+// unreferencedLayers returns the layer directories no metadata refers to.
+func (e *Engine) unreferencedLayers() ([]string, error) {
+	refs, err := e.layerReferences()
+	if err != nil {
+		return nil, err
+	}
+	var layers []string
+	err = forEachEntry(e.layersDir(), func(entry os.DirEntry) {
+		if entry.IsDir() && refs[entry.Name()] == 0 {
+			layers = append(layers, entry.Name())
+		}
+	})
+	return layers, err
+}
+
+// isDanglingImage reports whether an image directory has no tag or no
+// rootfs, as left behind by older versions or an interrupted pull.
+func (e *Engine) isDanglingImage(name string) bool {
+	if !strings.Contains(name, ":") {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(e.imagesDir(), name, "rootfs"))
+	return os.IsNotExist(err)
+}
+
+// PruneOptions selects what system prune removes.
+type PruneOptions struct {
+	Containers bool
+	Images     bool
+	Layers     bool
+}
+
+// PruneReport lists what system prune removed.
+type PruneReport struct {
+	Containers []string
+	Images     []string
+	Layers     []string
+	Reclaimed  int64
+}
+
+// systemPrune removes stopped containers, dangling images that no container
+// uses and unreferenced layers, as selected by opts.
+func (e *Engine) systemPrune(opts PruneOptions) (*PruneReport, error) {
+	report := &PruneReport{}
+	summaries, err := e.listContainerSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	usedImages := make(map[string]bool)
+	for _, summary := range summaries {
+		if opts.Containers && !isContainerActive(summary.Status) {
+			size := diskSize(filepath.Join(e.Root, "containers", summary.ID))
+			if err := e.removeContainer(summary.ID); err != nil {
+				return report, err
+			}
+			report.Containers = append(report.Containers, summary.ID)
+			report.Reclaimed += size
+			continue
+		}
+		if summary.Image != "" {
+			usedImages[filepath.Base(e.imageStorePath(summary.Image))] = true
+		}
+	}
+
+	if opts.Images {
+		var dangling []string
+		if err := forEachEntry(e.imagesDir(), func(entry os.DirEntry) {
+			if entry.IsDir() && !usedImages[entry.Name()] && e.isDanglingImage(entry.Name()) {
+				dangling = append(dangling, entry.Name())
+			}
+		}); err != nil {
+			return report, err
+		}
+		for _, name := range dangling {
+			path := filepath.Join(e.imagesDir(), name)
+			size := diskSize(path)
+			if err := os.RemoveAll(path); err != nil {
+				return report, fmt.Errorf("failed to remove image %s: %v", name, err)
+			}
+			report.Images = append(report.Images, name)
+			report.Reclaimed += size
+		}
+	}
+
+	if opts.Layers {
+		layers, err := e.unreferencedLayers()
+		if err != nil {
+			return report, err
+		}
+		for _, id := range layers {
+			path := filepath.Join(e.layersDir(), id)
+			size := diskSize(path)
+			if err := os.RemoveAll(path); err != nil {
+				return report, fmt.Errorf("failed to remove layer %s: %v", id, err)
+			}
+			report.Layers = append(report.Layers, id)
+			report.Reclaimed += size
+		}
+	}
+	return report, nil
+}
+
+// printPruneReport writes the removed objects and the reclaimed space.
+func printPruneReport(w io.Writer, report *PruneReport) {
+	for _, section := range []struct {
+		title string
+		ids   []string
+	}{
+		{"Deleted Containers:", report.Containers},
+		{"Deleted Images:", report.Images},
+		{"Deleted Layers:", report.Layers},
+	} {
+		if len(section.ids) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, section.title)
+		for _, id := range section.ids {
+			fmt.Fprintln(w, id)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Total reclaimed space: %d bytes\n", report.Reclaimed)
+}
+
+// confirm asks a yes/no question on w and reads the answer from r.
+func confirm(r io.Reader, w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// pruneCommand implements system prune.
+func (e *Engine) pruneCommand(args []string) {
+	flags := flag.NewFlagSet("system prune", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	force := flags.Bool("force", false, "Do not prompt for confirmation")
+	flags.BoolVar(force, "f", false, "Shorthand for --force")
+	var opts PruneOptions
+	flags.BoolVar(&opts.Containers, "containers", false, "Remove stopped containers")
+	flags.BoolVar(&opts.Images, "images", false, "Remove dangling images")
+	flags.BoolVar(&opts.Layers, "layers", false, "Remove unreferenced layers")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker system prune [-f] [--containers] [--images] [--layers]")
+		os.Exit(1)
+	}
+
+	// Without selectors everything is pruned
+	if !opts.Containers && !opts.Images && !opts.Layers {
+		opts = PruneOptions{Containers: true, Images: true, Layers: true}
+	}
+
+	if !*force {
+		fmt.Fprintln(os.Stderr, "WARNING! This will remove:")
+		if opts.Containers {
+			fmt.Fprintln(os.Stderr, "  - all stopped containers")
+		}
+		if opts.Images {
+			fmt.Fprintln(os.Stderr, "  - all dangling images")
+		}
+		if opts.Layers {
+			fmt.Fprintln(os.Stderr, "  - all unreferenced layers")
+		}
+		if !confirm(os.Stdin, os.Stderr, "Are you sure you want to continue?") {
+			return
+		}
+	}
+
+	report, err := e.systemPrune(opts)
+	if report != nil {
+		printPruneReport(os.Stdout, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
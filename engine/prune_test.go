@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSystemPruneLayers verifies that prune removes exactly the layers no
+// metadata refers to
+func TestSystemPruneLayers(t *testing.T) {
+	e := newTestEngine(t)
+	for _, id := range []string{"base-layer-1", "app-layer-1", "orphan-a", "orphan-b"} {
+		writeSizedFile(t, filepath.Join(e.layersDir(), id, "file.txt"), 10)
+	}
+	layer := ImageLayer{
+		ID:            "base-layer-1",
+		BaseLayerPath: filepath.Join(e.layersDir(), "base-layer-1"),
+		AppLayerPath:  filepath.Join(e.layersDir(), "app-layer-1"),
+	}
+	if err := e.AddLayer(layer); err != nil {
+		t.Fatalf("Failed to save layer metadata: %v", err)
+	}
+
+	report, err := e.systemPrune(PruneOptions{Layers: true})
+	if err != nil {
+		t.Fatalf("systemPrune failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Layers, []string{"orphan-a", "orphan-b"}) {
+		t.Errorf("Expected only the orphaned layers to be pruned, got %v", report.Layers)
+	}
+	if report.Reclaimed != 20 {
+		t.Errorf("Expected 20 bytes reclaimed, got %d", report.Reclaimed)
+	}
+	for _, id := range []string{"base-layer-1", "app-layer-1"} {
+		if _, err := os.Stat(filepath.Join(e.layersDir(), id)); err != nil {
+			t.Errorf("Expected referenced layer %s to be kept: %v", id, err)
+		}
+	}
+	for _, id := range []string{"orphan-a", "orphan-b"} {
+		if _, err := os.Stat(filepath.Join(e.layersDir(), id)); !os.IsNotExist(err) {
+			t.Errorf("Expected orphaned layer %s to be removed", id)
+		}
+	}
+}
+
+// TestSystemPruneContainersAndImages removes stopped containers and dangling
+// images, keeping tagged images and the selectors' other categories
+func TestSystemPruneContainersAndImages(t *testing.T) {
+	e := newTestEngine(t)
+	writeSizedFile(t, filepath.Join(e.imagesDir(), "tagged:latest", "rootfs", "bin"), 10)
+	writeSizedFile(t, filepath.Join(e.imagesDir(), "untagged", "rootfs", "bin"), 10)
+	writeSizedFile(t, filepath.Join(e.imagesDir(), "partial:latest", "manifest"), 10)
+	writeSizedFile(t, filepath.Join(e.layersDir(), "orphan", "file.txt"), 10)
+	createTestContainer(t, e, &ContainerConfig{ID: "prune-stopped", Image: "tagged"})
+
+	report, err := e.systemPrune(PruneOptions{Containers: true, Images: true})
+	if err != nil {
+		t.Fatalf("systemPrune failed: %v", err)
+	}
+	if !reflect.DeepEqual(report.Containers, []string{"prune-stopped"}) {
+		t.Errorf("Expected the stopped container to be pruned, got %v", report.Containers)
+	}
+	if !reflect.DeepEqual(report.Images, []string{"partial:latest", "untagged"}) {
+		t.Errorf("Expected the dangling images to be pruned, got %v", report.Images)
+	}
+	if len(report.Layers) != 0 {
+		t.Errorf("Expected layers to be kept without --layers, got %v", report.Layers)
+	}
+	if _, err := os.Stat(filepath.Join(e.imagesDir(), "tagged:latest")); err != nil {
+		t.Errorf("Expected the tagged image to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(e.layersDir(), "orphan")); err != nil {
+		t.Errorf("Expected the orphaned layer to be kept: %v", err)
+	}
+}
+
+// TestConfirm accepts only an explicit yes
+func TestConfirm(t *testing.T) {
+	var prompt strings.Builder
+	if !confirm(strings.NewReader("y\n"), &prompt, "Continue?") {
+		t.Error("Expected y to confirm")
+	}
+	if prompt.String() != "Continue? [y/N] " {
+		t.Errorf("Unexpected prompt %q", prompt.String())
+	}
+	for _, answer := range []string{"", "\n", "no\n", "maybe\n"} {
+		if confirm(strings.NewReader(answer), &prompt, "Continue?") {
+			t.Errorf("Expected %q not to confirm", answer)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// registryMirrorsEnv lists, comma separated, the mirrors Docker Hub images
+// are pulled through. Engine.RegistryMirrors, set by --registry-mirror,
+// takes precedence.
+const registryMirrorsEnv = "BASIC_DOCKER_REGISTRY_MIRRORS"
+
+// registryMirrors returns the configured mirrors in the order they are tried.
+func (e *Engine) registryMirrors() []string {
+	if len(e.RegistryMirrors) > 0 {
+		return e.RegistryMirrors
+	}
+	var mirrors []string
+	for _, mirror := range strings.Split(os.Getenv(registryMirrorsEnv), ",") {
+		if mirror = strings.TrimSpace(mirror); mirror != "" {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+	return mirrors
+}
+
+// mirrorBaseURL returns the /v2/ API URL of a mirror given as
+// "https://host[:port][/v2/]".
+func mirrorBaseURL(mirror string) (string, error) {
+	if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+		return "", fmt.Errorf("invalid registry mirror %q: expected an http, https or file URL", mirror)
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(mirror, "/"), "/v2")
+	return base + "/v2/", nil
+}
+
+// newMirrorRegistry returns the registry of a mirror: a FileRegistry for a
+// file:// URL and otherwise a registry configured by opts that is not
+// retried, so that falling back is quick.
+func newMirrorRegistry(mirror string, opts ...RegistryOption) (Registry, error) {
+	if dir, ok := strings.CutPrefix(mirror, fileRegistryScheme); ok {
+		if dir == "" {
+			return nil, fmt.Errorf("invalid registry mirror %q: the directory must not be empty", mirror)
+		}
+		return NewFileRegistry(dir), nil
+	}
+	url, err := mirrorBaseURL(mirror)
+	if err != nil {
+		return nil, err
+	}
+	registry := NewDockerHubRegistry(url, opts...)
+	registry.MaxAttempts = 1
+	return registry, nil
+}
+
+// mirroredRegistry tries each of its registries in order, so that requests
+// go to the mirrors first and to the canonical registry when they all fail.
+type mirroredRegistry struct {
+	registries []Registry
+	// names are the URLs of the registries, for logging.
+	names  []string
+	logger *slog.Logger
+}
+
+// newMirroredRegistry returns a registry that sends requests to mirrors
+// before canonical. The mirrors share the client and logger of canonical.
+func newMirroredRegistry(mirrors []string, canonical *DockerHubRegistry) (*mirroredRegistry, error) {
+	registry := &mirroredRegistry{logger: canonical.log()}
+	for _, mirror := range mirrors {
+		mirrorRegistry, err := newMirrorRegistry(mirror, WithHTTPClient(canonical.httpClient()), WithLogger(canonical.log()))
+		if err != nil {
+			return nil, err
+		}
+		registry.registries = append(registry.registries, mirrorRegistry)
+		registry.names = append(registry.names, mirror)
+	}
+	registry.registries = append(registry.registries, canonical)
+	registry.names = append(registry.names, canonical.BaseURL)
+	return registry, nil
+}
+
+// FetchManifest fetches the manifest from the first registry that has it.
+func (m *mirroredRegistry) FetchManifest(repo, tag string) (*Manifest, error) {
+	var err error
+	for i, registry := range m.registries {
+		var manifest *Manifest
+		if manifest, err = registry.FetchManifest(repo, tag); err == nil {
+			return manifest, nil
+		}
+		m.logger.Warn("registry request failed", "registry", m.names[i], "repo", repo, "tag", tag, "error", err)
+	}
+	return nil, err
+}
+
+// FetchLayer fetches the layer from the first registry that has it.
+func (m *mirroredRegistry) FetchLayer(repo, digest string) (io.ReadCloser, error) {
+	body, _, err := m.FetchLayerFrom(repo, digest, 0)
+	return body, err
+}
+
+// FetchLayerFrom fetches the layer from offset on from the first registry
+// that has it.
+func (m *mirroredRegistry) FetchLayerFrom(repo, digest string, offset int64) (io.ReadCloser, int64, error) {
+	var err error
+	for i, registry := range m.registries {
+		var body io.ReadCloser
+		var start int64
+		if ranged, ok := registry.(layerRangeFetcher); ok {
+			body, start, err = ranged.FetchLayerFrom(repo, digest, offset)
+		} else {
+			body, err = registry.FetchLayer(repo, digest)
+		}
+		if err == nil {
+			return body, start, nil
+		}
+		m.logger.Warn("registry request failed", "registry", m.names[i], "repo", repo, "digest", digest, "error", err)
+	}
+	return nil, 0, err
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// mirrorServer serves the manifest and layer of repo, or fails
+// every request when broken, and records the paths requested.
+type mirrorServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	paths []string
+}
+
+func newMirrorServer(t *testing.T, repo string, layer []byte, broken bool) *mirrorServer {
+	t.Helper()
+	sum := sha256.Sum256(layer)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest := fmt.Sprintf(`{"layers":[{"digest":%q,"size":%d}]}`, digest, len(layer))
+
+	s := &mirrorServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+		switch {
+		case broken:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case r.URL.Path == "/v2/"+repo+"/manifests/latest":
+			fmt.Fprint(w, manifest)
+		case r.URL.Path == "/v2/"+repo+"/blobs/"+digest:
+			w.Write(layer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *mirrorServer) requestPaths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+// TestPullUsesRegistryMirror pulls a Docker Hub image with a mirror
+// configured and checks that every request went to the mirror.
+func TestPullUsesRegistryMirror(t *testing.T) {
+	e := newTestEngine(t)
+	layer, err := os.ReadFile(writeTestTar(t, map[string]string{"etc/mirrored": "from the mirror"}))
+	if err != nil {
+		t.Fatalf("Failed to read test tar: %v", err)
+	}
+	mirror := newMirrorServer(t, "mirrored", layer, false)
+	t.Setenv(registryMirrorsEnv, mirror.URL)
+
+	image, err := e.pullImage("mirrored", PullOptions{})
+	if err != nil {
+		t.Fatalf("Pull through the mirror failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(image.RootFS, "etc", "mirrored"))
+	if err != nil || string(content) != "from the mirror" {
+		t.Errorf("Expected the layer from the mirror, got %q (%v)", content, err)
+	}
+	paths := mirror.requestPaths()
+	if len(paths) == 0 || paths[0] != "/v2/mirrored/manifests/latest" {
+		t.Errorf("Expected the manifest to be fetched from the mirror first, got %v", paths)
+	}
+}
+
+// TestMirroredRegistryFallsBack checks that mirrors are tried in order and
+// that the canonical registry is used when they fail.
+func TestMirroredRegistryFallsBack(t *testing.T) {
+	layer := []byte("layer data")
+	broken := newMirrorServer(t, "library/mirrored", layer, true)
+	working := newMirrorServer(t, "library/mirrored", layer, false)
+	canonical := newMirrorServer(t, "library/mirrored", layer, false)
+
+	registry, err := newMirroredRegistry([]string{broken.URL, working.URL + "/v2/"}, NewDockerHubRegistry(canonical.URL+"/v2/"))
+	if err != nil {
+		t.Fatalf("newMirroredRegistry failed: %v", err)
+	}
+	if _, err := registry.FetchManifest("library/mirrored", "latest"); err != nil {
+		t.Fatalf("FetchManifest failed: %v", err)
+	}
+	if got := broken.requestPaths(); len(got) != 1 {
+		t.Errorf("Expected one request to the broken mirror without retries, got %v", got)
+	}
+	if got := working.requestPaths(); len(got) != 1 {
+		t.Errorf("Expected the second mirror to serve the manifest, got %v", got)
+	}
+	if got := canonical.requestPaths(); len(got) != 0 {
+		t.Errorf("Expected no requests to the canonical registry, got %v", got)
+	}
+
+	// A repository no mirror has falls back to the canonical registry
+	registry, err = newMirroredRegistry([]string{broken.URL}, NewDockerHubRegistry(canonical.URL+"/v2/"))
+	if err != nil {
+		t.Fatalf("newMirroredRegistry failed: %v", err)
+	}
+	sum := sha256.Sum256(layer)
+	body, err := registry.FetchLayer("library/mirrored", "sha256:"+hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("FetchLayer failed: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != string(layer) {
+		t.Errorf("Expected the layer from the canonical registry, got %q", data)
+	}
+}
+
+// TestRegistryMirrorsConfig checks that --registry-mirror takes precedence
+// over the environment and that mirror URLs are validated.
+func TestRegistryMirrorsConfig(t *testing.T) {
+	e := newTestEngine(t)
+	t.Setenv(registryMirrorsEnv, "https://a.example.com, https://b.example.com/v2/,")
+	if got, want := e.registryMirrors(), []string{"https://a.example.com", "https://b.example.com/v2/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected mirrors %v from the environment, got %v", want, got)
+	}
+
+	args, flags, err := extractGlobalFlags([]string{"basic-docker", "--registry-mirror", "https://c.example.com", "--registry-mirror=http://d.example.com:5000", "pull", "alpine"})
+	if err != nil {
+		t.Fatalf("extractGlobalFlags failed: %v", err)
+	}
+	if want := []string{"basic-docker", "pull", "alpine"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected args %v, got %v", want, args)
+	}
+	e.RegistryMirrors = flags.registryMirrors
+	if got, want := e.registryMirrors(), []string{"https://c.example.com", "http://d.example.com:5000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected mirrors %v from the flags, got %v", want, got)
+	}
+
+	if _, _, err := extractGlobalFlags([]string{"basic-docker", "--registry-mirror", "mirror.example.com", "ps"}); err == nil {
+		t.Error("Expected an error for a mirror without a scheme")
+	}
+	if got, _ := mirrorBaseURL("https://mirror.example.com/v2"); got != "https://mirror.example.com/v2/" {
+		t.Errorf("Expected the /v2/ API URL, got %q", got)
+	}
+}
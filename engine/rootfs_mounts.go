@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// validateTmpfsPaths checks the paths given with run --tmpfs.
+func validateTmpfsPaths(paths []string) error {
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("tmpfs path %q must be absolute", path)
+		}
+		if filepath.Clean(path) == "/" {
+			return fmt.Errorf("tmpfs cannot be mounted over the root filesystem")
+		}
+	}
+	return nil
+}
+
+// setupRootfsMounts applies the filesystem options of a container before its
+// process starts: the rootfs is bind-mounted read-only onto itself for
+// --read-only, a tmpfs is mounted at each --tmpfs path and each -v volume is
+// bind-mounted at its target. The returned function undoes the mounts and
+// must be called once the container exits.
+func (e *Engine) setupRootfsMounts(config *ContainerConfig) (func(), error) {
+	rootfs := e.containerRootfs(config.ID)
+	var mounted []string
+	cleanup := func() {
+		for i := len(mounted) - 1; i >= 0; i-- {
+			if err := syscall.Unmount(mounted[i], syscall.MNT_DETACH); err != nil {
+				e.Logger.Warn("failed to unmount", "container", config.ID, "path", mounted[i], "error", err)
+			}
+		}
+	}
+
+	// Mount points must be created while the rootfs is still writable
+	var targets []string
+	for _, path := range config.Tmpfs {
+		target, err := resolveInRoot(rootfs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve tmpfs path %s: %v", path, err)
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create tmpfs mount point %s: %v", path, err)
+		}
+		targets = append(targets, target)
+	}
+	volumes, err := parseVolumeSpecs(config.Volumes)
+	if err != nil {
+		return nil, err
+	}
+	var volumeTargets []string
+	for _, volume := range volumes {
+		target, err := resolveInRoot(rootfs, volume.Target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve volume path %s: %v", volume.Target, err)
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create volume mount point %s: %v", volume.Target, err)
+		}
+		volumeTargets = append(volumeTargets, target)
+	}
+
+	if config.ReadOnly {
+		if err := syscall.Mount(rootfs, rootfs, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return nil, fmt.Errorf("failed to bind-mount rootfs (--read-only requires mount privileges): %v", err)
+		}
+		mounted = append(mounted, rootfs)
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		if err := syscall.Mount("", rootfs, "", flags, ""); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to make rootfs read-only: %v", err)
+		}
+	}
+
+	for i, target := range targets {
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to mount tmpfs at %s: %v", config.Tmpfs[i], err)
+		}
+		mounted = append(mounted, target)
+	}
+	for i, target := range volumeTargets {
+		if err := bindMount(e.volumeHostPath(volumes[i]), target); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to mount volume %s: %v", volumes[i], err)
+		}
+		mounted = append(mounted, target)
+	}
+	return cleanup, nil
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseRunArgsReadOnly verifies the parsing of --read-only and --tmpfs.
+func TestParseRunArgsReadOnly(t *testing.T) {
+	e := newTestEngine(t)
+	opts, err := e.parseRunArgs([]string{"--read-only", "--tmpfs", "/tmp", "--tmpfs", "/run", "alpine", "sh"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	if !opts.ReadOnly || len(opts.Tmpfs) != 2 || opts.Tmpfs[0] != "/tmp" || opts.Tmpfs[1] != "/run" {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	for _, path := range []string{"tmp", "/"} {
+		if _, err := e.parseRunArgs([]string{"--tmpfs", path, "alpine", "sh"}); err == nil {
+			t.Errorf("Expected tmpfs path %q to be rejected", path)
+		}
+	}
+}
+
+// TestReadOnlyRootfs verifies that writes to a read-only rootfs fail while
+// tmpfs paths stay writable, and that the mounts are undone afterwards.
+func TestReadOnlyRootfs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	e := newTestEngine(t)
+	config := &ContainerConfig{ID: "container-ro", ReadOnly: true, Tmpfs: []string{"/tmp"}}
+	rootfs := e.containerRootfs(config.ID)
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Fatalf("Failed to create rootfs: %v", err)
+	}
+
+	cleanup, err := e.setupRootfsMounts(config)
+	if err != nil {
+		t.Skipf("mounts are not permitted here: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "file"), []byte("x"), 0644); err == nil {
+		t.Error("Expected a write to the read-only rootfs to fail")
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "tmp", "file"), []byte("x"), 0644); err != nil {
+		t.Errorf("Expected a write to the tmpfs to succeed, got %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(filepath.Join(rootfs, "tmp", "file")); !os.IsNotExist(err) {
+		t.Errorf("Expected the tmpfs contents to be gone after cleanup, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "file"), []byte("x"), 0644); err != nil {
+		t.Errorf("Expected the rootfs to be writable after cleanup, got %v", err)
+	}
+}
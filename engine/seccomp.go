@@ -0,0 +1,326 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// defaultSeccompProfile names the built-in profile in --security-opt.
+const defaultSeccompProfile = "default"
+
+// Seccomp return values, from linux/seccomp.h.
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetKillThread  = 0x00000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetLog         = 0x7ffc0000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// SeccompProfile is a seccomp profile in the JSON format used by Docker.
+// Rules with argument filters or include/exclude conditions are not
+// supported and are skipped, leaving their syscalls to the default action.
+type SeccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet *uint32       `json:"defaultErrnoRet,omitempty"`
+	Syscalls        []SeccompRule `json:"syscalls,omitempty"`
+}
+
+// SeccompRule applies an action to a set of syscalls.
+type SeccompRule struct {
+	Names    []string          `json:"names,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Action   string            `json:"action"`
+	ErrnoRet *uint32           `json:"errnoRet,omitempty"`
+	Args     []json.RawMessage `json:"args,omitempty"`
+	Includes json.RawMessage   `json:"includes,omitempty"`
+	Excludes json.RawMessage   `json:"excludes,omitempty"`
+}
+
+// seccompDeniedSyscalls are left out of the built-in profile's allowlist:
+// they administer the host or escape the container's isolation.
+var seccompDeniedSyscalls = []string{
+	"acct", "add_key", "adjtimex", "bpf", "clock_adjtime", "clock_settime",
+	"create_module", "delete_module", "finit_module", "fsconfig", "fsmount",
+	"fsopen", "fspick", "get_kernel_syms", "get_mempolicy", "init_module",
+	"ioperm", "iopl", "kcmp", "kexec_file_load", "kexec_load", "keyctl",
+	"lookup_dcookie", "mbind", "mount", "mount_setattr", "move_mount",
+	"move_pages", "name_to_handle_at", "nfsservctl", "open_by_handle_at",
+	"open_tree", "perf_event_open", "pivot_root", "process_vm_readv",
+	"process_vm_writev", "ptrace", "query_module", "quotactl", "reboot",
+	"request_key", "set_mempolicy", "setns", "settimeofday", "swapoff",
+	"swapon", "syslog", "sysfs", "_sysctl", "umount2", "unshare", "uselib",
+	"userfaultfd", "ustat", "vhangup",
+}
+
+// builtinSeccompProfile returns the default profile: every syscall is denied
+// with EPERM except an allowlist of the syscalls known for this architecture
+// minus seccompDeniedSyscalls. clone3 fails with ENOSYS so that C libraries
+// fall back to clone.
+func builtinSeccompProfile() *SeccompProfile {
+	denied := make(map[string]bool, len(seccompDeniedSyscalls))
+	for _, name := range seccompDeniedSyscalls {
+		denied[name] = true
+	}
+	var allowed []string
+	for name := range syscallNumbers {
+		if !denied[name] && name != "clone3" {
+			allowed = append(allowed, name)
+		}
+	}
+	sort.Strings(allowed)
+	enosys := uint32(syscall.ENOSYS)
+	return &SeccompProfile{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Syscalls: []SeccompRule{
+			{Names: allowed, Action: "SCMP_ACT_ALLOW"},
+			{Names: []string{"clone3"}, Action: "SCMP_ACT_ERRNO", ErrnoRet: &enosys},
+		},
+	}
+}
+
+// loadSeccompProfile returns the built-in profile or reads one from a file.
+func loadSeccompProfile(spec string) (*SeccompProfile, error) {
+	if spec == defaultSeccompProfile {
+		return builtinSeccompProfile(), nil
+	}
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seccomp profile: %v", err)
+	}
+	var profile SeccompProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse seccomp profile %s: %v", spec, err)
+	}
+	return &profile, nil
+}
+
+// seccompAction converts a profile action to a seccomp return value. ERRNO
+// actions fail with errnoRet, or EPERM when it is not set.
+func seccompAction(action string, errnoRet *uint32) (uint32, error) {
+	switch action {
+	case "SCMP_ACT_ALLOW":
+		return seccompRetAllow, nil
+	case "SCMP_ACT_ERRNO":
+		errno := uint32(syscall.EPERM)
+		if errnoRet != nil {
+			errno = *errnoRet
+		}
+		return seccompRetErrno | errno&0xffff, nil
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return seccompRetKillThread, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return seccompRetKillProcess, nil
+	case "SCMP_ACT_TRAP":
+		return seccompRetTrap, nil
+	case "SCMP_ACT_LOG":
+		return seccompRetLog, nil
+	}
+	return 0, fmt.Errorf("unsupported seccomp action %q", action)
+}
+
+// seccompPolicy is a profile resolved to syscall numbers.
+type seccompPolicy struct {
+	defaultAction uint32
+	actions       map[uint32]uint32
+}
+
+// action returns the seccomp return value for a syscall number.
+func (p *seccompPolicy) action(nr uint32) uint32 {
+	if action, ok := p.actions[nr]; ok {
+		return action
+	}
+	return p.defaultAction
+}
+
+// resolve turns the profile into a policy for this architecture. Syscalls
+// unknown here are skipped, since profiles usually cover several
+// architectures. When rules overlap, the last one wins. Skipped rules are
+// logged to logger.
+func (p *SeccompProfile) resolve(logger *slog.Logger) (*seccompPolicy, error) {
+	defaultAction, err := seccompAction(p.DefaultAction, p.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+	policy := &seccompPolicy{defaultAction: defaultAction, actions: make(map[uint32]uint32)}
+	for _, rule := range p.Syscalls {
+		action, err := seccompAction(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+		names := rule.Names
+		if rule.Name != "" {
+			names = append(names, rule.Name)
+		}
+		if len(rule.Args) > 0 || len(rule.Includes) > 0 || len(rule.Excludes) > 0 {
+			logger.Debug("skipping conditional seccomp rule", "syscalls", strings.Join(names, ","))
+			continue
+		}
+		for _, name := range names {
+			nr, ok := syscallNumbers[name]
+			if !ok {
+				logger.Debug("skipping unknown syscall in seccomp profile", "syscall", name)
+				continue
+			}
+			policy.actions[nr] = action
+		}
+	}
+	return policy, nil
+}
+
+// BPF instructions used by seccomp filters, from linux/filter.h.
+const (
+	bpfLoadAbs = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+	bpfJumpEq  = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+	bpfJumpGe  = syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K
+	bpfReturn  = syscall.BPF_RET | syscall.BPF_K
+
+	// Offsets of the fields of struct seccomp_data.
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// compile generates the BPF filter enforcing the policy. The filter kills
+// processes making syscalls of another architecture, then compares the
+// syscall number against each syscall whose action is not the default.
+func (p *seccompPolicy) compile() []syscall.SockFilter {
+	filter := []syscall.SockFilter{
+		{Code: bpfLoadAbs, K: seccompDataArch},
+		{Code: bpfJumpEq, Jt: 1, K: seccompAuditArch},
+		{Code: bpfReturn, K: seccompRetKillProcess},
+		{Code: bpfLoadAbs, K: seccompDataNr},
+	}
+	if seccompX32Bit != 0 {
+		filter = append(filter,
+			syscall.SockFilter{Code: bpfJumpGe, Jf: 1, K: seccompX32Bit},
+			syscall.SockFilter{Code: bpfReturn, K: p.defaultAction})
+	}
+
+	numbers := make([]uint32, 0, len(p.actions))
+	for nr, action := range p.actions {
+		if action != p.defaultAction {
+			numbers = append(numbers, nr)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, nr := range numbers {
+		filter = append(filter,
+			syscall.SockFilter{Code: bpfJumpEq, Jf: 1, K: nr},
+			syscall.SockFilter{Code: bpfReturn, K: p.actions[nr]})
+	}
+	return append(filter, syscall.SockFilter{Code: bpfReturn, K: p.defaultAction})
+}
+
+// seccompFilter loads a profile and compiles it for this architecture.
+func (e *Engine) seccompFilter(spec string) ([]syscall.SockFilter, error) {
+	profile, err := loadSeccompProfile(spec)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := profile.resolve(e.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seccomp profile %s: %v", spec, err)
+	}
+	return policy.compile(), nil
+}
+
+// encodeSeccompFilter serializes a compiled filter for the container-init
+// command line, each instruction in the layout of struct sock_filter.
+func encodeSeccompFilter(filter []syscall.SockFilter) string {
+	data := make([]byte, 0, len(filter)*8)
+	for _, ins := range filter {
+		data = binary.LittleEndian.AppendUint16(data, ins.Code)
+		data = append(data, ins.Jt, ins.Jf)
+		data = binary.LittleEndian.AppendUint32(data, ins.K)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeSeccompFilter parses the output of encodeSeccompFilter.
+func decodeSeccompFilter(encoded string) ([]syscall.SockFilter, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) == 0 || len(data)%8 != 0 {
+		return nil, fmt.Errorf("invalid seccomp filter %q", encoded)
+	}
+	filter := make([]syscall.SockFilter, len(data)/8)
+	for i := range filter {
+		ins := data[i*8:]
+		filter[i] = syscall.SockFilter{
+			Code: binary.LittleEndian.Uint16(ins),
+			Jt:   ins[2],
+			Jf:   ins[3],
+			K:    binary.LittleEndian.Uint32(ins[4:]),
+		}
+	}
+	return filter, nil
+}
+
+const (
+	prSetSeccomp      = 22
+	prSetNoNewPrivs   = 38
+	seccompModeFilter = 2
+)
+
+// installSeccompFilter applies a filter to the calling thread, which must
+// be locked to its goroutine. Without CAP_SYS_ADMIN the kernel only accepts
+// filters from processes that cannot gain privileges, so no_new_privs is set
+// when the first attempt is refused.
+func installSeccompFilter(filter []syscall.SockFilter) error {
+	if syscallNumbers == nil {
+		return fmt.Errorf("seccomp is not supported on %s", runtime.GOARCH)
+	}
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)))
+	if errno == syscall.EACCES {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return fmt.Errorf("failed to set no_new_privs: %v", errno)
+		}
+		_, _, errno = syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)))
+	}
+	runtime.KeepAlive(filter)
+	if errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %v", errno)
+	}
+	return nil
+}
+
+// parseSecurityOpts validates the --security-opt values of run and returns
+// the seccomp profile to apply: "" for none, "default" for the built-in
+// profile or the absolute path of a profile file.
+func (e *Engine) parseSecurityOpts(opts []string) (string, error) {
+	seccomp := ""
+	for _, opt := range opts {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok || key != "seccomp" || value == "" {
+			return "", fmt.Errorf("unsupported security option %q", opt)
+		}
+		switch value {
+		case "unconfined":
+			seccomp = ""
+			continue
+		case defaultSeccompProfile:
+			seccomp = value
+		default:
+			path, err := filepath.Abs(value)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve seccomp profile %s: %v", value, err)
+			}
+			seccomp = path
+		}
+		if _, err := e.seccompFilter(seccomp); err != nil {
+			return "", err
+		}
+	}
+	return seccomp, nil
+}
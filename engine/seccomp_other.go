@@ -0,0 +1,12 @@
+//go:build !amd64
+
+package engine
+
+// Seccomp filters are only generated for x86-64; elsewhere profiles are
+// parsed but not applied.
+const (
+	seccompAuditArch = 0
+	seccompX32Bit    = 0
+)
+
+var syscallNumbers map[string]uint32
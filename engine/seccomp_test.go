@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// runSeccompFilter evaluates a compiled filter for a syscall the way the
+// kernel does.
+func runSeccompFilter(t *testing.T, filter []syscall.SockFilter, arch, nr uint32) uint32 {
+	t.Helper()
+	var acc uint32
+	for pc := 0; pc < len(filter); pc++ {
+		insn := filter[pc]
+		switch insn.Code {
+		case bpfLoadAbs:
+			acc = nr
+			if insn.K == seccompDataArch {
+				acc = arch
+			}
+		case bpfJumpEq, bpfJumpGe:
+			matched := acc == insn.K
+			if insn.Code == bpfJumpGe {
+				matched = acc >= insn.K
+			}
+			if matched {
+				pc += int(insn.Jt)
+			} else {
+				pc += int(insn.Jf)
+			}
+		case bpfReturn:
+			return insn.K
+		default:
+			t.Fatalf("Unexpected instruction %#x at %d", insn.Code, pc)
+		}
+	}
+	t.Fatal("Filter fell through without returning")
+	return 0
+}
+
+// writeSeccompProfile writes a profile to a temporary file.
+func writeSeccompProfile(t *testing.T, profile string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := os.WriteFile(path, []byte(profile), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	return path
+}
+
+// TestSeccompProfileParsing verifies that Docker style profiles load and
+// resolve, and that invalid ones are rejected.
+func TestSeccompProfileParsing(t *testing.T) {
+	e := newTestEngine(t)
+	if syscallNumbers == nil {
+		t.Skip("seccomp is not supported on this architecture")
+	}
+	path := writeSeccompProfile(t, `{
+		"defaultAction": "SCMP_ACT_ALLOW",
+		"syscalls": [
+			{"names": ["mkdir", "mkdirat", "not_a_syscall"], "action": "SCMP_ACT_ERRNO", "errnoRet": 13},
+			{"names": ["reboot"], "action": "SCMP_ACT_KILL_PROCESS"},
+			{"names": ["personality"], "action": "SCMP_ACT_ERRNO", "args": [{"index": 0, "value": 8, "op": "SCMP_CMP_EQ"}]}
+		]
+	}`)
+	profile, err := loadSeccompProfile(path)
+	if err != nil {
+		t.Fatalf("loadSeccompProfile failed: %v", err)
+	}
+	policy, err := profile.resolve(e.Logger)
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	cases := map[string]uint32{
+		"mkdirat":     seccompRetErrno | 13,
+		"reboot":      seccompRetKillProcess,
+		"read":        seccompRetAllow,
+		"personality": seccompRetAllow,
+	}
+	filter := policy.compile()
+	for name, want := range cases {
+		nr := syscallNumbers[name]
+		if got := policy.action(nr); got != want {
+			t.Errorf("action(%s) = %#x, want %#x", name, got, want)
+		}
+		if got := runSeccompFilter(t, filter, seccompAuditArch, nr); got != want {
+			t.Errorf("filter(%s) = %#x, want %#x", name, got, want)
+		}
+	}
+	if got := runSeccompFilter(t, filter, 0x40000003, syscallNumbers["read"]); got != seccompRetKillProcess {
+		t.Errorf("Expected a foreign architecture to be killed, got %#x", got)
+	}
+
+	for _, invalid := range []string{`{"defaultAction": "SCMP_ACT_MAYBE"}`, `{"defaultAction": `} {
+		if _, err := e.parseSecurityOpts([]string{"seccomp=" + writeSeccompProfile(t, invalid)}); err == nil {
+			t.Errorf("Expected profile %s to be rejected", invalid)
+		}
+	}
+	if _, err := e.parseSecurityOpts([]string{"apparmor=unconfined"}); err == nil {
+		t.Error("Expected an unsupported security option to be rejected")
+	}
+}
+
+// TestBuiltinSeccompProfile verifies the decisions of the default profile.
+func TestBuiltinSeccompProfile(t *testing.T) {
+	e := newTestEngine(t)
+	if syscallNumbers == nil {
+		t.Skip("seccomp is not supported on this architecture")
+	}
+	policy, err := builtinSeccompProfile().resolve(e.Logger)
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	filter := policy.compile()
+	cases := map[string]uint32{
+		"read":    seccompRetAllow,
+		"execve":  seccompRetAllow,
+		"mount":   seccompRetErrno | uint32(syscall.EPERM),
+		"ptrace":  seccompRetErrno | uint32(syscall.EPERM),
+		"clone3":  seccompRetErrno | uint32(syscall.ENOSYS),
+		"unshare": seccompRetErrno | uint32(syscall.EPERM),
+	}
+	for name, want := range cases {
+		if got := runSeccompFilter(t, filter, seccompAuditArch, syscallNumbers[name]); got != want {
+			t.Errorf("filter(%s) = %#x, want %#x", name, got, want)
+		}
+	}
+	if got := runSeccompFilter(t, filter, seccompAuditArch, 9999); got != seccompRetErrno|uint32(syscall.EPERM) {
+		t.Errorf("Expected unknown syscalls to be denied, got %#x", got)
+	}
+	if decoded, err := decodeSeccompFilter(encodeSeccompFilter(filter)); err != nil || !reflect.DeepEqual(decoded, filter) {
+		t.Errorf("Expected the filter to survive encoding, got %v", err)
+	}
+	if _, err := decodeSeccompFilter("not a filter"); err == nil {
+		t.Error("Expected an invalid filter to be rejected")
+	}
+
+	opts, err := e.parseRunArgs([]string{"--security-opt", "seccomp=default", "alpine", "sh"})
+	if err != nil || opts.Seccomp != defaultSeccompProfile {
+		t.Errorf("Expected the default profile to be selected, got %+v (err %v)", opts, err)
+	}
+}
+
+// TestSeccompHelperProcess runs container-init with a profile, with the mkdir
+// helper as the container command.
+func TestSeccompHelperProcess(t *testing.T) {
+	e := newTestEngine(t)
+	profile, ok := os.LookupEnv("BASIC_DOCKER_SECCOMP")
+	if !ok {
+		return
+	}
+	filter, err := e.seccompFilter(profile)
+	if err != nil {
+		t.Fatalf("seccompFilter failed: %v", err)
+	}
+	err = containerInit([]string{"--seccomp-filter=" + encodeSeccompFilter(filter), "--", os.Args[0], "-test.run=^TestMkdirHelperProcess$"}, slog.Default())
+	t.Fatalf("containerInit failed: %v", err)
+}
+
+// TestMkdirHelperProcess exits with 0 if it can create a directory.
+func TestMkdirHelperProcess(t *testing.T) {
+	dir := os.Getenv("BASIC_DOCKER_MKDIR")
+	if dir == "" {
+		return
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// TestSeccompDeniesSyscall verifies that a profile installed by
+// container-init makes the denied syscall fail in the command.
+func TestSeccompDeniesSyscall(t *testing.T) {
+	if syscallNumbers == nil {
+		t.Skip("seccomp is not supported on this architecture")
+	}
+	profile := writeSeccompProfile(t, `{
+		"defaultAction": "SCMP_ACT_ALLOW",
+		"syscalls": [{"names": ["mkdir", "mkdirat"], "action": "SCMP_ACT_ERRNO"}]
+	}`)
+	dir := filepath.Join(t.TempDir(), "created")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeccompHelperProcess$")
+	cmd.Env = append(os.Environ(), "BASIC_DOCKER_SECCOMP="+profile, "BASIC_DOCKER_MKDIR="+dir)
+	out, err := cmd.CombinedOutput()
+	if contains(string(out), "running without seccomp") {
+		t.Skipf("seccomp is not available here: %s", out)
+	}
+	if err == nil {
+		t.Error("Expected mkdir to fail under the profile")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created, got %v", dir, err)
+	}
+}
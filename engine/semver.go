@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semVersion is a parsed semantic version. Capsule versions are often written
+// without a patch ("1.1") or minor component, so missing parts default to 0.
+type semVersion struct {
+	Major, Minor, Patch int
+	Prerelease          string
+}
+
+// parseSemver parses versions of the form [v]major[.minor[.patch]][-pre][+build].
+func parseSemver(value string) (semVersion, error) {
+	var v semVersion
+	s := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+		if v.Prerelease == "" {
+			return v, fmt.Errorf("invalid version %q: empty prerelease", value)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q: too many components", value)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", value)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// compareSemver returns -1, 0 or 1 as a is lower than, equal to or higher
+// than b. A prerelease sorts before the release it precedes.
+func compareSemver(a, b semVersion) int {
+	for _, d := range []int{a.Major - b.Major, a.Minor - b.Minor, a.Patch - b.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case a.Prerelease == b.Prerelease:
+		return 0
+	case a.Prerelease == "":
+		return 1
+	case b.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(a.Prerelease, b.Prerelease)
+}
+
+// comparePrerelease compares dot-separated prerelease identifiers, numeric
+// identifiers numerically and lower than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// semverBound is a single comparison such as ">=1.2.0".
+type semverBound struct {
+	op      string
+	version semVersion
+}
+
+func (b semverBound) matches(v semVersion) bool {
+	c := compareSemver(v, b.version)
+	switch b.op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+// semverConstraint is a conjunction of bounds. An empty constraint matches
+// every release.
+type semverConstraint struct {
+	bounds []semverBound
+	// allowPrerelease is set when the constraint names a prerelease itself.
+	allowPrerelease bool
+}
+
+// parseSemverConstraint parses constraints such as "latest", "*", "1.1",
+// "^1.1", "~1.1.2" and space-separated comparisons like ">=1.0 <2.0".
+func parseSemverConstraint(value string) (semverConstraint, error) {
+	var c semverConstraint
+	value = strings.TrimSpace(value)
+	if value == "" || value == "latest" || value == "*" {
+		return c, nil
+	}
+
+	for _, term := range strings.Fields(value) {
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(term, candidate) {
+				op = candidate
+				break
+			}
+		}
+		v, err := parseSemver(strings.TrimPrefix(term, op))
+		if err != nil {
+			return c, fmt.Errorf("invalid constraint %q: %v", value, err)
+		}
+		if v.Prerelease != "" {
+			c.allowPrerelease = true
+		}
+
+		switch op {
+		case "^":
+			// Changes that do not modify the left-most non-zero component
+			upper := semVersion{Major: v.Major + 1}
+			if v.Major == 0 {
+				upper = semVersion{Minor: v.Minor + 1}
+			}
+			c.bounds = append(c.bounds, semverBound{">=", v}, semverBound{"<", upper})
+		case "~":
+			c.bounds = append(c.bounds, semverBound{">=", v}, semverBound{"<", semVersion{Major: v.Major, Minor: v.Minor + 1}})
+		case "":
+			c.bounds = append(c.bounds, semverBound{"=", v})
+		default:
+			c.bounds = append(c.bounds, semverBound{op, v})
+		}
+	}
+	return c, nil
+}
+
+// matches reports whether v satisfies every bound of the constraint.
+// Prereleases only match constraints that mention a prerelease.
+func (c semverConstraint) matches(v semVersion) bool {
+	if v.Prerelease != "" && !c.allowPrerelease {
+		return false
+	}
+	for _, bound := range c.bounds {
+		if !bound.matches(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package engine
+
+import "testing"
+
+// TestGetCapsuleMatching resolves several constraints against 1.0, 1.1 and 2.0
+func TestGetCapsuleMatching(t *testing.T) {
+	e := newTestEngine(t)
+	cm := e.NewCapsuleManager()
+	for _, version := range []string{"1.0", "1.1", "2.0", "2.1.0-rc.1", "not-a-version"} {
+		cm.AddCapsule("libssl", version, "/capsules/libssl-"+version)
+	}
+	cm.AddCapsule("other", "9.0", "/capsules/other-9.0")
+
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"latest", "2.0"},
+		{"", "2.0"},
+		{"^1.1", "1.1"},
+		{"^1.0", "1.1"},
+		{"~1.0", "1.0"},
+		{"1.0", "1.0"},
+		{"=1.1.0", "1.1"},
+		{">=1.0 <2.0", "1.1"},
+		{">1.1", "2.0"},
+		{"^2.1.0-rc.0", "2.1.0-rc.1"},
+	}
+	for _, tt := range tests {
+		capsule, err := cm.GetCapsuleMatching("libssl", tt.constraint)
+		if err != nil {
+			t.Errorf("GetCapsuleMatching(%q) failed: %v", tt.constraint, err)
+			continue
+		}
+		if capsule.Version != tt.want {
+			t.Errorf("GetCapsuleMatching(%q) = %s, want %s", tt.constraint, capsule.Version, tt.want)
+		}
+	}
+
+	for _, constraint := range []string{"^3.0", "<1.0", "~1.2"} {
+		if capsule, err := cm.GetCapsuleMatching("libssl", constraint); err == nil {
+			t.Errorf("Expected no match for %q, got %s", constraint, capsule.Version)
+		}
+	}
+	if _, err := cm.GetCapsuleMatching("libssl", "^one"); err == nil {
+		t.Error("Expected an error for an invalid constraint")
+	}
+	if _, err := cm.GetCapsuleMatching("missing", "latest"); err == nil {
+		t.Error("Expected an error for an unknown capsule")
+	}
+}
+
+// TestCompareSemver verifies version ordering including prereleases
+func TestCompareSemver(t *testing.T) {
+	ordered := []string{"0.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0", "1.0.1", "1.1", "2.0"}
+	for i := 0; i < len(ordered)-1; i++ {
+		a, _ := parseSemver(ordered[i])
+		b, _ := parseSemver(ordered[i+1])
+		if compareSemver(a, b) >= 0 || compareSemver(b, a) <= 0 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+	a, _ := parseSemver("1.0")
+	b, _ := parseSemver("v1.0.0+build.5")
+	if compareSemver(a, b) != 0 {
+		t.Error("Expected 1.0 and v1.0.0+build.5 to be equal")
+	}
+}
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// statsSampleInterval is the time between the two samples a CPU percentage
+// is computed from. Tests shorten it.
+var statsSampleInterval = time.Second
+
+// unlimitedMemory is the smallest cgroup v1 limit treated as no limit; the
+// kernel reports an unset limit as a page-aligned value near MaxInt64.
+const unlimitedMemory = 1 << 62
+
+// cgroupStats is the resource usage read from a container's cgroup.
+type cgroupStats struct {
+	CPUUsage    time.Duration
+	MemoryUsage int64
+	// MemoryLimit is zero when the cgroup has no memory limit.
+	MemoryLimit int64
+	// OOMEvents counts the times the memory limit was hit and OOMKills the
+	// processes the OOM killer killed.
+	OOMEvents int64
+	OOMKills  int64
+}
+
+// readCgroupInt reads a file holding a single integer. "max" reads as zero.
+func readCgroupInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// readCgroupKeyedInts reads a file of "key value" lines, such as
+// memory.events, skipping lines whose value is not an integer.
+func readCgroupKeyedInts(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]int64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, nil
+}
+
+// readCPUStatUsage returns usage_usec from a cgroup v2 cpu.stat file.
+func readCPUStatUsage(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("usage_usec not found in %s", path)
+}
+
+// containerNetworkIO returns the bytes received and sent by the network
+// namespace of pid. Containers sharing the host network namespace have no
+// traffic of their own, so ok is false for them.
+func containerNetworkIO(pid int) (rx, tx int64, ok bool) {
+	netns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return 0, 0, false
+	}
+	if hostns, err := os.Readlink("/proc/self/ns/net"); err != nil || hostns == netns {
+		return 0, 0, false
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return 0, 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, counters, found := strings.Cut(line, ":")
+		fields := strings.Fields(counters)
+		if !found || strings.TrimSpace(name) == "lo" || len(fields) < 9 {
+			continue
+		}
+		received, _ := strconv.ParseInt(fields[0], 10, 64)
+		sent, _ := strconv.ParseInt(fields[8], 10, 64)
+		rx += received
+		tx += sent
+	}
+	return rx, tx, true
+}
+
+// hostMemoryTotal returns the physical memory of the host, which bounds a
+// container without a memory limit.
+func hostMemoryTotal() int64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return int64(info.Totalram) * int64(info.Unit)
+}
+
+// ContainerStats is one row of the stats table.
+type ContainerStats struct {
+	ID          string
+	CPUPercent  float64
+	MemoryUsage int64
+	MemoryLimit int64
+	NetworkRx   int64
+	NetworkTx   int64
+	// OOMKills counts the container processes killed for running out of
+	// memory.
+	OOMKills int64
+	// NetworkIsolated is false when the container shares the host network,
+	// in which case the network counters are not meaningful.
+	NetworkIsolated bool
+}
+
+// MemoryPercent returns the memory usage as a share of the limit.
+func (s ContainerStats) MemoryPercent() float64 {
+	if s.MemoryLimit <= 0 {
+		return 0
+	}
+	return float64(s.MemoryUsage) * 100 / float64(s.MemoryLimit)
+}
+
+// cpuSample is a reading of a container's cumulative CPU time.
+type cpuSample struct {
+	usage time.Duration
+	at    time.Time
+}
+
+// cpuPercent returns the CPU used between two samples as a percentage of one
+// CPU, so a container saturating two CPUs reports 200%.
+func cpuPercent(prev, cur cpuSample) float64 {
+	elapsed := cur.at.Sub(prev.at)
+	if elapsed <= 0 || cur.usage < prev.usage {
+		return 0
+	}
+	return float64(cur.usage-prev.usage) * 100 / float64(elapsed)
+}
+
+// statsCollector computes container stats, diffing each container's CPU time
+// against the previous sample taken for it.
+type statsCollector struct {
+	engine   *Engine
+	previous map[string]cpuSample
+}
+
+func (e *Engine) newStatsCollector() *statsCollector {
+	return &statsCollector{engine: e, previous: make(map[string]cpuSample)}
+}
+
+// collect samples a container and returns its stats. The CPU percentage is
+// zero on the first sample of a container.
+func (c *statsCollector) collect(containerID string) (ContainerStats, error) {
+	stats := ContainerStats{ID: containerID}
+	usage, err := c.engine.newCgroupManager().Usage(containerID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read stats of container %s: %v", containerID, err)
+	}
+
+	sample := cpuSample{usage: usage.CPUUsage, at: time.Now()}
+	if prev, ok := c.previous[containerID]; ok {
+		stats.CPUPercent = cpuPercent(prev, sample)
+	}
+	c.previous[containerID] = sample
+
+	stats.MemoryUsage = usage.MemoryUsage
+	stats.MemoryLimit = usage.MemoryLimit
+	stats.OOMKills = usage.OOMKills
+	if stats.MemoryLimit == 0 {
+		stats.MemoryLimit = hostMemoryTotal()
+	}
+	if pidData, err := os.ReadFile(filepath.Join(c.engine.Root, "containers", containerID, "pid")); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(pidData))); err == nil {
+			stats.NetworkRx, stats.NetworkTx, stats.NetworkIsolated = containerNetworkIO(pid)
+		}
+	}
+	return stats, nil
+}
+
+// formatBytes renders a byte count with binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printStats writes the stats table.
+func printStats(w io.Writer, stats []ContainerStats) {
+	fmt.Fprintln(w, "CONTAINER ID\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tOOM KILLS")
+	for _, s := range stats {
+		netIO := "--"
+		if s.NetworkIsolated {
+			netIO = formatBytes(s.NetworkRx) + " / " + formatBytes(s.NetworkTx)
+		}
+		fmt.Fprintf(w, "%s\t%.2f%%\t%s / %s\t%.2f%%\t%s\t%d\n", s.ID, s.CPUPercent,
+			formatBytes(s.MemoryUsage), formatBytes(s.MemoryLimit), s.MemoryPercent(), netIO, s.OOMKills)
+	}
+}
+
+// statsTargets returns the given containers, or all running ones when none
+// are given.
+func (e *Engine) statsTargets(ids []string) ([]string, error) {
+	if len(ids) > 0 {
+		resolved := make([]string, len(ids))
+		for i, id := range ids {
+			resolved[i] = e.resolveContainerID(id)
+		}
+		return resolved, nil
+	}
+	summaries, err := e.listContainerSummaries()
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		if isContainerActive(summary.Status) {
+			ids = append(ids, summary.ID)
+		}
+	}
+	return ids, nil
+}
+
+// collectAll samples every container, logging the ones that cannot be read.
+func (c *statsCollector) collectAll(ids []string) []ContainerStats {
+	var all []ContainerStats
+	for _, id := range ids {
+		stats, err := c.collect(id)
+		if err != nil {
+			c.engine.Logger.Warn("skipping container", "container", id, "error", err)
+			continue
+		}
+		all = append(all, stats)
+	}
+	return all
+}
+
+// statsSnapshot samples the containers twice, statsSampleInterval apart, so
+// that the CPU percentages reflect current usage.
+func (e *Engine) statsSnapshot(ids []string) []ContainerStats {
+	collector := e.newStatsCollector()
+	collector.collectAll(ids)
+	time.Sleep(statsSampleInterval)
+	return collector.collectAll(ids)
+}
+
+// statsCommand implements stats.
+func (e *Engine) statsCommand(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	noStream := flags.Bool("no-stream", false, "Print a single snapshot instead of refreshing")
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker stats [--no-stream] [container-id...]")
+		os.Exit(1)
+	}
+
+	ids, err := e.statsTargets(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *noStream {
+		printStats(os.Stdout, e.statsSnapshot(ids))
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	collector := e.newStatsCollector()
+	collector.collectAll(ids)
+	ticker := time.NewTicker(statsSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigCh:
+			return
+		case <-ticker.C:
+		}
+		// Clear the screen and redraw the table in place
+		fmt.Print("\033[2J\033[H")
+		printStats(os.Stdout, collector.collectAll(ids))
+	}
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFakeCgroupFile writes a controller file of a container's fake cgroup.
+func writeFakeCgroupFile(t *testing.T, e *Engine, controller, containerID, name, value string) {
+	t.Helper()
+	dir := e.containerCgroupPath(controller, containerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create cgroup directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+// TestStatsSnapshot prints a single snapshot from fake v1 cgroup data
+func TestStatsSnapshot(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, false)
+	oldInterval := statsSampleInterval
+	statsSampleInterval = time.Millisecond
+	t.Cleanup(func() { statsSampleInterval = oldInterval })
+
+	createTestContainer(t, e, &ContainerConfig{ID: "stats-running", Command: "sleep"})
+	createTestContainer(t, e, &ContainerConfig{ID: "stats-stopped", Command: "sleep"})
+	pid := fmt.Sprintf("%d", os.Getpid())
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", "stats-running", "pid"), []byte(pid), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	writeFakeCgroupFile(t, e, "memory", "stats-running", "memory.usage_in_bytes", "52428800\n")
+	writeFakeCgroupFile(t, e, "memory", "stats-running", "memory.limit_in_bytes", "104857600\n")
+	writeFakeCgroupFile(t, e, "cpuacct", "stats-running", "cpuacct.usage", "1000000\n")
+	writeFakeCgroupFile(t, e, "memory", "stats-running", "memory.oom_control", "oom_kill_disable 0\nunder_oom 0\noom_kill 1\n")
+
+	ids, err := e.statsTargets(nil)
+	if err != nil {
+		t.Fatalf("statsTargets failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "stats-running" {
+		t.Fatalf("Expected only the running container, got %v", ids)
+	}
+
+	stats := e.statsSnapshot(ids)
+	if len(stats) != 1 {
+		t.Fatalf("Expected one row, got %+v", stats)
+	}
+	if stats[0].MemoryUsage != 50<<20 || stats[0].MemoryLimit != 100<<20 || stats[0].MemoryPercent() != 50 {
+		t.Errorf("Unexpected memory stats: %+v", stats[0])
+	}
+
+	var buf bytes.Buffer
+	printStats(&buf, stats)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != "CONTAINER ID\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tOOM KILLS" {
+		t.Fatalf("Unexpected stats table:\n%s", buf.String())
+	}
+	if lines[1] != "stats-running\t0.00%\t50.00MiB / 100.00MiB\t50.00%\t--\t1" {
+		t.Errorf("Unexpected stats row: %q", lines[1])
+	}
+}
+
+// TestStatsCollectorCPUPercent diffs CPU time between samples on cgroup v2
+func TestStatsCollectorCPUPercent(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, true)
+	writeFakeCgroupFile(t, e, "", "stats-v2", "memory.current", "1024\n")
+	writeFakeCgroupFile(t, e, "", "stats-v2", "memory.max", "max\n")
+	writeFakeCgroupFile(t, e, "", "stats-v2", "cpu.stat", "usage_usec 1000000\nuser_usec 800000\n")
+
+	collector := e.newStatsCollector()
+	first, err := collector.collect("stats-v2")
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	if first.CPUPercent != 0 || first.MemoryUsage != 1024 {
+		t.Errorf("Unexpected first sample: %+v", first)
+	}
+	if first.MemoryLimit != hostMemoryTotal() {
+		t.Errorf("Expected an unlimited container to be bounded by host memory, got %d", first.MemoryLimit)
+	}
+
+	// Pretend half a CPU was used over the last second
+	collector.previous["stats-v2"] = cpuSample{usage: 500 * time.Millisecond, at: time.Now().Add(-time.Second)}
+	second, err := collector.collect("stats-v2")
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	if second.CPUPercent < 45 || second.CPUPercent > 50 {
+		t.Errorf("Expected about 50%% CPU, got %.2f", second.CPUPercent)
+	}
+
+	if _, err := collector.collect("no-cgroup"); err == nil {
+		t.Error("Expected an error for a container without a cgroup")
+	}
+}
+
+// TestOOMEventsReported reads the OOM counters of a v2 memory.events file
+// and checks they reach stats and inspect, and are recorded when the
+// container exits
+func TestOOMEventsReported(t *testing.T) {
+	e := newTestEngine(t)
+	useFakeCgroupRoot(t, e, true)
+	createTestContainer(t, e, &ContainerConfig{ID: "oom-v2", Command: "sleep", StartedAt: time.Now()})
+	pid := fmt.Sprintf("%d", os.Getpid())
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", "oom-v2", "pid"), []byte(pid), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	writeFakeCgroupFile(t, e, "", "oom-v2", "memory.current", "1024\n")
+	writeFakeCgroupFile(t, e, "", "oom-v2", "cpu.stat", "usage_usec 1000\n")
+	writeFakeCgroupFile(t, e, "", "oom-v2", "memory.events", "low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\noom_group_kill 0\n")
+
+	oom, kills, err := e.newCgroupManager().OOMEvents("oom-v2")
+	if err != nil || oom != 3 || kills != 2 {
+		t.Errorf("OOMEvents = %d, %d, %v, want 3, 2", oom, kills, err)
+	}
+	stats, err := e.newStatsCollector().collect("oom-v2")
+	if err != nil || stats.OOMKills != 2 {
+		t.Errorf("Expected stats to report 2 OOM kills, got %+v, %v", stats, err)
+	}
+	info, err := e.inspectContainer("oom-v2")
+	if err != nil || info.OOMKills != 2 {
+		t.Errorf("Expected inspect to report 2 OOM kills, got %+v, %v", info, err)
+	}
+
+	e.cleanupContainerRuntime("oom-v2")
+	config, err := e.loadContainerConfig("oom-v2")
+	if err != nil || !config.OOMKilled {
+		t.Errorf("Expected the exited container to be marked OOM killed, got %+v, %v", config, err)
+	}
+}
+
+// TestFormatBytes uses binary units
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512B", 1536: "1.50KiB", 50 << 20: "50.00MiB", 3 << 30: "3.00GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
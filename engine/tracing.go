@@ -0,0 +1,58 @@
+package engine
+
+import "context"
+
+// attribute is a key and value recorded on a span.
+type attribute struct {
+	Key   string
+	Value any
+}
+
+// Span is a traced operation. It has the shape of the OpenTelemetry span
+// methods pulls use, so that an OpenTelemetry tracer plugs in through a thin
+// adapter.
+type Span interface {
+	SetAttributes(attrs ...attribute)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans as children of the span in ctx.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Names of the spans of a pull.
+const (
+	spanPull          = "pull"
+	spanManifestFetch = "manifest fetch"
+	spanLayerDownload = "layer download"
+	spanLayerExtract  = "layer extract"
+)
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
+
+// startSpan starts a span with attributes under the span in ctx.
+func (e *Engine) startSpan(ctx context.Context, name string, attrs ...attribute) (context.Context, Span) {
+	ctx, span := e.Tracer.Start(ctx, name)
+	span.SetAttributes(attrs...)
+	return ctx, span
+}
+
+// endSpan records err, when set, on span and ends it.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
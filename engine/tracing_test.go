@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// recordedSpan is a span kept by spanRecorder.
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]any
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.attrs["error"] = err }
+
+func (s *recordedSpan) End() { s.ended = true }
+
+type recordedSpanKey struct{}
+
+// spanRecorder is an in-memory tracer recording every span with its parent.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(recordedSpanKey{}).(*recordedSpan)
+	span := &recordedSpan{name: name, parent: parent, attrs: map[string]any{}}
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// useSpanRecorder traces with a spanRecorder for the rest of the test.
+func useSpanRecorder(t *testing.T, e *Engine) *spanRecorder {
+	t.Helper()
+	recorder := &spanRecorder{}
+	e.Tracer = recorder
+	return recorder
+}
+
+// TestPullSpans pulls a two-layer image and checks that the manifest fetch
+// and the download and extract of each layer are spans of the pull.
+func TestPullSpans(t *testing.T) {
+	e := newTestEngine(t)
+	recorder := useSpanRecorder(t, e)
+
+	handler := http.NewServeMux()
+	var digests []string
+	for i := 0; i < 2; i++ {
+		data, err := os.ReadFile(writeTestTar(t, map[string]string{fmt.Sprintf("file%d", i): "content"}))
+		if err != nil {
+			t.Fatalf("Failed to read layer: %v", err)
+		}
+		sum := sha256.Sum256(data)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		digests = append(digests, digest)
+		handler.HandleFunc("/v2/library/traced/blobs/"+digest, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		})
+	}
+	manifest := fmt.Sprintf(`{"layers": [{"digest": %q, "size": 10}, {"digest": %q, "size": 20}]}`, digests[0], digests[1])
+	handler.HandleFunc("/v2/library/traced/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(manifest))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	if _, err := e.PullWithOptions(&DockerHubRegistry{BaseURL: server.URL + "/v2/"}, "library/traced", PullOptions{}); err != nil {
+		t.Fatalf("PullWithOptions failed: %v", err)
+	}
+
+	var root *recordedSpan
+	var children []string
+	for _, span := range recorder.spans {
+		if !span.ended {
+			t.Errorf("Span %s was not ended", span.name)
+		}
+		if span.parent == nil {
+			if root != nil {
+				t.Errorf("Expected a single root span, got %s and %s", root.name, span.name)
+			}
+			root = span
+			continue
+		}
+		if span.parent.name != spanPull {
+			t.Errorf("Expected span %s to be a child of %s, got %s", span.name, spanPull, span.parent.name)
+		}
+		label := span.name
+		if digest, ok := span.attrs["digest"].(string); ok && span.name != spanManifestFetch {
+			label += fmt.Sprintf(" %s %v", digest, span.attrs["size"])
+		}
+		children = append(children, label)
+	}
+	if root == nil || root.name != spanPull || root.attrs["image"] != "library/traced" {
+		t.Fatalf("Expected a %s root span for the image, got %+v", spanPull, root)
+	}
+
+	sort.Strings(children)
+	want := []string{
+		fmt.Sprintf("%s %s 10", spanLayerDownload, digests[0]),
+		fmt.Sprintf("%s %s 20", spanLayerDownload, digests[1]),
+		fmt.Sprintf("%s %s 10", spanLayerExtract, digests[0]),
+		fmt.Sprintf("%s %s 20", spanLayerExtract, digests[1]),
+		spanManifestFetch,
+	}
+	sort.Strings(want)
+	if fmt.Sprint(children) != fmt.Sprint(want) {
+		t.Errorf("Expected child spans %q, got %q", want, children)
+	}
+}
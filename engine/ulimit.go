@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ulimitResources maps the names accepted by --ulimit to rlimit resources.
+// The syscall package lacks RLIMIT_NPROC, which is 6 on Linux.
+var ulimitResources = map[string]int{
+	"nofile": syscall.RLIMIT_NOFILE,
+	"nproc":  6,
+	"core":   syscall.RLIMIT_CORE,
+}
+
+// Ulimit is a resource limit of a container process.
+type Ulimit struct {
+	Name string
+	Soft uint64
+	Hard uint64
+}
+
+// String formats the limit as parseUlimit accepts it.
+func (u Ulimit) String() string {
+	return fmt.Sprintf("%s=%d:%d", u.Name, u.Soft, u.Hard)
+}
+
+// parseUlimit parses "name=soft[:hard]". Without a hard limit it equals the
+// soft limit.
+func parseUlimit(value string) (Ulimit, error) {
+	name, limits, ok := strings.Cut(value, "=")
+	if !ok {
+		return Ulimit{}, fmt.Errorf("invalid ulimit %q: expected name=soft[:hard]", value)
+	}
+	if _, known := ulimitResources[name]; !known {
+		return Ulimit{}, fmt.Errorf("invalid ulimit %q: unsupported limit %s (supported: core, nofile, nproc)", value, name)
+	}
+	softValue, hardValue, hasHard := strings.Cut(limits, ":")
+	soft, err := strconv.ParseUint(softValue, 10, 64)
+	if err != nil {
+		return Ulimit{}, fmt.Errorf("invalid ulimit %q: soft limit must be a non-negative integer", value)
+	}
+	hard := soft
+	if hasHard {
+		if hard, err = strconv.ParseUint(hardValue, 10, 64); err != nil {
+			return Ulimit{}, fmt.Errorf("invalid ulimit %q: hard limit must be a non-negative integer", value)
+		}
+	}
+	if soft > hard {
+		return Ulimit{}, fmt.Errorf("invalid ulimit %q: soft limit exceeds the hard limit", value)
+	}
+	return Ulimit{Name: name, Soft: soft, Hard: hard}, nil
+}
+
+// parseUlimits parses the values of repeated --ulimit flags.
+func parseUlimits(values []string) ([]Ulimit, error) {
+	var ulimits []Ulimit
+	for _, value := range values {
+		ulimit, err := parseUlimit(value)
+		if err != nil {
+			return nil, err
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	return ulimits, nil
+}
+
+// setUlimits applies resource limits to the calling process, from which the
+// container command inherits them.
+func setUlimits(ulimits []Ulimit) error {
+	for _, ulimit := range ulimits {
+		limit := syscall.Rlimit{Cur: ulimit.Soft, Max: ulimit.Hard}
+		if err := syscall.Setrlimit(ulimitResources[ulimit.Name], &limit); err != nil {
+			return fmt.Errorf("failed to set ulimit %s: %v", ulimit, err)
+		}
+	}
+	return nil
+}
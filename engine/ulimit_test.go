@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestParseUlimit covers the accepted --ulimit forms and the rejected ones.
+func TestParseUlimit(t *testing.T) {
+	e := newTestEngine(t)
+	tests := []struct {
+		value string
+		want  Ulimit
+	}{
+		{"nofile=1024:2048", Ulimit{Name: "nofile", Soft: 1024, Hard: 2048}},
+		{"nproc=512", Ulimit{Name: "nproc", Soft: 512, Hard: 512}},
+		{"core=0:0", Ulimit{Name: "core", Soft: 0, Hard: 0}},
+	}
+	for _, tt := range tests {
+		got, err := parseUlimit(tt.value)
+		if err != nil {
+			t.Errorf("parseUlimit(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseUlimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+		if reparsed, err := parseUlimit(got.String()); err != nil || reparsed != got {
+			t.Errorf("Expected %q to round trip, got %+v, %v", got.String(), reparsed, err)
+		}
+	}
+
+	for _, value := range []string{"nofile", "stack=1024", "nofile=-1", "nofile=abc", "nofile=1024:x", "nofile=2048:1024", "=1"} {
+		if _, err := parseUlimit(value); err == nil {
+			t.Errorf("Expected parseUlimit(%q) to fail", value)
+		}
+	}
+	if _, err := e.parseRunArgs([]string{"--ulimit", "nofile=10:5", "busybox", "sh"}); err == nil {
+		t.Error("Expected parseRunArgs to reject an invalid ulimit")
+	}
+	opts, err := e.parseRunArgs([]string{"--ulimit", "nofile=1024:2048", "--ulimit", "core=0", "busybox", "sh"})
+	if err != nil || len(opts.Ulimits) != 2 {
+		t.Errorf("Expected two ulimits, got %+v, %v", opts, err)
+	}
+}
+
+// TestUlimitHelperProcess runs container-init the way the engine does, with
+// a shell printing its open files limit as the container command.
+func TestUlimitHelperProcess(t *testing.T) {
+	ulimit, ok := os.LookupEnv("BASIC_DOCKER_ULIMIT")
+	if !ok {
+		return
+	}
+	err := containerInit([]string{"--ulimit=" + ulimit, "--", "sh", "-c", "ulimit -n"}, slog.Default())
+	t.Fatalf("containerInit failed: %v", err)
+}
+
+// TestUlimitNofile verifies that the container command sees the nofile
+// limit set with --ulimit.
+func TestUlimitNofile(t *testing.T) {
+	e := newTestEngine(t)
+	if os.Geteuid() != 0 {
+		t.Skip("setting resource limits requires root")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	config := &ContainerConfig{Command: "sh", Args: []string{"-c", "ulimit -n"}, Ulimits: []string{"nofile=1024:2048"}}
+	_, args, _, err := e.containerCommandLine(config, "")
+	if err != nil {
+		t.Fatalf("containerCommandLine failed: %v", err)
+	}
+	if args[0] != containerInitCommand || args[1] != "--ulimit=nofile=1024:2048" {
+		t.Errorf("Expected the ulimit to be passed to %s, got %v", containerInitCommand, args)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestUlimitHelperProcess$")
+	cmd.Env = append(os.Environ(), "BASIC_DOCKER_ULIMIT=nofile=1024:2048")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Container command failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "1024" {
+		t.Errorf("Expected ulimit -n to report 1024, got %q", got)
+	}
+}
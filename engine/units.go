@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the size suffixes ParseBytes accepts. All are binary, as for
+// docker's memory flags.
+var byteUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "ki": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mi": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gi": 1 << 30, "gib": 1 << 30,
+}
+
+// ParseBytes parses a positive size such as "512", "64m", "1.5g" or "2Gi"
+// into bytes. Suffixes are case insensitive.
+func ParseBytes(value string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end == -1 {
+		end = len(s)
+	}
+	number, suffix := s[:end], s[end:]
+	multiplier, ok := byteUnits[suffix]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q, expected b, k, m or g", value, suffix)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: expected a number with an optional unit", value)
+	}
+	bytes := n * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", value)
+	}
+	if int64(bytes) <= 0 {
+		return 0, fmt.Errorf("invalid size %q: must be positive", value)
+	}
+	return int64(bytes), nil
+}
+
+// minCPUQuota is the smallest CFS quota the kernel accepts, in microseconds.
+const minCPUQuota = 1000
+
+// ParseCPUs parses a number of CPUs such as "2" or "0.5" into a CFS quota
+// and period in microseconds.
+func ParseCPUs(value string) (quota, period int64, err error) {
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(cpus) || math.IsInf(cpus, 0) {
+		return 0, 0, fmt.Errorf("invalid CPUs %q: expected a number such as 0.5 or 2", value)
+	}
+	if cpus <= 0 {
+		return 0, 0, fmt.Errorf("invalid CPUs %q: must be positive", value)
+	}
+	quota = int64(math.Round(cpus * cpuPeriod))
+	if quota < minCPUQuota {
+		return 0, 0, fmt.Errorf("invalid CPUs %q: the minimum is %g", value, float64(minCPUQuota)/cpuPeriod)
+	}
+	return quota, cpuPeriod, nil
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseBytes covers the unit suffixes in their spellings and cases.
+func TestParseBytes(t *testing.T) {
+	for value, want := range map[string]int64{
+		"1":          1,
+		"1024":       1024,
+		"512b":       512,
+		"512B":       512,
+		"4k":         4 << 10,
+		"4kb":        4 << 10,
+		"4Ki":        4 << 10,
+		"4KiB":       4 << 10,
+		"64m":        64 << 20,
+		"64M":        64 << 20,
+		"64mi":       64 << 20,
+		"2g":         2 << 30,
+		"2Gi":        2 << 30,
+		"1.5g":       3 << 29,
+		"0.5k":       512,
+		" 16m ":      16 << 20,
+		"8589934592": 8 << 30,
+	} {
+		got, err := ParseBytes(value)
+		if err != nil || got != want {
+			t.Errorf("ParseBytes(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+}
+
+// TestParseBytesErrors covers rejected sizes and their messages.
+func TestParseBytesErrors(t *testing.T) {
+	for value, message := range map[string]string{
+		"":             "expected a number",
+		"m":            "expected a number",
+		"abc":          "unknown unit",
+		"12x":          "unknown unit",
+		"1t":           "unknown unit",
+		"1.2.3m":       "expected a number",
+		"-1m":          "unknown unit",
+		"0":            "must be positive",
+		"0g":           "must be positive",
+		"0.0001":       "must be positive",
+		"99999999999g": "too large",
+	} {
+		_, err := ParseBytes(value)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseBytes(%q) error = %v, want one mentioning %q", value, err, message)
+		}
+	}
+}
+
+// TestParseCPUs covers whole and fractional CPUs.
+func TestParseCPUs(t *testing.T) {
+	for value, want := range map[string]int64{"1": 100000, "2": 200000, "0.5": 50000, "1.25": 125000, "0.01": 1000} {
+		quota, period, err := ParseCPUs(value)
+		if err != nil || quota != want || period != cpuPeriod {
+			t.Errorf("ParseCPUs(%q) = %d, %d, %v, want %d, %d", value, quota, period, err, want, cpuPeriod)
+		}
+	}
+}
+
+// TestParseCPUsErrors covers rejected CPU counts and their messages.
+func TestParseCPUsErrors(t *testing.T) {
+	for value, message := range map[string]string{
+		"":      "expected a number",
+		"two":   "expected a number",
+		"1c":    "expected a number",
+		"NaN":   "expected a number",
+		"Inf":   "expected a number",
+		"0":     "must be positive",
+		"-1":    "must be positive",
+		"0.001": "the minimum is 0.01",
+	} {
+		_, _, err := ParseCPUs(value)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseCPUs(%q) error = %v, want one mentioning %q", value, err, message)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// minMemoryLimit is the smallest memory limit update accepts.
+const minMemoryLimit = 6 * 1024 * 1024
+
+// UpdateOptions are the limits to change on a running container. Zero leaves
+// a limit unchanged.
+type UpdateOptions struct {
+	Memory int64   `json:"memory,omitempty"`
+	CPUs   float64 `json:"cpus,omitempty"`
+}
+
+// validateUpdateOptions checks the new limits of a container.
+func validateUpdateOptions(opts UpdateOptions) error {
+	if opts == (UpdateOptions{}) {
+		return fmt.Errorf("nothing to update, set --memory or --cpus")
+	}
+	if opts.Memory != 0 && opts.Memory < minMemoryLimit {
+		return fmt.Errorf("memory limit %d is below the minimum of %d bytes", opts.Memory, minMemoryLimit)
+	}
+	if opts.CPUs < 0 || opts.CPUs > float64(runtime.NumCPU()) {
+		return fmt.Errorf("invalid CPUs %g, expected a value between 0 and %d", opts.CPUs, runtime.NumCPU())
+	}
+	return nil
+}
+
+// updateContainer changes the cgroup limits of a running container and
+// records them in its config.
+func (e *Engine) updateContainer(containerID string, opts UpdateOptions) error {
+	if err := validateUpdateOptions(opts); err != nil {
+		return err
+	}
+	unlock, err := e.lockContainer(containerID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if status := e.getContainerStatus(containerID); status != "Running" && status != "Paused" {
+		return fmt.Errorf("container %s is not running (status: %s)", containerID, status)
+	}
+	m := e.newCgroupManager()
+	if opts.Memory != 0 {
+		if err := m.SetMemory(containerID, opts.Memory); err != nil {
+			return err
+		}
+	}
+	if opts.CPUs != 0 {
+		if err := m.SetCPU(containerID, opts.CPUs); err != nil {
+			return err
+		}
+	}
+	return e.updateContainerConfig(containerID, func(c *ContainerConfig) {
+		if opts.Memory != 0 {
+			c.Memory = opts.Memory
+		}
+		if opts.CPUs != 0 {
+			c.CPUs = opts.CPUs
+		}
+	})
+}
+
+// updateCommand implements "update [--memory size] [--cpus n] <container-id>...".
+func (e *Engine) updateCommand(args []string) {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var opts UpdateOptions
+	fs.Func("memory", "memory limit, with an optional b, k, m or g unit", func(value string) error {
+		var err error
+		opts.Memory, err = ParseBytes(value)
+		return err
+	})
+	fs.Func("cpus", "number of CPUs, such as 0.5", func(value string) error {
+		quota, period, err := ParseCPUs(value)
+		if err != nil {
+			return err
+		}
+		opts.CPUs = float64(quota) / float64(period)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker update [--memory size] [--cpus n] <container-id>...")
+		os.Exit(1)
+	}
+
+	for _, ref := range fs.Args() {
+		if err := e.Update(e.resolveContainerID(ref), opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(ref)
+	}
+}
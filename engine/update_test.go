@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestUpdateContainerLimits writes new limits to the fake cgroup of a running
+// container and records them in its config.
+func TestUpdateContainerLimits(t *testing.T) {
+	e := newTestEngine(t)
+	root := useFakeCgroupRoot(t, e, true)
+	e.Cgroups = CgroupCapabilities{Memory: true, CPU: true}
+
+	containerID := "update-limits"
+	createTestContainer(t, e, &ContainerConfig{ID: containerID, Command: "sleep"})
+	if err := os.WriteFile(filepath.Join(e.Root, "containers", containerID, "pid"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	cgroupDir := filepath.Join(root, "basic-docker", containerID)
+	if err := os.MkdirAll(cgroupDir, 0755); err != nil {
+		t.Fatalf("Failed to create cgroup: %v", err)
+	}
+
+	if err := e.updateContainer(containerID, UpdateOptions{Memory: 64 << 20, CPUs: 0.5}); err != nil {
+		t.Fatalf("updateContainer failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroupDir, "memory.max")); string(data) != "67108864" {
+		t.Errorf("memory.max = %q, want 67108864", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroupDir, "cpu.max")); string(data) != "50000 100000" {
+		t.Errorf("cpu.max = %q, want \"50000 100000\"", data)
+	}
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Memory != 64<<20 || config.CPUs != 0.5 {
+		t.Errorf("Stored limits = %d bytes, %g CPUs, want 67108864 bytes, 0.5 CPUs", config.Memory, config.CPUs)
+	}
+
+	// Only the given limit changes
+	if err := e.updateContainer(containerID, UpdateOptions{Memory: 32 << 20}); err != nil {
+		t.Fatalf("updateContainer failed: %v", err)
+	}
+	if config, _ := e.loadContainerConfig(containerID); config.Memory != 32<<20 || config.CPUs != 0.5 {
+		t.Errorf("Stored limits = %d bytes, %g CPUs, want 33554432 bytes, 0.5 CPUs", config.Memory, config.CPUs)
+	}
+}
+
+// TestUpdateContainerRejects covers invalid limits and stopped containers.
+func TestUpdateContainerRejects(t *testing.T) {
+	e := newTestEngine(t)
+	createTestContainer(t, e, &ContainerConfig{ID: "update-stopped", Command: "sleep"})
+
+	for name, opts := range map[string]UpdateOptions{
+		"empty":        {},
+		"small memory": {Memory: 1024},
+		"negative cpu": {CPUs: -1},
+		"too many cpu": {CPUs: 1 << 20},
+	} {
+		if err := e.updateContainer("update-stopped", opts); err == nil {
+			t.Errorf("%s: updateContainer succeeded, want an error", name)
+		}
+	}
+	if err := e.updateContainer("update-stopped", UpdateOptions{Memory: 64 << 20}); err == nil {
+		t.Error("updateContainer on a stopped container succeeded, want an error")
+	}
+}
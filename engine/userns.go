@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// Host IDs that container root is remapped to when the engine runs as root,
+// as with Docker's default userns-remap range.
+const (
+	remappedRootID   = 100000
+	remappedIDsCount = 65536
+)
+
+// UserNamespaceMapping maps the container's uids and gids 0 to Size-1 to the
+// host IDs starting at HostUID and HostGID.
+type UserNamespaceMapping struct {
+	HostUID int `json:"hostUid"`
+	HostGID int `json:"hostGid"`
+	Size    int `json:"size"`
+}
+
+// defaultUserNamespaceMapping returns the mapping used by --userns. A root
+// engine maps container root to an unprivileged range; an unprivileged
+// engine can only map container root to its own uid and gid.
+func defaultUserNamespaceMapping() *UserNamespaceMapping {
+	if os.Geteuid() == 0 {
+		return &UserNamespaceMapping{HostUID: remappedRootID, HostGID: remappedRootID, Size: remappedIDsCount}
+	}
+	return &UserNamespaceMapping{HostUID: os.Geteuid(), HostGID: os.Getegid(), Size: 1}
+}
+
+// apply makes cmd start in a new user namespace with the mapping, running
+// as root inside it. setgroups is denied in the namespace, which the kernel
+// requires before an unprivileged process may write its gid_map.
+func (m *UserNamespaceMapping) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: m.HostUID, Size: m.Size}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: m.HostGID, Size: m.Size}}
+	attr.GidMappingsEnableSetgroups = false
+	attr.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true}
+}
+
+// shiftOwnership chowns a container's rootfs into the mapped range, so that
+// files owned by root in the image are owned by container root. Only a root
+// engine can do this; an unprivileged engine already owns the files.
+func (m *UserNamespaceMapping) shiftOwnership(rootfs string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return filepath.Walk(rootfs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		uid, gid := int(stat.Uid), int(stat.Gid)
+		if uid >= m.Size || gid >= m.Size {
+			return fmt.Errorf("%s is owned by %d:%d, outside of the user namespace mapping", path, uid, gid)
+		}
+		// Chown clears setuid and setgid bits, which are restored afterwards
+		if err := os.Lchown(path, m.HostUID+uid, m.HostGID+gid); err != nil {
+			return err
+		}
+		if info.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 && info.Mode()&os.ModeSymlink == 0 {
+			return os.Chmod(path, info.Mode())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestUserNamespaceRoot verifies that a process started with --userns is root
+// inside its namespace while running as the mapped unprivileged uid on the
+// host.
+func TestUserNamespaceRoot(t *testing.T) {
+	mapping := defaultUserNamespaceMapping()
+	if mapping.HostUID == 0 {
+		t.Fatalf("Expected container root to map to an unprivileged uid, got %+v", mapping)
+	}
+
+	cmd := exec.Command("id", "-u")
+	mapping.apply(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Skipf("user namespaces are not permitted here: %v", err)
+	}
+	if uid := strings.TrimSpace(string(out)); uid != "0" {
+		t.Errorf("Expected uid 0 inside the namespace, got %s", uid)
+	}
+
+	cmd = exec.Command("sleep", "10")
+	mapping.apply(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	status, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/status")
+	if err != nil {
+		t.Fatalf("Failed to read process status: %v", err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "Uid:" {
+			if fields[1] != strconv.Itoa(mapping.HostUID) {
+				t.Errorf("Expected host uid %d, got %s", mapping.HostUID, fields[1])
+			}
+			return
+		}
+	}
+	t.Error("No Uid line in process status")
+}
+
+// TestUserNamespaceContainerRootfs verifies that a container with --userns
+// runs chrooted into its rootfs rather than on the host filesystem.
+func TestUserNamespaceContainerRootfs(t *testing.T) {
+	e := newTestEngine(t)
+	report := runNamespacedTestContainer(t, e, &ContainerConfig{ID: "test-userns-rootfs", UserNS: defaultUserNamespaceMapping()})
+	if report["chrooted"] != "true" {
+		t.Errorf("Expected the command to run inside the rootfs, got %v", report)
+	}
+	if report["mkdir"] != "true" {
+		t.Errorf("Expected remapped root to own the rootfs, got %v", report)
+	}
+}
+
+// TestShiftOwnership verifies that a remapped rootfs is owned by the mapped
+// range and keeps its setuid bits.
+func TestShiftOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	rootfs := t.TempDir()
+	binary := filepath.Join(rootfs, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(binary, []byte("x"), 0755); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Lchown(binary, 0, 5); err != nil {
+		t.Fatalf("Failed to chown file: %v", err)
+	}
+	if err := os.Chmod(binary, 0755|os.ModeSetuid); err != nil {
+		t.Fatalf("Failed to chmod file: %v", err)
+	}
+
+	mapping := &UserNamespaceMapping{HostUID: remappedRootID, HostGID: remappedRootID, Size: remappedIDsCount}
+	if err := mapping.shiftOwnership(rootfs); err != nil {
+		t.Fatalf("shiftOwnership failed: %v", err)
+	}
+	info, err := os.Stat(binary)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != remappedRootID || stat.Gid != remappedRootID+5 {
+		t.Errorf("Expected owner %d:%d, got %d:%d", remappedRootID, remappedRootID+5, stat.Uid, stat.Gid)
+	}
+	if info.Mode()&os.ModeSetuid == 0 {
+		t.Errorf("Expected the setuid bit to be kept, got %v", info.Mode())
+	}
+}
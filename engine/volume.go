@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// volumesDir holds a directory per named volume. Volumes outlive the
+// containers that mount them.
+func (e *Engine) volumesDir() string {
+	return filepath.Join(e.Root, "volumes")
+}
+
+// VolumeMount is a directory mounted into a container with run -v
+// source:target. Source is the name of a volume or an absolute host path.
+type VolumeMount struct {
+	Source string
+	Target string
+}
+
+// parseVolumeSpec parses a run -v value, "name:/path" or "/host/path:/path".
+func parseVolumeSpec(spec string) (VolumeMount, error) {
+	source, target, ok := strings.Cut(spec, ":")
+	if !ok || source == "" || target == "" {
+		return VolumeMount{}, fmt.Errorf("invalid volume %q: expected source:target", spec)
+	}
+	if !filepath.IsAbs(target) {
+		return VolumeMount{}, fmt.Errorf("invalid volume %q: the target must be absolute", spec)
+	}
+	if filepath.Clean(target) == "/" {
+		return VolumeMount{}, fmt.Errorf("invalid volume %q: cannot mount over the root filesystem", spec)
+	}
+	if !filepath.IsAbs(source) && !validContainerName.MatchString(source) {
+		return VolumeMount{}, fmt.Errorf("invalid volume name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", source)
+	}
+	return VolumeMount{Source: source, Target: filepath.Clean(target)}, nil
+}
+
+// parseVolumeSpecs parses every run -v value.
+func parseVolumeSpecs(specs []string) ([]VolumeMount, error) {
+	var mounts []VolumeMount
+	for _, spec := range specs {
+		mount, err := parseVolumeSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, nil
+}
+
+// String formats the mount as given to run -v.
+func (m VolumeMount) String() string {
+	return m.Source + ":" + m.Target
+}
+
+// Named reports whether the mount is of a named volume.
+func (m VolumeMount) Named() bool {
+	return !filepath.IsAbs(m.Source)
+}
+
+// volumeHostPath is the host directory of m mounted into the container.
+func (e *Engine) volumeHostPath(m VolumeMount) string {
+	if m.Named() {
+		return filepath.Join(e.volumesDir(), m.Source)
+	}
+	return m.Source
+}
+
+// CreateVolume creates a named volume. Creating an existing volume is not an
+// error, so run can create the volumes it mounts.
+func (e *Engine) CreateVolume(name string) error {
+	if !validContainerName.MatchString(name) {
+		return fmt.Errorf("invalid volume name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	if err := os.MkdirAll(filepath.Join(e.volumesDir(), name), 0755); err != nil {
+		return fmt.Errorf("failed to create volume %s: %v", name, err)
+	}
+	return nil
+}
+
+// ListVolumes returns the names of the volumes, sorted.
+func (e *Engine) ListVolumes() ([]string, error) {
+	var names []string
+	err := forEachEntry(e.volumesDir(), func(entry os.DirEntry) {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// volumeUsers returns the containers, running or not, that mount a volume.
+func (e *Engine) volumeUsers(name string) ([]string, error) {
+	var users []string
+	err := forEachEntry(filepath.Join(e.Root, "containers"), func(entry os.DirEntry) {
+		config, err := e.loadContainerConfig(entry.Name())
+		if err != nil {
+			return
+		}
+		mounts, err := parseVolumeSpecs(config.Volumes)
+		if err != nil {
+			e.Logger.Warn("failed to parse container volumes", "container", config.ID, "error", err)
+			return
+		}
+		for _, mount := range mounts {
+			if mount.Named() && mount.Source == name {
+				users = append(users, config.ID)
+				return
+			}
+		}
+	})
+	sort.Strings(users)
+	return users, err
+}
+
+// RemoveVolume deletes a named volume and its contents. Volumes mounted by a
+// container are kept until the container is removed.
+func (e *Engine) RemoveVolume(name string) error {
+	if !validContainerName.MatchString(name) {
+		return fmt.Errorf("invalid volume name %q", name)
+	}
+	path := filepath.Join(e.volumesDir(), name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("volume %s not found", name)
+	}
+	users, err := e.volumeUsers(name)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("volume %s is in use by container %s", name, strings.Join(users, ", "))
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove volume %s: %v", name, err)
+	}
+	return nil
+}
+
+// unmountVolumes unmounts the volumes of a container left mounted when its
+// run was interrupted. It must succeed before the container directory is
+// removed, otherwise the removal would descend into the volumes.
+func (e *Engine) unmountVolumes(config *ContainerConfig) error {
+	mounts, err := parseVolumeSpecs(config.Volumes)
+	if err != nil {
+		return err
+	}
+	rootfs := e.containerRootfs(config.ID)
+	for _, mount := range mounts {
+		target, err := resolveInRoot(rootfs, mount.Target)
+		if err != nil {
+			return err
+		}
+		// EINVAL means the target is not a mount point
+		if err := syscall.Unmount(target, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to unmount volume %s: %v", mount, err)
+		}
+	}
+	return nil
+}
+
+// volumeCommand implements "volume create|ls|rm".
+func (e *Engine) volumeCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker volume <create|ls|rm> [name...]")
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker volume create <name>")
+			os.Exit(1)
+		}
+		if err := e.CreateVolume(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(args[1])
+	case "ls":
+		names, err := e.ListVolumes()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("VOLUME NAME")
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	case "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: basic-docker volume rm <name>...")
+			os.Exit(1)
+		}
+		failed := false
+		for _, name := range args[1:] {
+			if err := e.RemoveVolume(name); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			fmt.Println(name)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown subcommand for volume: %s\n", args[0])
+		os.Exit(1)
+	}
+}
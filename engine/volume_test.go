@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseVolumeSpec verifies the parsing of run -v values.
+func TestParseVolumeSpec(t *testing.T) {
+	e := newTestEngine(t)
+	opts, err := e.parseRunArgs([]string{"-v", "data:/var/lib/data", "--volume", "/srv:/srv/", "alpine", "sh"})
+	if err != nil {
+		t.Fatalf("parseRunArgs failed: %v", err)
+	}
+	mounts, err := parseVolumeSpecs(opts.Volumes)
+	if err != nil {
+		t.Fatalf("parseVolumeSpecs failed: %v", err)
+	}
+	want := []VolumeMount{{Source: "data", Target: "/var/lib/data"}, {Source: "/srv", Target: "/srv"}}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("Expected %v, got %v", want, mounts)
+	}
+	if !mounts[0].Named() || e.volumeHostPath(mounts[0]) != filepath.Join(e.volumesDir(), "data") || e.volumeHostPath(mounts[1]) != "/srv" {
+		t.Errorf("Unexpected host paths %s and %s", e.volumeHostPath(mounts[0]), e.volumeHostPath(mounts[1]))
+	}
+
+	for _, spec := range []string{"data", "data:relative", ":/data", "data:/", "../up:/data"} {
+		if _, err := e.parseRunArgs([]string{"-v", spec, "alpine", "sh"}); err == nil {
+			t.Errorf("Expected volume %q to be rejected", spec)
+		}
+	}
+}
+
+// TestVolumeCreateListRemove covers the lifecycle of named volumes.
+func TestVolumeCreateListRemove(t *testing.T) {
+	e := newTestEngine(t)
+	for _, name := range []string{"web", "db", "web"} {
+		if err := e.CreateVolume(name); err != nil {
+			t.Fatalf("CreateVolume(%q) failed: %v", name, err)
+		}
+	}
+	if err := e.CreateVolume("bad/name"); err == nil {
+		t.Error("Expected an error for an invalid volume name")
+	}
+	if names, err := e.ListVolumes(); err != nil || !reflect.DeepEqual(names, []string{"db", "web"}) {
+		t.Errorf("Expected volumes [db web], got %v (%v)", names, err)
+	}
+
+	if err := e.RemoveVolume("db"); err != nil {
+		t.Fatalf("RemoveVolume failed: %v", err)
+	}
+	if names, err := e.ListVolumes(); err != nil || !reflect.DeepEqual(names, []string{"web"}) {
+		t.Errorf("Expected volumes [web], got %v (%v)", names, err)
+	}
+	if err := e.RemoveVolume("db"); err == nil {
+		t.Error("Expected an error removing a missing volume")
+	}
+}
+
+// TestRemoveVolumeInUse refuses to remove a volume while a container mounts
+// it and allows it once the container is removed.
+func TestRemoveVolumeInUse(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.CreateVolume("data"); err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+	createTestContainer(t, e, &ContainerConfig{ID: "container-volume", Volumes: []string{"data:/data"}})
+
+	err := e.RemoveVolume("data")
+	if err == nil || !strings.Contains(err.Error(), "in use by container container-volume") {
+		t.Fatalf("Expected an in-use error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(e.volumesDir(), "data")); err != nil {
+		t.Errorf("Expected the volume to be kept: %v", err)
+	}
+
+	if err := e.removeContainer("container-volume"); err != nil {
+		t.Fatalf("removeContainer failed: %v", err)
+	}
+	if err := e.RemoveVolume("data"); err != nil {
+		t.Errorf("Expected the volume to be removable, got %v", err)
+	}
+}
+
+// TestVolumeMountPersists writes through a volume mount of one container and
+// reads the file through the mount of the next.
+func TestVolumeMountPersists(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	e := newTestEngine(t)
+	if err := e.CreateVolume("data"); err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+
+	for i, id := range []string{"container-first", "container-second"} {
+		config := &ContainerConfig{ID: id, Volumes: []string{"data:/var/data"}}
+		rootfs := e.containerRootfs(id)
+		if err := os.MkdirAll(rootfs, 0755); err != nil {
+			t.Fatalf("Failed to create rootfs: %v", err)
+		}
+		cleanup, err := e.setupRootfsMounts(config)
+		if err != nil {
+			t.Skipf("mounts are not permitted here: %v", err)
+		}
+		file := filepath.Join(rootfs, "var", "data", "file")
+		if i == 0 {
+			err = os.WriteFile(file, []byte("kept"), 0644)
+		} else if data, readErr := os.ReadFile(file); readErr != nil || string(data) != "kept" {
+			t.Errorf("Expected the file written by the first container, got %q (%v)", data, readErr)
+		}
+		cleanup()
+		if err != nil {
+			t.Fatalf("Failed to write to the volume: %v", err)
+		}
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("Expected the volume to be unmounted after cleanup, got %v", err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(e.volumesDir(), "data", "file")); err != nil || string(data) != "kept" {
+		t.Errorf("Expected the file in the volume directory, got %q (%v)", data, err)
+	}
+}
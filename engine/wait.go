@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// waitPollInterval is how often wait checks for a container's die event.
+// Tests shorten it.
+var waitPollInterval = 100 * time.Millisecond
+
+// waitGracePeriod bounds how long a started container may appear stopped
+// without a die event before wait gives up on it, as happens when the engine
+// running it was killed.
+var waitGracePeriod = 5 * time.Second
+
+// lastExitCode returns the exit code recorded by the last die event of a
+// container at or after since.
+func (e *Engine) lastExitCode(containerID string, since time.Time) (int, bool, error) {
+	file, err := os.Open(e.eventsPath())
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to open events log: %v", err)
+	}
+	defer file.Close()
+
+	code, found := 0, false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Action != eventDie || event.ID != containerID || event.Time.Before(since) {
+			continue
+		}
+		if value, err := strconv.Atoi(event.Attributes["exitCode"]); err == nil {
+			code, found = value, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, false, fmt.Errorf("failed to read events log: %v", err)
+	}
+	return code, found, nil
+}
+
+// WaitContainer blocks until the process of a container exits and returns
+// its exit code. A container that already exited returns at once.
+func (e *Engine) WaitContainer(containerID string) (int, error) {
+	config, err := e.loadContainerConfig(containerID)
+	if err != nil {
+		return 0, fmt.Errorf("container %s not found", containerID)
+	}
+	for {
+		// The config is reloaded since the container may start meanwhile
+		if current, err := e.loadContainerConfig(containerID); err == nil {
+			config = current
+		}
+		code, exited, err := e.lastExitCode(containerID, config.StartedAt)
+		if err != nil {
+			return 0, err
+		}
+		if exited {
+			return code, nil
+		}
+		if !config.StartedAt.IsZero() && e.getContainerStatus(containerID) == "Stopped" && time.Since(config.StartedAt) > waitGracePeriod {
+			return 0, fmt.Errorf("container %s stopped without recording an exit code", containerID)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// waitContainers waits for each container in turn and writes its exit code.
+func (e *Engine) waitContainers(w io.Writer, refs []string) error {
+	for _, ref := range refs {
+		code, err := e.WaitContainer(e.resolveContainerID(ref))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, code)
+	}
+	return nil
+}
+
+// waitCommand implements "wait <container-id>...".
+func (e *Engine) waitCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: basic-docker wait <container-id>...")
+		os.Exit(1)
+	}
+	if err := e.waitContainers(os.Stdout, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
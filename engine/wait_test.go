@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitContainers starts a short container and waits for it alongside one
+// that already exited, expecting their codes in order
+func TestWaitContainers(t *testing.T) {
+	e := newTestEngine(t)
+	oldInterval := waitPollInterval
+	waitPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { waitPollInterval = oldInterval })
+
+	createTestContainer(t, e, &ContainerConfig{ID: "wait-done", Command: "true", StartedAt: time.Now()})
+	e.emitEvent(eventDie, "wait-done", map[string]string{"exitCode": "0"})
+
+	createTestContainer(t, e, &ContainerConfig{ID: "wait-running", Name: "short", Command: "sh", StartedAt: time.Now()})
+	if err := e.reserveContainerName("short", "wait-running"); err != nil {
+		t.Fatalf("reserveContainerName failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- e.runContainerProcess("wait-running", exec.Command("sh", "-c", "sleep 0.2; exit 7"), nil)
+	}()
+
+	var buf bytes.Buffer
+	if err := e.waitContainers(&buf, []string{"short", "wait-done"}); err != nil {
+		t.Fatalf("waitContainers failed: %v", err)
+	}
+	if got := buf.String(); got != "7\n0\n" {
+		t.Errorf("Expected exit codes 7 and 0, got %q", got)
+	}
+	if err := <-done; err == nil {
+		t.Error("Expected the container process to exit with an error")
+	}
+
+	if err := e.waitContainers(&buf, []string{"missing"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing container error, got %v", err)
+	}
+}
+
+// TestWaitContainerLostExit verifies wait gives up on a started container
+// that stopped without a die event
+func TestWaitContainerLostExit(t *testing.T) {
+	e := newTestEngine(t)
+	oldInterval, oldGrace := waitPollInterval, waitGracePeriod
+	waitPollInterval, waitGracePeriod = 10*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { waitPollInterval, waitGracePeriod = oldInterval, oldGrace })
+
+	createTestContainer(t, e, &ContainerConfig{ID: "wait-lost", Command: "sh", StartedAt: time.Now()})
+	if _, err := e.WaitContainer("wait-lost"); err == nil || !strings.Contains(err.Error(), "without recording an exit code") {
+		t.Errorf("Expected a lost exit code error, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/j143/basic-docker-engine
 go 1.24.1
 
 require (
+	github.com/klauspost/compress v1.18.0
 	k8s.io/api v0.33.3
 	k8s.io/apimachinery v0.33.3
 	k8s.io/client-go v0.33.3